@@ -0,0 +1,427 @@
+/*
+Copyright 2019 IBM Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog"
+)
+
+/*
+ Deployments owned by a resource of a registered kind, e.g. an
+ OpenLibertyApplication, get an action configmap named
+ kappnav.actions.deployment-<subkind>.<deployment name> in the namespace of
+ the Deployment, for the runtime specific actions of the Deployment, e.g.
+ kappnav.actions.deployment-liberty.<deployment name>. The configmap is
+ deleted when the Deployment is no longer owned by a resource of that kind.
+
+ When only the kappnav namespace is writable, the configmaps are instead
+ placed in the kappnav namespace, named
+ kappnav.actions.deployment-<subkind>.<deployment namespace>.<deployment name>
+ As namespaces can not contain '.', names from different namespaces do not
+ collide. Owner references can not cross namespaces, so these configmaps are
+ deleted by the controller when the Deployment is deleted.
+
+ Names longer than the limit for configmap names are truncated, and end with
+ a short hash of the full name to keep them unique.
+
+ In opt-in mode, configmaps are only created for Deployments annotated with
+ kappnav.io/enable-actions=true.
+*/
+
+const (
+	// OpenLibertyApplication - kind of the owner of Liberty Deployments
+	OpenLibertyApplication = "OpenLibertyApplication"
+
+	// prefix of the name of action configmaps for Deployments, followed by the subkind
+	actionConfigMapKindPrefix = "kappnav.actions.deployment-"
+
+	// prefix of the name of action configmaps for Liberty Deployments
+	actionConfigMapPrefix = actionConfigMapKindPrefix + "liberty."
+
+	// label identifying configmaps managed by the controller
+	labelManagedBy    = "app.kubernetes.io/managed-by"
+	managedByKAppNav  = "kappnav-controller"
+	urlActionsKey     = "url-actions"
+	cmdActionsKey     = "cmd-actions"
+	inputsKey         = "inputs"
+	emptyActionsValue = "[]"
+
+	// uid of the Deployment of an action configmap
+	actionConfigMapOwnerUID = "kappnav.actions.owner.uid"
+
+	// type of the condition recording whether action configmaps can be created
+	// in the namespace of the action configmaps of an application
+	actionConfigMapCreationCondition = "ActionConfigMapCreationAllowed"
+	creationAllowedReason            = "CreationAllowed"
+	creationSuppressedReason         = "CreationSuppressed"
+
+	// annotation of Deployments opting in to action configmaps
+	kappnavEnableActions = "kappnav.io/enable-actions"
+
+	// maximum length of the name of a configmap
+	maxConfigMapNameLength = 253
+	// number of hex digits of the hash ending truncated names
+	nameHashLength = 10
+)
+
+// Action configmaps of the Deployments owned by resources of a kind
+type actionConfigMapKind struct {
+	subkind string // in the name of the configmaps, e.g. liberty
+	// Return the cmd-actions and inputs of the configmap of a Deployment.
+	// Empty inputs for none
+	actions func(resInfo *resourceInfo) (cmdActions string, inputs string)
+}
+
+// Action configmap kinds, by kind of the owner of the Deployments.
+// Only changed by registerActionConfigMapKind before the controller starts
+var actionConfigMapKinds = map[string]*actionConfigMapKind{
+	OpenLibertyApplication: {subkind: "liberty", actions: libertyActions},
+}
+
+// Command actions generated for each Liberty Deployment, in addition to the
+// kappnav actions of the Liberty kind
+var libertyCmdActions = []cmdAction{
+	{
+		Name:        "server-dump",
+		Text:        "Server Dump",
+		Description: "Dump the state of the Liberty server of the Deployment",
+		CmdPattern:  "kubectl exec -n {{.Namespace}} deployment/{{.Name}} -c {{index .Containers 0}} -- server dump",
+	},
+}
+
+// Actions of Liberty Deployments
+func libertyActions(resInfo *resourceInfo) (string, string) {
+	return cmdActionsJSON(libertyCmdActions, resInfo), ""
+}
+
+// Register the action configmaps of Deployments owned by resources of a kind.
+// Must be called before the controller starts
+func registerActionConfigMapKind(ownerKind string, kind *actionConfigMapKind) {
+	actionConfigMapKinds[ownerKind] = kind
+}
+
+// Return the action configmap kind of the first owner of the resource of a registered kind,
+// or nil if none
+func actionConfigMapKindOf(unstructuredObj *unstructured.Unstructured) *actionConfigMapKind {
+	for _, owner := range unstructuredObj.GetOwnerReferences() {
+		if kind, ok := actionConfigMapKinds[owner.Kind]; ok {
+			return kind
+		}
+	}
+	return nil
+}
+
+// Return true if action configmaps are enabled for the Deployment: always,
+// unless in opt-in mode where the Deployment must carry the enable annotation
+func actionsEnabled(unstructuredObj *unstructured.Unstructured) bool {
+	if !actionConfigMapsOptIn {
+		return true
+	}
+	return unstructuredObj.GetAnnotations()[kappnavEnableActions] == "true"
+}
+
+// Return the namespace and name of the action configmap of a Deployment
+func (kind *actionConfigMapKind) location(namespace string, deploymentName string) (string, string) {
+	prefix := actionConfigMapKindPrefix + kind.subkind + "."
+	if actionConfigMapsInkAppNavNamespace {
+		return getkAppNavNamespace(), truncateConfigMapName(prefix + namespace + "." + deploymentName)
+	}
+	return namespace, truncateConfigMapName(prefix + deploymentName)
+}
+
+// Return the namespace of the action configmaps of Deployments in a namespace
+func actionConfigMapNamespace(namespace string) string {
+	if actionConfigMapsInkAppNavNamespace {
+		return getkAppNavNamespace()
+	}
+	return namespace
+}
+
+// Return the namespace and name of the action configmap of a Liberty Deployment
+func actionConfigMapLocation(namespace string, deploymentName string) (string, string) {
+	return actionConfigMapKinds[OpenLibertyApplication].location(namespace, deploymentName)
+}
+
+// Return the name if it is short enough for a configmap. Otherwise truncate it,
+// and append a hash of the full name so that different long names remain different
+func truncateConfigMapName(name string) string {
+	if len(name) <= maxConfigMapNameLength {
+		return name
+	}
+	sum := sha256.Sum256([]byte(name))
+	hash := hex.EncodeToString(sum[:])[:nameHashLength]
+	// each part of a DNS subdomain must start and end with an alphanumeric character
+	truncated := strings.TrimRight(name[:maxConfigMapNameLength-nameHashLength-1], ".-")
+	return truncated + "-" + hash
+}
+
+// Create the action configmap for a Deployment owned by a resource of a registered kind,
+// if it does not already exist. In the namespace of the Deployment, the configmap
+// is owned by the Deployment so that it is garbage collected with the Deployment.
+func createActionConfigMap(resController *ClusterWatcher, unstructuredObj *unstructured.Unstructured) error {
+	kind := actionConfigMapKindOf(unstructuredObj)
+	if kind == nil {
+		return nil
+	}
+	if !actionsEnabled(unstructuredObj) {
+		if klog.V(4) {
+			klog.Infof("Not creating action configmap for Deployment %s/%s without annotation %s=true",
+				unstructuredObj.GetNamespace(), unstructuredObj.GetName(), kappnavEnableActions)
+		}
+		return nil
+	}
+	namespace, name := kind.location(unstructuredObj.GetNamespace(), unstructuredObj.GetName())
+	if !resController.actionConfigMapBreakers.allow(namespace) {
+		if klog.V(4) {
+			klog.Infof("Not creating action configmap %s/%s while creation keeps failing in the namespace", namespace, name)
+		}
+		return nil
+	}
+	err := writeActionConfigMap(resController, kind, unstructuredObj, namespace, name)
+	resController.actionConfigMapBreakers.record(namespace, err)
+	return err
+}
+
+// Create or take over the action configmap of a Deployment
+func writeActionConfigMap(resController *ClusterWatcher, kind *actionConfigMapKind, unstructuredObj *unstructured.Unstructured, namespace string, name string) error {
+	intf := resController.plugin.dynamicClient.Resource(coreConfigMapGVR).Namespace(namespace)
+
+	var ownerReferences []metav1.OwnerReference
+	if namespace == unstructuredObj.GetNamespace() {
+		controller := false
+		ownerReferences = []metav1.OwnerReference{{
+			APIVersion: unstructuredObj.GetAPIVersion(),
+			Kind:       unstructuredObj.GetKind(),
+			Name:       unstructuredObj.GetName(),
+			UID:        unstructuredObj.GetUID(),
+			Controller: &controller,
+		}}
+	}
+
+	existing, err := intf.Get(name, metav1.GetOptions{})
+	if err == nil {
+		if existing.GetLabels()[labelManagedBy] != managedByKAppNav || actionConfigMapOwnedBy(existing, unstructuredObj.GetUID()) {
+			// already exists
+			return nil
+		}
+		// left over from a deleted Deployment with the same name. Take it over
+		// before it is garbage collected with the deleted Deployment
+		if resController.skipWrite("owner of action configmap %s/%s: uid %s", namespace, name, unstructuredObj.GetUID()) {
+			return nil
+		}
+		existing.SetOwnerReferences(ownerReferences)
+		annotations := existing.GetAnnotations()
+		if annotations == nil {
+			annotations = make(map[string]string)
+		}
+		annotations[actionConfigMapOwnerUID] = string(unstructuredObj.GetUID())
+		existing.SetAnnotations(annotations)
+		_, err = intf.Update(existing, metav1.UpdateOptions{})
+		if err == nil && klog.V(2) {
+			klog.Infof("Updated owner of action configmap %s/%s to uid %s", namespace, name, unstructuredObj.GetUID())
+		}
+		return err
+	}
+	if !errors.IsNotFound(err) {
+		return err
+	}
+
+	var resInfo = &resourceInfo{}
+	parseResourceBasic(unstructuredObj, resInfo)
+	cmdActions, inputs := kind.actions(resInfo)
+	data := map[string]interface{}{
+		urlActionsKey: emptyActionsValue,
+		cmdActionsKey: cmdActions,
+	}
+	if inputs != "" {
+		data[inputsKey] = inputs
+	}
+
+	configMap := &unstructured.Unstructured{
+		Object: map[string]interface{}{
+			APIVERSION: V1,
+			KIND:       "ConfigMap",
+			METADATA: map[string]interface{}{
+				NAME:      name,
+				NAMESPACE: namespace,
+				LABELS: map[string]interface{}{
+					labelManagedBy: managedByKAppNav,
+				},
+				ANNOTATIONS: map[string]interface{}{
+					actionConfigMapOwnerUID: string(unstructuredObj.GetUID()),
+				},
+			},
+			"data": data,
+		},
+	}
+	if ownerReferences != nil {
+		configMap.SetOwnerReferences(ownerReferences)
+	}
+
+	if resController.skipWrite("action configmap %s/%s: %s %s", namespace, name, cmdActionsKey, cmdActions) {
+		return nil
+	}
+	_, err = intf.Create(configMap, metav1.CreateOptions{})
+	if err != nil {
+		if errors.IsAlreadyExists(err) {
+			return nil
+		}
+		return err
+	}
+	if klog.V(2) {
+		klog.Infof("Created action configmap %s/%s", namespace, name)
+	}
+	return nil
+}
+
+// Return true if the resource is a configmap created by the controller
+func isManagedConfigMap(unstructuredObj *unstructured.Unstructured) bool {
+	return unstructuredObj.GetKind() == "ConfigMap" && unstructuredObj.GetLabels()[labelManagedBy] == managedByKAppNav
+}
+
+// Return true if an add or update event is for a configmap created by the controller.
+// An update that adds or removes the label is not
+func isManagedConfigMapEvent(eventData *eventHandlerData) bool {
+	obj, ok := eventData.obj.(*unstructured.Unstructured)
+	if !ok || !isManagedConfigMap(obj) {
+		return false
+	}
+	if eventData.funcType == UpdateFunc {
+		oldObj, ok := eventData.oldObj.(*unstructured.Unstructured)
+		return ok && isManagedConfigMap(oldObj)
+	}
+	return true
+}
+
+// Return true if the action configmap was created for the Deployment with the given uid
+func actionConfigMapOwnedBy(configMap *unstructured.Unstructured, uid types.UID) bool {
+	if ownerUID, ok := configMap.GetAnnotations()[actionConfigMapOwnerUID]; ok {
+		return ownerUID == string(uid)
+	}
+	return ownedByUID(configMap, uid)
+}
+
+// Return true if the resource has an owner with the given uid
+func ownedByUID(unstructuredObj *unstructured.Unstructured, uid types.UID) bool {
+	for _, owner := range unstructuredObj.GetOwnerReferences() {
+		if owner.UID == uid {
+			return true
+		}
+	}
+	return false
+}
+
+// Delete the action configmap of a kind of a Deployment. Configmaps not created by the
+// controller for this Deployment are left alone.
+func deleteActionConfigMap(resController *ClusterWatcher, kind *actionConfigMapKind, unstructuredObj *unstructured.Unstructured) error {
+	namespace, name := kind.location(unstructuredObj.GetNamespace(), unstructuredObj.GetName())
+	intf := resController.plugin.dynamicClient.Resource(coreConfigMapGVR).Namespace(namespace)
+
+	configMap, err := intf.Get(name, metav1.GetOptions{})
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return nil
+		}
+		return err
+	}
+	if configMap.GetLabels()[labelManagedBy] != managedByKAppNav {
+		if klog.V(2) {
+			klog.Infof("Not deleting action configmap %s/%s not managed by %s", namespace, name, managedByKAppNav)
+		}
+		return nil
+	}
+	if !actionConfigMapOwnedBy(configMap, unstructuredObj.GetUID()) {
+		if klog.V(2) {
+			klog.Infof("Not deleting action configmap %s/%s of another Deployment with the same name", namespace, name)
+		}
+		return nil
+	}
+	if resController.skipWrite("deletion of action configmap %s/%s", namespace, name) {
+		return nil
+	}
+
+	err = intf.Delete(name, &metav1.DeleteOptions{})
+	if err != nil && !errors.IsNotFound(err) {
+		return err
+	}
+	if klog.V(2) {
+		klog.Infof("Deleted action configmap %s/%s", namespace, name)
+	}
+	return nil
+}
+
+// Create or delete the action configmap of a Deployment depending on whether
+// it is owned by a resource of a registered kind, and has actions enabled.
+// oldObj is the Deployment before an update, or nil.
+func syncActionConfigMap(resController *ClusterWatcher, unstructuredObj *unstructured.Unstructured, oldObj *unstructured.Unstructured) error {
+	var kind, oldKind *actionConfigMapKind
+	if actionsEnabled(unstructuredObj) {
+		kind = actionConfigMapKindOf(unstructuredObj)
+	}
+	if oldObj != nil && actionsEnabled(oldObj) {
+		oldKind = actionConfigMapKindOf(oldObj)
+	}
+	if oldKind != nil && oldKind != kind {
+		// no longer owned by a resource of the kind, or opted out
+		if err := deleteActionConfigMap(resController, oldKind, unstructuredObj); err != nil || kind == nil {
+			return err
+		}
+	}
+	if kind != nil {
+		return createActionConfigMap(resController, unstructuredObj)
+	}
+	return nil
+}
+
+// Delete the action configmap of a deleted Deployment. Only needed for configmaps
+// in the kappnav namespace, as others are garbage collected with the Deployment.
+func deleteActionConfigMapOfDeletedDeployment(resController *ClusterWatcher, unstructuredObj *unstructured.Unstructured) error {
+	if !actionConfigMapsInkAppNavNamespace {
+		return nil
+	}
+	kind := actionConfigMapKindOf(unstructuredObj)
+	if kind == nil {
+		return nil
+	}
+	return deleteActionConfigMap(resController, kind, unstructuredObj)
+}
+
+// Return the condition recording whether creation of action configmaps is suppressed
+// in the namespace of the action configmaps of the application because it keeps failing there
+func newActionConfigMapCreationCondition(resController *ClusterWatcher, appInfo *appResourceInfo) applicationCondition {
+	namespace := actionConfigMapNamespace(appInfo.namespace)
+	openedBy := resController.actionConfigMapBreakers.openedBy(namespace)
+	cond := &statusCondition{conditionType: actionConfigMapCreationCondition}
+	if openedBy == nil {
+		cond.status = conditionTrue
+		cond.reason = creationAllowedReason
+	} else {
+		cond.status = conditionFalse
+		cond.reason = creationSuppressedReason
+		cond.message = "creation of action configmaps in namespace " + namespace + " is suppressed after repeated failures: " + openedBy.Error()
+	}
+	// nothing to clear if creation was never suppressed for this application
+	return applicationCondition{cond, openedBy == nil}
+}
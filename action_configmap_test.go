@@ -0,0 +1,567 @@
+/*
+Copyright 2019 IBM Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/tools/cache"
+)
+
+const (
+	deploymentLiberty        = "test_data/liberty-deployment.json"
+	deploymentLibertyUnowned = "test_data/liberty-deployment-unowned.json"
+)
+
+// wait for the action configmap of a Deployment to exist or not
+func waitForActionConfigMap(resController *ClusterWatcher, namespace string, deploymentName string, expectExists bool) error {
+	namespace, name := actionConfigMapLocation(namespace, deploymentName)
+	intf := resController.plugin.dynamicClient.Resource(coreConfigMapGVR).Namespace(namespace)
+	for i := 0; i < 20; i++ {
+		configMap, err := intf.Get(name, metav1.GetOptions{})
+		exists := err == nil
+		if exists == expectExists {
+			if exists && configMap.GetLabels()[labelManagedBy] != managedByKAppNav {
+				return fmt.Errorf("action configmap %s/%s missing label %s", namespace, name, labelManagedBy)
+			}
+			return nil
+		}
+		time.Sleep(time.Millisecond * 500)
+	}
+	return fmt.Errorf("timed out waiting for action configmap %s/%s, expected to exist: %t", namespace, name, expectExists)
+}
+
+func TestIsOwnedByLiberty(t *testing.T) {
+	owned, err := readJSON(deploymentLiberty)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if actionConfigMapKindOf(owned) != actionConfigMapKinds[OpenLibertyApplication] {
+		t.Errorf("expecting %s to be owned by %s", deploymentLiberty, OpenLibertyApplication)
+	}
+	unowned, err := readJSON(deploymentLibertyUnowned)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if actionConfigMapKindOf(unowned) != nil {
+		t.Errorf("expecting %s not to be owned by %s", deploymentLibertyUnowned, OpenLibertyApplication)
+	}
+}
+
+// Test a Deployment owned by a registered kind gets the actions of the kind,
+// and one owned by an unregistered kind gets no action configmap
+func TestActionConfigMapRegisteredKind(t *testing.T) {
+	const nodeKind = "NodeApplication"
+	registerActionConfigMapKind(nodeKind, &actionConfigMapKind{
+		subkind: "nodejs",
+		actions: func(resInfo *resourceInfo) (string, string) {
+			return `[{"name":"restart-` + resInfo.name + `"}]`, `[{"name":"signal"}]`
+		},
+	})
+	defer delete(actionConfigMapKinds, nodeKind)
+
+	deployment, err := readJSON(deploymentLiberty)
+	if err != nil {
+		t.Fatal(err)
+	}
+	setOwnerKind := func(kind string) *unstructured.Unstructured {
+		owned := deployment.DeepCopy()
+		owners := owned.GetOwnerReferences()
+		for index := range owners {
+			owners[index].Kind = kind
+		}
+		owned.SetOwnerReferences(owners)
+		return owned
+	}
+
+	// unregistered kind
+	resController := &ClusterWatcher{
+		plugin: &ControllerPlugin{dynamicClient: fake.NewSimpleDynamicClient(runtime.NewScheme())},
+	}
+	if err = syncActionConfigMap(resController, setOwnerKind("SpringBootApplication"), nil); err != nil {
+		t.Fatal(err)
+	}
+	intf := resController.plugin.dynamicClient.Resource(coreConfigMapGVR).Namespace(deployment.GetNamespace())
+	configMaps, err := intf.List(metav1.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(configMaps.Items) != 0 {
+		t.Errorf("expecting no action configmap for an unregistered owner kind, got %d", len(configMaps.Items))
+	}
+
+	// registered kind
+	if err = syncActionConfigMap(resController, setOwnerKind(nodeKind), nil); err != nil {
+		t.Fatal(err)
+	}
+	name := actionConfigMapKindPrefix + "nodejs." + deployment.GetName()
+	configMap, err := intf.Get(name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("expecting action configmap %s: %s", name, err)
+	}
+	data, _, _ := unstructured.NestedStringMap(configMap.Object, "data")
+	if expected := `[{"name":"restart-` + deployment.GetName() + `"}]`; data[cmdActionsKey] != expected {
+		t.Errorf("expecting %s %s, got %s", cmdActionsKey, expected, data[cmdActionsKey])
+	}
+	if expected := `[{"name":"signal"}]`; data[inputsKey] != expected {
+		t.Errorf("expecting %s %s, got %s", inputsKey, expected, data[inputsKey])
+	}
+	if data[urlActionsKey] != emptyActionsValue {
+		t.Errorf("expecting %s %s, got %s", urlActionsKey, emptyActionsValue, data[urlActionsKey])
+	}
+
+	// the Liberty configmap has no inputs
+	if err = syncActionConfigMap(resController, deployment, nil); err != nil {
+		t.Fatal(err)
+	}
+	_, libertyName := actionConfigMapLocation(deployment.GetNamespace(), deployment.GetName())
+	configMap, err = intf.Get(libertyName, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("expecting action configmap %s: %s", libertyName, err)
+	}
+	data, _, _ = unstructured.NestedStringMap(configMap.Object, "data")
+	var resInfo = &resourceInfo{}
+	parseResourceBasic(deployment, resInfo)
+	if _, ok := data[inputsKey]; ok || data[cmdActionsKey] != cmdActionsJSON(libertyCmdActions, resInfo) {
+		t.Errorf("expecting Liberty action configmap with the Liberty %s and no %s, got %v", cmdActionsKey, inputsKey, data)
+	}
+
+	// changing the owner kind moves the configmap
+	if err = syncActionConfigMap(resController, setOwnerKind(nodeKind), deployment); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = intf.Get(libertyName, metav1.GetOptions{}); err == nil {
+		t.Errorf("expecting action configmap %s to be deleted after the owner kind changed", libertyName)
+	}
+	if _, err = intf.Get(name, metav1.GetOptions{}); err != nil {
+		t.Errorf("expecting action configmap %s to remain: %s", name, err)
+	}
+}
+
+// Test action configmap is deleted when Deployment is no longer owned by OpenLibertyApplication
+func TestActionConfigMapOwnerChange(t *testing.T) {
+	testName := "TestActionConfigMapOwnerChange"
+	beforeTest()
+	// kinds to check for status
+	var kindsToCheckStatus = map[string]bool{
+		APPLICATION:  true,
+		"Deployment": true,
+	}
+
+	var files = []string{
+		/* 0 */ CrdApplication,
+		/* 1 */ appProductpage,
+		/* 2 */ deploymentLiberty,
+		/* 3 */ KappnavConfigFile,
+	}
+	iteration0IDs, err := readResourceIDs(files)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	/* Iteration 0: owned by OpenLibertyApplication */
+	testActions := newTestActions(testName, kindsToCheckStatus)
+	iteration0IDs[1].expectedStatus = unknown // the Liberty Deployment is not a component
+	var emptyIDs = []resourceID{}
+	testActions.addIteration(iteration0IDs, emptyIDs)
+
+	/* Iteration 1: owner kind changed */
+	arrayLength := len(iteration0IDs)
+	var iteration1IDs = make([]resourceID, arrayLength, arrayLength)
+	copy(iteration1IDs, iteration0IDs)
+	iteration1IDs[2].fileName = deploymentLibertyUnowned
+	testActions.addIteration(iteration1IDs, emptyIDs)
+
+	clusterWatcher, err := createClusterWatcher(iteration0IDs, testActions, StatusFailureRate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer clusterWatcher.shutDown()
+
+	deployment := iteration0IDs[2]
+	if _, err = testActions.transition(); err != nil {
+		t.Fatal(err)
+	}
+	if err = waitForActionConfigMap(clusterWatcher, deployment.namespace, deployment.name, true); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = testActions.transition(); err != nil {
+		t.Fatal(err)
+	}
+	if err = waitForActionConfigMap(clusterWatcher, deployment.namespace, deployment.name, false); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Test an action configmap left over from a deleted Deployment is taken over by a new Deployment with the same name
+func TestActionConfigMapNameReuse(t *testing.T) {
+	resController := &ClusterWatcher{
+		plugin: &ControllerPlugin{dynamicClient: fake.NewSimpleDynamicClient(runtime.NewScheme())},
+	}
+	oldDeployment, err := readJSON(deploymentLiberty)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = createActionConfigMap(resController, oldDeployment); err != nil {
+		t.Fatal(err)
+	}
+
+	// deleted and re-created with same name
+	newDeployment := oldDeployment.DeepCopy()
+	newDeployment.SetUID(types.UID("6b2f3d8f-347d-11e9-9d73-0800275638b6"))
+	if err = createActionConfigMap(resController, newDeployment); err != nil {
+		t.Fatal(err)
+	}
+
+	namespace, name := actionConfigMapLocation(newDeployment.GetNamespace(), newDeployment.GetName())
+	configMap, err := resController.plugin.dynamicClient.Resource(coreConfigMapGVR).Namespace(namespace).Get(name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ownedByUID(configMap, newDeployment.GetUID()) || ownedByUID(configMap, oldDeployment.GetUID()) {
+		t.Errorf("expecting action configmap to be owned by uid %s only, got %v", newDeployment.GetUID(), configMap.GetOwnerReferences())
+	}
+}
+
+type actionConfigMapLocationTestData struct {
+	inkAppNavNamespace bool
+	namespace          string
+	deploymentName     string
+	expectedNamespace  string
+	expectedName       string
+}
+
+var actionConfigMapLocationTestDataArray = []actionConfigMapLocationTestData{
+	{false, "default", "liberty-sample", "default", "kappnav.actions.deployment-liberty.liberty-sample"},
+	{true, "default", "liberty-sample", defaultkAppNavNamespace, "kappnav.actions.deployment-liberty.default.liberty-sample"},
+	// names from different namespaces do not collide
+	{true, "a", "b-c", defaultkAppNavNamespace, "kappnav.actions.deployment-liberty.a.b-c"},
+	{true, "a-b", "c", defaultkAppNavNamespace, "kappnav.actions.deployment-liberty.a-b.c"},
+}
+
+func TestActionConfigMapLocation(t *testing.T) {
+	defer func() {
+		actionConfigMapsInkAppNavNamespace = false
+	}()
+	for _, data := range actionConfigMapLocationTestDataArray {
+		actionConfigMapsInkAppNavNamespace = data.inkAppNavNamespace
+		namespace, name := actionConfigMapLocation(data.namespace, data.deploymentName)
+		if namespace != data.expectedNamespace || name != data.expectedName {
+			t.Errorf("location of action configmap for %s/%s in kappnav namespace %t: expecting %s/%s, got %s/%s",
+				data.namespace, data.deploymentName, data.inkAppNavNamespace, data.expectedNamespace, data.expectedName, namespace, name)
+		}
+	}
+}
+
+// Test action configmap names for long Deployment names are valid, stable, and unique
+func TestActionConfigMapLongName(t *testing.T) {
+	defer func() {
+		actionConfigMapsInkAppNavNamespace = false
+	}()
+	longName := strings.Repeat("liberty-sample.", 16) + "a"
+	otherLongName := strings.Repeat("liberty-sample.", 16) + "b"
+	for _, inkAppNavNamespace := range []bool{false, true} {
+		actionConfigMapsInkAppNavNamespace = inkAppNavNamespace
+		_, name := actionConfigMapLocation("default", longName)
+		if len(name) > maxConfigMapNameLength {
+			t.Errorf("expecting name of at most %d characters, got %d: %s", maxConfigMapNameLength, len(name), name)
+		}
+		if errs := validation.IsDNS1123Subdomain(name); len(errs) != 0 {
+			t.Errorf("expecting valid name, got %s: %v", name, errs)
+		}
+		if _, again := actionConfigMapLocation("default", longName); again != name {
+			t.Errorf("expecting stable name %s, got %s", name, again)
+		}
+		if _, other := actionConfigMapLocation("default", otherLongName); other == name {
+			t.Errorf("expecting different names for different long Deployment names, got %s", name)
+		}
+	}
+}
+
+// Test action configmaps of Deployments with the same name in different namespaces are placed in the kappnav namespace
+func TestActionConfigMapInkAppNavNamespace(t *testing.T) {
+	actionConfigMapsInkAppNavNamespace = true
+	defer func() {
+		actionConfigMapsInkAppNavNamespace = false
+	}()
+	resController := &ClusterWatcher{
+		plugin: &ControllerPlugin{dynamicClient: fake.NewSimpleDynamicClient(runtime.NewScheme())},
+	}
+	deployment1, err := readJSON(deploymentLiberty)
+	if err != nil {
+		t.Fatal(err)
+	}
+	deployment2 := deployment1.DeepCopy()
+	deployment2.SetNamespace("other")
+	deployment2.SetUID(types.UID("8d4b5fab-347d-11e9-9d73-0800275638b6"))
+	for _, deployment := range []*unstructured.Unstructured{deployment1, deployment2} {
+		if err = createActionConfigMap(resController, deployment); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	intf := resController.plugin.dynamicClient.Resource(coreConfigMapGVR).Namespace(getkAppNavNamespace())
+	configMaps, err := intf.List(metav1.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(configMaps.Items) != 2 {
+		t.Fatalf("expecting 2 action configmaps in kappnav namespace, got %d", len(configMaps.Items))
+	}
+	for _, configMap := range configMaps.Items {
+		if len(configMap.GetOwnerReferences()) != 0 {
+			t.Errorf("expecting no owner references across namespaces for %s, got %v", configMap.GetName(), configMap.GetOwnerReferences())
+		}
+	}
+
+	// deleting one Deployment deletes only its configmap
+	if err = deleteActionConfigMapOfDeletedDeployment(resController, deployment2); err != nil {
+		t.Fatal(err)
+	}
+	_, name1 := actionConfigMapLocation(deployment1.GetNamespace(), deployment1.GetName())
+	if _, err = intf.Get(name1, metav1.GetOptions{}); err != nil {
+		t.Errorf("expecting action configmap %s to remain: %s", name1, err)
+	}
+	_, name2 := actionConfigMapLocation(deployment2.GetNamespace(), deployment2.GetName())
+	if _, err = intf.Get(name2, metav1.GetOptions{}); err == nil {
+		t.Errorf("expecting action configmap %s to be deleted", name2)
+	}
+}
+
+type actionConfigMapOptInTestData struct {
+	optIn          bool
+	annotation     string // value of the enable annotation. Empty for none
+	expectedExists bool
+}
+
+var actionConfigMapOptInTestDataArray = []actionConfigMapOptInTestData{
+	{false, "", true},
+	{true, "", false},
+	{true, "false", false},
+	{true, "true", true},
+}
+
+// Test action configmaps are only created for Deployments with the enable annotation in opt-in mode
+func TestActionConfigMapOptIn(t *testing.T) {
+	defer func() {
+		actionConfigMapsOptIn = false
+	}()
+	deployment, err := readJSON(deploymentLiberty)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, data := range actionConfigMapOptInTestDataArray {
+		actionConfigMapsOptIn = data.optIn
+		resController := &ClusterWatcher{
+			plugin: &ControllerPlugin{dynamicClient: fake.NewSimpleDynamicClient(runtime.NewScheme())},
+		}
+		annotated := deployment.DeepCopy()
+		if data.annotation != "" {
+			annotated.SetAnnotations(map[string]string{kappnavEnableActions: data.annotation})
+		}
+		if err = syncActionConfigMap(resController, annotated, nil); err != nil {
+			t.Fatal(err)
+		}
+		namespace, name := actionConfigMapLocation(annotated.GetNamespace(), annotated.GetName())
+		_, err = resController.plugin.dynamicClient.Resource(coreConfigMapGVR).Namespace(namespace).Get(name, metav1.GetOptions{})
+		if exists := err == nil; exists != data.expectedExists {
+			t.Errorf("opt-in %t, annotation %q: expecting action configmap to exist: %t, got %t", data.optIn, data.annotation, data.expectedExists, exists)
+		}
+	}
+
+	// removing the annotation in opt-in mode deletes the configmap
+	actionConfigMapsOptIn = true
+	resController := &ClusterWatcher{
+		plugin: &ControllerPlugin{dynamicClient: fake.NewSimpleDynamicClient(runtime.NewScheme())},
+	}
+	enabled := deployment.DeepCopy()
+	enabled.SetAnnotations(map[string]string{kappnavEnableActions: "true"})
+	if err = syncActionConfigMap(resController, enabled, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err = syncActionConfigMap(resController, deployment, enabled); err != nil {
+		t.Fatal(err)
+	}
+	namespace, name := actionConfigMapLocation(deployment.GetNamespace(), deployment.GetName())
+	if _, err = resController.plugin.dynamicClient.Resource(coreConfigMapGVR).Namespace(namespace).Get(name, metav1.GetOptions{}); err == nil {
+		t.Errorf("expecting action configmap %s/%s to be deleted after opting out", namespace, name)
+	}
+}
+
+// Return a configmap, labeled as created by the controller if managed
+func testConfigMap(name string, managed bool) *unstructured.Unstructured {
+	configMap := &unstructured.Unstructured{Object: map[string]interface{}{
+		APIVERSION: V1,
+		KIND:       "ConfigMap",
+		METADATA: map[string]interface{}{
+			NAME:      name,
+			NAMESPACE: "default",
+		},
+	}}
+	if managed {
+		configMap.SetLabels(map[string]string{labelManagedBy: managedByKAppNav})
+	}
+	return configMap
+}
+
+func TestIgnoreManagedConfigMaps(t *testing.T) {
+	defer func() {
+		ignoreManagedConfigMaps = true
+	}()
+	managed := testConfigMap(actionConfigMapPrefix+"liberty", true)
+	user := testConfigMap("user-config", false)
+
+	var testData = []struct {
+		ignore        bool
+		eventData     *eventHandlerData
+		expectBatched bool
+	}{
+		{true, &eventHandlerData{funcType: AddFunc, obj: managed}, false},
+		{true, &eventHandlerData{funcType: UpdateFunc, obj: managed, oldObj: managed}, false},
+		{true, &eventHandlerData{funcType: AddFunc, obj: user}, true},
+		{true, &eventHandlerData{funcType: UpdateFunc, obj: user, oldObj: user}, true},
+		// label added to a configmap of the user
+		{true, &eventHandlerData{funcType: UpdateFunc, obj: managed, oldObj: user}, true},
+		{false, &eventHandlerData{funcType: AddFunc, obj: managed}, true},
+	}
+	for index, data := range testData {
+		ignoreManagedConfigMaps = data.ignore
+		resController := &ClusterWatcher{
+			resourceChannel:   newResourceChannel(),
+			deletedComponents: newDeletedComponents(0),
+		}
+		rw := &ResourceWatcher{
+			GroupVersionResource: coreConfigMapGVR,
+			store:                cache.NewStore(cache.MetaNamespaceKeyFunc),
+		}
+		obj := data.eventData.obj.(*unstructured.Unstructured)
+		if err := rw.store.Add(obj); err != nil {
+			t.Fatal(err)
+		}
+		data.eventData.gvr = coreConfigMapGVR
+		data.eventData.key = obj.GetNamespace() + "/" + obj.GetName()
+		if err := batchResourceHandler(resController, rw, data.eventData); err != nil {
+			t.Fatal(err)
+		}
+		select {
+		case <-resController.resourceChannel.batchResourceChan:
+			if !data.expectBatched {
+				t.Errorf("%d: expecting event for %s to be ignored", index, data.eventData.key)
+			}
+		default:
+			if data.expectBatched {
+				t.Errorf("%d: expecting event for %s to be batched up", index, data.eventData.key)
+			}
+		}
+	}
+}
+
+func TestManagedConfigMapDeletionProcessed(t *testing.T) {
+	resController := &ClusterWatcher{
+		resourceChannel:   newResourceChannel(),
+		deletedComponents: newDeletedComponents(0),
+	}
+	rw := &ResourceWatcher{
+		GroupVersionResource: coreConfigMapGVR,
+		store:                cache.NewStore(cache.MetaNamespaceKeyFunc),
+	}
+	managed := testConfigMap(actionConfigMapPrefix+"liberty", true)
+	eventData := &eventHandlerData{funcType: DeleteFunc, gvr: coreConfigMapGVR, key: "default/" + managed.GetName(), obj: managed}
+	if err := batchResourceHandler(resController, rw, eventData); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-resController.resourceChannel.batchResourceChan:
+	default:
+		t.Error("expecting deletion of a managed configmap to be processed")
+	}
+}
+
+// Test applications record whether creation of action configmaps is suppressed in their namespace
+func TestActionConfigMapCreationCondition(t *testing.T) {
+	app, err := readJSON(appProductpage)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := fake.NewSimpleDynamicClient(runtime.NewScheme())
+	intf := client.Resource(coreApplicationGVR).Namespace(app.GetNamespace())
+	if _, err = intf.Create(app, metav1.CreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	resController := &ClusterWatcher{
+		plugin:                  &ControllerPlugin{dynamicClient: client},
+		resourceMap:             map[schema.GroupVersionResource]*ResourceWatcher{coreApplicationGVR: {GroupVersionResource: coreApplicationGVR}},
+		actionConfigMapBreakers: newNamespaceBreakers("action configmap creation", 1, time.Minute, nil),
+	}
+	initControllerMaps(resController)
+	var appInfo = &appResourceInfo{}
+	if err = resController.parseAppResource(app, appInfo); err != nil {
+		t.Fatal(err)
+	}
+
+	// the application as updated in the cache
+	getCondition := func() *statusCondition {
+		t.Helper()
+		updated, err := intf.Get(app.GetName(), metav1.GetOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		appInfo = &appResourceInfo{}
+		if err = resController.parseAppResource(updated, appInfo); err != nil {
+			t.Fatal(err)
+		}
+		return getStatusCondition(updated, actionConfigMapCreationCondition)
+	}
+
+	// not added while creation is allowed
+	if err = writeApplicationConditions(resController, appInfo, []applicationCondition{newActionConfigMapCreationCondition(resController, appInfo)}); err != nil {
+		t.Fatal(err)
+	}
+	if cond := getCondition(); cond != nil {
+		t.Errorf("expecting no condition %s while creation was never suppressed, got %s %s", actionConfigMapCreationCondition, cond.status, cond.reason)
+	}
+
+	// suppressed
+	resController.actionConfigMapBreakers.record(app.GetNamespace(), fmt.Errorf("exceeded quota"))
+	if err = writeApplicationConditions(resController, appInfo, []applicationCondition{newActionConfigMapCreationCondition(resController, appInfo)}); err != nil {
+		t.Fatal(err)
+	}
+	cond := getCondition()
+	if cond == nil || cond.status != conditionFalse || cond.reason != creationSuppressedReason || !strings.Contains(cond.message, "exceeded quota") {
+		t.Fatalf("expecting condition %s %s with the error, got %+v", conditionFalse, creationSuppressedReason, cond)
+	}
+
+	// cleared once creation succeeds again
+	resController.actionConfigMapBreakers.record(app.GetNamespace(), nil)
+	if err = writeApplicationConditions(resController, appInfo, []applicationCondition{newActionConfigMapCreationCondition(resController, appInfo)}); err != nil {
+		t.Fatal(err)
+	}
+	if cond = getCondition(); cond == nil || cond.status != conditionTrue || cond.reason != creationAllowedReason {
+		t.Errorf("expecting condition %s %s, got %+v", conditionTrue, creationAllowedReason, cond)
+	}
+}
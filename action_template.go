@@ -0,0 +1,151 @@
+/*
+Copyright 2019 IBM Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"text/template"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/klog"
+)
+
+/*
+ The cmd-pattern of the command actions generated for a Deployment is a
+ text/template executed against the fields of the Deployment, e.g.
+
+   kubectl logs -n {{.Namespace}} deployment/{{.Name}} -c {{index .Containers 0}} -l app={{.Labels.app}}
+
+ Every value reachable from the template is quoted for the shell beforehand,
+ so that labels or annotations containing spaces, quotes, or shell syntax
+ are passed as one argument rather than run. A template referencing a label
+ or annotation the Deployment does not have as a field, e.g. {{.Labels.app}},
+ fails, and the action is left out.
+*/
+
+// Command action of an action configmap, as found in cmd-actions
+type cmdAction struct {
+	Name        string `json:"name"`
+	Text        string `json:"text"`
+	Description string `json:"description,omitempty"`
+	Image       string `json:"image,omitempty"`
+	CmdPattern  string `json:"cmd-pattern"` // template, rendered for each Deployment
+}
+
+// Fields of a resource available to cmd-pattern templates, each quoted for the shell
+type actionTemplateContext struct {
+	Kind        string
+	Namespace   string
+	Name        string
+	Labels      map[string]string
+	Annotations map[string]string
+	Containers  []string // names of the containers of the pod template, or of a Pod
+}
+
+// Return the string as a single shell word: in single quotes, with single quotes escaped
+func shellQuote(value string) string {
+	return "'" + strings.Replace(value, "'", `'"'"'`, -1) + "'"
+}
+
+// Return a copy of the map with each value quoted for the shell
+func shellQuoteMap(values map[string]string) map[string]string {
+	ret := make(map[string]string, len(values))
+	for key, value := range values {
+		ret[key] = shellQuote(value)
+	}
+	return ret
+}
+
+// Return the names of the containers of the pod template of a resource, or of a Pod
+func containerNamesOf(unstructuredObj *unstructured.Unstructured) []string {
+	containers, ok, _ := unstructured.NestedSlice(unstructuredObj.Object, SPEC, "template", SPEC, "containers")
+	if !ok {
+		containers, _, _ = unstructured.NestedSlice(unstructuredObj.Object, SPEC, "containers")
+	}
+	ret := make([]string, 0, len(containers))
+	for _, container := range containers {
+		containerMap, ok := container.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if name, _ := containerMap[NAME].(string); name != "" {
+			ret = append(ret, name)
+		}
+	}
+	return ret
+}
+
+// Return the template context of a resource
+func newActionTemplateContext(resInfo *resourceInfo) *actionTemplateContext {
+	context := &actionTemplateContext{
+		Kind:        shellQuote(resInfo.kind),
+		Namespace:   shellQuote(resInfo.namespace),
+		Name:        shellQuote(resInfo.name),
+		Labels:      shellQuoteMap(resInfo.labels),
+		Annotations: make(map[string]string),
+		Containers:  make([]string, 0),
+	}
+	if resInfo.unstructuredObj != nil {
+		context.Annotations = shellQuoteMap(resInfo.unstructuredObj.GetAnnotations())
+		for _, name := range containerNamesOf(resInfo.unstructuredObj) {
+			context.Containers = append(context.Containers, shellQuote(name))
+		}
+	}
+	return context
+}
+
+// Render a cmd-pattern template against a resource
+func renderCmdPattern(pattern string, context *actionTemplateContext) (string, error) {
+	tmpl, err := template.New("cmd-pattern").Option("missingkey=error").Parse(pattern)
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, context); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// Return the cmd-actions of a resource as JSON, with the cmd-pattern of each action
+// rendered against the resource. Actions whose template fails are left out
+func cmdActionsJSON(actions []cmdAction, resInfo *resourceInfo) string {
+	if len(actions) == 0 {
+		return emptyActionsValue
+	}
+	context := newActionTemplateContext(resInfo)
+	rendered := make([]cmdAction, 0, len(actions))
+	for _, action := range actions {
+		cmd, err := renderCmdPattern(action.CmdPattern, context)
+		if err != nil {
+			klog.Errorf("leaving out action %s of %s %s/%s: %s", action.Name, resInfo.kind, resInfo.namespace, resInfo.name, err)
+			continue
+		}
+		action.CmdPattern = cmd
+		rendered = append(rendered, action)
+	}
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(rendered); err != nil {
+		klog.Errorf("unable to encode actions of %s %s/%s: %s", resInfo.kind, resInfo.namespace, resInfo.name, err)
+		return emptyActionsValue
+	}
+	return strings.TrimSpace(buf.String())
+}
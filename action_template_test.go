@@ -0,0 +1,143 @@
+/*
+Copyright 2019 IBM Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+type cmdPatternTestData struct {
+	pattern     string
+	expected    string
+	expectedErr bool
+}
+
+var cmdPatternTestDataArray = []cmdPatternTestData{
+	{pattern: "kubectl get deployment {{.Name}} -n {{.Namespace}}", expected: "kubectl get deployment 'liberty-sample' -n 'default'"},
+	{pattern: "kubectl get pods -l app={{.Labels.app}}", expected: "kubectl get pods -l app='liberty-sample'"},
+	{pattern: `echo {{index .Labels "team"}}`, expected: `echo 'web tier'`},
+	{pattern: `echo {{index .Labels "quote"}}`, expected: `echo 'it'"'"'s "here"'`},
+	{pattern: `echo {{.Annotations.note}}`, expected: `echo '$(rm -rf /); echo pwned'`},
+	{pattern: "kubectl logs deployment/{{.Name}} -c {{index .Containers 0}}", expected: "kubectl logs deployment/'liberty-sample' -c 'liberty-sample'"},
+	{pattern: "kubectl get pods -l version={{.Labels.version}}", expectedErr: true},
+	{pattern: "kubectl get {{.Name", expectedErr: true},
+}
+
+// Return the Liberty Deployment with labels and annotations to escape
+func actionTemplateTestResource(t *testing.T) *resourceInfo {
+	t.Helper()
+	deployment, err := readJSON(deploymentLiberty)
+	if err != nil {
+		t.Fatal(err)
+	}
+	labels := deployment.GetLabels()
+	labels["team"] = "web tier"
+	labels["quote"] = `it's "here"`
+	deployment.SetLabels(labels)
+	deployment.SetAnnotations(map[string]string{"note": "$(rm -rf /); echo pwned"})
+	var resInfo = &resourceInfo{}
+	parseResourceBasic(deployment, resInfo)
+	return resInfo
+}
+
+// Test cmd-pattern templates referencing the fields of a resource, with each value quoted for the shell
+func TestRenderCmdPattern(t *testing.T) {
+	context := newActionTemplateContext(actionTemplateTestResource(t))
+	for _, data := range cmdPatternTestDataArray {
+		cmd, err := renderCmdPattern(data.pattern, context)
+		if data.expectedErr {
+			if err == nil {
+				t.Errorf("pattern %q: expecting error, got %q", data.pattern, cmd)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("pattern %q: unexpected error %s", data.pattern, err)
+			continue
+		}
+		if cmd != data.expected {
+			t.Errorf("pattern %q: expecting %q, got %q", data.pattern, data.expected, cmd)
+		}
+	}
+}
+
+// Test quoted values are passed to the shell as one argument, as is
+func TestShellQuote(t *testing.T) {
+	sh, err := exec.LookPath("sh")
+	if err != nil {
+		t.Skip("no shell")
+	}
+	for _, value := range []string{"web tier", `it's "here"`, "$(echo pwned)", "a;b && c", "'", ""} {
+		out, err := exec.Command(sh, "-c", "printf %s "+shellQuote(value)).Output()
+		if err != nil {
+			t.Fatalf("value %q: %s", value, err)
+		}
+		if string(out) != value {
+			t.Errorf("value %q: expecting the shell to print it as is, got %q", value, string(out))
+		}
+	}
+}
+
+// Test actions whose template fails are left out of the cmd-actions
+func TestCmdActionsJSON(t *testing.T) {
+	resInfo := actionTemplateTestResource(t)
+	if actions := cmdActionsJSON(nil, resInfo); actions != emptyActionsValue {
+		t.Errorf("expecting %s without actions, got %s", emptyActionsValue, actions)
+	}
+
+	actions := cmdActionsJSON([]cmdAction{
+		{Name: "logs", Text: "Logs", CmdPattern: "kubectl logs -n {{.Namespace}} deployment/{{.Name}} && echo {{index .Labels \"team\"}}"},
+		{Name: "version", Text: "Version", CmdPattern: "echo {{.Labels.version}}"},
+	}, resInfo)
+	var rendered []cmdAction
+	if err := json.Unmarshal([]byte(actions), &rendered); err != nil {
+		t.Fatalf("unable to parse cmd-actions %s: %s", actions, err)
+	}
+	if len(rendered) != 1 || rendered[0].Name != "logs" {
+		t.Fatalf("expecting only the logs action, got %s", actions)
+	}
+	expected := "kubectl logs -n 'default' deployment/'liberty-sample' && echo 'web tier'"
+	if rendered[0].CmdPattern != expected {
+		t.Errorf("expecting cmd-pattern %q, got %q", expected, rendered[0].CmdPattern)
+	}
+	if strings.Contains(actions, `\u0026`) {
+		t.Errorf("expecting cmd-actions not HTML escaped, got %s", actions)
+	}
+
+}
+
+// Test the Liberty command actions are rendered against the Deployment, without inputs
+func TestLibertyActions(t *testing.T) {
+	cmdActions, inputs := libertyActions(actionTemplateTestResource(t))
+	if inputs != "" {
+		t.Errorf("expecting no Liberty inputs, got %s", inputs)
+	}
+	var rendered []cmdAction
+	if err := json.Unmarshal([]byte(cmdActions), &rendered); err != nil {
+		t.Fatalf("unable to parse cmd-actions %s: %s", cmdActions, err)
+	}
+	if len(rendered) != len(libertyCmdActions) {
+		t.Fatalf("expecting %d Liberty actions, got %s", len(libertyCmdActions), cmdActions)
+	}
+	expected := "kubectl exec -n 'default' deployment/'liberty-sample' -c 'liberty-sample' -- server dump"
+	if rendered[0].Name != "server-dump" || rendered[0].CmdPattern != expected {
+		t.Errorf("expecting server-dump with cmd-pattern %q, got %s", expected, cmdActions)
+	}
+}
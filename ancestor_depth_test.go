@@ -0,0 +1,82 @@
+/*
+Copyright 2019 IBM Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// create a chain of applications, each a component of the next one
+func newAncestorChain(t *testing.T, length int) (*ClusterWatcher, []*unstructured.Unstructured) {
+	apps := make([]*unstructured.Unstructured, 0, length)
+	for i := 0; i < length; i++ {
+		app := readVersionedJSON(t, appProductpage, fmt.Sprintf("%d", i+1))
+		app.SetName(fmt.Sprintf("level%d", i))
+		app.SetLabels(map[string]string{"level": fmt.Sprintf("%d", i)})
+		componentKinds := []interface{}{map[string]interface{}{GROUP: coreApplicationGVR.Group, KIND: APPLICATION}}
+		if err := unstructured.SetNestedSlice(app.Object, componentKinds, SPEC, COMPONENTKINDS); err != nil {
+			t.Fatal(err)
+		}
+		selector := map[string]string{"level": fmt.Sprintf("%d", i-1)}
+		if err := unstructured.SetNestedStringMap(app.Object, selector, SPEC, "selector", "matchLabels"); err != nil {
+			t.Fatal(err)
+		}
+		apps = append(apps, app)
+	}
+	resController := newAppCacheTestWatcher(t, apps...)
+	resController.groupKindToGVR.Store(coreApplicationGVR.Group+"/"+APPLICATION, coreApplicationGVR)
+	resController.apiVersionKindToGVR.Store(apps[0].GetAPIVersion()+"/"+APPLICATION, coreApplicationGVR)
+	return resController, apps
+}
+
+func TestFindAllApplicationsMaxDepth(t *testing.T) {
+	defer func(depth int) {
+		maxAncestorDepth = depth
+	}(maxAncestorDepth)
+	resController, apps := newAncestorChain(t, 10)
+
+	// no limit
+	maxAncestorDepth = 0
+	found := make(map[string]*resourceInfo)
+	findAllApplicationsForResource(resController, apps[0], found)
+	if len(found) != len(apps) {
+		t.Fatalf("expecting all %d applications of the chain without limit, got %d", len(apps), len(found))
+	}
+
+	// stopped after the maximum number of levels
+	maxAncestorDepth = 5
+	before := testutil.ToFloat64(ancestorDepthExceeded)
+	found = make(map[string]*resourceInfo)
+	findAllApplicationsForResource(resController, apps[0], found)
+	if len(found) != maxAncestorDepth+1 {
+		t.Errorf("expecting the application and %d ancestors, got %d applications", maxAncestorDepth, len(found))
+	}
+	for i, app := range apps {
+		var resInfo = &resourceInfo{}
+		resController.parseResource(app, resInfo)
+		if _, ok := found[resController.resourceKey(resInfo)]; ok != (i <= maxAncestorDepth) {
+			t.Errorf("application %s at level %d found: %t", app.GetName(), i, ok)
+		}
+	}
+	if exceeded := testutil.ToFloat64(ancestorDepthExceeded) - before; exceeded != 1 {
+		t.Errorf("expecting the search to be stopped once, got %v", exceeded)
+	}
+}
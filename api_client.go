@@ -22,6 +22,7 @@ import (
 	"net/http"
 	"net/url"
 	"strings"
+	"time"
 
 	"k8s.io/klog"
 )
@@ -34,6 +35,10 @@ var client = &http.Client{}
 
 /* Call API server to calculate component status */
 func calculateComponentStatus(destURL string, resInfo *resourceInfo) (status string, flyover string, flyoverNLS string, retErr error) {
+	start := time.Now()
+	defer func() {
+		componentStatusDuration.Observe(time.Since(start).Seconds())
+	}()
 
 	status = ""
 	flyover = ""
@@ -75,7 +80,7 @@ func calculateComponentStatus(destURL string, resInfo *resourceInfo) (status str
 		var ok bool
 		tmp, ok = resultMap["value"]
 		if ok {
-			status = tmp.(string)
+			status = statusAtJSONPath(tmp.(string), componentStatusJSONPath)
 		}
 		tmp, ok = resultMap["flyover"]
 		if ok {
@@ -0,0 +1,191 @@
+/*
+Copyright 2019 IBM Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"k8s.io/klog"
+)
+
+/*
+ The availability of the API server is checked periodically. While it is
+ unavailable, the batch processor is paused so that status writes do not
+ fail en masse. Changes keep being batched from the caches, and are
+ processed once the API server is available again.
+*/
+
+const (
+	// path of GET /debug/api-server
+	apiServerHealthPath = "/debug/api-server"
+
+	// DefaultAPIHealthCheckInterval - interval between checks of the availability of the API server
+	DefaultAPIHealthCheckInterval = time.Second * 10
+)
+
+// Availability of the API server
+type apiHealth struct {
+	check     func() error  // returns an error if the API server is not available
+	interval  time.Duration // time between checks
+	available bool
+	since     time.Time     // time of the last change of availability
+	lastError error         // error of the last failed check
+	recovered chan struct{} // closed when the API server is available again. nil while available
+	stopCh    chan struct{}
+	mutex     sync.Mutex
+}
+
+// Response of GET /debug/api-server
+type apiHealthStatus struct {
+	Available bool      `json:"available"`
+	Paused    bool      `json:"paused"` // true if the batch processor is paused
+	Since     time.Time `json:"since"`
+	LastError string    `json:"lastError,omitempty"`
+}
+
+// Create a health check of the API server, assumed available until the first check fails.
+// Return nil if the interval is not positive, to never pause
+func newAPIHealth(check func() error, interval time.Duration) *apiHealth {
+	if interval <= 0 {
+		return nil
+	}
+	apiServerAvailable.Set(1)
+	return &apiHealth{
+		check:     check,
+		interval:  interval,
+		available: true,
+		since:     time.Now(),
+		stopCh:    make(chan struct{}),
+	}
+}
+
+// Start checking the API server periodically
+func (health *apiHealth) start() {
+	if health == nil {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(health.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				health.probe()
+			case <-health.stopCh:
+				return
+			}
+		}
+	}()
+}
+
+// Stop checking the API server
+func (health *apiHealth) stop() {
+	if health == nil {
+		return
+	}
+	health.mutex.Lock()
+	defer health.mutex.Unlock()
+	select {
+	case <-health.stopCh:
+	default:
+		close(health.stopCh)
+	}
+}
+
+// Check the API server once, and record whether it is available
+func (health *apiHealth) probe() {
+	err := health.check()
+
+	health.mutex.Lock()
+	defer health.mutex.Unlock()
+	if err != nil {
+		health.lastError = err
+		if health.available {
+			klog.Errorf("API server unavailable, pausing status processing: %s", err)
+			health.available = false
+			health.since = time.Now()
+			health.recovered = make(chan struct{})
+			apiServerAvailable.Set(0)
+		}
+		return
+	}
+	if !health.available {
+		klog.Infof("API server available again, resuming status processing")
+		health.available = true
+		health.since = time.Now()
+		close(health.recovered)
+		health.recovered = nil
+		apiServerAvailable.Set(1)
+	}
+}
+
+// Return true if the API server is available. Always true without health check
+func (health *apiHealth) isAvailable() bool {
+	if health == nil {
+		return true
+	}
+	health.mutex.Lock()
+	defer health.mutex.Unlock()
+	return health.available
+}
+
+// Return a channel closed once the API server is available again.
+// Return nil, which blocks forever, while it is available
+func (health *apiHealth) recoveredChan() <-chan struct{} {
+	if health == nil {
+		return nil
+	}
+	health.mutex.Lock()
+	defer health.mutex.Unlock()
+	return health.recovered
+}
+
+// Return the availability of the API server
+func (health *apiHealth) status() apiHealthStatus {
+	if health == nil {
+		return apiHealthStatus{Available: true}
+	}
+	health.mutex.Lock()
+	defer health.mutex.Unlock()
+	status := apiHealthStatus{
+		Available: health.available,
+		Paused:    !health.available,
+		Since:     health.since,
+	}
+	if health.lastError != nil {
+		status.LastError = health.lastError.Error()
+	}
+	return status
+}
+
+// Handler for GET /debug/api-server
+func apiHealthHandler(resController *ClusterWatcher) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", http.MethodGet)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(resController.apiHealth.status()); err != nil && klog.V(2) {
+			klog.Infof("apiHealth unable to write response: %s", err)
+		}
+	}
+}
@@ -0,0 +1,152 @@
+/*
+Copyright 2019 IBM Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// API server whose availability is controlled by the test
+type fakeAPIServer struct {
+	err   error
+	mutex sync.Mutex
+}
+
+func (server *fakeAPIServer) setError(err error) {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	server.err = err
+}
+
+func (server *fakeAPIServer) check() error {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	return server.err
+}
+
+// wait until the availability of the API server is as expected
+func waitAPIAvailable(t *testing.T, health *apiHealth, expected bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second * 5)
+	for health.isAvailable() != expected {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for API server available to be %t", expected)
+		}
+		time.Sleep(time.Millisecond * 5)
+	}
+}
+
+// get the availability of the API server from its handler
+func getAPIHealthStatus(t *testing.T, resController *ClusterWatcher) apiHealthStatus {
+	t.Helper()
+	recorder := httptest.NewRecorder()
+	apiHealthHandler(resController)(recorder, httptest.NewRequest(http.MethodGet, apiServerHealthPath, nil))
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("expecting status code %d, got %d", http.StatusOK, recorder.Code)
+	}
+	var status apiHealthStatus
+	if err := json.Unmarshal(recorder.Body.Bytes(), &status); err != nil {
+		t.Fatal(err)
+	}
+	return status
+}
+
+func TestBatchStorePausedWhileAPIUnavailable(t *testing.T) {
+	server := &fakeAPIServer{}
+	health := newAPIHealth(server.check, time.Millisecond*10)
+	health.start()
+	defer health.stop()
+
+	resController := &ClusterWatcher{
+		resourceChannel: newResourceChannel(),
+		apiHealth:       health,
+	}
+	defer resController.resourceChannel.close()
+	ts := newBatchStore(resController, time.Millisecond*10)
+
+	// API server goes down
+	server.setError(fmt.Errorf("connection refused"))
+	waitAPIAvailable(t, health, false)
+	status := getAPIHealthStatus(t, resController)
+	if status.Available || !status.Paused || status.LastError != "connection refused" {
+		t.Errorf("expecting paused status with last error, got %+v", status)
+	}
+	if value := testutil.ToFloat64(apiServerAvailable); value != 0 {
+		t.Errorf("expecting api_server_available 0, got %v", value)
+	}
+
+	// events are buffered while paused
+	gvr := schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "deployments"}
+	for _, name := range []string{"details", "ratings", "reviews"} {
+		resInfo := &resourceInfo{gvr: gvr, kind: "Deployment", namespace: "default", name: name}
+		resController.resourceChannel.send(&batchResources{
+			applications:    map[string]*resourceInfo{},
+			nonApplications: map[string]*resourceInfo{resInfo.key(): resInfo},
+		})
+	}
+	batches := make(chan *batchResources, 1)
+	go func() {
+		if resources, ok := ts.getNextBatch(); ok {
+			batches <- resources
+		}
+	}()
+	select {
+	case <-batches:
+		t.Fatal("expecting no batch while the API server is unavailable")
+	case <-time.After(time.Millisecond * 200):
+	}
+
+	// the buffered events are processed once the API server recovers
+	server.setError(nil)
+	waitAPIAvailable(t, health, true)
+	select {
+	case resources := <-batches:
+		if len(resources.nonApplications) != 3 {
+			t.Errorf("expecting 3 resources in batch, got %d", len(resources.nonApplications))
+		}
+	case <-time.After(time.Second * 5):
+		t.Fatal("timed out waiting for batch after the API server recovered")
+	}
+	status = getAPIHealthStatus(t, resController)
+	if !status.Available || status.Paused {
+		t.Errorf("expecting available status, got %+v", status)
+	}
+	if value := testutil.ToFloat64(apiServerAvailable); value != 1 {
+		t.Errorf("expecting api_server_available 1, got %v", value)
+	}
+}
+
+func TestAPIHealthDisabled(t *testing.T) {
+	health := newAPIHealth(func() error { return fmt.Errorf("connection refused") }, 0)
+	if health != nil {
+		t.Fatal("expecting no health check with an interval of 0")
+	}
+	health.start()
+	defer health.stop()
+	if !health.isAvailable() || health.recoveredChan() != nil {
+		t.Error("expecting API server available without health check")
+	}
+}
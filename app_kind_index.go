@@ -0,0 +1,123 @@
+/*
+Copyright 2019 IBM Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"sort"
+	"sync"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+/*
+ Index from component kind to the applications that include the kind, so
+ that a resource is only matched against the applications that may select
+ it. The index is on the kind alone, so the candidates include applications
+ of kinds of the same name in other groups. Those are told apart when the
+ resource is matched, against the resolved groups of the component kinds.
+ The kinds are read from spec.componentKinds and spec.components as is, not
+ resolved, so that the candidates are the same whether or not the kinds are
+ known yet.
+*/
+
+// Applications including each component kind, keyed as the events of the application informer
+type appKindIndex struct {
+	apps  map[string]map[string]struct{} // kind to keys of applications
+	kinds map[string][]string            // key of application to its kinds
+	mutex sync.Mutex
+}
+
+func newAppKindIndex() *appKindIndex {
+	return &appKindIndex{
+		apps:  make(map[string]map[string]struct{}),
+		kinds: make(map[string][]string),
+	}
+}
+
+// Return the component kinds of an application from its spec, including the kinds of listed components
+func componentKindsOf(unstructuredObj *unstructured.Unstructured) []string {
+	componentKinds, _, _ := unstructured.NestedSlice(unstructuredObj.Object, SPEC, COMPONENTKINDS)
+	components, _, _ := unstructured.NestedSlice(unstructuredObj.Object, SPEC, COMPONENTS)
+	ret := make([]string, 0, len(componentKinds)+len(components))
+	seen := make(map[string]bool)
+	for _, entry := range append(componentKinds, components...) {
+		kindMap, ok := entry.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if kind, _ := kindMap[KIND].(string); kind != "" && !seen[kind] {
+			seen[kind] = true
+			ret = append(ret, kind)
+		}
+	}
+	return ret
+}
+
+// Index the component kinds of an application, replacing those of its previous version
+func (index *appKindIndex) set(key string, unstructuredObj *unstructured.Unstructured) {
+	if index == nil {
+		return
+	}
+	kinds := componentKindsOf(unstructuredObj)
+	index.mutex.Lock()
+	defer index.mutex.Unlock()
+	index.removeLocked(key)
+	for _, kind := range kinds {
+		keys, ok := index.apps[kind]
+		if !ok {
+			keys = make(map[string]struct{})
+			index.apps[kind] = keys
+		}
+		keys[key] = struct{}{}
+	}
+	index.kinds[key] = kinds
+}
+
+// Remove a deleted application
+func (index *appKindIndex) remove(key string) {
+	if index == nil {
+		return
+	}
+	index.mutex.Lock()
+	defer index.mutex.Unlock()
+	index.removeLocked(key)
+}
+
+// Remove an application. Must be called with the mutex held
+func (index *appKindIndex) removeLocked(key string) {
+	for _, kind := range index.kinds[key] {
+		keys := index.apps[kind]
+		delete(keys, key)
+		if len(keys) == 0 {
+			delete(index.apps, kind)
+		}
+	}
+	delete(index.kinds, key)
+}
+
+// Return the keys of the applications including the kind, sorted
+func (index *appKindIndex) candidates(kind string) []string {
+	index.mutex.Lock()
+	defer index.mutex.Unlock()
+	keys := index.apps[kind]
+	ret := make([]string, 0, len(keys))
+	for key := range keys {
+		ret = append(ret, key)
+	}
+	sort.Strings(ret)
+	return ret
+}
@@ -0,0 +1,135 @@
+/*
+Copyright 2019 IBM Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// return the sorted keys of applications
+func appKeys(apps []*appResourceInfo) []string {
+	ret := make([]string, 0, len(apps))
+	for _, app := range apps {
+		ret = append(ret, app.namespace+"/"+app.name)
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+func TestAppKindIndex(t *testing.T) {
+	index := newAppKindIndex()
+	app := readVersionedJSON(t, appProductpage, "1")
+	index.set("default/productpage-app", app)
+	for _, kind := range []string{"Service", "Deployment", "StatefulSet"} {
+		if keys := index.candidates(kind); len(keys) != 1 || keys[0] != "default/productpage-app" {
+			t.Errorf("expecting productpage-app for kind %s, got %v", kind, keys)
+		}
+	}
+
+	// kinds replaced on update
+	kinds := []interface{}{map[string]interface{}{GROUP: "", KIND: "ConfigMap"}}
+	if err := unstructured.SetNestedSlice(app.Object, kinds, SPEC, COMPONENTKINDS); err != nil {
+		t.Fatal(err)
+	}
+	index.set("default/productpage-app", app)
+	if keys := index.candidates("Deployment"); len(keys) != 0 {
+		t.Errorf("expecting no application for kind Deployment once removed, got %v", keys)
+	}
+	if keys := index.candidates("ConfigMap"); len(keys) != 1 {
+		t.Errorf("expecting productpage-app for kind ConfigMap once added, got %v", keys)
+	}
+
+	// kinds of listed components
+	components := []interface{}{map[string]interface{}{GROUP: "apps", KIND: "Deployment", NAME: "details"}}
+	if err := unstructured.SetNestedSlice(app.Object, components, SPEC, COMPONENTS); err != nil {
+		t.Fatal(err)
+	}
+	index.set("default/productpage-app", app)
+	for _, kind := range []string{"ConfigMap", "Deployment"} {
+		if keys := index.candidates(kind); len(keys) != 1 || keys[0] != "default/productpage-app" {
+			t.Errorf("expecting productpage-app for kind %s, got %v", kind, keys)
+		}
+	}
+
+	index.remove("default/productpage-app")
+	if len(index.apps) != 0 || len(index.kinds) != 0 {
+		t.Errorf("expecting empty index once the application is deleted, got %v", index.apps)
+	}
+}
+
+// Test looking up applications in the index finds the same as checking every application
+func TestAppKindIndexMatchesLinearScan(t *testing.T) {
+	random := rand.New(rand.NewSource(767))
+	kinds := []string{"Service", "Deployment", "StatefulSet", "ConfigMap"}
+	groups := map[string]string{"Service": "", "Deployment": "apps", "StatefulSet": "apps", "ConfigMap": ""}
+	namespaces := []string{"default", "other"}
+	labels := []string{"a", "b", "c"}
+
+	apps := make([]*unstructured.Unstructured, 0)
+	for i := 0; i < 50; i++ {
+		app := readVersionedJSON(t, appProductpage, fmt.Sprintf("%d", i+1))
+		app.SetName(fmt.Sprintf("app%d", i))
+		app.SetNamespace(namespaces[random.Intn(len(namespaces))])
+		app.SetLabels(map[string]string{"app": labels[random.Intn(len(labels))]})
+		componentKinds := make([]interface{}, 0)
+		for _, kind := range kinds {
+			if random.Intn(2) == 0 {
+				componentKinds = append(componentKinds, map[string]interface{}{GROUP: groups[kind], KIND: kind})
+			}
+		}
+		if err := unstructured.SetNestedSlice(app.Object, componentKinds, SPEC, COMPONENTKINDS); err != nil {
+			t.Fatal(err)
+		}
+		selector := map[string]string{"app": labels[random.Intn(len(labels))]}
+		if err := unstructured.SetNestedStringMap(app.Object, selector, SPEC, "selector", "matchLabels"); err != nil {
+			t.Fatal(err)
+		}
+		apps = append(apps, app)
+	}
+
+	linear := newAppCacheTestWatcher(t, apps...)
+	indexed := newAppCacheTestWatcher(t, apps...)
+	for _, resController := range []*ClusterWatcher{linear, indexed} {
+		resController.groupKindToGVR.Store("/Service", coreServiceGVR)
+		resController.groupKindToGVR.Store("apps/StatefulSet", coreStatefulSetGVR)
+		resController.groupKindToGVR.Store("/ConfigMap", coreConfigMapGVR)
+	}
+	indexed.appKinds = newAppKindIndex()
+	for _, app := range apps {
+		indexed.appKinds.set(appResourceKey(app), app)
+	}
+
+	for i := 0; i < 200; i++ {
+		kind := kinds[random.Intn(len(kinds))]
+		var resInfo = &resourceInfo{
+			kind:      kind,
+			namespace: namespaces[random.Intn(len(namespaces))],
+			name:      fmt.Sprintf("resource%d", i),
+			labels:    map[string]string{"app": labels[random.Intn(len(labels))]},
+		}
+		expected := appKeys(getApplicationsForResource(linear, resInfo))
+		found := appKeys(getApplicationsForResource(indexed, resInfo))
+		if fmt.Sprint(expected) != fmt.Sprint(found) {
+			t.Errorf("%s %s/%s with labels %v: expecting applications %v, got %v", kind, resInfo.namespace, resInfo.name, resInfo.labels, expected, found)
+		}
+	}
+}
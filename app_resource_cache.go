@@ -0,0 +1,114 @@
+/*
+Copyright 2019 IBM Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"sync"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+/*
+ Every resource event checks the resource against all applications. The
+ parsed applications are kept across batches, keyed by application and
+ resourceVersion, so that an unchanged application is not parsed again.
+ An entry is dropped when the application handler sees the application
+ change, and replaced if a newer resourceVersion is listed first.
+*/
+
+// Parsed applications, keyed the same way as the events of the application informer
+type appResourceCache struct {
+	entries map[string]*cachedAppResource
+	mutex   sync.Mutex
+}
+
+type cachedAppResource struct {
+	resourceVersion string
+	appResInfo      *appResourceInfo
+}
+
+func newAppResourceCache() *appResourceCache {
+	return &appResourceCache{entries: make(map[string]*cachedAppResource)}
+}
+
+// Return the key of an application, namespace/name as for the events of its informer
+func appResourceKey(unstructuredObj *unstructured.Unstructured) string {
+	if unstructuredObj.GetNamespace() == "" {
+		return unstructuredObj.GetName()
+	}
+	return unstructuredObj.GetNamespace() + "/" + unstructuredObj.GetName()
+}
+
+// Return the parsed application at the resourceVersion, or nil if not cached
+func (cache *appResourceCache) get(key string, resourceVersion string) *appResourceInfo {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+	entry, ok := cache.entries[key]
+	if !ok || entry.resourceVersion != resourceVersion {
+		return nil
+	}
+	return entry.appResInfo
+}
+
+// Keep a parsed application, replacing the one of any other resourceVersion
+func (cache *appResourceCache) add(key string, resourceVersion string, appResInfo *appResourceInfo) {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+	cache.entries[key] = &cachedAppResource{resourceVersion: resourceVersion, appResInfo: appResInfo}
+}
+
+// Drop the parsed application, once it changed or was deleted
+func (cache *appResourceCache) remove(key string) {
+	if cache == nil {
+		return
+	}
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+	delete(cache.entries, key)
+}
+
+// Return the number of parsed applications
+func (cache *appResourceCache) size() int {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+	return len(cache.entries)
+}
+
+// parseAppResourceCached parses an application, reusing what was already parsed
+// for the same resourceVersion. The returned structure may be the cached one, and
+// must be copied before it is changed.
+// Malformed applications and applications without resourceVersion are not cached
+func (resController *ClusterWatcher) parseAppResourceCached(unstructuredObj *unstructured.Unstructured) (*appResourceInfo, error) {
+	cache := resController.appResources
+	resourceVersion := unstructuredObj.GetResourceVersion()
+	if cache == nil || resourceVersion == "" {
+		var appResInfo = &appResourceInfo{}
+		return appResInfo, resController.parseAppResource(unstructuredObj, appResInfo)
+	}
+	key := appResourceKey(unstructuredObj)
+	if cached := cache.get(key, resourceVersion); cached != nil {
+		return cached, nil
+	}
+	var appResInfo = &appResourceInfo{}
+	err := resController.parseAppResource(unstructuredObj, appResInfo)
+	if isFatalParseError(err) {
+		return appResInfo, err
+	}
+	// malformed fields are left out. The rest is still usable
+	cache.add(key, resourceVersion, appResInfo)
+	return appResInfo, err
+}
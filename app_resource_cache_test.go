@@ -0,0 +1,141 @@
+/*
+Copyright 2019 IBM Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/tools/cache"
+)
+
+// create a controller watching the given applications, with Deployments as a known component kind
+func newAppCacheTestWatcher(t testing.TB, apps ...*unstructured.Unstructured) *ClusterWatcher {
+	resController := &ClusterWatcher{
+		resourceMap:  make(map[schema.GroupVersionResource]*ResourceWatcher),
+		appResources: newAppResourceCache(),
+	}
+	resController.groupKindToGVR.Store("apps/Deployment", coreDeploymentGVR)
+	rw := &ResourceWatcher{GroupVersionResource: coreApplicationGVR, store: cache.NewStore(cache.MetaNamespaceKeyFunc)}
+	for _, app := range apps {
+		if err := rw.store.Add(app); err != nil {
+			t.Fatal(err)
+		}
+	}
+	resController.resourceMap[coreApplicationGVR] = rw
+	return resController
+}
+
+// find the applications of a resource, and return the number of resources parsed
+func countAppParses(resController *ClusterWatcher, resInfo *resourceInfo) ([]*appResourceInfo, float64) {
+	before := testutil.ToFloat64(resourceParses)
+	apps := getApplicationsForResource(resController, resInfo)
+	return apps, testutil.ToFloat64(resourceParses) - before
+}
+
+func TestAppResourceCache(t *testing.T) {
+	app := readVersionedJSON(t, appProductpage, "100")
+	resController := newAppCacheTestWatcher(t, app)
+	var deployment = &resourceInfo{}
+	resController.parseResource(readVersionedJSON(t, deploymentProcuctpageV1, "1"), deployment)
+
+	// parsed once
+	apps, parses := countAppParses(resController, deployment)
+	if len(apps) != 1 || apps[0].name != app.GetName() || parses != 1 {
+		t.Fatalf("expecting application %s parsed once, got %d applications and %v parses", app.GetName(), len(apps), parses)
+	}
+	apps, parses = countAppParses(resController, deployment)
+	if len(apps) != 1 || parses != 0 {
+		t.Errorf("expecting cached application, got %d applications and %v parses", len(apps), parses)
+	}
+
+	// applications found are copies
+	apps[0].name = "changed"
+	if apps, _ = countAppParses(resController, deployment); len(apps) != 1 || apps[0].name != app.GetName() {
+		t.Errorf("expecting cached application unchanged by callers")
+	}
+
+	// stale entry replaced once the application is updated
+	updated := readVersionedJSON(t, appProductpage, "101")
+	if err := unstructured.SetNestedStringMap(updated.Object, map[string]string{"app": "other"}, "spec", "selector", "matchLabels"); err != nil {
+		t.Fatal(err)
+	}
+	rw := resController.getResourceWatcher(coreApplicationGVR)
+	if err := rw.store.Update(updated); err != nil {
+		t.Fatal(err)
+	}
+	apps, parses = countAppParses(resController, deployment)
+	if len(apps) != 0 || parses != 1 {
+		t.Errorf("expecting updated application parsed again and no longer selecting %s, got %d applications and %v parses", deployment.name, len(apps), parses)
+	}
+	key := appResourceKey(updated)
+	if resController.appResources.get(key, "100") != nil || resController.appResources.get(key, "101") == nil {
+		t.Error("expecting stale entry of the application evicted")
+	}
+
+	// dropped when the application handler sees a change
+	resController.appResources.remove(key)
+	if size := resController.appResources.size(); size != 0 {
+		t.Errorf("expecting no cached application after it changed, got %d", size)
+	}
+
+	// not cached without resourceVersion
+	unversioned := newAppCacheTestWatcher(t, readVersionedJSON(t, appProductpage, ""))
+	countAppParses(unversioned, deployment)
+	if _, parses = countAppParses(unversioned, deployment); parses != 1 {
+		t.Errorf("expecting application without resourceVersion parsed every time, got %v parses", parses)
+	}
+}
+
+// create a controller watching the given number of applications, and a component of one of them
+func newAppCacheBenchmark(b *testing.B, count int, cached bool) (*ClusterWatcher, *resourceInfo) {
+	apps := make([]*unstructured.Unstructured, 0, count)
+	for i := 0; i < count; i++ {
+		app := readVersionedJSON(b, appProductpage, "100")
+		app.SetName(fmt.Sprintf("app%d", i))
+		apps = append(apps, app)
+	}
+	resController := newAppCacheTestWatcher(b, apps...)
+	if !cached {
+		resController.appResources = nil
+	}
+	var deployment = &resourceInfo{}
+	resController.parseResource(readVersionedJSON(b, deploymentProcuctpageV1, "1"), deployment)
+	return resController, deployment
+}
+
+// Benchmark finding the applications of a resource event among 100 applications
+func BenchmarkGetApplicationsForResource(b *testing.B) {
+	resController, deployment := newAppCacheBenchmark(b, 100, false)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		getApplicationsForResource(resController, deployment)
+	}
+}
+
+func BenchmarkGetApplicationsForResourceCached(b *testing.B) {
+	resController, deployment := newAppCacheBenchmark(b, 100, true)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		getApplicationsForResource(resController, deployment)
+	}
+}
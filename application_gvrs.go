@@ -0,0 +1,138 @@
+/*
+Copyright 2019 IBM Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+/*
+ During a migration of the Application CRD, applications may be served at
+ more than one version, e.g. v1beta1 and v1. Besides coreApplicationGVR, the
+ controller watches the versions of -applicationVersions, and treats the
+ applications of all versions uniformly: an application read at any version
+ is parsed with the GVR of coreApplicationGVR, so that it has the same key in
+ the batches, caches and queues, and its status is computed and written once.
+ Applications are listed from each version, the first version that has an
+ application winning.
+*/
+
+// GVRs of the applications besides coreApplicationGVR, from -applicationVersions
+var additionalApplicationGVRs []schema.GroupVersionResource
+
+// Parse comma separated versions of the applications, e.g. v1, into their GVRs.
+// The version of coreApplicationGVR is always watched, and is left out
+func parseApplicationVersions(value string) ([]schema.GroupVersionResource, error) {
+	var gvrs []schema.GroupVersionResource
+	seen := make(map[string]bool)
+	for _, version := range strings.Split(value, ",") {
+		version = strings.TrimSpace(version)
+		if version == "" {
+			continue
+		}
+		if strings.Contains(version, "/") {
+			return nil, fmt.Errorf("%s is not a version of %s. Expecting e.g. v1", version, coreApplicationGVR.GroupResource())
+		}
+		if version == coreApplicationGVR.Version || seen[version] {
+			continue
+		}
+		seen[version] = true
+		gvrs = append(gvrs, schema.GroupVersionResource{Group: coreApplicationGVR.Group, Version: version, Resource: coreApplicationGVR.Resource})
+	}
+	return gvrs, nil
+}
+
+// Return the GVRs of the applications, coreApplicationGVR first
+func applicationGVRs() []schema.GroupVersionResource {
+	return append([]schema.GroupVersionResource{coreApplicationGVR}, additionalApplicationGVRs...)
+}
+
+// Return true if the GVR is one of the versions of the applications
+func isApplicationGVR(gvr schema.GroupVersionResource) bool {
+	for _, appGVR := range applicationGVRs() {
+		if gvr == appGVR {
+			return true
+		}
+	}
+	return false
+}
+
+// List the applications in the caches of all versions, each application once
+func (resController *ClusterWatcher) listApplications() []interface{} {
+	if len(additionalApplicationGVRs) == 0 {
+		return resController.listResources(coreApplicationGVR)
+	}
+	var ret = make([]interface{}, 0)
+	seen := make(map[string]bool)
+	for _, gvr := range applicationGVRs() {
+		for _, obj := range resController.listResources(gvr) {
+			unstructuredObj, ok := obj.(*unstructured.Unstructured)
+			if !ok {
+				continue
+			}
+			key := unstructuredObj.GetNamespace() + "/" + unstructuredObj.GetName()
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			ret = append(ret, obj)
+		}
+	}
+	return ret
+}
+
+// Get an application from the cache of the first version that has it, by its namespace/name key
+func (resController *ClusterWatcher) getApplicationByKey(key string) (interface{}, bool) {
+	for _, gvr := range applicationGVRs() {
+		rw := resController.getResourceWatcher(gvr)
+		if rw == nil || rw.store == nil {
+			continue
+		}
+		if obj, exists, err := rw.store.GetByKey(key); err == nil && exists {
+			return obj, true
+		}
+	}
+	return nil, false
+}
+
+// Get an application from the cache of the first version that has it.
+// Return an error if no version of the applications is watched
+func (resController *ClusterWatcher) getApplication(namespace string, name string) (interface{}, bool, error) {
+	obj, exists, err := resController.getResource(coreApplicationGVR, namespace, name)
+	if exists || len(additionalApplicationGVRs) == 0 {
+		return obj, exists, err
+	}
+	if obj, exists = resController.getApplicationByKey(namespace + "/" + name); exists {
+		return obj, true, nil
+	}
+	return nil, false, err
+}
+
+// Return the GVR of the first version whose cache has the application, so that it is written
+// at a version it is served at. Default to the watched GVR of coreApplicationGVR
+func (resController *ClusterWatcher) getApplicationGVR(namespace string, name string) (schema.GroupVersionResource, bool) {
+	for _, gvr := range applicationGVRs() {
+		if _, exists, err := resController.getResource(gvr, namespace, name); err == nil && exists {
+			return gvr, true
+		}
+	}
+	return resController.getWatchGVR(coreApplicationGVR)
+}
@@ -0,0 +1,133 @@
+/*
+Copyright 2019 IBM Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/tools/cache"
+)
+
+const (
+	versionedAppV1beta1 = "test_data/versioned-app-v1beta1.json"
+	versionedAppV1      = "test_data/versioned-app-v1.json"
+)
+
+var coreApplicationV1GVR = schema.GroupVersionResource{Group: "app.k8s.io", Version: "v1", Resource: "applications"}
+
+func TestParseApplicationVersions(t *testing.T) {
+	var tests = []struct {
+		value    string
+		expected []schema.GroupVersionResource
+		err      bool
+	}{
+		{"", nil, false},
+		{"v1", []schema.GroupVersionResource{coreApplicationV1GVR}, false},
+		// the version of coreApplicationGVR and duplicates are left out
+		{" v1beta1, v1 ,v1", []schema.GroupVersionResource{coreApplicationV1GVR}, false},
+		{"app.k8s.io/v1", nil, true},
+	}
+	for _, test := range tests {
+		gvrs, err := parseApplicationVersions(test.value)
+		if (err != nil) != test.err {
+			t.Errorf("parseApplicationVersions %q: expecting error %t, got %v", test.value, test.err, err)
+			continue
+		}
+		if !reflect.DeepEqual(gvrs, test.expected) {
+			t.Errorf("parseApplicationVersions %q: expecting %v, got %v", test.value, test.expected, gvrs)
+		}
+	}
+}
+
+// Test an application parsed from either version of the Application CRD is the same application
+func TestParseAppResourceAcrossVersions(t *testing.T) {
+	saved := additionalApplicationGVRs
+	defer func() { additionalApplicationGVRs = saved }()
+	additionalApplicationGVRs = []schema.GroupVersionResource{coreApplicationV1GVR}
+
+	var resController = &ClusterWatcher{}
+	initControllerMaps(resController)
+	resController.apiVersionKindToGVR.Store("app.k8s.io/v1/Application", coreApplicationV1GVR)
+
+	var appInfos []*appResourceInfo
+	for _, file := range []string{versionedAppV1beta1, versionedAppV1} {
+		appObj, err := readJSON(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var appInfo = &appResourceInfo{}
+		if err := resController.parseAppResource(appObj, appInfo); err != nil {
+			t.Fatalf("%s: %s", file, err)
+		}
+		if appInfo.gvr != coreApplicationGVR {
+			t.Errorf("%s: expecting GVR %s, got %s", file, coreApplicationGVR, appInfo.gvr)
+		}
+		appInfos = append(appInfos, appInfo)
+	}
+
+	beta, v1 := appInfos[0], appInfos[1]
+	if resController.resourceKey(&beta.resourceInfo) != resController.resourceKey(&v1.resourceInfo) {
+		t.Errorf("expecting the same key, got %s and %s", resController.resourceKey(&beta.resourceInfo), resController.resourceKey(&v1.resourceInfo))
+	}
+	if !isSameResource(&beta.resourceInfo, &v1.resourceInfo) {
+		t.Errorf("expecting the same application, got %s %s/%s and %s %s/%s", beta.gvr, beta.namespace, beta.name, v1.gvr, v1.namespace, v1.name)
+	}
+	if !reflect.DeepEqual(beta.componentKinds, v1.componentKinds) {
+		t.Errorf("expecting the same component kinds, got %v and %v", beta.componentKinds, v1.componentKinds)
+	}
+	if !reflect.DeepEqual(beta.matchLabels, v1.matchLabels) || !reflect.DeepEqual(beta.matchExpressions, v1.matchExpressions) {
+		t.Errorf("expecting the same selector, got %v %v and %v %v", beta.matchLabels, beta.matchExpressions, v1.matchLabels, v1.matchExpressions)
+	}
+	if !isApplicationGVR(coreApplicationV1GVR) {
+		t.Errorf("expecting %s to be an application GVR", coreApplicationV1GVR)
+	}
+}
+
+// Test an application is read and written at the version it is cached at
+func TestGetApplicationGVR(t *testing.T) {
+	saved := additionalApplicationGVRs
+	defer func() { additionalApplicationGVRs = saved }()
+	additionalApplicationGVRs = []schema.GroupVersionResource{coreApplicationV1GVR}
+
+	appObj, err := readJSON(versionedAppV1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v1Store := cache.NewStore(cache.MetaNamespaceKeyFunc)
+	if err = v1Store.Add(appObj); err != nil {
+		t.Fatal(err)
+	}
+	var resController = &ClusterWatcher{
+		resourceMap: map[schema.GroupVersionResource]*ResourceWatcher{
+			coreApplicationGVR:   {GroupVersionResource: coreApplicationGVR, store: cache.NewStore(cache.MetaNamespaceKeyFunc)},
+			coreApplicationV1GVR: {GroupVersionResource: coreApplicationV1GVR, store: v1Store},
+		},
+	}
+	initControllerMaps(resController)
+
+	if gvr, ok := resController.getApplicationGVR(appObj.GetNamespace(), appObj.GetName()); !ok || gvr != coreApplicationV1GVR {
+		t.Errorf("expecting %s for the application cached at v1, got %s %t", coreApplicationV1GVR, gvr, ok)
+	}
+	if gvr, ok := resController.getApplicationGVR(appObj.GetNamespace(), "unknown-app"); !ok || gvr != coreApplicationGVR {
+		t.Errorf("expecting %s for an application not cached, got %s %t", coreApplicationGVR, gvr, ok)
+	}
+	if _, exists, err := resController.getApplication(appObj.GetNamespace(), appObj.GetName()); err != nil || !exists {
+		t.Errorf("expecting the application cached at v1 to be found, got %t %v", exists, err)
+	}
+}
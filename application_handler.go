@@ -18,11 +18,16 @@ package main
 
 import (
 	"fmt"
+	"strconv"
 	"strings"
 
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/tools/cache"
 	"k8s.io/klog"
 )
 
@@ -55,16 +60,69 @@ func sameLabels(labels1 map[string]string, labels2 map[string]string) bool {
 	return true
 }
 
+// Return true if the annotation is written by the controller with the status of the resource
+func isStatusAnnotation(key string) bool {
+	switch key {
+	case statusAnnotationKey(), kappnavStatusFlyover, kappnavStatusFlyoverNls, kappnavStatusComponentGroups, kappnavStatusAvailability:
+		return true
+	}
+	return false
+}
+
+// Return true if both have the same annotations, other than those written with the status.
+// Annotations whose value is not a string are left out, as selectors never match them
+func sameSelectableAnnotations(annotations1 map[string]interface{}, annotations2 map[string]interface{}) bool {
+	count := 0
+	for key, val1 := range annotations1 {
+		str1, ok := val1.(string)
+		if !ok || isStatusAnnotation(key) {
+			continue
+		}
+		if str2, ok := annotations2[key].(string); !ok || str1 != str2 {
+			return false
+		}
+		count++
+	}
+	for key, val2 := range annotations2 {
+		if _, ok := val2.(string); ok && !isStatusAnnotation(key) {
+			count--
+		}
+	}
+	return count == 0
+}
+
+// Return true if an update of an application may change its parent applications or their status:
+// a change of its labels, of its annotations, of whether it is top-level, or of its status
+func parentsAffectedByUpdate(oldResInfo *resourceInfo, newResInfo *resourceInfo) bool {
+	return !sameLabels(oldResInfo.labels, newResInfo.labels) ||
+		!sameSelectableAnnotations(oldResInfo.annotations, newResInfo.annotations) ||
+		oldResInfo.isTopLevel() != newResInfo.isTopLevel() ||
+		oldResInfo.kappnavStatVal != newResInfo.kappnavStatVal
+}
+
+// Count an evaluation of a selector, and whether it matched
+func countSelectorEvaluation(evaluations prometheus.Counter, matches prometheus.Counter, matched bool) {
+	evaluations.Inc()
+	if matched {
+		matches.Inc()
+	}
+}
+
 // Return true if the labels defined in matchLabels also are defined in labels
 // matchLabels: match labels defined in the application
 // labels: labels in the resource
 // Return false if matchLabels is nil or empty
-func labelsMatch(matchLabels map[string]string, labels map[string]string) bool {
-	if klog.V(5) {
+func labelsMatch(matchLabels map[string]string, labels map[string]string) (matched bool) {
+	if selectorMetrics {
+		defer func() { countSelectorEvaluation(labelsMatchEvaluations, labelsMatchMatches, matched) }()
+	}
+	// check level once, and format arguments only if enabled
+	var logEnabled = klog.V(5)
+	if logEnabled {
 		klog.Infof("labelsMatch: matchLabels %s, labels: %s\n", matchLabels, labels)
 	}
 	if matchLabels == nil || len(matchLabels) == 0 {
-		if klog.V(5) {
+		if logEnabled {
 			klog.Infof("labelsMatch: false\n")
 		}
 		return false
@@ -72,29 +130,31 @@ func labelsMatch(matchLabels map[string]string, labels map[string]string) bool {
 	for key, val := range matchLabels {
 		otherVal, ok := labels[key]
 		if !ok {
-			if klog.V(5) {
+			if logEnabled {
 				klog.Infof("labelsMatch: false\n")
 			}
 			return false
 		}
 		if strings.Compare(val, otherVal) != 0 {
-			if klog.V(5) {
+			if logEnabled {
 				klog.Infof("labelsMatch: false\n")
 			}
 			return false
 		}
 	}
 	// everything match
-	if klog.V(5) {
+	if logEnabled {
 		klog.Infof("labelsMatch: true\n")
 	}
 	return true
 }
 
-// Return true if input kind is contained in array of groupKind
-func isContainedIn(arr []groupKind, kind string) bool {
+// Return true if the group and kind are one of the component kinds. The group is compared
+// with that of the GVR each component kind resolved to, so that kinds of the same name in
+// different API groups are told apart
+func isComponentKind(arr []groupKind, group string, kind string) bool {
 	for _, gk := range arr {
-		if strings.Compare(gk.kind, kind) == 0 {
+		if gk.kind == kind && gk.gvr.Group == group {
 			return true
 		}
 	}
@@ -113,23 +173,44 @@ func isContainedInStringArray(arr []string, inStr string) bool {
 
 // Return true if labels match the given expressions
 // Return false if expressions is nil or empty
-func expressionsMatch(expressions []matchExpression, labels map[string]string) bool {
-	if klog.V(5) {
-		klog.Infof("expressionsMatch: expressions: %s len:%d, labels: %s\n", expressions, len(expressions), labels)
+func expressionsMatch(expressions []matchExpression, labels map[string]string) (matched bool) {
+	return expressionsMatchAnnotations(expressions, labels, nil)
+}
+
+// Return true if labels, and annotations for expressions whose source is annotation, match the given expressions
+// Return false if expressions is nil or empty
+func expressionsMatchAnnotations(expressions []matchExpression, labels map[string]string, annotations map[string]interface{}) (matched bool) {
+	if selectorMetrics {
+		defer func() { countSelectorEvaluation(expressionsMatchEvaluations, expressionsMatchMatches, matched) }()
+	}
+	// check level once, and format arguments only if enabled
+	var logEnabled = klog.V(5)
+	if logEnabled {
+		klog.Infof("expressionsMatch: expressions: %s len:%d, labels: %s, annotations: %s\n", expressions, len(expressions), labels, annotations)
 	}
 	if expressions == nil || len(expressions) == 0 {
-		if klog.V(5) {
+		if logEnabled {
 			klog.Info("expressionsMatch: nil or empty expressions")
 		}
 		return false
 	}
 	for _, expr := range expressions {
-		value, ok := labels[expr.key]
+		var value string
+		var ok bool
+		if expr.source == expressionSourceAnnotation {
+			// annotations whose value is not a string do not match
+			var annotation interface{}
+			if annotation, ok = annotations[expr.key]; ok {
+				value, ok = annotation.(string)
+			}
+		} else {
+			value, ok = labels[expr.key]
+		}
 		switch expr.operator {
 		case OperatorIn:
 			if !ok || !isContainedInStringArray(expr.values, value) {
 				// not in
-				if klog.V(5) {
+				if logEnabled {
 					klog.Infof("expressionsMatch: false\n")
 				}
 				return false
@@ -137,7 +218,7 @@ func expressionsMatch(expressions []matchExpression, labels map[string]string) b
 		case OperatorNotIn:
 			if !ok || isContainedInStringArray(expr.values, value) {
 				// label deos notexists or there is a match
-				if klog.V(5) {
+				if logEnabled {
 					klog.Infof("expressionsMatch: false\n")
 				}
 				return false
@@ -145,7 +226,7 @@ func expressionsMatch(expressions []matchExpression, labels map[string]string) b
 		case OperatorExists:
 			if !ok {
 				// does not exist
-				if klog.V(5) {
+				if logEnabled {
 					klog.Infof("expressionsMatch: false\n")
 				}
 				return false
@@ -153,24 +234,52 @@ func expressionsMatch(expressions []matchExpression, labels map[string]string) b
 		case OperatorDoesNotExist:
 			if ok {
 				// exists
-				if klog.V(5) {
+				if logEnabled {
+					klog.Infof("expressionsMatch: false\n")
+				}
+				return false
+			}
+		case OperatorGreaterThan, OperatorLessThan:
+			if !ok || !integerLabelMatches(expr.operator, expr.values, value) {
+				// label does not exist, or is not an integer in range
+				if logEnabled {
 					klog.Infof("expressionsMatch: false\n")
 				}
 				return false
 			}
 		default:
-			if klog.V(5) {
+			if logEnabled {
 				klog.Infof("expressionsMatch: false\n")
 			}
 			return false
 		}
 	}
-	if klog.V(5) {
+	if logEnabled {
 		klog.Infof("expressionsMatch: true\n")
 	}
 	return true
 }
 
+// Return true if the label value compares to the single value of a Gt or Lt expression.
+// Return false if there is not exactly one value, or either is not an integer
+func integerLabelMatches(operator string, values []string, value string) bool {
+	if len(values) != 1 {
+		return false
+	}
+	bound, err := strconv.ParseInt(values[0], 10, 64)
+	if err != nil {
+		return false
+	}
+	labelValue, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return false
+	}
+	if operator == OperatorGreaterThan {
+		return labelValue > bound
+	}
+	return labelValue < bound
+}
+
 /* Check if resource namespace matches what application requires of its components.
 Return true if resource is not namespace, or
       resource namespace matches application namespace, or
@@ -183,8 +292,8 @@ func resourceNamespaceMatchesApplicationComponentNamespaces(resController *Clust
 		return true
 	}
 
-	if appResInfo.namespace == namespace && resController.isNamespacePermitted(namespace) {
-		// same namespace
+	if appResInfo.namespace == namespace {
+		// same namespace. The application's own namespace is implicitly permitted
 		return true
 	}
 	// Different namespace. Check if this Application allows this namespace
@@ -206,19 +315,92 @@ func resourceComponentOfApplication(resController *ClusterWatcher, appResInfo *a
 	}
 
 	if isSameResource(&appResInfo.resourceInfo, resInfo) {
-		// self
+		// an application is never a component of itself, even if its selector matches its own labels
+		if klog.V(4) {
+			klog.Infof("    resourceComponentOfApplication false: resource %s %s/%s is the application itself\n", resInfo.gvr, resInfo.namespace, resInfo.name)
+		}
+		return false
+	}
+	if resInfo.isTopLevel() {
+		// umbrella application, never rolled up into another application even if its labels match
+		if klog.V(4) {
+			klog.Infof("    resourceComponentOfApplication false: application %s/%s is top-level\n", resInfo.namespace, resInfo.name)
+		}
+		return false
+	}
+	if !isComponentKind(appResInfo.componentKinds, resInfo.group(), resInfo.kind) {
+		// resource group and kind not what the application wants to include
 		if klog.V(4) {
-			klog.Infof("    resourceComponentOfApplication false: resource is self\n")
+			klog.Infof("    resourceComponentOfApplication false: component kinds: %v, resource group: %s kind: %s\n", appResInfo.componentKinds, resInfo.group(), resInfo.kind)
 		}
 		return false
 	}
-	if !isContainedIn(appResInfo.componentKinds, resInfo.kind) {
-		// resource kind not what the application wants to include
+	var listed = isListedComponent(appResInfo, resInfo)
+	var ret = listed || selectorMatches(appResInfo, resInfo.labels, resInfo.annotations)
+	if !ret && appResInfo.matchTemplateLabels && len(resInfo.templateLabels) > 0 {
+		// application also wants to match pod template labels of workload components
+		ret = selectorMatches(appResInfo, resInfo.templateLabels, resInfo.annotations)
 		if klog.V(4) {
-			klog.Infof("    resourceComponentOfApplication false: component kinds: %v, resource kind: %s\n", appResInfo.componentKinds, resInfo.kind)
+			klog.Infof("    resourceComponentOfApplication matching template labels %v: %t\n", resInfo.templateLabels, ret)
+		}
+	}
+	if ret && !listed && excludeSelectorMatches(appResInfo, resInfo) {
+		// matched by the selector, but left out by the exclude selector
+		if klog.V(4) {
+			klog.Infof("    resourceComponentOfApplication false: excluded by the exclude selector\n")
+		}
+		ret = false
+	}
+	if ret {
+		if name := excludingComponentPredicate(appResInfo, resInfo); name != "" {
+			if klog.V(4) {
+				klog.Infof("    resourceComponentOfApplication false: excluded by component predicate %s\n", name)
+			}
+			ret = false
+		}
+	}
+	if klog.V(4) {
+		klog.Infof("    resourceComponentOfApplication %t\n", ret)
+	}
+	return ret
+}
+
+// Return true if the resource is listed explicitly as a component of the application.
+// Listed components are in the namespace of the application, or not namespaced
+func isListedComponent(appResInfo *appResourceInfo, resInfo *resourceInfo) bool {
+	if resInfo.namespace != "" && resInfo.namespace != appResInfo.namespace {
+		return false
+	}
+	for _, ref := range appResInfo.components {
+		if ref.kind == resInfo.kind && ref.name == resInfo.name && ref.gvr.Group == resInfo.gvr.Group {
+			return true
 		}
+	}
+	return false
+}
+
+// Return true if the resource matches the exclude selector of the application.
+// Both matchLabels and matchExpressions must match if both are specified.
+// Return false if the application has no exclude selector
+func excludeSelectorMatches(appResInfo *appResourceInfo, resInfo *resourceInfo) bool {
+	if len(appResInfo.excludeMatchLabels) == 0 && len(appResInfo.excludeMatchExpressions) == 0 {
+		return false
+	}
+	if len(appResInfo.excludeMatchLabels) > 0 && !labelsMatch(appResInfo.excludeMatchLabels, resInfo.labels) {
 		return false
 	}
+	if len(appResInfo.excludeMatchExpressions) > 0 {
+		return expressionsMatchAnnotations(appResInfo.excludeMatchExpressions, resInfo.labels, resInfo.annotations)
+	}
+	return true
+}
+
+// Return true if the given labels match the selector of the application.
+// Expressions whose source is annotation match the given annotations instead.
+// Both matchLabels and matchExpressions must match if both are specified,
+// or either of them if the application combines them with or.
+// Return false if the application has no selector
+func selectorMatches(appResInfo *appResourceInfo, labels map[string]string, annotations map[string]interface{}) bool {
 	var hasMatchLabels = true
 	if len(appResInfo.matchLabels) == 0 {
 		hasMatchLabels = false
@@ -229,19 +411,19 @@ func resourceComponentOfApplication(resController *ClusterWatcher, appResInfo *a
 	}
 
 	var ret bool
-	if hasMatchLabels && hasMatchExpressions {
-		ret = labelsMatch(appResInfo.matchLabels, resInfo.labels) &&
-			expressionsMatch(appResInfo.matchExpressions, resInfo.labels)
+	if hasMatchLabels && hasMatchExpressions && appResInfo.selectorCombine == selectorCombineOr {
+		ret = labelsMatch(appResInfo.matchLabels, labels) ||
+			expressionsMatchAnnotations(appResInfo.matchExpressions, labels, annotations)
+	} else if hasMatchLabels && hasMatchExpressions {
+		ret = labelsMatch(appResInfo.matchLabels, labels) &&
+			expressionsMatchAnnotations(appResInfo.matchExpressions, labels, annotations)
 	} else if hasMatchLabels {
-		ret = labelsMatch(appResInfo.matchLabels, resInfo.labels)
+		ret = labelsMatch(appResInfo.matchLabels, labels)
 	} else if hasMatchExpressions {
-		ret = expressionsMatch(appResInfo.matchExpressions, resInfo.labels)
+		ret = expressionsMatchAnnotations(appResInfo.matchExpressions, labels, annotations)
 	} else {
 		ret = false
 	}
-	if klog.V(4) {
-		klog.Infof("    resourceComponentOfApplication %t\n", ret)
-	}
 	return ret
 }
 
@@ -261,8 +443,17 @@ func deleteResource(resController *ClusterWatcher, resInfo *resourceInfo) error
 			intf = intfNoNS
 		}
 
-		var err error
-		err = intf.Delete(resInfo.name, nil)
+		if resController.skipWrite("deletion of %s %s/%s", resInfo.kind, resInfo.namespace, resInfo.name) {
+			return nil
+		}
+		operation := fmt.Sprintf("deleting %s %s/%s", resInfo.kind, resInfo.namespace, resInfo.name)
+		err := retryOnError(operation, deleteAttempts, deleteRetryDelay, isRetryableError, func() error {
+			return intf.Delete(resInfo.name, nil)
+		})
+		if errors.IsNotFound(err) {
+			// already deleted
+			err = nil
+		}
 		if err != nil {
 			if klog.V(4) {
 				klog.Infof("    deleteResource error: %s %s %s %s\n", resInfo.gvr, resInfo.namespace, resInfo.name, err)
@@ -298,12 +489,19 @@ func resourceDeleted(resController *ClusterWatcher, resInfo *resourceInfo) (bool
 		if err == nil {
 			return false, fmt.Errorf("Resource %s %s %s not deleted", resInfo.gvr, resInfo.namespace, resInfo.name)
 		}
-		// TODO: better checking between error and resource deleted
+		if !errors.IsNotFound(err) {
+			// e.g. forbidden or unavailable. Whether the resource was deleted is not known
+			if klog.V(4) {
+				klog.Infof("    resourceDeleted unknown: %s %s %s %s\n", resInfo.gvr, resInfo.namespace, resInfo.name, err)
+			}
+			return false, err
+		}
 		if klog.V(4) {
-			klog.Infof("    resourceDeleted true: %s %s %s %s\n", resInfo.gvr, resInfo.namespace, resInfo.name, err)
+			klog.Infof("    resourceDeleted true: %s %s %s\n", resInfo.gvr, resInfo.namespace, resInfo.name)
 		}
 		return true, nil
 	}
+	// no longer watched
 	return true, nil
 }
 
@@ -313,12 +511,23 @@ func getApplicationsForResource(resController *ClusterWatcher, resInfo *resource
 		klog.Infof("getApplicationsForResource: %s\n", resInfo.name)
 	}
 	var ret = make([]*appResourceInfo, 0)
-	// loop over all applications
-	var apps = resController.listResources(coreApplicationGVR)
+	// loop over the applications including the kind of the resource, or all applications without index
+	var apps []interface{}
+	if resController.appKinds != nil {
+		apps = resController.listApplicationsIncluding(resInfo.kind)
+	} else {
+		apps = resController.listApplications()
+	}
 	for _, app := range apps {
-		var unstructuredObj = app.(*unstructured.Unstructured)
-		var appResInfo = &appResourceInfo{}
-		if err := resController.parseAppResource(unstructuredObj, appResInfo); err == nil {
+		unstructuredObj, ok := app.(*unstructured.Unstructured)
+		if !ok {
+			// only unstructured objects are listed by the informers
+			if klog.V(2) {
+				klog.Infof("getApplicationsForResource skipping application of unexpected type %T\n", app)
+			}
+			continue
+		}
+		if appResInfo, err := resController.parseAppResourceCached(unstructuredObj); !isFatalParseError(err) {
 			if klog.V(4) {
 				klog.Infof("    checking application: %s\n", appResInfo.name)
 			}
@@ -326,7 +535,9 @@ func getApplicationsForResource(resController *ClusterWatcher, resInfo *resource
 				if klog.V(4) {
 					klog.Infof("    found application: %s\n", appResInfo.name)
 				}
-				ret = append(ret, appResInfo)
+				// callers change the applications found. Leave the cached one unchanged
+				var found = *appResInfo
+				ret = append(ret, &found)
 			}
 		} else {
 			// shouldn't happen
@@ -336,6 +547,23 @@ func getApplicationsForResource(resController *ClusterWatcher, resInfo *resource
 	return ret
 }
 
+// Return the applications in the cache whose component kinds include the kind
+func (resController *ClusterWatcher) listApplicationsIncluding(kind string) []interface{} {
+	var ret = make([]interface{}, 0)
+	for _, key := range resController.appKinds.candidates(kind) {
+		app, exists := resController.getApplicationByKey(key)
+		if !exists {
+			// deleted, and not yet removed from the index by the application handler
+			continue
+		}
+		ret = append(ret, app)
+	}
+	return ret
+}
+
+// DefaultMaxAncestorDepth - levels of ancestor applications followed from a resource
+const DefaultMaxAncestorDepth = 50
+
 /* Recursive find all applications and ancestors for a resource
    alreadyFound: map of applications that have already been processed
 */
@@ -345,100 +573,191 @@ func findAllApplicationsForResource(resController *ClusterWatcher, obj interface
 	var resInfo = &resourceInfo{}
 	resController.parseResource(unstructuredObj, resInfo)
 
-	findAllApplicationsForResourceHelper(resController, resInfo, alreadyFound)
+	findAllApplicationsForResourceHelper(resController, resInfo, alreadyFound, nil)
 	return
 }
 
-func findAllApplicationsForResourceHelper(resController *ClusterWatcher, resInfo *resourceInfo, alreadyFound map[string]*resourceInfo) {
+// path: keys of the resources from the resource the search started at, down to this one
+func findAllApplicationsForResourceHelper(resController *ClusterWatcher, resInfo *resourceInfo, alreadyFound map[string]*resourceInfo, path []string) {
 
+	key := resController.resourceKey(resInfo)
 	if resInfo.gvr == coreApplicationGVR {
-		key := resInfo.key()
 		_, exists := alreadyFound[key]
 		if exists {
 			return
 		}
 		alreadyFound[key] = resInfo
 	}
+	path = append(path, key)
+	if maxAncestorDepth > 0 && len(path) > maxAncestorDepth {
+		// deeper than any sensible hierarchy of applications. Stop following this branch
+		klog.Errorf("findAllApplicationsForResource not following ancestors deeper than %d applications: %s", maxAncestorDepth, strings.Join(path, " -> "))
+		ancestorDepthExceeded.Inc()
+		return
+	}
 
 	// recursively find all parent applications
 	for _, appResInfo := range getApplicationsForResource(resController, resInfo) {
-		findAllApplicationsForResourceHelper(resController, &appResInfo.resourceInfo, alreadyFound)
+		findAllApplicationsForResourceHelper(resController, &appResInfo.resourceInfo, alreadyFound, path[:len(path):len(path)])
 	}
 }
 
 // Callback to handle resource changes
 // TODO: DO not add resource if only kappnav status changed
 var batchResourceHandler resourceActionFunc = func(resController *ClusterWatcher, rw *ResourceWatcher, eventData *eventHandlerData) error {
+	eventsReceived.WithLabelValues(eventData.gvr.String()).Inc()
 	key := eventData.key
 	obj, exists, err := rw.store.GetByKey(key)
 	applications := make(map[string]*resourceInfo)
 	nonApplications := make(map[string]*resourceInfo)
 	if err != nil {
-		klog.Errorf("fetching key %s from store failed: %v", key, err)
+		logErrorS(err, "fetching resource from store failed", eventLogFields(eventData)...)
 		return err
 	}
 	if !exists {
 		// delete resource
 		if klog.V(3) {
-			klog.Infof("    processing deleted resource %s\n", key)
+			logInfoS("processing deleted resource", eventLogFields(eventData)...)
 		}
 		// batch up all parent applications
 		findAllApplicationsForResource(resController, eventData.obj, applications)
+		if deletedObj, ok := eventData.obj.(*unstructured.Unstructured); ok {
+			var resInfo = &resourceInfo{}
+			resController.parseResource(deletedObj, resInfo)
+			if resInfo.kind == DEPLOYMENT {
+				err = deleteActionConfigMapOfDeletedDeployment(resController, deletedObj)
+				if err != nil {
+					klog.Errorf("Unable to delete action configmap for Deployment %s/%s: %s", resInfo.namespace, resInfo.name, err)
+				}
+			}
+			resController.deletedComponents.add(resInfo, func() {
+				// recompute status of applications without the deleted component
+				expired := make(map[string]*resourceInfo)
+				findAllApplicationsForResource(resController, deletedObj, expired)
+				resController.resourceChannel.send(&batchResources{
+					applications:    expired,
+					nonApplications: make(map[string]*resourceInfo),
+				})
+			})
+		}
 	} else {
+		if ignoreManagedConfigMaps && isManagedConfigMapEvent(eventData) {
+			// written by the controller itself. Processing it would only trigger more writes
+			if klog.V(4) {
+				logInfoS("ignoring event for managed configmap", append(eventLogFields(eventData), "managedBy", managedByKAppNav)...)
+			}
+			return nil
+		}
 		var resInfo = &resourceInfo{}
 		resController.parseResource(eventData.obj.(*unstructured.Unstructured), resInfo)
+		resController.deletedComponents.remove(resInfo)
+		var oldObj *unstructured.Unstructured
+		if eventData.funcType == UpdateFunc {
+			oldObj = eventData.oldObj.(*unstructured.Unstructured)
+		}
+		if resInfo.kind == DEPLOYMENT {
+			// create action configmap, or delete it if no longer owned by a resource of a registered kind
+			err = syncActionConfigMap(resController, eventData.obj.(*unstructured.Unstructured), oldObj)
+			if err != nil {
+				klog.Errorf("Unable to update action configmap for Deployment %s/%s: %s", resInfo.namespace, resInfo.name, err)
+			}
+		}
 		if eventData.funcType == UpdateFunc {
 			if klog.V(3) {
-				klog.Infof("    processig updated resource : %s\n", key)
+				logInfoS("processing updated resource", eventLogFields(eventData)...)
 			}
 			var oldResInfo = &resourceInfo{}
 			resController.parseResource(eventData.oldObj.(*unstructured.Unstructured), oldResInfo)
-			if !sameLabels(oldResInfo.labels, resInfo.labels) {
-				// label changed. Update ancestors matched by old labels
+			if !sameLabels(oldResInfo.labels, resInfo.labels) || !sameLabels(oldResInfo.templateLabels, resInfo.templateLabels) {
+				// label or pod template label changed. Update ancestors matched by old labels
 				findAllApplicationsForResource(resController, eventData.oldObj, applications)
 			}
 		} else {
 			if klog.V(3) {
-				klog.Infof("   processing added resource: %s\n", key)
+				logInfoS("processing added resource", eventLogFields(eventData)...)
 			}
 		}
 		// find all ancestors
 		findAllApplicationsForResource(resController, obj, applications)
 
-		nonApplications[resInfo.key()] = resInfo
+		nonApplications[resController.resourceKey(resInfo)] = resInfo
 
 	}
 	resourceToBatch := batchResources{
 		applications:    applications,
 		nonApplications: nonApplications,
+		window:          rw.batchDuration,
 	}
 	if klog.V(3) {
-		klog.Infof("    Sending %d applications and %d resources on channel\n", len(resourceToBatch.applications), len(resourceToBatch.nonApplications))
+		logInfoS("sending batch", append(eventLogFields(eventData), "applications", len(resourceToBatch.applications), "resources", len(resourceToBatch.nonApplications))...)
 	}
+	applicationsQueued.Add(float64(len(resourceToBatch.applications)))
 	resController.resourceChannel.send(&resourceToBatch)
 	return nil
 }
 
 // Start watching component kinds of the application. Also put
-// application on batch of applications to recalculate status
+// application on batch of applications to recalculate status.
+// Objects that are not applications are skipped without error.
 func startWatchApplicationComponentKinds(resController *ClusterWatcher, obj interface{}, applications map[string]*resourceInfo) error {
 	if klog.V(4) {
 		klog.Infof("startWatchApplicationComponentKinds: %T %s\n", obj, obj)
 	}
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		// deleted while the watch was disconnected. Use the last known state
+		if klog.V(4) {
+			klog.Infof("    startWatchApplicationComponentKinds unwrapping tombstone for %s\n", tombstone.Key)
+		}
+		obj = tombstone.Obj
+	}
 	switch obj.(type) {
 	case *unstructured.Unstructured:
 		var unstructuredObj = obj.(*unstructured.
 			Unstructured)
 
 		var appInfo = &appResourceInfo{}
-		if err := resController.parseAppResource(unstructuredObj, appInfo); err == nil {
+		err := resController.parseAppResource(unstructuredObj, appInfo)
+		// written together once known
+		conditions := []applicationCondition{newValidCondition(err)}
+		defer func() {
+			if appInfo.name == "" {
+				return
+			}
+			if condErr := writeApplicationConditions(resController, appInfo, conditions); condErr != nil && klog.V(2) {
+				klog.Infof("    startWatchApplicationComponentKinds unable to record conditions of %s %s: %s", appInfo.namespace, appInfo.name, condErr)
+			}
+		}()
+		if isFatalParseError(err) {
+			// skip it so the rest of the batch is still processed
+			klog.Errorf("    startWatchApplicationComponentKinds skipping application %s %s: %s", appInfo.namespace, appInfo.name, err)
+		} else {
+			if err != nil {
+				// recorded on the Valid condition. Process what parsed
+				klog.Errorf("    startWatchApplicationComponentKinds application %s %s is partly malformed: %s", appInfo.namespace, appInfo.name, err)
+			}
 			// start watching all component kinds of the application
 			var componentKinds = appInfo.componentKinds
 			nsFilter := resController.nsFilter
+			var deniedKinds = make([]string, 0)
+			var gvrs = make([]schema.GroupVersionResource, 0, len(componentKinds))
+			for _, elem := range componentKinds {
+				if isAPIGroupDenied(elem.gvr.Group) {
+					deniedKinds = append(deniedKinds, elem.group+"/"+elem.kind)
+					continue
+				}
+				gvrs = append(gvrs, elem.gvr)
+			}
+			// referenced before watched, so the watch is not stopped in between
+			key := appResourceKey(unstructuredObj)
+			for _, gvr := range resController.watchRefs.set(key, gvrs) {
+				resController.RemoveFromWatch(gvr)
+			}
 			for _, elem := range componentKinds {
-				// TODO: PWB process group here, map to gvr
+				if isAPIGroupDenied(elem.gvr.Group) {
+					continue
+				}
 				/* Start processing kinds in the application's namespace */
-				nsFilter.permitNamespace(resController, elem.gvr, appInfo.resourceInfo.namespace)
+				nsFilter.permitApplicationNamespace(resController, elem.gvr, appInfo.resourceInfo.namespace)
 
 				/* also permit namespaces in the kappnav.component.namespaces annotation */
 				for _, ns := range appInfo.componentNamespaces {
@@ -451,13 +770,22 @@ func startWatchApplicationComponentKinds(resController *ClusterWatcher, obj inte
 					return err
 				}
 			}
-			applications[appInfo.resourceInfo.key()] = &appInfo.resourceInfo
+			// watched once known
+			resController.pendingKinds.set(key, appInfo.unresolvedKinds)
+			if len(appInfo.unresolvedKinds) > 0 && klog.V(2) {
+				klog.Infof("    startWatchApplicationComponentKinds application %s %s waiting for component kinds %v", appInfo.namespace, appInfo.name, appInfo.unresolvedKinds)
+			}
+			conditions = append(conditions, newComponentKindsWatchedCondition(deniedKinds), newSelectorCondition(appInfo),
+				newActionConfigMapCreationCondition(resController, appInfo))
+			applications[resController.resourceKey(&appInfo.resourceInfo)] = &appInfo.resourceInfo
 		}
 
 		return nil
 
 	default:
-		return fmt.Errorf("    batchAddModifyApplication.addApplication: not Unstructured: type: %T val: % s", obj, obj)
+		// skip it so the rest of the batch is still processed
+		klog.Errorf("    startWatchApplicationComponentKinds skipping object not Unstructured: type: %T val: %s", obj, obj)
+		return nil
 	}
 }
 
@@ -465,21 +793,40 @@ func startWatchApplicationComponentKinds(resController *ClusterWatcher, obj inte
 // TODO: Do not add applications to be processed if only kappnav status changed
 var batchApplicationHandler resourceActionFunc = func(resController *ClusterWatcher, rw *ResourceWatcher, eventData *eventHandlerData) error {
 	if klog.V(4) {
-		klog.Infof("batchApplicationHander\n")
+		logInfoS("batchApplicationHandler", eventLogFields(eventData)...)
 	}
+	eventsReceived.WithLabelValues(eventData.gvr.String()).Inc()
 
 	key := eventData.key
+	// application changed. Parse it again when checking resources against it
+	resController.appResources.remove(key)
 	obj, exists, err := rw.store.GetByKey(key)
 	if err != nil {
-		klog.Errorf("   batchApplicationhandler fetching key %s failed: %v", key, err)
+		logErrorS(err, "fetching application from store failed", eventLogFields(eventData)...)
 		return err
 	}
+	if unstructuredObj, ok := obj.(*unstructured.Unstructured); ok && exists {
+		resController.appKinds.set(key, unstructuredObj)
+	} else if !exists {
+		resController.appKinds.remove(key)
+		resController.pendingKinds.set(key, nil)
+		// stop watching the kinds no other application includes
+		for _, gvr := range resController.watchRefs.remove(key) {
+			resController.RemoveFromWatch(gvr)
+		}
+	}
 	applications := make(map[string]*resourceInfo)
 	nonApplications := make(map[string]*resourceInfo)
+	requeueParents := true
 	if !exists {
 		// application is gone. Update parent applications
 		if klog.V(3) {
-			klog.Infof("    processing application deleted: %s\n", key)
+			logInfoS("processing application deleted", eventLogFields(eventData)...)
+		}
+		if deletedObj, ok := eventData.obj.(*unstructured.Unstructured); ok {
+			var resInfo = &resourceInfo{}
+			resController.parseResource(deletedObj, resInfo)
+			resController.statusCache.remove(resController.resourceKey(resInfo))
 		}
 		// batch up all ancestor applications
 		findAllApplicationsForResource(resController, eventData.obj, applications)
@@ -487,37 +834,48 @@ var batchApplicationHandler resourceActionFunc = func(resController *ClusterWatc
 		if eventData.funcType == UpdateFunc {
 			// application updated
 			if klog.V(3) {
-				klog.Infof("    processing application updated: %s\n", key)
+				logInfoS("processing application updated", eventLogFields(eventData)...)
 			}
 			var oldResInfo = &resourceInfo{}
 			resController.parseResource(eventData.oldObj.(*unstructured.Unstructured), oldResInfo)
 			var newResInfo = &resourceInfo{}
 			resController.parseResource(eventData.obj.(*unstructured.Unstructured), newResInfo)
-			// Something changed. batch up ancestors of application
-			// TODO: optimize by finding ancestors only if label or
-			// selector changed.  Note that a label change affects which
-			// parent applications selects this application. A selector
-			// changes affects which sub-components are included in calculation
-			findAllApplicationsForResource(resController, eventData.oldObj, applications)
+			// A label or annotation change, including becoming top-level or no
+			// longer, affects which parent applications select this application.
+			// Otherwise the parents only depend on the overall status of this
+			// application. A selector change affects which sub-components are
+			// included in calculation, and parents are batched up when the
+			// resulting status is written.
+			requeueParents = !parentRequeueOnStatusChangeOnly || parentsAffectedByUpdate(oldResInfo, newResInfo)
+			if requeueParents {
+				// Something changed. batch up ancestors of application
+				findAllApplicationsForResource(resController, eventData.oldObj, applications)
+			} else if klog.V(3) {
+				logInfoS("status of application unchanged, not batching up ancestors", eventLogFields(eventData)...)
+			}
 		} else {
 			if klog.V(3) {
-				klog.Infof("    processing application added: %s\n", key)
+				logInfoS("processing application added", eventLogFields(eventData)...)
 			}
 		}
 		err = startWatchApplicationComponentKinds(resController, obj, applications)
 		if err != nil {
-			klog.Errorf("    process application error %s\n", err)
+			logErrorS(err, "process application error", eventLogFields(eventData)...)
 			return err
 		}
-		findAllApplicationsForResource(resController, eventData.obj, applications)
+		if requeueParents {
+			findAllApplicationsForResource(resController, eventData.obj, applications)
+		}
 	}
 	resourceToBatch := batchResources{
 		applications:    applications,
 		nonApplications: nonApplications,
+		window:          rw.batchDuration,
 	}
 	if klog.V(3) {
-		klog.Infof("    Sending %d applications and %d resources on channel\n", len(resourceToBatch.applications), len(resourceToBatch.nonApplications))
+		logInfoS("sending batch", append(eventLogFields(eventData), "applications", len(resourceToBatch.applications), "resources", len(resourceToBatch.nonApplications))...)
 	}
+	applicationsQueued.Add(float64(len(resourceToBatch.applications)))
 	resController.resourceChannel.send(&resourceToBatch)
 
 	return nil
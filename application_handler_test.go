@@ -0,0 +1,1088 @@
+/*
+Copyright 2019 IBM Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/cache"
+)
+
+const (
+	templateApp           = "test_data/template-app.json"
+	templateNoAnnoApp     = "test_data/template-noanno-app.json"
+	templateDeployment    = "test_data/template-deployment.json"
+	componentsApp         = "test_data/productpage-app-components.json"
+	mixedComponentsApp    = "test_data/mixed-app-components.json"
+	combineOrApp          = "test_data/combine-or-app.json"
+	combineAndApp         = "test_data/combine-and-app.json"
+	annotationInApp       = "test_data/annotation-in-app.json"
+	annotationExistsApp   = "test_data/annotation-exists-app.json"
+	annotationLabelApp    = "test_data/annotation-label-app.json"
+	annotatedDeployment   = "test_data/annotated-deployment.json"
+	excludeLabelsApp      = "test_data/exclude-labels-app.json"
+	excludeExpressionsApp = "test_data/exclude-expressions-app.json"
+	canaryDeployment      = "test_data/details-canary.json"
+)
+
+type componentTestData struct {
+	appFile      string
+	resourceFile string
+	expected     bool
+}
+
+var templateLabelsTestData = []componentTestData{
+	// application opts in, deployment matches on pod template labels only
+	{appFile: templateApp, resourceFile: templateDeployment, expected: true},
+	// application does not opt in, top level labels do not match
+	{appFile: templateNoAnnoApp, resourceFile: templateDeployment, expected: false},
+	// application opts in, neither top level nor template labels match
+	{appFile: templateApp, resourceFile: cDeployment, expected: false},
+	// top level labels still match without opting in
+	{appFile: appProductpage, resourceFile: deploymentProcuctpageV1, expected: true},
+}
+
+func TestParseTemplateLabels(t *testing.T) {
+	unstructuredObj, err := readJSON(templateDeployment)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var resInfo = &resourceInfo{}
+	parseResourceBasic(unstructuredObj, resInfo)
+	if resInfo.labels["app"] != "template-workload" {
+		t.Errorf("expecting label app=template-workload, got %v", resInfo.labels)
+	}
+	if resInfo.templateLabels["app"] != "template-pod" || resInfo.templateLabels["version"] != "v1" {
+		t.Errorf("expecting template labels app=template-pod and version=v1, got %v", resInfo.templateLabels)
+	}
+
+	// resource without a template has no template labels
+	unstructuredObj, err = readJSON(appProductpage)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resInfo = &resourceInfo{}
+	parseResourceBasic(unstructuredObj, resInfo)
+	if len(resInfo.templateLabels) != 0 {
+		t.Errorf("expecting no template labels for %s, got %v", appProductpage, resInfo.templateLabels)
+	}
+}
+
+func TestResourceComponentOfApplicationTemplateLabels(t *testing.T) {
+	var resController = &ClusterWatcher{}
+	initControllerMaps(resController)
+	for _, data := range templateLabelsTestData {
+		appObj, err := readJSON(data.appFile)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var appInfo = &appResourceInfo{}
+		err = resController.parseAppResource(appObj, appInfo)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		resObj, err := readJSON(data.resourceFile)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var resInfo = &resourceInfo{}
+		resController.parseResource(resObj, resInfo)
+
+		result := resourceComponentOfApplication(resController, appInfo, resInfo)
+		if result != data.expected {
+			t.Errorf("resourceComponentOfApplication for application %s and resource %s: expecting %t but got %t", data.appFile, data.resourceFile, data.expected, result)
+		}
+	}
+}
+
+// Test an application matching a Deployment on its pod template labels is batched up
+// when only those labels change, so it no longer counts the Deployment
+func TestTemplateLabelsChanged(t *testing.T) {
+	testName := "TestTemplateLabelsChanged"
+	beforeTest()
+	// kinds to check for status
+	var kindsToCheckStatus = map[string]bool{
+		APPLICATION: true,
+	}
+
+	var files = []string{
+		/* 0 */ CrdApplication,
+		/* 1 */ templateApp,
+		/* 2 */ templateDeployment,
+		/* 3 */ KappnavConfigFile,
+	}
+	iteration0IDs, err := readResourceIDs(files)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testActions := newTestActions(testName, kindsToCheckStatus)
+	var emptyIDs = []resourceID{}
+	testActions.addIteration(iteration0IDs, emptyIDs)
+
+	clusterWatcher, err := createClusterWatcher(iteration0IDs, testActions, StatusFailureRate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer clusterWatcher.shutDown()
+
+	err = testActions.transitionAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// capture what is sent for batch processing
+	batchChannel := clusterWatcher.resourceChannel
+	captureChannel := newResourceChannel()
+	clusterWatcher.resourceChannel = captureChannel
+	defer func() {
+		clusterWatcher.resourceChannel = batchChannel
+	}()
+
+	app, deployment := iteration0IDs[1], iteration0IDs[2]
+	gvr, ok := clusterWatcher.getWatchGVR(deployment.gvr)
+	if !ok {
+		t.Fatalf("Unable to find GVR for kind %s", deployment.kind)
+	}
+	rw := clusterWatcher.getResourceWatcher(gvr)
+	if rw == nil {
+		t.Fatal("deployments not watched")
+	}
+	key := deployment.namespace + "/" + deployment.name
+	obj, exists, err := rw.store.GetByKey(key)
+	if err != nil || !exists {
+		t.Fatalf("unable to get deployment %s from cache: %v", key, err)
+	}
+	oldObj := obj.(*unstructured.Unstructured)
+	newObj := oldObj.DeepCopy()
+	if err = unstructured.SetNestedStringMap(newObj.Object, map[string]string{"app": "other-pod"}, SPEC, "template", METADATA, LABELS); err != nil {
+		t.Fatal(err)
+	}
+	newObj.SetResourceVersion(oldObj.GetResourceVersion() + "1")
+	// as the informer updates the cache before calling the handler
+	if err = rw.store.Update(newObj); err != nil {
+		t.Fatal(err)
+	}
+
+	eventData := &eventHandlerData{
+		funcType: UpdateFunc,
+		kind:     DEPLOYMENT,
+		gvr:      gvr,
+		key:      key,
+		obj:      newObj,
+		oldObj:   oldObj,
+	}
+	if err = batchResourceHandler(clusterWatcher, rw, eventData); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case resources := <-captureChannel.batchResourceChan:
+		batched := false
+		for _, res := range resources.applications {
+			if res.namespace == app.namespace && res.name == app.name {
+				batched = true
+			}
+		}
+		if !batched {
+			t.Errorf("expecting %s matched by the old pod template labels to be batched up", app.name)
+		}
+	case <-time.After(time.Second * 5):
+		t.Fatal("timed out waiting for applications to be batched")
+	}
+}
+
+var selectorCombineTestData = []componentTestData{
+	// or: either matchLabels or matchExpressions
+	{appFile: combineOrApp, resourceFile: templateDeployment, expected: true},
+	{appFile: combineOrApp, resourceFile: deploymentDetailsV1, expected: true},
+	{appFile: combineOrApp, resourceFile: cDeployment, expected: false},
+	// and by default: both
+	{appFile: combineAndApp, resourceFile: templateDeployment, expected: false},
+	{appFile: combineAndApp, resourceFile: deploymentDetailsV1, expected: false},
+	{appFile: combineAndApp, resourceFile: cDeployment, expected: false},
+	// a single selector is unaffected
+	{appFile: appDetails, resourceFile: deploymentDetailsV1, expected: true},
+}
+
+func TestResourceComponentOfApplicationSelectorCombine(t *testing.T) {
+	var resController = &ClusterWatcher{}
+	initControllerMaps(resController)
+	for _, data := range selectorCombineTestData {
+		appObj, err := readJSON(data.appFile)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var appInfo = &appResourceInfo{}
+		err = resController.parseAppResource(appObj, appInfo)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		resObj, err := readJSON(data.resourceFile)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var resInfo = &resourceInfo{}
+		resController.parseResource(resObj, resInfo)
+
+		result := resourceComponentOfApplication(resController, appInfo, resInfo)
+		if result != data.expected {
+			t.Errorf("resourceComponentOfApplication for application %s and resource %s: expecting %t but got %t", data.appFile, data.resourceFile, data.expected, result)
+		}
+	}
+}
+
+var annotationExpressionsTestData = []componentTestData{
+	// expressions matched against annotations
+	{appFile: annotationInApp, resourceFile: annotatedDeployment, expected: true},
+	{appFile: annotationExistsApp, resourceFile: annotatedDeployment, expected: true},
+	{appFile: annotationInApp, resourceFile: deploymentDetailsV1, expected: false},
+	{appFile: annotationExistsApp, resourceFile: deploymentDetailsV1, expected: false},
+	// labels by default, even if an annotation has the key
+	{appFile: annotationLabelApp, resourceFile: annotatedDeployment, expected: false},
+	{appFile: annotationLabelApp, resourceFile: deploymentDetailsV1, expected: false},
+}
+
+func TestResourceComponentOfApplicationAnnotations(t *testing.T) {
+	var resController = &ClusterWatcher{}
+	initControllerMaps(resController)
+	for _, data := range annotationExpressionsTestData {
+		appObj, err := readJSON(data.appFile)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var appInfo = &appResourceInfo{}
+		err = resController.parseAppResource(appObj, appInfo)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		resObj, err := readJSON(data.resourceFile)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var resInfo = &resourceInfo{}
+		resController.parseResource(resObj, resInfo)
+
+		result := resourceComponentOfApplication(resController, appInfo, resInfo)
+		if result != data.expected {
+			t.Errorf("resourceComponentOfApplication for application %s and resource %s: expecting %t but got %t", data.appFile, data.resourceFile, data.expected, result)
+		}
+	}
+}
+
+var excludeSelectorTestData = []componentTestData{
+	// matched by the selector, and left out by the exclude selector
+	{appFile: excludeLabelsApp, resourceFile: canaryDeployment, expected: false},
+	{appFile: excludeExpressionsApp, resourceFile: canaryDeployment, expected: false},
+	// matched by the selector only
+	{appFile: excludeLabelsApp, resourceFile: deploymentDetailsV1, expected: true},
+	{appFile: excludeExpressionsApp, resourceFile: deploymentDetailsV1, expected: true},
+	// no exclude selector
+	{appFile: appDetails, resourceFile: canaryDeployment, expected: true},
+}
+
+func TestResourceComponentOfApplicationExcludeSelector(t *testing.T) {
+	var resController = &ClusterWatcher{}
+	initControllerMaps(resController)
+	for _, data := range excludeSelectorTestData {
+		appObj, err := readJSON(data.appFile)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var appInfo = &appResourceInfo{}
+		err = resController.parseAppResource(appObj, appInfo)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		resObj, err := readJSON(data.resourceFile)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var resInfo = &resourceInfo{}
+		resController.parseResource(resObj, resInfo)
+
+		result := resourceComponentOfApplication(resController, appInfo, resInfo)
+		if result != data.expected {
+			t.Errorf("resourceComponentOfApplication for application %s and resource %s: expecting %t but got %t", data.appFile, data.resourceFile, data.expected, result)
+		}
+	}
+}
+
+// Test a malformed exclude selector excludes nothing, rather than what is left of it once its malformed parts are left out
+func TestMalformedExcludeSelector(t *testing.T) {
+	var resController = &ClusterWatcher{}
+	initControllerMaps(resController)
+	appObj, err := readJSON(excludeLabelsApp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	spec := appObj.Object["spec"].(map[string]interface{})
+	excludeSelector := spec["excludeSelector"].(map[string]interface{})
+	// the matchLabels still parse, the expression does not
+	excludeSelector["matchExpressions"] = []interface{}{map[string]interface{}{"operator": "In"}}
+
+	var appInfo = &appResourceInfo{}
+	err = resController.parseAppResource(appObj, appInfo)
+	if !isParseError(err, ErrInvalidExcludeSelector) || isFatalParseError(err) {
+		t.Fatalf("expecting a non-fatal %s error, got %v", ErrInvalidExcludeSelector, err)
+	}
+	if reason := parseErrorConditionReason(err); reason != "InvalidExcludeSelector" {
+		t.Errorf("expecting condition reason InvalidExcludeSelector, got %s", reason)
+	}
+
+	resObj, err := readJSON(canaryDeployment)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var resInfo = &resourceInfo{}
+	resController.parseResource(resObj, resInfo)
+	if !resourceComponentOfApplication(resController, appInfo, resInfo) {
+		t.Errorf("expecting %s to be a component while the exclusion is disabled", resInfo.name)
+	}
+}
+
+var annotationSourceExpressionsTestData = []struct {
+	expressions []matchExpression
+	labels      map[string]string
+	annotations map[string]interface{}
+	expected    bool
+}{
+	{[]matchExpression{{key: "owner", operator: OperatorIn, values: []string{"payments"}, source: expressionSourceAnnotation}}, map[string]string{}, map[string]interface{}{"owner": "payments"}, true},
+	{[]matchExpression{{key: "owner", operator: OperatorIn, values: []string{"payments"}, source: expressionSourceAnnotation}}, map[string]string{"owner": "payments"}, map[string]interface{}{}, false},
+	{[]matchExpression{{key: "owner", operator: OperatorIn, values: []string{"payments"}, source: expressionSourceAnnotation}}, map[string]string{}, map[string]interface{}{"owner": "billing"}, false},
+	{[]matchExpression{{key: "owner", operator: OperatorExists, source: expressionSourceAnnotation}}, map[string]string{}, map[string]interface{}{"owner": ""}, true},
+	{[]matchExpression{{key: "owner", operator: OperatorExists, source: expressionSourceAnnotation}}, map[string]string{"owner": "payments"}, nil, false},
+	{[]matchExpression{{key: "owner", operator: OperatorExists, source: expressionSourceLabel}}, map[string]string{"owner": "payments"}, nil, true},
+	{[]matchExpression{{key: "owner", operator: OperatorExists}}, map[string]string{}, map[string]interface{}{"owner": "payments"}, false},
+	// labels and annotations together
+	{[]matchExpression{{key: "app", operator: OperatorIn, values: []string{"details"}}, {key: "owner", operator: OperatorExists, source: expressionSourceAnnotation}}, map[string]string{"app": "details"}, map[string]interface{}{"owner": "payments"}, true},
+	{[]matchExpression{{key: "app", operator: OperatorIn, values: []string{"details"}}, {key: "owner", operator: OperatorExists, source: expressionSourceAnnotation}}, map[string]string{"app": "details"}, map[string]interface{}{}, false},
+}
+
+func TestExpressionsMatchAnnotations(t *testing.T) {
+	for _, data := range annotationSourceExpressionsTestData {
+		if result := expressionsMatchAnnotations(data.expressions, data.labels, data.annotations); result != data.expected {
+			t.Errorf("expressions %v, labels %v, annotations %v: expecting %t, got %t", data.expressions, data.labels, data.annotations, data.expected, result)
+		}
+	}
+}
+
+var sameSelectableAnnotationsTestData = []struct {
+	annotations1 map[string]interface{}
+	annotations2 map[string]interface{}
+	expected     bool
+}{
+	{nil, map[string]interface{}{}, true},
+	{map[string]interface{}{"owner": "payments"}, map[string]interface{}{"owner": "payments"}, true},
+	{map[string]interface{}{"owner": "payments"}, map[string]interface{}{"owner": "billing"}, false},
+	{map[string]interface{}{"owner": "payments"}, map[string]interface{}{}, false},
+	{map[string]interface{}{}, map[string]interface{}{"owner": "payments"}, false},
+	// status written by the controller
+	{map[string]interface{}{kappnavStatusValue: Normal, kappnavStatusFlyover: ""}, map[string]interface{}{kappnavStatusValue: problem}, true},
+	{map[string]interface{}{"owner": "payments", kappnavStatusAvailability: "100.0"}, map[string]interface{}{"owner": "payments"}, true},
+}
+
+func TestSameSelectableAnnotations(t *testing.T) {
+	for _, data := range sameSelectableAnnotationsTestData {
+		if result := sameSelectableAnnotations(data.annotations1, data.annotations2); result != data.expected {
+			t.Errorf("annotations %v and %v: expecting same %t, got %t", data.annotations1, data.annotations2, data.expected, result)
+		}
+	}
+}
+
+// Return an application with the given annotations and status
+func appResInfoWithAnnotations(annotations map[string]interface{}, status string) *resourceInfo {
+	return &resourceInfo{
+		kind:           APPLICATION,
+		metadata:       map[string]interface{}{ANNOTATIONS: annotations},
+		annotations:    annotations,
+		kappnavStatVal: status,
+	}
+}
+
+func TestParentsAffectedByUpdate(t *testing.T) {
+	topLevel := map[string]interface{}{kappnavTopLevel: "true"}
+	var tests = []struct {
+		name     string
+		oldInfo  *resourceInfo
+		newInfo  *resourceInfo
+		expected bool
+	}{
+		{"unchanged", appResInfoWithAnnotations(nil, Normal), appResInfoWithAnnotations(nil, Normal), false},
+		{"status changed", appResInfoWithAnnotations(nil, Normal), appResInfoWithAnnotations(nil, warning), true},
+		{"became top-level", appResInfoWithAnnotations(nil, Normal), appResInfoWithAnnotations(topLevel, Normal), true},
+		{"no longer top-level", appResInfoWithAnnotations(topLevel, Normal), appResInfoWithAnnotations(nil, Normal), true},
+		{"still top-level", appResInfoWithAnnotations(topLevel, Normal), appResInfoWithAnnotations(topLevel, Normal), false},
+	}
+	for _, test := range tests {
+		if affected := parentsAffectedByUpdate(test.oldInfo, test.newInfo); affected != test.expected {
+			t.Errorf("%s: expecting parents affected %t, got %t", test.name, test.expected, affected)
+		}
+	}
+}
+
+var listedComponentsTestData = []componentTestData{
+	// explicit list only
+	{appFile: componentsApp, resourceFile: deploymentProcuctpageV1, expected: true},
+	{appFile: componentsApp, resourceFile: deploymentDetailsV1, expected: false},
+	{appFile: componentsApp, resourceFile: serviceProductpage, expected: false},
+	// explicit list and selector
+	{appFile: mixedComponentsApp, resourceFile: serviceProductpage, expected: true},
+	{appFile: mixedComponentsApp, resourceFile: serviceDetails, expected: true},
+	{appFile: mixedComponentsApp, resourceFile: deploymentDetailsV1, expected: true},
+	{appFile: mixedComponentsApp, resourceFile: deploymentProcuctpageV1, expected: false},
+}
+
+func TestResourceComponentOfApplicationListedComponents(t *testing.T) {
+	var resController = &ClusterWatcher{}
+	initControllerMaps(resController)
+	for _, data := range listedComponentsTestData {
+		appObj, err := readJSON(data.appFile)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var appInfo = &appResourceInfo{}
+		err = resController.parseAppResource(appObj, appInfo)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		resObj, err := readJSON(data.resourceFile)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var resInfo = &resourceInfo{}
+		resController.parseResource(resObj, resInfo)
+
+		result := resourceComponentOfApplication(resController, appInfo, resInfo)
+		if result != data.expected {
+			t.Errorf("resourceComponentOfApplication for application %s and resource %s: expecting %t but got %t", data.appFile, data.resourceFile, data.expected, result)
+		}
+	}
+
+	// kinds of listed components are watched even if not in componentKinds
+	appObj, err := readJSON(componentsApp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var appInfo = &appResourceInfo{}
+	err = resController.parseAppResource(appObj, appInfo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	watched := false
+	for _, gk := range appInfo.componentKinds {
+		if gk.group == "apps" && gk.kind == DEPLOYMENT {
+			watched = true
+		}
+	}
+	if len(appInfo.components) != 1 || !watched {
+		t.Errorf("expecting 1 listed component and kind apps/%s to be watched, got %v %v", DEPLOYMENT, appInfo.components, appInfo.componentKinds)
+	}
+}
+
+// Return a widget of the given apiVersion, labeled as a component of the widget application
+func newWidget(apiVersion string, name string) *unstructured.Unstructured {
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": apiVersion,
+		"kind":       "Widget",
+		"metadata": map[string]interface{}{
+			"name":      name,
+			"namespace": "default",
+			"labels":    map[string]interface{}{"app": "widgets"},
+		},
+	}}
+}
+
+// Test kinds of the same name in different API groups are told apart
+func TestResourceComponentOfApplicationGroup(t *testing.T) {
+	exampleWidgetGVR := schema.GroupVersionResource{Group: "example.com", Version: "v1", Resource: "widgets"}
+	otherWidgetGVR := schema.GroupVersionResource{Group: "other.example.com", Version: "v1", Resource: "widgets"}
+	var resController = &ClusterWatcher{}
+	initControllerMaps(resController)
+	resController.groupKindToGVR.Store("example.com/Widget", exampleWidgetGVR)
+	resController.groupKindToGVR.Store("other.example.com/Widget", otherWidgetGVR)
+	resController.apiVersionKindToGVR.Store("example.com/v1/Widget", exampleWidgetGVR)
+	resController.apiVersionKindToGVR.Store("other.example.com/v1/Widget", otherWidgetGVR)
+
+	appObj := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "app.k8s.io/v1beta1",
+		"kind":       APPLICATION,
+		"metadata": map[string]interface{}{
+			"name":      "widget-app",
+			"namespace": "default",
+		},
+		"spec": map[string]interface{}{
+			"componentKinds": []interface{}{
+				map[string]interface{}{"group": "example.com", "kind": "Widget"},
+			},
+			"selector": map[string]interface{}{"matchLabels": map[string]interface{}{"app": "widgets"}},
+		},
+	}}
+	var appInfo = &appResourceInfo{}
+	if err := resController.parseAppResource(appObj, appInfo); err != nil {
+		t.Fatal(err)
+	}
+
+	var testData = []struct {
+		resObj   *unstructured.Unstructured
+		gvr      schema.GroupVersionResource // set on the parsed resource. Empty to take the group from the apiVersion
+		expected bool
+	}{
+		{newWidget("example.com/v1", "widget1"), exampleWidgetGVR, true},
+		{newWidget("other.example.com/v1", "widget2"), otherWidgetGVR, false},
+		// GVR not known yet
+		{newWidget("example.com/v2", "widget3"), schema.GroupVersionResource{}, true},
+		{newWidget("other.example.com/v2", "widget4"), schema.GroupVersionResource{}, false},
+	}
+	for _, data := range testData {
+		var resInfo = &resourceInfo{}
+		resController.parseResource(data.resObj, resInfo)
+		if resInfo.gvr != data.gvr {
+			t.Errorf("widget %s: expecting GVR %s, got %s", resInfo.name, data.gvr, resInfo.gvr)
+		}
+		if result := resourceComponentOfApplication(resController, appInfo, resInfo); result != data.expected {
+			t.Errorf("resourceComponentOfApplication for widget %s of %s: expecting %t but got %t", resInfo.name, resInfo.apiVersion, data.expected, result)
+		}
+	}
+
+	// a listed component of the other group adds its kind
+	unstructured.SetNestedSlice(appObj.Object, []interface{}{
+		map[string]interface{}{"group": "other.example.com", "kind": "Widget", "name": "widget2"},
+	}, "spec", "components")
+	appInfo = &appResourceInfo{}
+	if err := resController.parseAppResource(appObj, appInfo); err != nil {
+		t.Fatal(err)
+	}
+	if !isComponentKind(appInfo.componentKinds, "example.com", "Widget") || !isComponentKind(appInfo.componentKinds, "other.example.com", "Widget") {
+		t.Errorf("expecting component kinds of both groups, got %v", appInfo.componentKinds)
+	}
+}
+
+// Test resources in the application's own namespace are components when the namespace
+// is not in the namespaces of the kappnav instance, and the filter was not pre-seeded
+func TestResourceComponentOfApplicationSameNamespace(t *testing.T) {
+	var resController = &ClusterWatcher{}
+	initControllerMaps(resController)
+	resController.nsFilter = newNamespaceFilter()
+	resController.namespaces = map[string]string{"other": "other"}
+
+	appObj, err := readJSON(appProductpage)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var appInfo = &appResourceInfo{}
+	err = resController.parseAppResource(appObj, appInfo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resController.isNamespacePermitted(appInfo.namespace) {
+		t.Fatalf("expecting namespace %s not to be permitted", appInfo.namespace)
+	}
+
+	// filter seeded with the application namespace at parse time
+	for _, elem := range appInfo.componentKinds {
+		if _, ok := resController.nsFilter.namespacesForGVR[elem.gvr][appInfo.namespace]; !ok {
+			t.Errorf("expecting namespace %s seeded for %s, got %v", appInfo.namespace, elem.gvr, resController.nsFilter.namespacesForGVR[elem.gvr])
+		}
+	}
+
+	resObj, err := readJSON(deploymentProcuctpageV1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var resInfo = &resourceInfo{}
+	resController.parseResource(resObj, resInfo)
+	if !resourceComponentOfApplication(resController, appInfo, resInfo) {
+		t.Errorf("expecting %s/%s in the application namespace to be a component", resInfo.namespace, resInfo.name)
+	}
+}
+
+func TestResourceComponentOfApplicationSelf(t *testing.T) {
+	var resController = &ClusterWatcher{}
+	initControllerMaps(resController)
+
+	appObj, err := readJSON(appProductpage)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the application selects applications, and its own labels match its selector
+	componentKinds, _, _ := unstructured.NestedSlice(appObj.Object, SPEC, COMPONENTKINDS)
+	componentKinds = append(componentKinds, map[string]interface{}{GROUP: "app.k8s.io", KIND: APPLICATION})
+	unstructured.SetNestedSlice(appObj.Object, componentKinds, SPEC, COMPONENTKINDS)
+	var appInfo = &appResourceInfo{}
+	if err = resController.parseAppResource(appObj, appInfo); err != nil {
+		t.Fatal(err)
+	}
+
+	var self = &resourceInfo{}
+	resController.parseResource(appObj, self)
+	if resourceComponentOfApplication(resController, appInfo, self) {
+		t.Errorf("expecting application %s not to be a component of itself", appInfo.name)
+	}
+
+	// same namespace and name, different GVR
+	resObj, err := readJSON(deploymentProcuctpageV1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resObj.SetName(appObj.GetName())
+	resObj.SetUID(appObj.GetUID())
+	var sameName = &resourceInfo{}
+	resController.parseResource(resObj, sameName)
+	if !resourceComponentOfApplication(resController, appInfo, sameName) {
+		t.Errorf("expecting %s %s/%s with the name of the application to be a component", sameName.kind, sameName.namespace, sameName.name)
+	}
+}
+
+var sameResourceTestData = []struct {
+	res1     resourceInfo
+	res2     resourceInfo
+	expected bool
+}{
+	{resourceInfo{gvr: coreApplicationGVR, namespace: "default", name: "app1", uid: "1"},
+		resourceInfo{gvr: coreApplicationGVR, namespace: "default", name: "app1", uid: "1"}, true},
+	// uid not known
+	{resourceInfo{gvr: coreApplicationGVR, namespace: "default", name: "app1", uid: "1"},
+		resourceInfo{gvr: coreApplicationGVR, namespace: "default", name: "app1"}, true},
+	// re-created with the same name
+	{resourceInfo{gvr: coreApplicationGVR, namespace: "default", name: "app1", uid: "1"},
+		resourceInfo{gvr: coreApplicationGVR, namespace: "default", name: "app1", uid: "2"}, false},
+	{resourceInfo{gvr: coreApplicationGVR, namespace: "default", name: "app1"},
+		resourceInfo{gvr: coreApplicationGVR, namespace: "other", name: "app1"}, false},
+	{resourceInfo{gvr: coreApplicationGVR, namespace: "default", name: "app1"},
+		resourceInfo{gvr: coreDeploymentGVR, namespace: "default", name: "app1"}, false},
+}
+
+func TestIsSameResource(t *testing.T) {
+	for _, data := range sameResourceTestData {
+		if result := isSameResource(&data.res1, &data.res2); result != data.expected {
+			t.Errorf("isSameResource %s %s/%s uid %q and %s %s/%s uid %q: expecting %t, got %t",
+				data.res1.gvr, data.res1.namespace, data.res1.name, data.res1.uid,
+				data.res2.gvr, data.res2.namespace, data.res2.name, data.res2.uid, data.expected, result)
+		}
+	}
+}
+
+type expressionsMatchTestData struct {
+	expressions []matchExpression
+	labels      map[string]string
+	expected    bool
+}
+
+var integerExpressionsTestData = []expressionsMatchTestData{
+	{[]matchExpression{{key: "replica-index", operator: OperatorGreaterThan, values: []string{"2"}}}, map[string]string{"replica-index": "3"}, true},
+	{[]matchExpression{{key: "replica-index", operator: OperatorGreaterThan, values: []string{"2"}}}, map[string]string{"replica-index": "2"}, false},
+	{[]matchExpression{{key: "replica-index", operator: OperatorGreaterThan, values: []string{"-1"}}}, map[string]string{"replica-index": "0"}, true},
+	{[]matchExpression{{key: "version-major", operator: OperatorLessThan, values: []string{"3"}}}, map[string]string{"version-major": "2"}, true},
+	{[]matchExpression{{key: "version-major", operator: OperatorLessThan, values: []string{"3"}}}, map[string]string{"version-major": "3"}, false},
+	// missing label
+	{[]matchExpression{{key: "version-major", operator: OperatorLessThan, values: []string{"3"}}}, map[string]string{"app": "details"}, false},
+	// non-integer label or value
+	{[]matchExpression{{key: "version-major", operator: OperatorLessThan, values: []string{"3"}}}, map[string]string{"version-major": "v2"}, false},
+	{[]matchExpression{{key: "version-major", operator: OperatorGreaterThan, values: []string{"1"}}}, map[string]string{"version-major": "2.5"}, false},
+	{[]matchExpression{{key: "version-major", operator: OperatorGreaterThan, values: []string{"one"}}}, map[string]string{"version-major": "2"}, false},
+	// not exactly one value
+	{[]matchExpression{{key: "version-major", operator: OperatorGreaterThan, values: []string{"1", "2"}}}, map[string]string{"version-major": "3"}, false},
+	{[]matchExpression{{key: "version-major", operator: OperatorLessThan, values: []string{}}}, map[string]string{"version-major": "3"}, false},
+	// combined with other operators
+	{[]matchExpression{{key: "app", operator: OperatorIn, values: []string{"details"}}, {key: "replica-index", operator: OperatorLessThan, values: []string{"5"}}}, map[string]string{"app": "details", "replica-index": "4"}, true},
+	{[]matchExpression{{key: "app", operator: OperatorIn, values: []string{"details"}}, {key: "replica-index", operator: OperatorLessThan, values: []string{"5"}}}, map[string]string{"app": "details", "replica-index": "5"}, false},
+	// empty expressions never match
+	{[]matchExpression{}, map[string]string{"replica-index": "3"}, false},
+	{nil, map[string]string{"replica-index": "3"}, false},
+}
+
+func TestExpressionsMatchIntegerOperators(t *testing.T) {
+	for _, data := range integerExpressionsTestData {
+		if result := expressionsMatch(data.expressions, data.labels); result != data.expected {
+			t.Errorf("expressions %v, labels %v: expecting %t, got %t", data.expressions, data.labels, data.expected, result)
+		}
+	}
+}
+
+// predicate excluding one resource by namespace and name
+type excludeResourcePredicate struct {
+	namespace string
+	name      string
+}
+
+func (predicate excludeResourcePredicate) includes(appResInfo *appResourceInfo, resInfo *resourceInfo) bool {
+	return resInfo.namespace != predicate.namespace || resInfo.name != predicate.name
+}
+
+func TestResourceComponentOfApplicationPredicate(t *testing.T) {
+	var resController = &ClusterWatcher{}
+	initControllerMaps(resController)
+	appObj, err := readJSON(appProductpage)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var appInfo = &appResourceInfo{}
+	err = resController.parseAppResource(appObj, appInfo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var resInfos = make([]*resourceInfo, 0, 2)
+	for _, fileName := range []string{deploymentProcuctpageV1, serviceProductpage} {
+		resObj, err := readJSON(fileName)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var resInfo = &resourceInfo{}
+		resController.parseResource(resObj, resInfo)
+		if !resourceComponentOfApplication(resController, appInfo, resInfo) {
+			t.Fatalf("expecting %s to be a component of %s with default predicate", fileName, appProductpage)
+		}
+		resInfos = append(resInfos, resInfo)
+	}
+	deployment, service := resInfos[0], resInfos[1]
+
+	registerComponentPredicate("test", excludeResourcePredicate{namespace: deployment.namespace, name: deployment.name})
+	defer unregisterComponentPredicate("test")
+	if resourceComponentOfApplication(resController, appInfo, deployment) {
+		t.Errorf("expecting %s/%s to be excluded by predicate", deployment.namespace, deployment.name)
+	}
+	if !resourceComponentOfApplication(resController, appInfo, service) {
+		t.Errorf("expecting %s/%s to still be a component", service.namespace, service.name)
+	}
+}
+
+// Test the ancestry of a deleted application is kept apart from that of an application re-created with the same name
+func TestFindAllApplicationsNameReuse(t *testing.T) {
+	var resController = &ClusterWatcher{}
+	initControllerMaps(resController)
+	deletedObj, err := readJSON(appProductpage)
+	if err != nil {
+		t.Fatal(err)
+	}
+	recreatedObj := deletedObj.DeepCopy()
+	recreatedObj.SetUID(types.UID("7c3a4e9a-347d-11e9-9d73-0800275638b6"))
+
+	applications := make(map[string]*resourceInfo)
+	findAllApplicationsForResource(resController, deletedObj, applications)
+	findAllApplicationsForResource(resController, recreatedObj, applications)
+	if len(applications) != 2 {
+		t.Fatalf("expecting the deleted and the re-created application batched apart, got %d", len(applications))
+	}
+	for key, appInfo := range applications {
+		if key != appInfo.key() || appInfo.uid == "" {
+			t.Errorf("expecting application keyed with its uid %s, got %s", appInfo.uid, key)
+		}
+	}
+}
+
+// Test tombstones are unwrapped, and objects of the wrong type are skipped
+func TestStartWatchApplicationComponentKinds(t *testing.T) {
+	testName := "TestStartWatchApplicationComponentKinds"
+	beforeTest()
+	var kindsToCheckStatus = map[string]bool{}
+	var files = []string{
+		CrdApplication,
+		KappnavConfigFile,
+	}
+	iteration0IDs, err := readResourceIDs(files)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testActions := newTestActions(testName, kindsToCheckStatus)
+	clusterWatcher, err := createClusterWatcher(iteration0IDs, testActions, StatusFailureRate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer clusterWatcher.shutDown()
+
+	appObj, err := readJSON(appProductpage)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var appInfo = &resourceInfo{}
+	clusterWatcher.parseResource(appObj, appInfo)
+
+	// tombstone is unwrapped
+	applications := make(map[string]*resourceInfo)
+	tombstone := cache.DeletedFinalStateUnknown{Key: "default/productpage-app", Obj: appObj}
+	err = startWatchApplicationComponentKinds(clusterWatcher, tombstone, applications)
+	if err != nil {
+		t.Fatalf("unexpected error for tombstone: %s", err)
+	}
+	if _, ok := applications[appInfo.key()]; !ok {
+		t.Errorf("expecting application %s from tombstone to be batched, got %v", appInfo.key(), applications)
+	}
+
+	// wrong type is skipped without error
+	applications = make(map[string]*resourceInfo)
+	err = startWatchApplicationComponentKinds(clusterWatcher, "not an application", applications)
+	if err != nil {
+		t.Errorf("unexpected error for wrong-typed object: %s", err)
+	}
+	if len(applications) != 0 {
+		t.Errorf("expecting no applications batched for wrong-typed object, got %v", applications)
+	}
+}
+
+// typed object that is not unstructured
+type typedApplication struct {
+	metav1.ObjectMeta
+}
+
+// Test listed applications that are not unstructured are skipped
+func TestGetApplicationsForResourceUnexpectedType(t *testing.T) {
+	testName := "TestGetApplicationsForResourceUnexpectedType"
+	beforeTest()
+	// kinds to check for status
+	var kindsToCheckStatus = map[string]bool{
+		APPLICATION:  true,
+		"Deployment": true,
+	}
+
+	var files = []string{
+		/* 0 */ CrdApplication,
+		/* 1 */ appProductpage,
+		/* 2 */ deploymentProcuctpageV1,
+		/* 3 */ KappnavConfigFile,
+	}
+	iteration0IDs, err := readResourceIDs(files)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	/* Iteration 0: all normal */
+	testActions := newTestActions(testName, kindsToCheckStatus)
+	var emptyIDs = []resourceID{}
+	testActions.addIteration(iteration0IDs, emptyIDs)
+
+	clusterWatcher, err := createClusterWatcher(iteration0IDs, testActions, StatusFailureRate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer clusterWatcher.shutDown()
+
+	err = testActions.transitionAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rw := clusterWatcher.getResourceWatcher(coreApplicationGVR)
+	if rw == nil {
+		t.Fatal("applications not watched")
+	}
+	typed := &typedApplication{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "typed-app"}}
+	if err = rw.store.Add(typed); err != nil {
+		t.Fatal(err)
+	}
+	defer rw.store.Delete(typed)
+
+	deploymentObj, err := getResource(clusterWatcher, iteration0IDs[2])
+	if err != nil {
+		t.Fatal(err)
+	}
+	var resInfo = &resourceInfo{}
+	clusterWatcher.parseResource(deploymentObj, resInfo)
+	apps := getApplicationsForResource(clusterWatcher, resInfo)
+	if len(apps) != 1 || apps[0].name != iteration0IDs[1].name {
+		t.Errorf("expecting only application %s for %s, got %d applications", iteration0IDs[1].name, resInfo.name, len(apps))
+	}
+}
+
+// Test parents of an updated application are batched up only if its overall status changed
+func TestBatchApplicationHandlerParentRequeue(t *testing.T) {
+	testName := "TestBatchApplicationHandlerParentRequeue"
+	beforeTest()
+	// kinds to check for status
+	var kindsToCheckStatus = map[string]bool{
+		APPLICATION:  true,
+		"Service":    true,
+		"Deployment": true,
+	}
+
+	var files = []string{
+		/* 0 */ CrdApplication,
+		/* 1 */ appBookinfo,
+		/* 2 */ appProductpage,
+		/* 3 */ deploymentProcuctpageV1,
+		/* 4 */ serviceProductpage,
+		/* 5 */ KappnavConfigFile,
+	}
+	iteration0IDs, err := readResourceIDs(files)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	/* Iteration 0: all normal */
+	testActions := newTestActions(testName, kindsToCheckStatus)
+	var emptyIDs = []resourceID{}
+	testActions.addIteration(iteration0IDs, emptyIDs)
+
+	clusterWatcher, err := createClusterWatcher(iteration0IDs, testActions, StatusFailureRate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer clusterWatcher.shutDown()
+
+	err = testActions.transitionAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// capture what is sent for batch processing
+	batchChannel := clusterWatcher.resourceChannel
+	captureChannel := newResourceChannel()
+	clusterWatcher.resourceChannel = captureChannel
+	defer func() {
+		clusterWatcher.resourceChannel = batchChannel
+	}()
+
+	rw := clusterWatcher.getResourceWatcher(coreApplicationGVR)
+	if rw == nil {
+		t.Fatal("applications not watched")
+	}
+	child, parent := iteration0IDs[2], iteration0IDs[1]
+	key := child.namespace + "/" + child.name
+	obj, exists, err := rw.store.GetByKey(key)
+	if err != nil || !exists {
+		t.Fatalf("unable to get application %s from cache: %v", key, err)
+	}
+	oldObj := obj.(*unstructured.Unstructured)
+
+	// returns whether the parent is batched up after an update of the child
+	parentBatched := func(newObj *unstructured.Unstructured) bool {
+		eventData := &eventHandlerData{
+			funcType: UpdateFunc,
+			kind:     APPLICATION,
+			gvr:      coreApplicationGVR,
+			key:      key,
+			obj:      newObj,
+			oldObj:   oldObj,
+		}
+		if err := batchApplicationHandler(clusterWatcher, rw, eventData); err != nil {
+			t.Fatal(err)
+		}
+		select {
+		case resources := <-captureChannel.batchResourceChan:
+			for _, app := range resources.applications {
+				if app.namespace == parent.namespace && app.name == parent.name {
+					return true
+				}
+			}
+			return false
+		case <-time.After(time.Second * 5):
+			t.Fatal("timed out waiting for applications to be batched")
+		}
+		return false
+	}
+
+	// components churned, but overall status unchanged
+	unchanged := oldObj.DeepCopy()
+	annotations := unchanged.GetAnnotations()
+	annotations[kappnavStatusFlyover] = testName
+	unchanged.SetAnnotations(annotations)
+	if parentBatched(unchanged) {
+		t.Errorf("expecting parent %s not to be batched up when status of %s is unchanged", parent.name, child.name)
+	}
+
+	// overall status changed
+	changed := oldObj.DeepCopy()
+	annotations = changed.GetAnnotations()
+	annotations[kappnavStatusValue] = warning
+	changed.SetAnnotations(annotations)
+	if !parentBatched(changed) {
+		t.Errorf("expecting parent %s to be batched up when status of %s changed", parent.name, child.name)
+	}
+}
+
+// Test a top-level application is never a component of another application, even if its labels match
+func TestTopLevelApplicationExcluded(t *testing.T) {
+	testName := "TestTopLevelApplicationExcluded"
+	beforeTest()
+	// kinds to check for status
+	var kindsToCheckStatus = map[string]bool{
+		APPLICATION: true,
+	}
+
+	var files = []string{
+		/* 0 */ CrdApplication,
+		/* 1 */ appBookinfo,
+		/* 2 */ appProductpage,
+		/* 3 */ KappnavConfigFile,
+	}
+	iteration0IDs, err := readResourceIDs(files)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testActions := newTestActions(testName, kindsToCheckStatus)
+	// no components other than applications
+	iteration0IDs[1].expectedStatus = unknown
+	iteration0IDs[2].expectedStatus = unknown
+	var emptyIDs = []resourceID{}
+	testActions.addIteration(iteration0IDs, emptyIDs)
+
+	clusterWatcher, err := createClusterWatcher(iteration0IDs, testActions, StatusFailureRate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer clusterWatcher.shutDown()
+
+	err = testActions.transitionAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	appObj, err := getResource(clusterWatcher, iteration0IDs[2])
+	if err != nil {
+		t.Fatal(err)
+	}
+	var productpage = &resourceInfo{}
+	clusterWatcher.parseResource(appObj, productpage)
+	if apps := getApplicationsForResource(clusterWatcher, productpage); len(apps) != 1 || apps[0].name != "bookinfo" {
+		t.Fatalf("expecting productpage-app to be a component of bookinfo, got %d applications", len(apps))
+	}
+
+	topLevelObj := appObj.DeepCopy()
+	annotations := topLevelObj.GetAnnotations()
+	if annotations == nil {
+		annotations = make(map[string]string)
+	}
+	annotations[kappnavTopLevel] = "true"
+	topLevelObj.SetAnnotations(annotations)
+	var topLevel = &resourceInfo{}
+	clusterWatcher.parseResource(topLevelObj, topLevel)
+	if apps := getApplicationsForResource(clusterWatcher, topLevel); len(apps) != 0 {
+		t.Errorf("expecting top-level productpage-app not to be a component of any application, got %s", apps[0].name)
+	}
+
+	// only applications are top-level
+	resObj, err := readJSON(deploymentProcuctpageV1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resObj.SetAnnotations(map[string]string{kappnavTopLevel: "true"})
+	var deployment = &resourceInfo{}
+	clusterWatcher.parseResource(resObj, deployment)
+	if deployment.isTopLevel() {
+		t.Errorf("expecting a %s never to be top-level", deployment.kind)
+	}
+}
@@ -0,0 +1,143 @@
+/*
+Copyright 2019 IBM Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/klog"
+)
+
+/*
+ Status of an application computed on demand from the cached components,
+ for debugging and dashboards. Components use the status last written to
+ them, and child applications their last computed status, so the API server
+ is not called. Nothing is written.
+*/
+
+const (
+	// path prefix of GET /applications/{namespace}/{name}/status
+	applicationStatusPathPrefix = "/applications/"
+)
+
+// Response of GET /applications/{namespace}/{name}/status
+type applicationStatusResponse struct {
+	Namespace    string                       `json:"namespace"`
+	Name         string                       `json:"name"`
+	Status       string                       `json:"status"`
+	Availability string                       `json:"availability"`
+	Breakdown    map[string]int               `json:"breakdown"`  // number of components for each status
+	Components   []applicationComponentStatus `json:"components"` // components counted in the status
+}
+
+// Status of one component of the application
+type applicationComponentStatus struct {
+	Kind      string  `json:"kind"`
+	Namespace string  `json:"namespace"`
+	Name      string  `json:"name"`
+	Status    string  `json:"status"`
+	Weight    float64 `json:"weight"`
+}
+
+// Compute the status of an application from the cached components.
+// Return nil if the application is not in the cache
+func (resController *ClusterWatcher) computeApplicationStatus(namespace string, name string) (*applicationStatusResponse, error) {
+	obj, exists, err := resController.getApplication(namespace, name)
+	if err != nil || !exists {
+		return nil, err
+	}
+	unstructuredObj, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		return nil, nil
+	}
+	var resInfo = &resourceInfo{}
+	resController.parseResource(unstructuredObj, resInfo)
+
+	key := resController.resourceKey(resInfo)
+	hasStatus := make(map[string]*resourceInfo)
+	_, status, breakdown, availability, err := processOneApplication(resController, resInfo, make(map[string]*resourceInfo), hasStatus,
+		make(map[string]*resourceInfo), map[string]*resourceInfo{key: resInfo}, make(map[string]*resourceInfo))
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &applicationStatusResponse{
+		Namespace:    namespace,
+		Name:         name,
+		Status:       status,
+		Availability: availability,
+		Breakdown:    breakdown,
+		Components:   make([]applicationComponentStatus, 0),
+	}
+	if computed, ok := hasStatus[key]; ok {
+		for _, component := range computed.components {
+			resp.Components = append(resp.Components, applicationComponentStatus{
+				Kind:      component.resInfo.kind,
+				Namespace: component.resInfo.namespace,
+				Name:      component.resInfo.name,
+				Status:    component.status,
+				Weight:    component.weight,
+			})
+		}
+	}
+	sort.Slice(resp.Components, func(i, j int) bool {
+		if resp.Components[i].Kind != resp.Components[j].Kind {
+			return resp.Components[i].Kind < resp.Components[j].Kind
+		}
+		if resp.Components[i].Namespace != resp.Components[j].Namespace {
+			return resp.Components[i].Namespace < resp.Components[j].Namespace
+		}
+		return resp.Components[i].Name < resp.Components[j].Name
+	})
+	return resp, nil
+}
+
+// Handler for GET /applications/{namespace}/{name}/status
+func applicationStatusHandler(resController *ClusterWatcher) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", http.MethodGet)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		parts := strings.Split(strings.TrimPrefix(r.URL.Path, applicationStatusPathPrefix), "/")
+		if len(parts) != 3 || parts[0] == "" || parts[1] == "" || parts[2] != "status" {
+			http.NotFound(w, r)
+			return
+		}
+		resp, err := resController.computeApplicationStatus(parts[0], parts[1])
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if resp == nil {
+			http.Error(w, "application "+parts[0]+"/"+parts[1]+" not found", http.StatusNotFound)
+			return
+		}
+		if klog.V(4) {
+			klog.Infof("computeApplicationStatus %s/%s status: %s breakdown: %v", parts[0], parts[1], resp.Status, resp.Breakdown)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(resp); err != nil && klog.V(2) {
+			klog.Infof("computeApplicationStatus unable to write response: %s", err)
+		}
+	}
+}
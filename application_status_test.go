@@ -0,0 +1,145 @@
+/*
+Copyright 2019 IBM Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// GET the path from the HTTP endpoints
+func getApplicationStatus(resController *ClusterWatcher, method string, path string) *httptest.ResponseRecorder {
+	recorder := httptest.NewRecorder()
+	newHTTPHandler(resController).ServeHTTP(recorder, httptest.NewRequest(method, path, nil))
+	return recorder
+}
+
+type applicationStatusTestData struct {
+	path               string
+	expectedCode       int
+	expectedStatus     string
+	expectedComponents string // kind/namespace/name=status of the components
+}
+
+var applicationStatusTestDataArray = []applicationStatusTestData{
+	{path: "/applications/default/productpage-app/status", expectedCode: http.StatusOK, expectedStatus: warning,
+		expectedComponents: "Deployment/default/productpage-v1=" + warning},
+	{path: "/applications/default/details-app/status", expectedCode: http.StatusOK, expectedStatus: Normal,
+		expectedComponents: "Deployment/default/details-v1=" + Normal},
+	{path: "/applications/default/bookinfo/status", expectedCode: http.StatusOK, expectedStatus: warning,
+		expectedComponents: "Application/default/details-app=" + Normal + ",Application/default/productpage-app=" + warning},
+	{path: "/applications/default/missing-app/status", expectedCode: http.StatusNotFound},
+	{path: "/applications/default/bookinfo", expectedCode: http.StatusNotFound},
+	{path: "/applications/default/bookinfo/health", expectedCode: http.StatusNotFound},
+	{path: "/applications/default/bookinfo/status/components", expectedCode: http.StatusNotFound},
+}
+
+// Test the status of an application is computed on demand from the cached components, without writing it
+func TestApplicationStatusEndpoint(t *testing.T) {
+	testName := "TestApplicationStatusEndpoint"
+	beforeTest()
+	// kinds to check for status
+	var kindsToCheckStatus = map[string]bool{
+		APPLICATION:  true,
+		"Deployment": true,
+	}
+
+	var files = []string{
+		/* 0 */ CrdApplication,
+		/* 1 */ appBookinfo,
+		/* 2 */ appProductpage,
+		/* 3 */ appDetails,
+		/* 4 */ deploymentProcuctpageV1,
+		/* 5 */ deploymentDetailsV1,
+		/* 6 */ KappnavConfigFile,
+	}
+	iteration0IDs, err := readResourceIDs(files)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testActions := newTestActions(testName, kindsToCheckStatus)
+	iteration0IDs[1].expectedStatus = warning // bookinfo warning due to productpage app
+	iteration0IDs[2].expectedStatus = warning // productpage app warning due to its deployment
+	iteration0IDs[4].expectedStatus = warning
+	var emptyIDs = []resourceID{}
+	testActions.addIteration(iteration0IDs, emptyIDs)
+
+	clusterWatcher, err := createClusterWatcher(iteration0IDs, testActions, StatusFailureRate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer clusterWatcher.shutDown()
+
+	err = testActions.transitionAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, data := range applicationStatusTestDataArray {
+		recorder := getApplicationStatus(clusterWatcher, http.MethodGet, data.path)
+		if recorder.Code != data.expectedCode {
+			t.Errorf("path %s: expecting code %d, got %d: %s", data.path, data.expectedCode, recorder.Code, recorder.Body.String())
+			continue
+		}
+		if data.expectedCode != http.StatusOK {
+			continue
+		}
+		var resp applicationStatusResponse
+		if err := json.Unmarshal(recorder.Body.Bytes(), &resp); err != nil {
+			t.Errorf("path %s: unable to parse response %s: %s", data.path, recorder.Body.String(), err)
+			continue
+		}
+		if resp.Status != data.expectedStatus {
+			t.Errorf("path %s: expecting status %s, got %s", data.path, data.expectedStatus, resp.Status)
+		}
+		components := make([]string, 0, len(resp.Components))
+		total := 0
+		for _, component := range resp.Components {
+			components = append(components, component.Kind+"/"+component.Namespace+"/"+component.Name+"="+component.Status)
+			if component.Weight != 1 {
+				t.Errorf("path %s: expecting weight 1 for %s, got %v", data.path, component.Name, component.Weight)
+			}
+		}
+		for _, count := range resp.Breakdown {
+			total += count
+		}
+		if joined := strings.Join(components, ","); joined != data.expectedComponents {
+			t.Errorf("path %s: expecting components %q, got %q", data.path, data.expectedComponents, joined)
+		}
+		if total != len(resp.Components) {
+			t.Errorf("path %s: expecting breakdown %v to count the %d components", data.path, resp.Breakdown, len(resp.Components))
+		}
+	}
+
+	// nothing is written
+	obj, err := getResource(clusterWatcher, iteration0IDs[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status := obj.GetAnnotations()[kappnavStatusValue]; status != warning {
+		t.Errorf("expecting bookinfo still %s, got %s", warning, status)
+	}
+
+	recorder := getApplicationStatus(clusterWatcher, http.MethodPost, "/applications/default/bookinfo/status")
+	if recorder.Code != http.StatusMethodNotAllowed {
+		t.Errorf("expecting code %d for POST, got %d", http.StatusMethodNotAllowed, recorder.Code)
+	}
+}
@@ -0,0 +1,140 @@
+/*
+Copyright 2019 IBM Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/klog"
+)
+
+/*
+ Applications annotated with kappnav.io/assembly-phase=true also get the
+ standard status.assemblyPhase of app.k8s.io Applications, computed from the
+ readiness of their components, and status.observedGeneration, the generation
+ of the application the phase was computed for.
+*/
+
+const (
+	assemblyPhasePending   = "Pending"   // no components, or components whose status is not known yet
+	assemblyPhaseSucceeded = "Succeeded" // all components ready
+	assemblyPhaseFailed    = "Failed"    // some components not ready
+
+	assemblyPhaseField      = "assemblyPhase"
+	observedGenerationField = "observedGeneration"
+)
+
+// Assembly phase of an application, and the generation of the application it was computed for
+type assemblyStatus struct {
+	phase              string
+	observedGeneration int64
+}
+
+// Return the assembly phase of an application from the number of its components in each status.
+// A component is ready if its status is the healthy status
+func assemblyPhase(breakdown map[string]int, healthyStatus string, unknownStatus string) string {
+	total, ready, unknown := 0, 0, 0
+	for status, count := range breakdown {
+		total += count
+		switch status {
+		case healthyStatus:
+			ready += count
+		case unknownStatus:
+			unknown += count
+		}
+	}
+	switch {
+	case total == 0:
+		return assemblyPhasePending
+	case ready == total:
+		return assemblyPhaseSucceeded
+	case ready+unknown < total:
+		return assemblyPhaseFailed
+	default:
+		return assemblyPhasePending
+	}
+}
+
+// Return the assembly status to write for an application with the given number of components
+// in each status, or nil if the application does not opt in. generation is the generation of
+// the application the status was computed for
+func (resController *ClusterWatcher) newAssemblyStatus(resInfo *resourceInfo, breakdown map[string]int, generation int64) *assemblyStatus {
+	if resInfo.kind != APPLICATION {
+		return nil
+	}
+	if value, _ := resInfo.annotations[kappnavAssemblyPhase].(string); value != "true" {
+		return nil
+	}
+	return &assemblyStatus{
+		phase:              assemblyPhase(breakdown, resController.healthyStatus(), resController.getUnknownStatus()),
+		observedGeneration: generation,
+	}
+}
+
+// Return the assembly status of a resource, or nil if it has none
+func getAssemblyStatus(unstructuredObj *unstructured.Unstructured) *assemblyStatus {
+	phase, found, err := unstructured.NestedString(unstructuredObj.Object, STATUS, assemblyPhaseField)
+	if err != nil || !found {
+		return nil
+	}
+	status := &assemblyStatus{phase: phase}
+	switch generation := unstructuredObj.Object[STATUS].(map[string]interface{})[observedGenerationField].(type) {
+	case int64:
+		status.observedGeneration = generation
+	case float64:
+		status.observedGeneration = int64(generation)
+	}
+	return status
+}
+
+// Return true if the assembly status of an opted-in application, with the given number of components
+// in each status, differs from the one it has
+func (resController *ClusterWatcher) assemblyStatusChanged(resInfo *resourceInfo, breakdown map[string]int) bool {
+	if resInfo.unstructuredObj == nil {
+		return false
+	}
+	status := resController.newAssemblyStatus(resInfo, breakdown, resInfo.unstructuredObj.GetGeneration())
+	return status != nil && !status.sameAs(getAssemblyStatus(resInfo.unstructuredObj))
+}
+
+// Return true if the status is the same as other
+func (status *assemblyStatus) sameAs(other *assemblyStatus) bool {
+	return other != nil && status.phase == other.phase && status.observedGeneration == other.observedGeneration
+}
+
+// Return true if the status was computed for an older generation than the resource is now at,
+// or than the assembly status already written. It is then not written, for the status of the
+// newer generation to be written instead
+func (status *assemblyStatus) staleFor(unstructuredObj *unstructured.Unstructured) bool {
+	if status.observedGeneration < unstructuredObj.GetGeneration() {
+		return true
+	}
+	existing := getAssemblyStatus(unstructuredObj)
+	return existing != nil && existing.observedGeneration > status.observedGeneration
+}
+
+// Set status.assemblyPhase and status.observedGeneration
+func setAssemblyStatus(unstructuredObj *unstructured.Unstructured, status *assemblyStatus) {
+	if err := unstructured.SetNestedField(unstructuredObj.Object, status.phase, STATUS, assemblyPhaseField); err != nil {
+		if klog.V(2) {
+			klog.Infof("setAssemblyStatus unable to set assembly phase for %s: %s", unstructuredObj.GetName(), err)
+		}
+		return
+	}
+	if err := unstructured.SetNestedField(unstructuredObj.Object, status.observedGeneration, STATUS, observedGenerationField); err != nil && klog.V(2) {
+		klog.Infof("setAssemblyStatus unable to set observed generation for %s: %s", unstructuredObj.GetName(), err)
+	}
+}
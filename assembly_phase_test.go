@@ -0,0 +1,145 @@
+/*
+Copyright 2019 IBM Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"testing"
+)
+
+type assemblyPhaseTestData struct {
+	breakdown map[string]int
+	expected  string
+}
+
+var assemblyPhaseTestDataArray = []assemblyPhaseTestData{
+	{breakdown: nil, expected: assemblyPhasePending},
+	{breakdown: map[string]int{}, expected: assemblyPhasePending},
+	{breakdown: map[string]int{Normal: 3}, expected: assemblyPhaseSucceeded},
+	{breakdown: map[string]int{Normal: 2, "Unknown": 1}, expected: assemblyPhasePending},
+	{breakdown: map[string]int{"Unknown": 2}, expected: assemblyPhasePending},
+	{breakdown: map[string]int{Normal: 2, warning: 1}, expected: assemblyPhaseFailed},
+	{breakdown: map[string]int{problem: 1, "Unknown": 1}, expected: assemblyPhaseFailed},
+	{breakdown: map[string]int{"Red Alert": 1}, expected: assemblyPhaseFailed},
+}
+
+func TestAssemblyPhase(t *testing.T) {
+	var resController = &ClusterWatcher{
+		statusPrecedence: []string{"Red Alert", problem, warning, "Unknown", Normal},
+		unknownStatus:    "Unknown",
+	}
+	for _, data := range assemblyPhaseTestDataArray {
+		phase := assemblyPhase(data.breakdown, resController.healthyStatus(), resController.getUnknownStatus())
+		if phase != data.expected {
+			t.Errorf("assembly phase for breakdown %v: expecting %s, got %s", data.breakdown, data.expected, phase)
+		}
+	}
+}
+
+func TestNewAssemblyStatus(t *testing.T) {
+	var resController = &ClusterWatcher{
+		statusPrecedence: []string{"Red Alert", problem, warning, "Unknown", Normal},
+		unknownStatus:    "Unknown",
+	}
+	resInfo := &resourceInfo{
+		kind:        APPLICATION,
+		annotations: map[string]interface{}{},
+	}
+	breakdown := map[string]int{Normal: 1}
+	if resController.newAssemblyStatus(resInfo, breakdown, 1) != nil {
+		t.Error("expecting no assembly status without the annotation")
+	}
+	resInfo.annotations[kappnavAssemblyPhase] = "true"
+	status := resController.newAssemblyStatus(resInfo, breakdown, 2)
+	if status == nil || status.phase != assemblyPhaseSucceeded || status.observedGeneration != 2 {
+		t.Errorf("expecting assembly status Succeeded at generation 2, got %+v", status)
+	}
+	resInfo.kind = "Deployment"
+	if resController.newAssemblyStatus(resInfo, breakdown, 2) != nil {
+		t.Error("expecting no assembly status for a component")
+	}
+}
+
+// Test an opted-in application with an unchanged kappnav status is changed when its assembly status is not written yet
+func TestAssemblyStatusChanged(t *testing.T) {
+	var resController = &ClusterWatcher{
+		statusPrecedence: []string{"Red Alert", problem, warning, "Unknown", Normal},
+		unknownStatus:    "Unknown",
+	}
+	unstructuredObj, err := readJSON(appProductpage)
+	if err != nil {
+		t.Fatal(err)
+	}
+	unstructuredObj.SetGeneration(2)
+	var resInfo = &resourceInfo{}
+	resController.parseResource(unstructuredObj, resInfo)
+	breakdown := map[string]int{Normal: 2}
+	if resController.assemblyStatusChanged(resInfo, breakdown) {
+		t.Error("expecting no assembly status change without the annotation")
+	}
+
+	unstructuredObj.SetAnnotations(map[string]string{kappnavAssemblyPhase: "true"})
+	resController.parseResource(unstructuredObj, resInfo)
+	if !resController.assemblyStatusChanged(resInfo, breakdown) {
+		t.Error("expecting assembly status change before it is written")
+	}
+	setAssemblyStatus(unstructuredObj, &assemblyStatus{phase: assemblyPhaseSucceeded, observedGeneration: 2})
+	if resController.assemblyStatusChanged(resInfo, breakdown) {
+		t.Error("expecting no assembly status change once written")
+	}
+	if !resController.assemblyStatusChanged(resInfo, map[string]int{Normal: 1, problem: 1}) {
+		t.Error("expecting assembly status change when a component is no longer ready")
+	}
+}
+
+func TestSetAssemblyStatus(t *testing.T) {
+	unstructuredObj, err := readJSON(appProductpage)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if getAssemblyStatus(unstructuredObj) != nil {
+		t.Fatal("expecting no assembly status before it is set")
+	}
+	unstructuredObj.SetGeneration(3)
+
+	status := &assemblyStatus{phase: assemblyPhaseFailed, observedGeneration: 3}
+	if status.staleFor(unstructuredObj) {
+		t.Error("expecting status of the current generation not to be stale")
+	}
+	setAssemblyStatus(unstructuredObj, status)
+	if current := getAssemblyStatus(unstructuredObj); !status.sameAs(current) {
+		t.Fatalf("expecting assembly status %+v, got %+v", *status, current)
+	}
+
+	// generation read back from JSON
+	unstructuredObj.Object[STATUS].(map[string]interface{})[observedGenerationField] = float64(3)
+	if current := getAssemblyStatus(unstructuredObj); !status.sameAs(current) {
+		t.Errorf("expecting assembly status %+v, got %+v", *status, current)
+	}
+
+	// computed from an older generation of the application
+	unstructuredObj.SetGeneration(4)
+	if !status.staleFor(unstructuredObj) {
+		t.Error("expecting status of an older generation of the application to be stale")
+	}
+
+	// older than the status already written
+	setAssemblyStatus(unstructuredObj, &assemblyStatus{phase: assemblyPhaseSucceeded, observedGeneration: 5})
+	status = &assemblyStatus{phase: assemblyPhaseFailed, observedGeneration: 4}
+	if !status.staleFor(unstructuredObj) {
+		t.Error("expecting status older than the observed generation to be stale")
+	}
+}
@@ -20,6 +20,7 @@ import (
 	"fmt"
 	"strings"
 
+	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime/schema"
@@ -533,7 +534,7 @@ func autoCreateModifyApplication(resController *ClusterWatcher, rw *ResourceWatc
 		}
 		appResInfo := &appResourceInfo{}
 		err = resController.parseAppResource(unstructuredObj, appResInfo)
-		if err != nil {
+		if isFatalParseError(err) {
 			klog.Errorf("parseApplication error %s", err)
 			return err
 		}
@@ -548,6 +549,9 @@ func autoCreateModifyApplication(resController *ClusterWatcher, rw *ResourceWatc
 			if klog.V(2) {
 				klog.Infof("Changing autocreated application annotations and labels for %s/%s", resInfo.namespace, resInfo.autoCreateName)
 			}
+			if resController.skipWrite("annotations and labels of auto-created application %s/%s", resInfo.namespace, resInfo.autoCreateName) {
+				return nil
+			}
 			setApplicationAnnotationLabels(unstructuredObj, resInfo)
 			_, err = intf.Update(unstructuredObj, metav1.UpdateOptions{})
 			if err != nil {
@@ -664,6 +668,9 @@ func createApplication(resController *ClusterWatcher, rw *ResourceWatcher, resIn
 			return err
 		}
 
+		if resController.skipWrite("auto-created application %s/%s: %s", resInfo.namespace, resInfo.autoCreateName, template) {
+			return nil
+		}
 		_, err = intf.Create(unstructuredObj, metav1.CreateOptions{})
 		if err != nil {
 			klog.Errorf("Unable to create Application %s/%s error: %s", resInfo.namespace, resInfo.autoCreateName, err)
@@ -818,6 +825,9 @@ func sameMatchExpressions(expressions1 []matchExpression, expressions2 []matchEx
 		if !sameStringArray(expr1.values, expr2.values) {
 			return false
 		}
+		if expr1.source != expr2.source {
+			return false
+		}
 	}
 	return true
 }
@@ -885,6 +895,12 @@ func autoCreatedApplicationNeedsUpdate(appResInfo *appResourceInfo, resInfo *aut
 			}
 			return true
 		}
+		if matchExpression.source == expressionSourceAnnotation {
+			if klog.V(5) {
+				klog.Infof("autoCreatedApplicationNeedsUpdate created app matches annotation %s instead of label", matchExpression.key)
+			}
+			return true
+		}
 
 		if !sameStringArray(matchExpression.values, resInfo.autoCreateLabelValues) {
 			if klog.V(5) {
@@ -903,7 +919,8 @@ func autoCreatedApplicationNeedsUpdate(appResInfo *appResourceInfo, resInfo *aut
 	return false
 }
 
-/* Return true if the resource exists */
+// Return true if the resource exists, or if it can not be told
+// because of an error other than the resource not being found
 func resourceExisting(dynamicClient dynamic.Interface, namespace string, name string, gvr schema.GroupVersionResource) bool {
 	var intfNoNS = dynamicClient.Resource(gvr)
 	var intf dynamic.ResourceInterface
@@ -916,12 +933,38 @@ func resourceExisting(dynamicClient dynamic.Interface, namespace string, name st
 	// fetch the current resource
 	_, err := intf.Get(name, metav1.GetOptions{})
 	if err != nil {
+		if errors.IsNotFound(err) {
+			return false
+		}
 		klog.Errorf("resourceExisting error: %s, type: %T", err, err)
-		return false
 	}
 	return true
 }
 
+// Return the name and kind of the resource an application was auto-created from.
+// Return false for applications not auto-created, which are never orphans,
+// e.g. user-authored applications that copied the annotations but not the label
+func autoCreatedFrom(appResInfo *appResourceInfo) (string, string, bool) {
+	if !autoGenerated(appResInfo) {
+		return "", "", false
+	}
+	fromName, fromKind, ok := getAutoCreatedFromParams(appResInfo.annotations)
+	if !ok || fromName == "" || fromKind == "" {
+		return "", "", false
+	}
+	return fromName, fromKind, true
+}
+
+// Return true if the application was auto-created from a resource that no longer exists.
+// sourceExists returns whether the resource of the kind exists in the namespace of the application
+func isOrphanedAutoCreatedApplication(appResInfo *appResourceInfo, sourceExists func(kind string, namespace string, name string) bool) bool {
+	fromName, fromKind, ok := autoCreatedFrom(appResInfo)
+	if !ok {
+		return false
+	}
+	return !sourceExists(fromKind, appResInfo.namespace, fromName)
+}
+
 /* Delte auto-creqated applications  whose original resource no longer exists
  */
 func deleteOrphanedAutoCreatedApplications(resController *ClusterWatcher) error {
@@ -949,43 +992,34 @@ func deleteOrphanedAutoCreatedApplications(resController *ClusterWatcher) error
 	for _, unstructuredObj := range unstructuredList.Items {
 		var appResInfo = &appResourceInfo{}
 		err = resController.parseAppResource(&unstructuredObj, appResInfo)
-		if err != nil {
+		if isFatalParseError(err) {
 			continue
 		}
 		if klog.V(4) {
 			klog.Infof("    deleteOrphanedAutoCreatedApplications checking application: %s\n", appResInfo.name)
 		}
 
-		annotationsObj, ok := appResInfo.metadata[ANNOTATIONS]
-		if !ok {
-			continue
-		}
-
-		annotations, ok := annotationsObj.(map[string]interface{})
-		if !ok {
-			continue
-		}
-
-		fromName, fromKind, ok := getAutoCreatedFromParams(annotations)
-		if !ok {
-			continue
-		}
-
-		fromGVR, ok := resController.getWatchGVRForKind(fromKind)
-		if !ok {
-			if klog.V(5) {
-				klog.Errorf("Error in deleteOrphanedAutoCreatedApplications: Unable to find GVR for %s", fromKind)
+		orphaned := isOrphanedAutoCreatedApplication(appResInfo, func(kind string, namespace string, name string) bool {
+			fromGVR, ok := resController.getWatchGVRForKind(kind)
+			if !ok {
+				// kind no longer watched. Keep the application rather than guess
+				if klog.V(5) {
+					klog.Errorf("Error in deleteOrphanedAutoCreatedApplications: Unable to find GVR for %s", kind)
+				}
+				return true
 			}
-			continue
-		}
-		if !resourceExisting(resController.plugin.dynamicClient, appResInfo.namespace, fromName, fromGVR) {
+			return resourceExisting(resController.plugin.dynamicClient, namespace, name, fromGVR)
+		})
+		if orphaned {
 			if klog.V(4) {
-				klog.Infof("    deleting application: %s/%s created from name: %s kind: %s\n", appResInfo.namespace, appResInfo.name, fromName, fromKind)
+				klog.Infof("    deleting application: %s/%s created from name: %s kind: %s\n", appResInfo.namespace, appResInfo.name,
+					appResInfo.annotations[AppAutoCreatedFromName], appResInfo.annotations[AppAutoCreatedFromKind])
 			}
 			err := deleteResource(resController, &appResInfo.resourceInfo)
 			if err != nil {
 				klog.Errorf("Error deleting orphaned application:  %s/%s. Error: %s\n", appResInfo.namespace, appResInfo.name, err)
-			} else {
+			} else if !resController.plugin.DryRun {
+				orphanedApplicationsDeleted.Inc()
 				if klog.V(2) {
 					klog.Infof("Deleted orphaned auto-created application %s/%s\n", appResInfo.namespace, appResInfo.name)
 				}
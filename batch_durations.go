@@ -0,0 +1,70 @@
+/*
+Copyright 2019 IBM Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+/*
+ Changes are batched for the batchDuration by default. With the
+ resourceBatchDurations flag, e.g. pods=10s,applications.app.k8s.io=500ms,
+ the changes of a resource are batched for the duration of the resource
+ instead. Resources are named resource.group, as kinds of the same name may
+ be defined in more than one API group, and without version, as all versions
+ of a resource share its changes. A batch is flushed
+ once the shortest window of the changes it holds expires, so a change of a
+ kind with a short window is not delayed by changes of kinds with long
+ windows batched before it, while changes of high churn kinds alone keep
+ coalescing for their longer window.
+*/
+
+// Batch duration of each resource, parsed from resourceBatchDurations at startup. Empty for none
+var batchDurationsByResource map[schema.GroupResource]time.Duration
+
+// Parse the setting of the resourceBatchDurations flag into a map from resource to batch duration
+func parseResourceBatchDurations(value string) (map[schema.GroupResource]time.Duration, error) {
+	ret := make(map[schema.GroupResource]time.Duration)
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		parts := strings.Split(entry, "=")
+		if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" {
+			return nil, fmt.Errorf("%s is not of the form resource.group=duration", entry)
+		}
+		duration, err := time.ParseDuration(strings.TrimSpace(parts[1]))
+		if err != nil {
+			return nil, fmt.Errorf("batch duration of %s: %s", parts[0], err)
+		}
+		if duration <= 0 {
+			return nil, fmt.Errorf("batch duration %s of %s must be positive", parts[1], parts[0])
+		}
+		ret[schema.ParseGroupResource(strings.TrimSpace(parts[0]))] = duration
+	}
+	return ret, nil
+}
+
+// Return the batch duration of a resource, or 0 for the default batch duration
+func resourceBatchDuration(groupResource schema.GroupResource) time.Duration {
+	return batchDurationsByResource[groupResource]
+}
@@ -0,0 +1,169 @@
+/*
+Copyright 2019 IBM Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/clock"
+)
+
+func TestParseResourceBatchDurations(t *testing.T) {
+	durations, err := parseResourceBatchDurations("pods=10s, applications.app.k8s.io=500ms")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pods := schema.GroupResource{Resource: "pods"}
+	applications := schema.GroupResource{Group: "app.k8s.io", Resource: "applications"}
+	if len(durations) != 2 || durations[pods] != time.Second*10 || durations[applications] != time.Millisecond*500 {
+		t.Errorf("unexpected batch durations %v", durations)
+	}
+	for _, invalid := range []string{"pods", "pods=soon", "pods=0s", "pods=-1s", "=5s"} {
+		if _, err := parseResourceBatchDurations(invalid); err == nil {
+			t.Errorf("expecting %q to be invalid", invalid)
+		}
+	}
+}
+
+// Test resources of the same kind in different API groups have their own batch duration,
+// shared by all versions of each
+func TestResourceBatchDuration(t *testing.T) {
+	defer func(saved map[schema.GroupResource]time.Duration) { batchDurationsByResource = saved }(batchDurationsByResource)
+	durations, err := parseResourceBatchDurations("applications.app.k8s.io=500ms")
+	if err != nil {
+		t.Fatal(err)
+	}
+	batchDurationsByResource = durations
+
+	resController := &ClusterWatcher{resourceMap: make(map[schema.GroupVersionResource]*ResourceWatcher)}
+	for _, data := range []struct {
+		apiVersion string
+		expected   time.Duration
+	}{
+		{"app.k8s.io/v1beta1", time.Millisecond * 500},
+		{"app.k8s.io/v1", time.Millisecond * 500},
+		{"argoproj.io/v1alpha1", 0},
+	} {
+		gv, err := schema.ParseGroupVersion(data.apiVersion)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rw, _ := resController.mapResource(APPLICATION, gv.Group, gv.Version, "applications", true)
+		if rw.batchDuration != data.expected {
+			t.Errorf("%s: expecting batch duration %s, got %s", data.apiVersion, data.expected, rw.batchDuration)
+		}
+	}
+}
+
+// Test changes with a short window are flushed sooner than changes with a long window
+func TestBatchWindows(t *testing.T) {
+	const longWindow = time.Second * 10
+	const shortWindow = time.Second
+	fakeClock := clock.NewFakeClock(time.Unix(0, 0))
+	resController := &ClusterWatcher{resourceChannel: newResourceChannel()}
+	ts := newBatchStore(resController, time.Minute)
+	ts.clock = fakeClock
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	ts.stopCh = stopCh
+
+	batches := make(chan *batchResources, 10)
+	go func() {
+		for {
+			resources, ok := ts.getNextBatch()
+			if !ok {
+				return
+			}
+			batches <- resources
+		}
+	}()
+	send := func(name string, window time.Duration) {
+		resInfo := &resourceInfo{kind: "Pod", namespace: "default", name: name}
+		resController.resourceChannel.send(&batchResources{
+			applications:    map[string]*resourceInfo{},
+			nonApplications: map[string]*resourceInfo{resInfo.key(): resInfo},
+			window:          window,
+		})
+	}
+	// wait for the batch to end after the window
+	waitWindow := func(window time.Duration) {
+		t.Helper()
+		deadline := fakeClock.Now().Add(window)
+		if err := waitFor("batch window", func() bool { return batchDeadline(ts).Equal(deadline) }); err != nil {
+			t.Fatal(err)
+		}
+	}
+	expectBatch := func(what string, expected ...string) {
+		t.Helper()
+		select {
+		case resources := <-batches:
+			if len(resources.nonApplications) != len(expected) {
+				t.Errorf("%s: expecting %d resources, got %d", what, len(expected), len(resources.nonApplications))
+			}
+			names := make(map[string]bool)
+			for _, resInfo := range resources.nonApplications {
+				names[resInfo.name] = true
+			}
+			for _, name := range expected {
+				if !names[name] {
+					t.Errorf("%s: expecting %s in the batch", what, name)
+				}
+			}
+		case <-time.After(time.Second * 5):
+			t.Fatalf("%s: timed out waiting for the batch", what)
+		}
+	}
+	expectNoBatch := func(what string) {
+		t.Helper()
+		select {
+		case resources := <-batches:
+			t.Errorf("%s: expecting no batch, got %d resources", what, len(resources.nonApplications))
+		case <-time.After(time.Millisecond * 100):
+		}
+	}
+
+	// a long window kind alone waits for its own window, not the default
+	send("slow1", longWindow)
+	waitWindow(longWindow)
+	fakeClock.Step(shortWindow)
+	expectNoBatch("long window after the short window")
+	fakeClock.Step(longWindow - shortWindow)
+	expectBatch("long window", "slow1")
+
+	// a short window kind is flushed at the end of its window, taking the
+	// long window changes batched before it along
+	send("slow2", longWindow)
+	waitWindow(longWindow)
+	send("fast1", shortWindow)
+	waitWindow(shortWindow)
+	fakeClock.Step(shortWindow)
+	expectBatch("short window", "slow2", "fast1")
+
+	// the replaced timer of the long window pops without flushing
+	fakeClock.Step(longWindow)
+	expectNoBatch("replaced long window")
+
+	// the default batch duration applies without a window
+	send("default1", 0)
+	waitWindow(time.Minute)
+	fakeClock.Step(longWindow)
+	expectNoBatch("default window after the long window")
+	fakeClock.Step(time.Minute - longWindow)
+	expectBatch("default window", "default1")
+}
@@ -20,6 +20,7 @@ import (
 	"sync"
 	"time"
 
+	"k8s.io/apimachinery/pkg/util/clock"
 	"k8s.io/klog"
 )
 
@@ -31,6 +32,20 @@ import (
  resources usage during time of high activity.
  Resources that did not process successfully may be put back into
  batchStore for retry
+
+ Within a batch, resources are keyed, so an application referenced by any
+ number of events is recomputed once per flush, from the resourceInfo of
+ its latest event. A batch may be flushed early when it reaches the maximum
+ size. An application changed again after it was flushed early is held
+ until the window of the batch ends, and recomputed in the next batch
+ instead, so that it is recomputed at most once per window. Its status then
+ lags by up to one more batch duration. Batches are processed one at a time,
+ in order, so a later batch always sees the changes of an earlier one.
+
+ At shutdown the producers are stopped first, then the channel is closed.
+ Senders blocked on a full channel are released rather than holding up the
+ close. What is still batched is then processed as a final batch, without
+ waiting for the batch window, before the consumer stops.
 */
 
 const (
@@ -48,12 +63,15 @@ var (
 type batchResources struct {
 	applications    map[string]*resourceInfo
 	nonApplications map[string]*resourceInfo
+	window          time.Duration // time the changes may be batched, from the kind of the event. 0 for the batch duration
 }
 
 // Closeable channel to send resources.
 // Once closed, sends are ignored
 type resourceChannel struct {
 	batchResourceChan chan *batchResources
+	closing           chan struct{}  // closed once closing, to release blocked senders
+	senders           sync.WaitGroup // sends in progress
 	done              bool
 	mutex             sync.Mutex
 }
@@ -62,6 +80,7 @@ type resourceChannel struct {
 func newResourceChannel() *resourceChannel {
 	var resourceChannel = &resourceChannel{
 		batchResourceChan: make(chan *batchResources, 1024),
+		closing:           make(chan struct{}),
 		done:              false,
 	}
 	return resourceChannel
@@ -69,25 +88,40 @@ func newResourceChannel() *resourceChannel {
 
 /*
   Close the channel
-  Once closed, all future sends are ignored
+  Once closed, all future sends are ignored. Sends blocked on a full
+  channel are dropped, so that closing never waits for the consumer
 */
 func (rc *resourceChannel) close() {
 	rc.mutex.Lock()
-	defer rc.mutex.Unlock()
-
-	if !rc.done {
-		rc.done = true
-		close(rc.batchResourceChan)
+	if rc.done {
+		rc.mutex.Unlock()
+		return
 	}
+	rc.done = true
+	close(rc.closing)
+	rc.mutex.Unlock()
+
+	rc.senders.Wait()
+	close(rc.batchResourceChan)
 }
 
 // Send on the channel if not closed
 func (rc *resourceChannel) send(resource *batchResources) {
 	rc.mutex.Lock()
-	defer rc.mutex.Unlock()
+	if rc.done {
+		rc.mutex.Unlock()
+		return
+	}
+	rc.senders.Add(1)
+	rc.mutex.Unlock()
+	defer rc.senders.Done()
 
-	if !rc.done {
-		rc.batchResourceChan <- resource
+	select {
+	case rc.batchResourceChan <- resource:
+	case <-rc.closing:
+		if klog.V(4) {
+			klog.Infof("resourceChannel.send dropping %d applications and %d resources at shutdown\n", len(resource.applications), len(resource.nonApplications))
+		}
 	}
 }
 
@@ -96,12 +130,20 @@ func (rc *resourceChannel) send(resource *batchResources) {
 */
 type batchStore struct {
 	batchDuration time.Duration   // how long to batch
+	warmupPeriod  time.Duration   // how long to only batch at startup. 0 for no warmup
+	maxBatchSize  int             // number of applications and resources at which a batch is flushed early. 0 for no limit
+	stopCh        <-chan struct{} // closed to stop the consumer at once, dropping what is batched. nil to stop only once the resource channel is closed and drained
 	resController *ClusterWatcher // the cluster watcher
 
-	timerStarted bool            // whether timer had started
-	done         bool            // done  if no more resources to batch
-	timerChan    chan struct{}   // timer channel to send timer event
-	store        *batchResources // the actual resources to process
+	clock        clock.Clock              // source of time of the batch windows and the warmup. A fake clock in tests
+	timerStarted bool                     // whether timer had started
+	deadline     time.Time                // time the started timer pops
+	timerGen     int                      // generation of the started timer. Timers of earlier generations are ignored
+	done         bool                     // done  if no more resources to batch
+	timerChan    chan int                 // timer channel to send timer event, with the generation of the timer
+	store        *batchResources          // the actual resources to process
+	flushed      map[string]bool          // keys of applications flushed early in the current window
+	held         map[string]*resourceInfo // applications changed after being flushed early, for the next window
 
 	mutex sync.Mutex
 }
@@ -114,7 +156,8 @@ batchInterval: amount of time to batch resources before making them available fo
 func newBatchStore(resController *ClusterWatcher, batchInterval time.Duration) *batchStore {
 	ts := &batchStore{}
 	ts.resController = resController
-	ts.timerChan = make(chan struct{}, 1)
+	ts.clock = clock.RealClock{}
+	ts.timerChan = make(chan int, 16)
 	ts.batchDuration = batchInterval
 	ts.timerStarted = false
 	ts.done = false
@@ -122,20 +165,45 @@ func newBatchStore(resController *ClusterWatcher, batchInterval time.Duration) *
 		applications:    make(map[string]*resourceInfo),
 		nonApplications: make(map[string]*resourceInfo),
 	}
+	ts.flushed = make(map[string]bool)
+	ts.held = make(map[string]*resourceInfo)
 	return ts
 }
 
-/*
- start timer to wait for more resources to batch up
-*/
-func (ts *batchStore) startTimer() {
-	if !ts.timerStarted {
+// End the window of the current batch. Applications held since they were
+// flushed early start the next batch. Must be called with the mutex held
+func (ts *batchStore) nextWindow() {
+	ts.flushed = make(map[string]bool)
+	for key, resInfo := range ts.held {
+		ts.store.applications[key] = resInfo
+	}
+	if len(ts.held) > 0 {
+		if klog.V(4) {
+			klog.Infof("batchStore.nextWindow %d applications held for the next batch\n", len(ts.held))
+		}
+		ts.held = make(map[string]*resourceInfo)
+		ts.startTimer(0)
+	}
+}
+
+// start timer to wait for more resources to batch up, for the window of the
+// resources, or the batch duration if 0. A started timer is replaced only if
+// the window ends sooner
+func (ts *batchStore) startTimer(window time.Duration) {
+	if window <= 0 {
+		window = ts.batchDuration
+	}
+	deadline := ts.clock.Now().Add(window)
+	if !ts.timerStarted || deadline.Before(ts.deadline) {
 		ts.timerStarted = true
+		ts.deadline = deadline
+		ts.timerGen++
 		timerChan := ts.timerChan
-		duration := ts.batchDuration
+		gen := ts.timerGen
+		after := ts.clock.After(window)
 		go func() {
-			time.Sleep(duration)
-			timerChan <- struct{}{}
+			<-after
+			timerChan <- gen
 		}()
 	}
 }
@@ -148,6 +216,7 @@ func (ts *batchStore) startTimer() {
  *     true if there resources to process, and false to shut down
  */
 func (ts *batchStore) getNextBatch() (*batchResources, bool) {
+	var resumed <-chan struct{} // closed once the API server is available again, while holding a batch
 	for {
 		select {
 		case resources, open := <-ts.resController.resourceChannel.batchResourceChan:
@@ -159,23 +228,69 @@ func (ts *batchStore) getNextBatch() (*batchResources, bool) {
 					klog.Infof("batchStore.getNextBatch channel closed\n")
 				}
 				ts.done = true
+				// drain what is batched as a final batch
+				for key, resInfo := range ts.held {
+					ts.store.applications[key] = resInfo
+				}
+				ts.held = make(map[string]*resourceInfo)
+				ret := ts.store
+				ts.store = &batchResources{
+					applications:    make(map[string]*resourceInfo),
+					nonApplications: make(map[string]*resourceInfo),
+				}
 				ts.mutex.Unlock()
-				return nil, false
+				if len(ret.applications) == 0 && len(ret.nonApplications) == 0 {
+					return nil, false
+				}
+				if klog.V(2) {
+					klog.Infof("batchStore.getNextBatch processing %d applications and %d resources before stopping\n", len(ret.applications), len(ret.nonApplications))
+				}
+				return ret, true
 			}
 			if klog.V(4) {
 				klog.Infof("batchStore.getNextBatch received %d applications and %d resources\n", len(resources.applications), len(resources.nonApplications))
 			}
+			// applications referenced by multiple events in the same batch are processed once
 			for _, resInfo := range resources.applications {
-				ts.store.applications[resInfo.key()] = resInfo
+				key := ts.resController.resourceKey(resInfo)
+				if ts.flushed[key] {
+					// already recomputed in this window
+					ts.held[key] = resInfo
+					continue
+				}
+				ts.store.applications[key] = resInfo
 			}
 			for _, resInfo := range resources.nonApplications {
-				ts.store.nonApplications[resInfo.key()] = resInfo
+				ts.store.nonApplications[ts.resController.resourceKey(resInfo)] = resInfo
+			}
+			if ts.isFull() && ts.resController.apiHealth.recoveredChan() == nil {
+				// flush early to bound memory, e.g. on a relist. The timer started for this
+				// batch pops for the next one
+				if klog.V(2) {
+					klog.Infof("batchStore.getNextBatch flushing early at %d applications and %d resources\n", len(ts.store.applications), len(ts.store.nonApplications))
+				}
+				batchesFlushedEarly.Inc()
+				for key := range ts.store.applications {
+					ts.flushed[key] = true
+				}
+				ret := ts.store
+				ts.store = &batchResources{
+					applications:    make(map[string]*resourceInfo),
+					nonApplications: make(map[string]*resourceInfo),
+				}
+				ts.mutex.Unlock()
+				return ret, true
 			}
-			ts.startTimer()
+			ts.startTimer(resources.window)
 			ts.mutex.Unlock()
 
-		case <-ts.timerChan:
+		case gen := <-ts.timerChan:
 			ts.mutex.Lock()
+			if gen != ts.timerGen {
+				// replaced by a timer of a shorter window
+				ts.mutex.Unlock()
+				continue
+			}
 			// If we are here, there is something in the store
 			if klog.V(4) {
 				klog.Infof("batchStore.getNextBatch timer popped applications %d, resources %d\n", len(ts.store.applications), len(ts.store.nonApplications))
@@ -185,17 +300,55 @@ func (ts *batchStore) getNextBatch() (*batchResources, bool) {
 				return nil, false
 			}
 			ts.timerStarted = false // reset
+			if len(ts.store.applications) == 0 && len(ts.store.nonApplications) == 0 {
+				// already flushed early
+				ts.nextWindow()
+				ts.mutex.Unlock()
+				continue
+			}
+			if resumed = ts.resController.apiHealth.recoveredChan(); resumed != nil {
+				// keep batching until the API server is available again
+				if klog.V(4) {
+					klog.Infof("batchStore.getNextBatch holding batch while the API server is unavailable\n")
+				}
+				ts.mutex.Unlock()
+				continue
+			}
 			ret := ts.store
 			ts.store = &batchResources{
 				applications:    make(map[string]*resourceInfo),
 				nonApplications: make(map[string]*resourceInfo),
 			}
+			ts.nextWindow()
 			ts.mutex.Unlock()
 			return ret, true
+
+		case <-ts.stopCh:
+			if klog.V(4) {
+				klog.Infof("batchStore.getNextBatch stopped\n")
+			}
+			ts.mutex.Lock()
+			ts.done = true
+			ts.mutex.Unlock()
+			return nil, false
+
+		case <-resumed:
+			// process what was batched while the API server was unavailable
+			resumed = nil
+			ts.mutex.Lock()
+			if len(ts.store.applications) > 0 || len(ts.store.nonApplications) > 0 {
+				ts.startTimer(0)
+			}
+			ts.mutex.Unlock()
 		}
 	}
 }
 
+// Return true if the store reached the maximum batch size. Must be called with the mutex held
+func (ts *batchStore) isFull() bool {
+	return ts.maxBatchSize > 0 && len(ts.store.applications)+len(ts.store.nonApplications) >= ts.maxBatchSize
+}
+
 // Put back resources to be retried again
 // Check to ensure resources still exist before putting them back
 func (ts *batchStore) putBack(resources *batchResources, putbackError error) {
@@ -256,7 +409,7 @@ func (ts *batchStore) putBack(resources *batchResources, putbackError error) {
 	// start timer
 	// TODO: adjust timer based on frequency of error
 	if numPutBack > 0 {
-		ts.startTimer()
+		ts.startTimer(0)
 	}
 }
 
@@ -267,8 +420,12 @@ func (ts *batchStore) run() {
 	if klog.V(2) {
 		klog.Infof("batchStore.run started\n")
 	}
+	if !ts.warmup() {
+		return
+	}
 	for {
 		if resources, ok := ts.getNextBatch(); ok {
+			batchSize.Observe(float64(len(resources.applications) + len(resources.nonApplications)))
 			if err := processBatchOfApplicationsAndResources(ts, resources); err != nil {
 				// put them back for retry later
 				if klog.V(4) {
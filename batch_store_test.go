@@ -0,0 +1,416 @@
+/*
+Copyright 2019 IBM Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"k8s.io/apimachinery/pkg/util/clock"
+)
+
+// Test an application referenced by three events in one batch is computed once
+func TestBatchDeduplicatesApplications(t *testing.T) {
+	testName := "TestBatchDeduplicatesApplications"
+	beforeTest()
+	// kinds to check for status
+	var kindsToCheckStatus = map[string]bool{
+		APPLICATION:  true,
+		"Service":    true,
+		"Deployment": true,
+	}
+
+	var files = []string{
+		/* 0 */ CrdApplication,
+		/* 1 */ appBookinfo,
+		/* 2 */ appProductpage,
+		/* 3 */ deploymentProcuctpageV1,
+		/* 4 */ serviceProductpage,
+		/* 5 */ KappnavConfigFile,
+	}
+	iteration0IDs, err := readResourceIDs(files)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	/* Iteration 0: all normal */
+	testActions := newTestActions(testName, kindsToCheckStatus)
+	var emptyIDs = []resourceID{}
+	testActions.addIteration(iteration0IDs, emptyIDs)
+
+	clusterWatcher, err := createClusterWatcher(iteration0IDs, testActions, StatusFailureRate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer clusterWatcher.shutDown()
+
+	err = testActions.transitionAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// batch on a separate channel
+	batchChannel := clusterWatcher.resourceChannel
+	clusterWatcher.resourceChannel = newResourceChannel()
+	defer func() {
+		clusterWatcher.resourceChannel = batchChannel
+	}()
+	ts := newBatchStore(clusterWatcher, time.Millisecond*100)
+
+	var resInfos = make([]*resourceInfo, 0, len(iteration0IDs))
+	for _, id := range iteration0IDs[1:5] {
+		unstructuredObj, err := getResource(clusterWatcher, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var resInfo = &resourceInfo{}
+		clusterWatcher.parseResource(unstructuredObj, resInfo)
+		resInfos = append(resInfos, resInfo)
+	}
+	bookinfo, productpage, deployment, service := resInfos[0], resInfos[1], resInfos[2], resInfos[3]
+
+	// productpage application referenced by an application event, and by events of two of its components
+	clusterWatcher.resourceChannel.send(&batchResources{
+		applications:    map[string]*resourceInfo{productpage.key(): productpage},
+		nonApplications: map[string]*resourceInfo{},
+	})
+	clusterWatcher.resourceChannel.send(&batchResources{
+		applications:    map[string]*resourceInfo{productpage.key(): productpage, bookinfo.key(): bookinfo},
+		nonApplications: map[string]*resourceInfo{deployment.key(): deployment},
+	})
+	clusterWatcher.resourceChannel.send(&batchResources{
+		applications:    map[string]*resourceInfo{productpage.key(): productpage, bookinfo.key(): bookinfo},
+		nonApplications: map[string]*resourceInfo{service.key(): service},
+	})
+
+	// all events are already sent, so they join the same batch
+	resources, ok := ts.getNextBatch()
+	if !ok {
+		t.Fatal("batch store closed")
+	}
+	if len(resources.applications) != 2 || len(resources.nonApplications) != 2 {
+		t.Fatalf("expecting 2 applications and 2 resources in batch, got %d applications and %d resources", len(resources.applications), len(resources.nonApplications))
+	}
+
+	// productpage is also a component of bookinfo, but is still computed once
+	before := testutil.ToFloat64(applicationStatusComputations)
+	if err = processBatchOfApplicationsAndResources(ts, resources); err != nil {
+		t.Fatal(err)
+	}
+	if computed := testutil.ToFloat64(applicationStatusComputations) - before; computed != 2 {
+		t.Errorf("expecting 2 application status computations, got %v", computed)
+	}
+}
+
+// Test a large influx of changes is processed in batches bounded by the maximum batch size, without losing any
+func TestBatchFlushedEarly(t *testing.T) {
+	const maxSize = 10
+	const total = 25
+	resController := &ClusterWatcher{resourceChannel: newResourceChannel()}
+	ts := newBatchStore(resController, time.Millisecond*100)
+	ts.maxBatchSize = maxSize
+
+	for i := 0; i < total; i++ {
+		resInfo := &resourceInfo{kind: "Deployment", namespace: "default", name: fmt.Sprintf("deployment%d", i)}
+		resources := &batchResources{
+			applications:    map[string]*resourceInfo{},
+			nonApplications: map[string]*resourceInfo{resInfo.key(): resInfo},
+		}
+		if i%5 == 0 {
+			app := &resourceInfo{kind: APPLICATION, namespace: "default", name: fmt.Sprintf("app%d", i)}
+			resources.applications[app.key()] = app
+		}
+		resController.resourceChannel.send(resources)
+	}
+
+	before := testutil.ToFloat64(batchesFlushedEarly)
+	seen := make(map[string]bool)
+	batches := 0
+	for len(seen) < total+total/5 {
+		resources, ok := ts.getNextBatch()
+		if !ok {
+			t.Fatal("batch store closed")
+		}
+		batches++
+		size := len(resources.applications) + len(resources.nonApplications)
+		// the last send may add both an application and a resource
+		if size > maxSize+1 {
+			t.Errorf("expecting batch %d bounded by %d, got %d", batches, maxSize, size)
+		}
+		for key := range resources.applications {
+			seen[key] = true
+		}
+		for key := range resources.nonApplications {
+			seen[key] = true
+		}
+	}
+	if batches < 3 {
+		t.Errorf("expecting at least 3 batches, got %d", batches)
+	}
+	if flushed := testutil.ToFloat64(batchesFlushedEarly) - before; flushed < 2 {
+		t.Errorf("expecting at least 2 batches flushed early, got %v", flushed)
+	}
+}
+
+// Return the sorted namespace/name of the applications of a batch, joined
+func batchApplicationKeys(resources *batchResources) string {
+	keys := make([]string, 0, len(resources.applications))
+	for _, resInfo := range resources.applications {
+		keys = append(keys, resInfo.namespace+"/"+resInfo.name)
+	}
+	sort.Strings(keys)
+	return strings.Join(keys, ",")
+}
+
+// Test an application changed again after its batch was flushed early is recomputed once per window, in the next batch
+func TestBatchCoalescesAcrossEarlyFlush(t *testing.T) {
+	resController := &ClusterWatcher{resourceChannel: newResourceChannel()}
+	ts := newBatchStore(resController, time.Millisecond*200)
+	ts.maxBatchSize = 2
+
+	apps := make([]*resourceInfo, 0, 3)
+	for _, name := range []string{"app1", "app2", "app3"} {
+		apps = append(apps, &resourceInfo{kind: APPLICATION, namespace: "default", name: name})
+	}
+	send := func(resInfos ...*resourceInfo) {
+		resources := &batchResources{
+			applications:    map[string]*resourceInfo{},
+			nonApplications: map[string]*resourceInfo{},
+		}
+		for _, resInfo := range resInfos {
+			resources.applications[resInfo.key()] = resInfo
+		}
+		resController.resourceChannel.send(resources)
+	}
+
+	// window 1: flushed early when full, then app1 changes again
+	send(apps[0])
+	send(apps[0], apps[1])
+	send(apps[0], apps[2])
+	send(apps[0])
+	var expected = []string{
+		"default/app1,default/app2", // flushed early
+		"default/app3",              // end of window 1, without app1
+		"default/app1",              // window 2
+	}
+	for i, expectedKeys := range expected {
+		resources, ok := ts.getNextBatch()
+		if !ok {
+			t.Fatal("batch store closed")
+		}
+		if keys := batchApplicationKeys(resources); keys != expectedKeys {
+			t.Errorf("batch %d: expecting applications %s, got %s", i, expectedKeys, keys)
+		}
+	}
+
+	// window 3: nothing held over
+	ts.mutex.Lock()
+	flushed, held := len(ts.flushed), len(ts.held)
+	ts.mutex.Unlock()
+	if flushed != 0 || held != 0 {
+		t.Errorf("expecting no application flushed or held after the last window, got %d flushed and %d held", flushed, held)
+	}
+}
+
+// Return the time the current batch of the store ends
+func batchDeadline(ts *batchStore) time.Time {
+	ts.mutex.Lock()
+	defer ts.mutex.Unlock()
+	return ts.deadline
+}
+
+// Test a batch is flushed once the batch duration passes on the clock of the store, and not before
+func TestBatchFlushedAfterBatchDuration(t *testing.T) {
+	fakeClock := clock.NewFakeClock(time.Unix(0, 0))
+	resController := &ClusterWatcher{resourceChannel: newResourceChannel()}
+	ts := newBatchStore(resController, time.Minute)
+	ts.clock = fakeClock
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	ts.stopCh = stopCh
+
+	batches := make(chan *batchResources, 10)
+	go func() {
+		for {
+			resources, ok := ts.getNextBatch()
+			if !ok {
+				return
+			}
+			batches <- resources
+		}
+	}()
+	send := func(names ...string) {
+		resources := &batchResources{
+			applications:    map[string]*resourceInfo{},
+			nonApplications: map[string]*resourceInfo{},
+		}
+		for _, name := range names {
+			resInfo := &resourceInfo{kind: APPLICATION, namespace: "default", name: name}
+			resources.applications[resInfo.key()] = resInfo
+		}
+		resController.resourceChannel.send(resources)
+	}
+
+	send("app1")
+	deadline := fakeClock.Now().Add(time.Minute)
+	if err := waitFor("batch window", func() bool { return batchDeadline(ts).Equal(deadline) }); err != nil {
+		t.Fatal(err)
+	}
+	fakeClock.Step(time.Minute - time.Second)
+	send("app1", "app2")
+	if err := waitFor("second change", func() bool {
+		ts.mutex.Lock()
+		defer ts.mutex.Unlock()
+		return len(ts.store.applications) == 2
+	}); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case resources := <-batches:
+		t.Fatalf("expecting no batch before the batch duration, got %s", batchApplicationKeys(resources))
+	default:
+	}
+	if !batchDeadline(ts).Equal(deadline) {
+		t.Errorf("expecting the batch to still end at %s, got %s", deadline, batchDeadline(ts))
+	}
+
+	fakeClock.Step(time.Second)
+	select {
+	case resources := <-batches:
+		if keys := batchApplicationKeys(resources); keys != "default/app1,default/app2" {
+			t.Errorf("expecting applications default/app1,default/app2, got %s", keys)
+		}
+	case <-time.After(time.Second * 5):
+		t.Fatal("timed out waiting for the batch")
+	}
+}
+
+// Test cancelling a context given as stopCh stops the batch consumer at once, without closing the resource channel
+func TestBatchStoreStoppedByContext(t *testing.T) {
+	for _, warmupPeriod := range []time.Duration{0, time.Minute} {
+		resController := &ClusterWatcher{resourceChannel: newResourceChannel()}
+		ctx, cancel := context.WithCancel(context.Background())
+		ts := newBatchStore(resController, time.Minute)
+		ts.warmupPeriod = warmupPeriod
+		ts.stopCh = ctx.Done()
+
+		stopped := make(chan struct{})
+		go func() {
+			ts.run()
+			close(stopped)
+		}()
+		select {
+		case <-stopped:
+			t.Fatalf("warmup %s: expecting batch consumer running before cancel", warmupPeriod)
+		case <-time.After(time.Millisecond * 50):
+		}
+
+		cancel()
+		select {
+		case <-stopped:
+		case <-time.After(time.Second * 5):
+			t.Fatalf("warmup %s: timed out waiting for the batch consumer to stop", warmupPeriod)
+		}
+		ts.mutex.Lock()
+		done := ts.done
+		ts.mutex.Unlock()
+		if !done {
+			t.Errorf("warmup %s: expecting batch store done once stopped", warmupPeriod)
+		}
+	}
+}
+
+// Test closing the channel drains what is batched as a final batch, without waiting for the batch window
+func TestBatchStoreDrainedOnClose(t *testing.T) {
+	resController := &ClusterWatcher{resourceChannel: newResourceChannel()}
+	ts := newBatchStore(resController, time.Minute)
+	app := &resourceInfo{gvr: coreApplicationGVR, kind: APPLICATION, namespace: "default", name: "app1"}
+	resController.resourceChannel.send(&batchResources{
+		applications:    map[string]*resourceInfo{resController.resourceKey(app): app},
+		nonApplications: map[string]*resourceInfo{},
+	})
+	resController.resourceChannel.close()
+
+	batches := make(chan *batchResources, 2)
+	go func() {
+		for {
+			resources, ok := ts.getNextBatch()
+			if !ok {
+				close(batches)
+				return
+			}
+			batches <- resources
+		}
+	}()
+	select {
+	case resources, ok := <-batches:
+		if !ok {
+			t.Fatal("expecting a final batch before stopping")
+		}
+		if keys := batchApplicationKeys(resources); keys != "default/app1" {
+			t.Errorf("expecting application default/app1 in the final batch, got %s", keys)
+		}
+	case <-time.After(time.Second * 5):
+		t.Fatal("timed out waiting for the final batch")
+	}
+	select {
+	case _, ok := <-batches:
+		if ok {
+			t.Error("expecting no batch after the final one")
+		}
+	case <-time.After(time.Second * 5):
+		t.Fatal("timed out waiting for the batch store to stop")
+	}
+}
+
+// Test closing the channel is not held up by a send blocked on a full channel
+func TestResourceChannelCloseReleasesSenders(t *testing.T) {
+	rc := newResourceChannel()
+	for i := 0; i < cap(rc.batchResourceChan); i++ {
+		rc.send(&batchResources{})
+	}
+	sent := make(chan struct{})
+	go func() {
+		rc.send(&batchResources{})
+		close(sent)
+	}()
+	select {
+	case <-sent:
+		t.Fatal("expecting the send to block on a full channel")
+	case <-time.After(time.Millisecond * 50):
+	}
+
+	closed := make(chan struct{})
+	go func() {
+		rc.close()
+		close(closed)
+	}()
+	for _, done := range []chan struct{}{closed, sent} {
+		select {
+		case <-done:
+		case <-time.After(time.Second * 5):
+			t.Fatal("timed out closing the channel with a blocked sender")
+		}
+	}
+	// sends after close are ignored
+	rc.send(&batchResources{})
+}
@@ -0,0 +1,166 @@
+/*
+Copyright 2019 IBM Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/klog"
+)
+
+/*
+ Per namespace circuit breakers, to stop calling the API server for an
+ operation that keeps failing in a namespace, e.g. creating configmaps when
+ the quota of the namespace is exceeded.
+*/
+
+// States of a circuit breaker, also the values of its metric
+const (
+	breakerClosed   = 0 // operations are attempted
+	breakerOpen     = 1 // operations are suppressed until the cooldown expires
+	breakerHalfOpen = 2 // one operation is attempted to decide whether to close again
+
+	defaultBreakerFailureThreshold = 5
+	defaultBreakerCooldown         = time.Minute
+)
+
+// Circuit breaker for one namespace
+type circuitBreaker struct {
+	state     int
+	failures  int       // consecutive failures while closed
+	openedAt  time.Time // time the breaker last opened
+	lastError error     // error that last opened the breaker
+}
+
+// Circuit breakers of an operation, one for each namespace
+type namespaceBreakers struct {
+	operation        string               // name of the operation, for logging
+	failureThreshold int                  // consecutive failures to open the breaker. 0 to never open
+	cooldown         time.Duration        // time the breaker stays open before half-opening
+	state            *prometheus.GaugeVec // state of the breaker of each namespace
+	breakers         map[string]*circuitBreaker
+	mutex            sync.Mutex
+}
+
+func newNamespaceBreakers(operation string, failureThreshold int, cooldown time.Duration, state *prometheus.GaugeVec) *namespaceBreakers {
+	return &namespaceBreakers{
+		operation:        operation,
+		failureThreshold: failureThreshold,
+		cooldown:         cooldown,
+		state:            state,
+		breakers:         make(map[string]*circuitBreaker),
+	}
+}
+
+// Return the breaker of a namespace. Must be called with the mutex held
+func (nb *namespaceBreakers) breaker(namespace string) *circuitBreaker {
+	breaker, ok := nb.breakers[namespace]
+	if !ok {
+		breaker = &circuitBreaker{state: breakerClosed}
+		nb.breakers[namespace] = breaker
+	}
+	return breaker
+}
+
+// Change the state of a breaker. Must be called with the mutex held
+func (nb *namespaceBreakers) setState(namespace string, breaker *circuitBreaker, state int) {
+	breaker.state = state
+	if nb.state != nil {
+		nb.state.WithLabelValues(namespace).Set(float64(state))
+	}
+}
+
+// Return true if the operation may be attempted in the namespace.
+// Once the cooldown of an open breaker expires, a single attempt is allowed
+// until its outcome is recorded
+func (nb *namespaceBreakers) allow(namespace string) bool {
+	if nb == nil || nb.failureThreshold <= 0 {
+		return true
+	}
+	nb.mutex.Lock()
+	defer nb.mutex.Unlock()
+	breaker := nb.breaker(namespace)
+	switch breaker.state {
+	case breakerOpen:
+		if time.Since(breaker.openedAt) < nb.cooldown {
+			return false
+		}
+		if klog.V(2) {
+			klog.Infof("retrying %s in namespace %s after cooldown", nb.operation, namespace)
+		}
+		nb.setState(namespace, breaker, breakerHalfOpen)
+		return true
+	case breakerHalfOpen:
+		// retry already in progress
+		return false
+	default:
+		return true
+	}
+}
+
+// Record the outcome of an attempted operation in the namespace
+func (nb *namespaceBreakers) record(namespace string, err error) {
+	if nb == nil || nb.failureThreshold <= 0 {
+		return
+	}
+	nb.mutex.Lock()
+	defer nb.mutex.Unlock()
+	breaker := nb.breaker(namespace)
+	if err == nil {
+		if breaker.state != breakerClosed && klog.V(2) {
+			klog.Infof("resuming %s in namespace %s", nb.operation, namespace)
+		}
+		breaker.failures = 0
+		breaker.lastError = nil
+		nb.setState(namespace, breaker, breakerClosed)
+		return
+	}
+
+	breaker.failures++
+	if breaker.state == breakerHalfOpen || breaker.failures >= nb.failureThreshold {
+		breaker.openedAt = time.Now()
+		breaker.lastError = err
+		if breaker.state != breakerOpen {
+			klog.Errorf("suppressing %s in namespace %s for %s after %d consecutive failures: %s",
+				nb.operation, namespace, nb.cooldown, breaker.failures, err)
+		}
+		nb.setState(namespace, breaker, breakerOpen)
+	}
+}
+
+// Return the state of the breaker of a namespace
+func (nb *namespaceBreakers) getState(namespace string) int {
+	nb.mutex.Lock()
+	defer nb.mutex.Unlock()
+	return nb.breaker(namespace).state
+}
+
+// Return the error that opened the breaker of a namespace, or nil if the breaker is closed
+func (nb *namespaceBreakers) openedBy(namespace string) error {
+	if nb == nil {
+		return nil
+	}
+	nb.mutex.Lock()
+	defer nb.mutex.Unlock()
+	breaker, ok := nb.breakers[namespace]
+	if !ok || breaker.state == breakerClosed {
+		return nil
+	}
+	return breaker.lastError
+}
@@ -0,0 +1,128 @@
+/*
+Copyright 2019 IBM Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// check the state of the breaker of a namespace and its metric
+func checkBreakerState(t *testing.T, nb *namespaceBreakers, namespace string, expected int) {
+	t.Helper()
+	if state := nb.getState(namespace); state != expected {
+		t.Errorf("expecting breaker of namespace %s in state %d, got %d", namespace, expected, state)
+	}
+	if value := testutil.ToFloat64(nb.state.WithLabelValues(namespace)); value != float64(expected) {
+		t.Errorf("expecting breaker metric of namespace %s to be %d, got %v", namespace, expected, value)
+	}
+}
+
+func TestNamespaceBreakers(t *testing.T) {
+	const threshold = 3
+	const cooldown = time.Millisecond * 50
+	state := prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: "test_breaker_state"}, []string{"namespace"})
+	nb := newNamespaceBreakers("test operation", threshold, cooldown, state)
+	quotaErr := fmt.Errorf("exceeded quota")
+
+	// closed until the threshold is reached
+	for i := 0; i < threshold-1; i++ {
+		if !nb.allow("ns1") {
+			t.Fatalf("expecting attempt %d to be allowed", i)
+		}
+		nb.record("ns1", quotaErr)
+	}
+	checkBreakerState(t, nb, "ns1", breakerClosed)
+
+	// a success resets the count
+	nb.record("ns1", nil)
+	for i := 0; i < threshold-1; i++ {
+		nb.record("ns1", quotaErr)
+	}
+	checkBreakerState(t, nb, "ns1", breakerClosed)
+
+	if err := nb.openedBy("ns1"); err != nil {
+		t.Errorf("expecting no error while closed, got %s", err)
+	}
+
+	// open
+	nb.record("ns1", quotaErr)
+	checkBreakerState(t, nb, "ns1", breakerOpen)
+	if err := nb.openedBy("ns1"); err != quotaErr {
+		t.Errorf("expecting the breaker opened by %s, got %v", quotaErr, err)
+	}
+	if nb.allow("ns1") {
+		t.Error("expecting attempts to be suppressed while open")
+	}
+	if !nb.allow("ns2") {
+		t.Error("expecting attempts in other namespaces to be allowed")
+	}
+
+	// half-open after the cooldown, with a single attempt
+	time.Sleep(cooldown * 2)
+	if !nb.allow("ns1") {
+		t.Fatal("expecting an attempt to be allowed after the cooldown")
+	}
+	checkBreakerState(t, nb, "ns1", breakerHalfOpen)
+	if nb.allow("ns1") {
+		t.Error("expecting only one attempt while half-open")
+	}
+
+	// failed attempt opens again
+	nb.record("ns1", quotaErr)
+	checkBreakerState(t, nb, "ns1", breakerOpen)
+	if nb.allow("ns1") {
+		t.Error("expecting attempts to be suppressed after a failed retry")
+	}
+
+	// successful attempt closes
+	time.Sleep(cooldown * 2)
+	if !nb.allow("ns1") {
+		t.Fatal("expecting an attempt to be allowed after the cooldown")
+	}
+	nb.record("ns1", nil)
+	checkBreakerState(t, nb, "ns1", breakerClosed)
+	if err := nb.openedBy("ns1"); err != nil {
+		t.Errorf("expecting no error once closed, got %s", err)
+	}
+	if !nb.allow("ns1") {
+		t.Error("expecting attempts to be allowed once closed")
+	}
+}
+
+func TestNamespaceBreakersDisabled(t *testing.T) {
+	nb := newNamespaceBreakers("test operation", 0, time.Minute, nil)
+	for i := 0; i < 10; i++ {
+		nb.record("ns1", fmt.Errorf("exceeded quota"))
+	}
+	if !nb.allow("ns1") {
+		t.Error("expecting attempts to be allowed with a threshold of 0")
+	}
+
+	var none *namespaceBreakers
+	none.record("ns1", fmt.Errorf("exceeded quota"))
+	if !none.allow("ns1") {
+		t.Error("expecting attempts to be allowed without breakers")
+	}
+	if err := none.openedBy("ns1"); err != nil {
+		t.Errorf("expecting no error without breakers, got %s", err)
+	}
+}
@@ -0,0 +1,88 @@
+/*
+Copyright 2019 IBM Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"sort"
+	"sync"
+)
+
+/*
+ Component predicates are evaluated after a resource matches the namespaces,
+ component kinds, and selector of an application. A resource is a component
+ of the application only if all registered predicates include it.
+*/
+
+// A predicate deciding whether a resource that matches the selector of an
+// application is a component of the application
+type componentPredicate interface {
+	includes(appResInfo *appResourceInfo, resInfo *resourceInfo) bool
+}
+
+// name of the built-in predicate
+const defaultComponentPredicate = "default"
+
+// built-in predicate that includes all resources
+type alwaysIncludeComponent struct{}
+
+func (alwaysIncludeComponent) includes(appResInfo *appResourceInfo, resInfo *resourceInfo) bool {
+	return true
+}
+
+var (
+	componentPredicates = map[string]componentPredicate{
+		defaultComponentPredicate: alwaysIncludeComponent{},
+	}
+	componentPredicatesMutex sync.Mutex
+)
+
+// Register a component predicate under a name, replacing any predicate with the same name
+func registerComponentPredicate(name string, predicate componentPredicate) {
+	componentPredicatesMutex.Lock()
+	defer componentPredicatesMutex.Unlock()
+	componentPredicates[name] = predicate
+}
+
+// Remove the component predicate registered under a name
+func unregisterComponentPredicate(name string) {
+	componentPredicatesMutex.Lock()
+	defer componentPredicatesMutex.Unlock()
+	delete(componentPredicates, name)
+}
+
+// Return the name of the first predicate that excludes the resource from the
+// application, sorted by name, or "" if all predicates include it
+func excludingComponentPredicate(appResInfo *appResourceInfo, resInfo *resourceInfo) string {
+	componentPredicatesMutex.Lock()
+	names := make([]string, 0, len(componentPredicates))
+	for name := range componentPredicates {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	predicates := make([]componentPredicate, 0, len(names))
+	for _, name := range names {
+		predicates = append(predicates, componentPredicates[name])
+	}
+	componentPredicatesMutex.Unlock()
+
+	for i, predicate := range predicates {
+		if !predicate.includes(appResInfo, resInfo) {
+			return names[i]
+		}
+	}
+	return ""
+}
@@ -0,0 +1,71 @@
+/*
+Copyright 2019 IBM Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// Copy of the resources of the component kinds of an application, taken at the
+// start of the computation of its status, so that the status is computed from a
+// consistent set of components even if the caches change during the computation.
+// Only the lists are copied: the caches replace changed objects rather than
+// modifying them, so the objects themselves are shared
+type componentSnapshot struct {
+	resources map[schema.GroupVersionResource][]interface{}
+	deleted   []*resourceInfo
+}
+
+// Copy the lists of cached resources of the component kinds, and the components deleted within the grace period
+func newComponentSnapshot(resController *ClusterWatcher, componentKinds []groupKind) *componentSnapshot {
+	snapshot := &componentSnapshot{
+		resources: make(map[schema.GroupVersionResource][]interface{}),
+	}
+	for _, component := range componentKinds {
+		gvr, ok := resController.getGVRForGroupKind(component.group, component.kind)
+		if !ok {
+			continue
+		}
+		if _, ok := snapshot.resources[gvr]; ok {
+			continue
+		}
+		// a new list of the cached objects
+		snapshot.resources[gvr] = resController.listResources(gvr)
+	}
+	for _, deleted := range resController.deletedComponents.list() {
+		var copied = &resourceInfo{}
+		*copied = *deleted
+		snapshot.deleted = append(snapshot.deleted, copied)
+	}
+	return snapshot
+}
+
+// Return the resources of a gvr, from the snapshot if there is one, otherwise from the cache
+func (snapshot *componentSnapshot) listResources(resController *ClusterWatcher, gvr schema.GroupVersionResource) []interface{} {
+	if snapshot == nil {
+		return resController.listResources(gvr)
+	}
+	return snapshot.resources[gvr]
+}
+
+// Return the components deleted within the grace period, from the snapshot if there is one
+func (snapshot *componentSnapshot) listDeleted(resController *ClusterWatcher) []*resourceInfo {
+	if snapshot == nil {
+		return resController.deletedComponents.list()
+	}
+	return snapshot.deleted
+}
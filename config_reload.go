@@ -0,0 +1,103 @@
+/*
+Copyright 2019 IBM Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"k8s.io/klog"
+)
+
+/*
+ The configuration read from the kappnav-config ConfigMap (status precedence,
+ unknown status, application namespaces, and component kind groups) can be
+ reloaded without a restart through POST /reload or SIGHUP.
+*/
+
+const (
+	// path of POST /reload
+	reloadPath = "/reload"
+)
+
+// Re-read the kappnav-config ConfigMap and apply it. The configuration is replaced as a whole,
+// and left unchanged if the ConfigMap is not valid.
+// All applications are then recomputed with the new configuration.
+func (resController *ClusterWatcher) reloadConfig() error {
+	statusPrecedence, unknownStatus, namespaces, kindGroups, err := fetchDataFromConfigMap(resController.plugin.dynamicClient)
+	if err != nil {
+		return err
+	}
+
+	resController.configMutex.Lock()
+	resController.statusPrecedence = statusPrecedence
+	resController.unknownStatus = unknownStatus
+	resController.namespaces = namespaces
+	resController.componentKindGroups = kindGroups
+	resController.configMutex.Unlock()
+	if klog.V(2) {
+		klog.Infof("reloaded configuration: status precedence: %v, unknown status: %s, namespaces: %v, component kind groups: %v", statusPrecedence, unknownStatus, namespaces, kindGroups)
+	}
+
+	// Re-establish the component kinds and namespaces watched for each application, as
+	// newly permitted namespaces are only watched once an application needs them
+	var applications = make(map[string]*resourceInfo)
+	for _, app := range resController.listApplications() {
+		if err := startWatchApplicationComponentKinds(resController, app, applications); err != nil {
+			return err
+		}
+	}
+	if len(applications) > 0 {
+		resController.resourceChannel.send(&batchResources{
+			applications:    applications,
+			nonApplications: make(map[string]*resourceInfo),
+		})
+	}
+	return nil
+}
+
+// Handler for POST /reload
+func reloadConfigHandler(resController *ClusterWatcher) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if err := resController.reloadConfig(); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+// Reload the configuration each time the process receives SIGHUP
+func reloadConfigOnSignal(resController *ClusterWatcher) {
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGHUP)
+	go func() {
+		for range sigChan {
+			klog.Infof("received SIGHUP, reloading configuration")
+			if err := resController.reloadConfig(); err != nil {
+				klog.Errorf("unable to reload configuration: %s", err)
+			}
+		}
+	}()
+}
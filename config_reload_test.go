@@ -0,0 +1,147 @@
+/*
+Copyright 2019 IBM Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// update data of the kappnav-config ConfigMap
+func updateKAppNavConfig(resController *ClusterWatcher, data map[string]string) error {
+	intf := resController.plugin.dynamicClient.Resource(coreConfigMapGVR).Namespace(getkAppNavNamespace())
+	configMap, err := intf.Get(kappnavConfig, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+	for key, value := range data {
+		if err := unstructured.SetNestedField(configMap.Object, value, "data", key); err != nil {
+			return err
+		}
+	}
+	_, err = intf.Update(configMap, metav1.UpdateOptions{})
+	return err
+}
+
+func TestReloadConfig(t *testing.T) {
+	testName := "TestReloadConfig"
+	beforeTest()
+	// kinds to check for status
+	var kindsToCheckStatus = map[string]bool{
+		APPLICATION:  true,
+		"Service":    true,
+		"Deployment": true,
+	}
+
+	var files = []string{
+		/* 0 */ CrdApplication,
+		/* 1 */ appProductpage,
+		/* 2 */ deploymentProcuctpageV1,
+		/* 3 */ serviceProductpage,
+		/* 4 */ KappnavConfigFile,
+	}
+	iteration0IDs, err := readResourceIDs(files)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	/* Iteration 0: all normal */
+	testActions := newTestActions(testName, kindsToCheckStatus)
+	var emptyIDs = []resourceID{}
+	testActions.addIteration(iteration0IDs, emptyIDs)
+
+	clusterWatcher, err := createClusterWatcher(iteration0IDs, testActions, StatusFailureRate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer clusterWatcher.shutDown()
+
+	err = testActions.transitionAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(clusterWatcher.getComponentKindGroups()) != 0 || !clusterWatcher.isAllNamespacesPermitted() {
+		t.Fatalf("expecting no component kind groups and all namespaces permitted before reload")
+	}
+
+	// capture what is sent for batch processing
+	batchChannel := clusterWatcher.resourceChannel
+	captureChannel := newResourceChannel()
+	clusterWatcher.resourceChannel = captureChannel
+	defer func() {
+		clusterWatcher.resourceChannel = batchChannel
+	}()
+
+	err = updateKAppNavConfig(clusterWatcher, map[string]string{
+		componentKindGroups: "{ \"Deployment\": \"Workloads\" }",
+		appNamespaces:       "default",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	handler := newHTTPHandler(clusterWatcher)
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, reloadPath, nil))
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("expecting code %d, got %d: %s", http.StatusOK, recorder.Code, recorder.Body.String())
+	}
+	if group := clusterWatcher.getComponentKindGroups()["Deployment"]; group != "Workloads" {
+		t.Errorf("expecting Deployment in group Workloads after reload, got %q", group)
+	}
+	if !clusterWatcher.isNamespacePermitted("default") || clusterWatcher.isNamespacePermitted("other") {
+		t.Errorf("expecting only namespace default permitted after reload")
+	}
+
+	select {
+	case resources := <-captureChannel.batchResourceChan:
+		if len(resources.applications) != 1 {
+			t.Errorf("expecting 1 application recomputed after reload, got %d", len(resources.applications))
+		}
+	case <-time.After(time.Second * 5):
+		t.Fatal("timed out waiting for applications to be recomputed")
+	}
+
+	// invalid configuration is not applied
+	err = updateKAppNavConfig(clusterWatcher, map[string]string{
+		appStatusPrecedence: "not JSON",
+		componentKindGroups: "{}",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	recorder = httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, reloadPath, nil))
+	if recorder.Code != http.StatusInternalServerError {
+		t.Errorf("expecting code %d for invalid configuration, got %d", http.StatusInternalServerError, recorder.Code)
+	}
+	if group := clusterWatcher.getComponentKindGroups()["Deployment"]; group != "Workloads" {
+		t.Errorf("expecting configuration unchanged after invalid reload, got group %q", group)
+	}
+
+	// invalid method
+	recorder = httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, reloadPath, nil))
+	if recorder.Code != http.StatusMethodNotAllowed {
+		t.Errorf("expecting code %d for GET, got %d", http.StatusMethodNotAllowed, recorder.Code)
+	}
+}
@@ -0,0 +1,157 @@
+/*
+Copyright 2019 IBM Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"os"
+)
+
+// Effective configuration of the controller, logged once at startup
+type controllerConfig struct {
+	APIURL                             string  `json:"apiURL"`
+	MasterURL                          string  `json:"master"`
+	KubeEnv                            string  `json:"kubeEnv"`
+	KAppNavNamespace                   string  `json:"kappnavNamespace"`
+	BatchDuration                      string  `json:"batchDuration"`
+	ResyncPeriod                       string  `json:"resyncPeriod"`
+	RequeueBaseDelay                   string  `json:"requeueBaseDelay"`
+	RequeueMaxDelay                    string  `json:"requeueMaxDelay"`
+	DeletionGracePeriod                string  `json:"deletionGracePeriod"`
+	HTTPAddr                           string  `json:"httpAddr"`
+	StatusConditions                   bool    `json:"statusConditions"`
+	StatusConditionType                string  `json:"statusConditionType"`
+	ActionConfigMapsInKAppNavNamespace bool    `json:"actionConfigMapsInKAppNavNamespace"`
+	StatusSnapshot                     bool    `json:"statusSnapshot"`
+	ParentRequeueOnStatusChangeOnly    bool    `json:"parentRequeueOnStatusChangeOnly"`
+	ActionConfigMapsOptIn              bool    `json:"actionConfigMapsOptIn"`
+	StatusWritesPerNamespace           int     `json:"statusWritesPerNamespace"`
+	DeniedAPIGroups                    string  `json:"deniedAPIGroups"`
+	NoReaderStatus                     string  `json:"noReaderStatus"`
+	HandlerWorkers                     int     `json:"handlerWorkers"`
+	ActionConfigMapFailureThreshold    int     `json:"actionConfigMapFailureThreshold"`
+	ActionConfigMapCooldown            string  `json:"actionConfigMapCooldown"`
+	StatusAnnotation                   string  `json:"statusAnnotation"`
+	APIHealthCheckInterval             string  `json:"apiHealthCheckInterval"`
+	ParsedResourceCacheSize            int     `json:"parsedResourceCacheSize"`
+	DeploymentHealth                   string  `json:"deploymentHealth"`
+	IgnoreManagedConfigMaps            bool    `json:"ignoreManagedConfigMaps"`
+	MaxStatusConditions                int     `json:"maxStatusConditions"`
+	EventSampleRate                    float64 `json:"eventSampleRate"`
+	HeartbeatInterval                  string  `json:"heartbeatInterval"`
+	HealthReaderMode                   string  `json:"healthReaderMode"`
+	TransientPhaseStatus               string  `json:"transientPhaseStatus"`
+	StartupWarmup                      string  `json:"startupWarmup"`
+	MaxBatchSize                       int     `json:"maxBatchSize"`
+	ComponentStatusJSONPath            string  `json:"componentStatusJSONPath"`
+	StartupRetries                     int     `json:"startupRetries"`
+	StartupRetryDelay                  string  `json:"startupRetryDelay"`
+	StatusFreshnessTTL                 string  `json:"statusFreshnessTTL"`
+	SelectorMetrics                    bool    `json:"selectorMetrics"`
+	SelectorlessApplications           string  `json:"selectorlessApplications"`
+	RelistBurstThreshold               int     `json:"relistBurstThreshold"`
+	RelistBurstWindow                  string  `json:"relistBurstWindow"`
+	MinComponentsForStatusEvents       int     `json:"minComponentsForStatusEvents"`
+	MetricsAddr                        string  `json:"metricsAddr"`
+	HealthAddr                         string  `json:"healthAddr"`
+	OrphanedApplicationsInterval       string  `json:"orphanedApplicationsInterval"`
+	DumpStacksOnSignal                 bool    `json:"dumpStacksOnSignal"`
+	DryRun                             bool    `json:"dryRun"`
+	ScopedWatchMaxNamespaces           int     `json:"scopedWatchMaxNamespaces"`
+	DeleteAttempts                     int     `json:"deleteAttempts"`
+	MaxAncestorDepth                   int     `json:"maxAncestorDepth"`
+	StatusWriteQPS                     float64 `json:"statusWriteQPS"`
+	StatusWriteBurst                   int     `json:"statusWriteBurst"`
+	ResourceBatchDurations             string  `json:"resourceBatchDurations"`
+	LogFormat                          string  `json:"logFormat"`
+	LogFile                            string  `json:"logFile"`
+	EnablePprof                        bool    `json:"enablePprof"`
+	ApplicationVersions                string  `json:"applicationVersions"`
+}
+
+// Collect the resolved settings of the controller
+func newControllerConfig() *controllerConfig {
+	return &controllerConfig{
+		APIURL:                             apiURL,
+		MasterURL:                          masterURL,
+		KubeEnv:                            os.Getenv("KUBE_ENV"),
+		KAppNavNamespace:                   getkAppNavNamespace(),
+		BatchDuration:                      batchDuration.String(),
+		ResyncPeriod:                       resyncPeriod.String(),
+		RequeueBaseDelay:                   requeueBaseDelay.String(),
+		RequeueMaxDelay:                    requeueMaxDelay.String(),
+		DeletionGracePeriod:                deletionGracePeriod.String(),
+		HTTPAddr:                           httpAddr,
+		StatusConditions:                   emitStatusConditions,
+		StatusConditionType:                statusConditionType,
+		ActionConfigMapsInKAppNavNamespace: actionConfigMapsInkAppNavNamespace,
+		StatusSnapshot:                     statusSnapshot,
+		ParentRequeueOnStatusChangeOnly:    parentRequeueOnStatusChangeOnly,
+		ActionConfigMapsOptIn:              actionConfigMapsOptIn,
+		StatusWritesPerNamespace:           statusWritesPerNamespace,
+		DeniedAPIGroups:                    deniedAPIGroups,
+		NoReaderStatus:                     noReaderStatus,
+		HandlerWorkers:                     handlerWorkers,
+		ActionConfigMapFailureThreshold:    actionConfigMapFailureThreshold,
+		ActionConfigMapCooldown:            actionConfigMapCooldown.String(),
+		StatusAnnotation:                   statusAnnotationKey(),
+		APIHealthCheckInterval:             apiHealthCheckInterval.String(),
+		ParsedResourceCacheSize:            parsedResourceCacheSize,
+		DeploymentHealth:                   deploymentHealthSource,
+		IgnoreManagedConfigMaps:            ignoreManagedConfigMaps,
+		MaxStatusConditions:                maxStatusConditions,
+		EventSampleRate:                    eventSampleRate,
+		HeartbeatInterval:                  heartbeatInterval.String(),
+		HealthReaderMode:                   healthReaderMode,
+		TransientPhaseStatus:               transientPhaseStatus,
+		StartupWarmup:                      startupWarmup.String(),
+		MaxBatchSize:                       maxBatchSize,
+		ComponentStatusJSONPath:            componentStatusJSONPath,
+		StartupRetries:                     startupRetries,
+		StartupRetryDelay:                  startupRetryDelay.String(),
+		StatusFreshnessTTL:                 statusFreshnessTTL,
+		SelectorMetrics:                    selectorMetrics,
+		SelectorlessApplications:           selectorlessApplications,
+		RelistBurstThreshold:               relistBurstThreshold,
+		RelistBurstWindow:                  relistBurstWindow.String(),
+		MinComponentsForStatusEvents:       minComponentsForStatusEvents,
+		MetricsAddr:                        metricsAddr,
+		HealthAddr:                         healthAddr,
+		OrphanedApplicationsInterval:       orphanedApplicationsInterval.String(),
+		DumpStacksOnSignal:                 dumpStacksOnSignal,
+		DryRun:                             dryRun,
+		ScopedWatchMaxNamespaces:           scopedWatchMaxNamespaces,
+		DeleteAttempts:                     deleteAttempts,
+		MaxAncestorDepth:                   maxAncestorDepth,
+		StatusWriteQPS:                     statusWriteQPS,
+		StatusWriteBurst:                   statusWriteBurst,
+		ResourceBatchDurations:             resourceBatchDurations,
+		LogFormat:                          logFormat,
+		LogFile:                            logFile,
+		EnablePprof:                        enablePprof,
+		ApplicationVersions:                applicationVersions,
+	}
+}
+
+// Return the configuration as JSON
+func (config *controllerConfig) String() string {
+	data, err := json.Marshal(config)
+	if err != nil {
+		return err.Error()
+	}
+	return string(data)
+}
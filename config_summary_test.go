@@ -0,0 +1,57 @@
+/*
+Copyright 2019 IBM Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestControllerConfigSummary(t *testing.T) {
+	savedBatchDuration := batchDuration
+	savedResyncPeriod := resyncPeriod
+	batchDuration = time.Second * 5
+	resyncPeriod = 0
+	emitStatusConditions = true
+	defer func() {
+		batchDuration = savedBatchDuration
+		resyncPeriod = savedResyncPeriod
+		emitStatusConditions = false
+	}()
+
+	var summary map[string]interface{}
+	if err := json.Unmarshal([]byte(newControllerConfig().String()), &summary); err != nil {
+		t.Fatal(err)
+	}
+	var expected = map[string]interface{}{
+		"batchDuration":    "5s",
+		"resyncPeriod":     "0s",
+		"kappnavNamespace": defaultkAppNavNamespace,
+		"statusConditions": true,
+	}
+	for key, value := range expected {
+		if summary[key] != value {
+			t.Errorf("expecting %s to be %v in configuration summary, got %v", key, value, summary[key])
+		}
+	}
+	for _, key := range []string{"apiURL", "requeueBaseDelay", "requeueMaxDelay", "deletionGracePeriod", "httpAddr", "actionConfigMapsInKAppNavNamespace", "statusSnapshot", "parentRequeueOnStatusChangeOnly", "actionConfigMapsOptIn", "statusWritesPerNamespace", "deniedAPIGroups", "noReaderStatus", "handlerWorkers", "actionConfigMapFailureThreshold", "actionConfigMapCooldown", "statusAnnotation", "apiHealthCheckInterval", "parsedResourceCacheSize", "deploymentHealth", "ignoreManagedConfigMaps", "maxStatusConditions", "eventSampleRate", "heartbeatInterval", "healthReaderMode", "transientPhaseStatus", "startupWarmup", "maxBatchSize", "componentStatusJSONPath", "startupRetries", "startupRetryDelay", "statusFreshnessTTL", "selectorMetrics", "selectorlessApplications", "relistBurstThreshold", "relistBurstWindow", "minComponentsForStatusEvents", "metricsAddr", "healthAddr", "orphanedApplicationsInterval", "dumpStacksOnSignal", "dryRun", "scopedWatchMaxNamespaces", "deleteAttempts", "maxAncestorDepth", "statusWriteQPS", "statusWriteBurst", "resourceBatchDurations", "logFormat", "logFile", "enablePprof", "applicationVersions"} {
+		if _, ok := summary[key]; !ok {
+			t.Errorf("expecting %s in configuration summary %v", key, summary)
+		}
+	}
+}
@@ -17,10 +17,13 @@ limitations under the License.
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"math"
 	"os"
 	"runtime"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -34,6 +37,7 @@ import (
 	"k8s.io/client-go/discovery"
 	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
 	"k8s.io/client-go/util/workqueue"
 	"k8s.io/klog"
 )
@@ -94,46 +98,70 @@ store +controller |          |    +queue        |                |   batch
 const (
 	retryLimit = 5 // number of times to retry if the handlers encounter error
 
-	DEPLOYMENT                 = "Deployment"
-	STATEFULSET                = "StatefulSet"
-	APPLICATION                = "Application"
-	KAppNav                    = "KAppNav"
-	KappnavUIService           = "kappnav-ui-service"
-	CustomResourceDefinition   = "CustomResourceDefinition"
-	OpenShiftWebConsoleConfig  = "OpenShiftWebConsoleConfig"
-	OpenShiftWebConsole        = "openshift-web-console"
-	V1                         = "v1"
-	CONFIGMAPS                 = "configmaps"
-	APIVERSION                 = "apiVersion"
-	KIND                       = "kind"
-	ANNOTATIONS                = "annotations"
-	MATCHEXPRESSIONS           = "matchExpressions"
-	KEY                        = "key"
-	PLURAL                     = "plural"
-	OPERATOR                   = "operator"
-	SCOPE                      = "scope"
-	NAMESPACED                 = "Namespaced"
-	VALUES                     = "values"
-	GROUP                      = "group"
-	METADATA                   = "metadata"
-	MATCHLABELS                = "matchLabels"
-	NAME                       = "name"
-	NAMES                      = "names"
-	NAMESPACE                  = "namespace"
-	LABELS                     = "labels"
-	SPEC                       = "spec"
-	VERSION                    = "version"
-	SELECTOR                   = "selector"
-	COMPONENTKINDS             = "componentKinds"
-	statusUnknown              = "status-unknown"
-	appStatusPrecedence        = "app-status-precedence"
-	appNamespaces              = "app-namespaces"
-	kappnavStatusValue         = "kappnav.status.value"
-	kappnavStatusFlyover       = "kappnav.status.flyover"
-	kappnavStatusFlyoverNls    = "kappnav.status.flyover.nls"
-	defaultkAppNavNamespace    = "kappnav"
-	kappnavConfig              = "kappnav-config"
-	kappnavComponentNamespaces = "kappnav.component.namespaces" // annotation for additional namespaces for application components
+	// DefaultResyncPeriod - period to resync informers
+	DefaultResyncPeriod = time.Minute * 10
+
+	DEPLOYMENT                     = "Deployment"
+	STATEFULSET                    = "StatefulSet"
+	APPLICATION                    = "Application"
+	KAppNav                        = "KAppNav"
+	KappnavUIService               = "kappnav-ui-service"
+	CustomResourceDefinition       = "CustomResourceDefinition"
+	OpenShiftWebConsoleConfig      = "OpenShiftWebConsoleConfig"
+	OpenShiftWebConsole            = "openshift-web-console"
+	V1                             = "v1"
+	CONFIGMAPS                     = "configmaps"
+	APIVERSION                     = "apiVersion"
+	KIND                           = "kind"
+	ANNOTATIONS                    = "annotations"
+	MATCHEXPRESSIONS               = "matchExpressions"
+	KEY                            = "key"
+	PLURAL                         = "plural"
+	OPERATOR                       = "operator"
+	SCOPE                          = "scope"
+	NAMESPACED                     = "Namespaced"
+	VALUES                         = "values"
+	SOURCE                         = "source"
+	GROUP                          = "group"
+	METADATA                       = "metadata"
+	MATCHLABELS                    = "matchLabels"
+	NAME                           = "name"
+	NAMES                          = "names"
+	NAMESPACE                      = "namespace"
+	UID                            = "uid"
+	LABELS                         = "labels"
+	SPEC                           = "spec"
+	TEMPLATE                       = "template"
+	STATUS                         = "status"
+	CONDITIONS                     = "conditions"
+	VERSION                        = "version"
+	SELECTOR                       = "selector"
+	EXCLUDESELECTOR                = "excludeSelector"
+	COMPONENTKINDS                 = "componentKinds"
+	COMPONENTS                     = "components"
+	statusUnknown                  = "status-unknown"
+	appStatusPrecedence            = "app-status-precedence"
+	appNamespaces                  = "app-namespaces"
+	componentKindGroups            = "component-kind-groups"
+	kappnavStatusValue             = "kappnav.status.value"
+	kappnavStatusFlyover           = "kappnav.status.flyover"
+	kappnavStatusFlyoverNls        = "kappnav.status.flyover.nls"
+	kappnavStatusComponentGroups   = "kappnav.status.component.groups" // annotation for components of an application bucketed by display group
+	kappnavStatusAvailability      = "kappnav.status.availability"     // annotation for the weighted percentage of components of an application that are healthy, e.g. Normal
+	defaultkAppNavNamespace        = "kappnav"
+	kappnavConfig                  = "kappnav-config"
+	kappnavComponentNamespaces     = "kappnav.component.namespaces"       // annotation for additional namespaces for application components
+	kappnavComponentTemplateLabels = "kappnav.component.template.labels"  // annotation to also match pod template labels of components
+	kappnavExcludeSubApplications  = "kappnav.io/exclude-subapplications" // annotation to leave child applications out of the status of an application
+	kappnavMaintenance             = "kappnav.io/maintenance"             // annotation to leave a paused component out of the status of its applications
+	kappnavStatusWeight            = "kappnav.io/status-weight"           // annotation for the weight of a component in the availability of its applications
+	kappnavTopLevel                = "kappnav.io/top-level"               // annotation for an application that is never a component of another application
+	kappnavStatusTimestamp         = "kappnav.status.timestamp"           // annotation for the RFC 3339 time an external agent last updated the kappnav status
+	kappnavNamespaceWeights        = "kappnav.io/namespace-weights"       // annotation for the weight of the components of each namespace in the availability of an application
+	kappnavSelectorCombine         = "kappnav.selector.combine"           // annotation for how matchLabels and matchExpressions combine: and, or
+	kappnavAssemblyPhase           = "kappnav.io/assembly-phase"          // annotation to also write the app.k8s.io assemblyPhase and observedGeneration of an application
+	kappnavStatusCalculator        = "kappnav.io/status-calculator"       // annotation for the name of the calculator aggregating the status of the components of an application
+	kappnavStatusQuorum            = "kappnav.status.quorum"              // annotation for the fraction of the components of each kind that must be healthy for the kind to count as healthy
 )
 
 // coreKindToGVR map is for backward compatibility with initial releases
@@ -291,26 +319,69 @@ type ControllerPlugin struct {
 	discoveryClient discovery.DiscoveryInterface
 	batchDuration   time.Duration
 	statusFunc      calculateComponentStatusFunc
+
+	// ResyncPeriod is the period at which informers re-deliver every object in
+	// their cache as an update, to recover from events missed e.g. while the API
+	// server restarts. 0 for no resync.
+	// A resync re-runs the status calculation of the applications of every
+	// watched object through batchResourceHandler. The updates do not change the
+	// resource version, so the controller must process each as a change. With
+	// relistBurstThreshold set, a resync of a kind with many objects is coalesced
+	// into one reconcile of all applications instead
+	ResyncPeriod time.Duration
+
+	// DryRun logs the status, applications and configmaps the controller would
+	// write instead of writing them
+	DryRun bool
+
+	// EventRecorder records an event against an application when its computed
+	// status changes. nil to not record events
+	EventRecorder record.EventRecorder
 }
 
 // ClusterWatcher watches all resources for one Kube cluster
 type ClusterWatcher struct {
-	plugin              *ControllerPlugin
-	handlerMgr          *HandlerManager
-	nsFilter            *namespaceFilter
-	resourceMap         map[schema.GroupVersionResource]*ResourceWatcher // all resources being watched
-	gvrsToWatch         map[schema.GroupVersionResource]bool             // set of gvrs to watch for resources
-	apiVersionKindToGVR sync.Map
-	groupKindToGVR      sync.Map
-	statusPrecedence    []string // array of status precedence
-	unknownStatus       string   // value of unkown status
-	namespaces          map[string]string
-	resourceChannel     *resourceChannel // channel to send application updates
-	mutex               sync.Mutex
-}
-
-// NewClusterWatcher creates a new ClusterWatcher
-func NewClusterWatcher(controllerPlugin *ControllerPlugin) (*ClusterWatcher, error) {
+	plugin                  *ControllerPlugin
+	handlerMgr              *HandlerManager
+	nsFilter                *namespaceFilter
+	resourceMap             map[schema.GroupVersionResource]*ResourceWatcher // all resources being watched
+	gvrsToWatch             map[schema.GroupVersionResource]bool             // set of gvrs to watch for resources
+	apiVersionKindToGVR     sync.Map
+	groupKindToGVR          sync.Map
+	groupKindResolver       *groupKindResolver // group/kind to GVR from discovery
+	statusPrecedence        []string           // array of status precedence
+	unknownStatus           string             // value of unkown status
+	namespaces              map[string]string
+	componentKindGroups     map[string]string    // map from component kind to display group
+	resourceChannel         *resourceChannel     // channel to send application updates
+	statusRetries           *statusRetryQueue    // status updates that failed to be delivered
+	deletedComponents       *deletedComponents   // components deleted within the grace period
+	statusExpiries          *statusExpiryTimers  // requeue components once their kappnav status expires
+	statusCache             *statusCache         // last computed status of each application
+	statusWrites            *namespaceSemaphore  // limits concurrent status writes per namespace
+	statusWriteLimiter      *statusWriteLimiter  // limits the rate of status writes. nil for no limit
+	handlers                *handlerPool         // workers calling event handlers. nil to call them from the worker of each GVR
+	actionConfigMapBreakers *namespaceBreakers   // suppress action configmap creation in namespaces where it keeps failing
+	apiHealth               *apiHealth           // availability of the API server. nil to not check
+	heartbeat               *heartbeat           // periodic heartbeat. nil for none
+	parsedResources         *parsedResourceCache // resources parsed in the current batch
+	appResources            *appResourceCache    // parsed applications, until they change
+	appKinds                *appKindIndex        // applications including each component kind. nil to check every application
+	pendingKinds            *pendingKinds        // applications waiting for component kinds not known yet
+	watchRefs               *watchRefs           // applications referencing each GVR watched
+	printerColumns          *printerColumnReader // health printer column of the CRD of each GVR
+	relistBursts            *relistCoalescer     // coalesces relist bursts into one reconcile. nil to process all events
+	keyer                   resourceKeyer        // key identifying a resource in maps, caches, and queues
+	orphanReaper            *orphanReaper        // periodic deletion of orphaned auto-created applications. nil for none
+	batchesDone             chan struct{}        // closed once the batch consumer stops
+	stopped                 chan struct{}        // closed once shut down
+	shutDownOnce            sync.Once
+	mutex                   sync.Mutex
+	configMutex             sync.RWMutex // guards the configuration read from the kappnav-config ConfigMap
+}
+
+// NewClusterWatcher creates a new ClusterWatcher, shut down once the context is cancelled
+func NewClusterWatcher(ctx context.Context, controllerPlugin *ControllerPlugin) (*ClusterWatcher, error) {
 
 	var resController = &ClusterWatcher{}
 	resController.plugin = controllerPlugin
@@ -318,9 +389,22 @@ func NewClusterWatcher(controllerPlugin *ControllerPlugin) (*ClusterWatcher, err
 	resController.nsFilter = newNamespaceFilter()
 	resController.gvrsToWatch = make(map[schema.GroupVersionResource]bool, 50)
 	resController.resourceMap = make(map[schema.GroupVersionResource]*ResourceWatcher, 50)
+	resController.groupKindResolver = newGroupKindResolver(controllerPlugin.discoveryClient)
+	resController.statusCache = newStatusCache()
+	resController.parsedResources = newParsedResourceCache(parsedResourceCacheSize)
+	resController.appResources = newAppResourceCache()
+	resController.appKinds = newAppKindIndex()
+	resController.pendingKinds = newPendingKinds()
+	resController.watchRefs = newWatchRefs()
+	resController.printerColumns = newPrinterColumnReader()
+	resController.statusWrites = newNamespaceSemaphore(statusWritesPerNamespace)
+	resController.statusWriteLimiter = newStatusWriteLimiter(statusWriteQPS, statusWriteBurst)
+	resController.handlers = newHandlerPool(handlerWorkers)
+	resController.actionConfigMapBreakers = newNamespaceBreakers("action configmap creation",
+		actionConfigMapFailureThreshold, actionConfigMapCooldown, actionConfigMapBreakerState)
 
 	var err error
-	resController.statusPrecedence, resController.unknownStatus, resController.namespaces, err =
+	resController.statusPrecedence, resController.unknownStatus, resController.namespaces, resController.componentKindGroups, err =
 		fetchDataFromConfigMap(controllerPlugin.dynamicClient)
 	if err != nil {
 		return nil, err
@@ -332,10 +416,56 @@ func NewClusterWatcher(controllerPlugin *ControllerPlugin) (*ClusterWatcher, err
 		return nil, err
 	}
 
+	// pause processing while the API server is unavailable
+	resController.apiHealth = newAPIHealth(func() error {
+		_, err := controllerPlugin.discoveryClient.ServerVersion()
+		return err
+	}, apiHealthCheckInterval)
+	resController.apiHealth.start()
+
+	resController.heartbeat = newHeartbeat(heartbeatInterval)
+	resController.heartbeat.start()
+
 	// start batchStore to unprocessed resource changes
 	resController.resourceChannel = newResourceChannel()
 	batchStore := newBatchStore(resController, controllerPlugin.batchDuration)
-	go batchStore.run()
+	batchStore.warmupPeriod = startupWarmup
+	batchStore.maxBatchSize = maxBatchSize
+	resController.batchesDone = make(chan struct{})
+	resController.stopped = make(chan struct{})
+	go func() {
+		batchStore.run()
+		close(resController.batchesDone)
+	}()
+
+	resController.relistBursts = newRelistCoalescer(relistBurstThreshold, relistBurstWindow, resController.reconcileAllApplications)
+
+	// start retrying status updates that failed to be delivered
+	resController.statusRetries = newStatusRetryQueue(defaultStatusRetryQueueSize, defaultStatusRetryInterval,
+		func(resInfo *resourceInfo, status string, flyover string, flyoverNLS string) error {
+			err := sendResourceStatus(resController, resInfo, status, flyover, flyoverNLS)
+			if err == nil {
+				resController.recordWrittenStatus(resInfo)
+			}
+			return err
+		})
+	resController.statusRetries.start()
+
+	resController.deletedComponents = newDeletedComponents(deletionGracePeriod)
+	resController.statusExpiries = newStatusExpiryTimers()
+	resController.setKeyer(defaultKeyer)
+
+	if ctx.Done() != nil {
+		go func() {
+			<-ctx.Done()
+			resController.shutDown()
+		}()
+	}
+
+	resController.orphanReaper = newOrphanReaper(orphanedApplicationsInterval, func() error {
+		return reapOrphanedApplications(resController)
+	})
+	resController.orphanReaper.start()
 
 	// start watch CRD
 	gvr, ok := resController.getWatchGVR(coreCustomResourceDefinitionGVR)
@@ -345,7 +475,7 @@ func NewClusterWatcher(controllerPlugin *ControllerPlugin) (*ClusterWatcher, err
 		}
 		return nil, nil
 	}
-	err = resController.AddToWatch(gvr)
+	err = resController.PinWatch(gvr)
 	if err != nil {
 		if klog.V(4) {
 			klog.Infof("NewClusterWatcher error adding GVR: %s to watch, returning nil", coreCustomResourceDefinitionGVR)
@@ -366,8 +496,8 @@ func getkAppNavNamespace() string {
 	return ns
 }
 
-// fetchDataFromConfigMap gets status precedence, unknown status, and application namespaces from ConfigMap Kubernetes
-func fetchDataFromConfigMap(dynInterf dynamic.Interface) ([]string, string, map[string]string, error) {
+// fetchDataFromConfigMap gets status precedence, unknown status, application namespaces, and component kind groups from ConfigMap Kubernetes
+func fetchDataFromConfigMap(dynInterf dynamic.Interface) ([]string, string, map[string]string, map[string]string, error) {
 	gvr := schema.GroupVersionResource{
 		Group:    "",
 		Version:  V1,
@@ -382,35 +512,35 @@ func fetchDataFromConfigMap(dynInterf dynamic.Interface) ([]string, string, map[
 	var err error
 	unstructuredObj, err = intf.Get(kappnavConfig, metav1.GetOptions{})
 	if err != nil {
-		return nil, "", nil, err
+		return nil, "", nil, nil, err
 	}
 
 	var objMap = unstructuredObj.Object
 	dataMap, ok := objMap["data"].(map[string]interface{})
 	if !ok {
-		return nil, "", nil, fmt.Errorf("Configmap kappnav-config does not not contain \"data\" property")
+		return nil, "", nil, nil, fmt.Errorf("Configmap kappnav-config does not not contain \"data\" property")
 	}
 	unknownStatObj, ok := dataMap[statusUnknown]
 	if !ok {
-		return nil, "", nil, fmt.Errorf("Configmap kappnav-config does not contain status-unknown property")
+		return nil, "", nil, nil, fmt.Errorf("Configmap kappnav-config does not contain status-unknown property")
 	}
 	unknownStat, ok := unknownStatObj.(string)
 	if !ok {
-		return nil, "", nil, fmt.Errorf("Configmap kappnav-config status-unknown not a string")
+		return nil, "", nil, nil, fmt.Errorf("Configmap kappnav-config status-unknown not a string")
 	}
 
 	appStatPreced, ok := dataMap[appStatusPrecedence]
 	if !ok {
-		return nil, "", nil, fmt.Errorf("Configmap kappnav-config does not contain app-status-precedence property")
+		return nil, "", nil, nil, fmt.Errorf("Configmap kappnav-config does not contain app-status-precedence property")
 	}
 
 	statusPrecedence, ok := appStatPreced.(string)
 	if !ok {
-		return nil, "", nil, fmt.Errorf("Configmap kappnav-config app-status-precedence not a JSON array")
+		return nil, "", nil, nil, fmt.Errorf("Configmap kappnav-config app-status-precedence not a JSON array")
 	}
 	ret, err := jsonToArrayOfString(statusPrecedence)
 	if err != nil {
-		return nil, "", nil, fmt.Errorf("In ConfigMap kappnav-config, the value of app-status-precedence not valid JSON: %s, parsing error: %s", statusPrecedence, err)
+		return nil, "", nil, nil, fmt.Errorf("In ConfigMap kappnav-config, the value of app-status-precedence not valid JSON: %s, parsing error: %s", statusPrecedence, err)
 	}
 
 	namespaces := make(map[string]string)
@@ -418,11 +548,24 @@ func fetchDataFromConfigMap(dynInterf dynamic.Interface) ([]string, string, map[
 	if ok {
 		appNamespacesStr, ok := appNamespaces.(string)
 		if !ok {
-			return nil, "", nil, fmt.Errorf("Configmap kappnav-config app-namespaces is not a string")
+			return nil, "", nil, nil, fmt.Errorf("Configmap kappnav-config app-namespaces is not a string")
 		}
 		namespaces = stringToNamespaceMap(appNamespacesStr)
 	}
-	return ret, unknownStat, namespaces, nil
+
+	kindGroups := make(map[string]string)
+	kindGroupsObj, ok := dataMap[componentKindGroups]
+	if ok {
+		kindGroupsStr, ok := kindGroupsObj.(string)
+		if !ok {
+			return nil, "", nil, nil, fmt.Errorf("Configmap kappnav-config component-kind-groups is not a string")
+		}
+		kindGroups, err = jsonToMapOfString(kindGroupsStr)
+		if err != nil {
+			return nil, "", nil, nil, fmt.Errorf("In ConfigMap kappnav-config, the value of component-kind-groups not valid JSON: %s, parsing error: %s", kindGroupsStr, err)
+		}
+	}
+	return ret, unknownStat, namespaces, kindGroups, nil
 }
 
 func jsonToArrayOfString(str string) ([]string, error) {
@@ -450,11 +593,58 @@ func jsonToArrayOfString(str string) ([]string, error) {
 	return ret, nil
 }
 
+func jsonToMapOfString(str string) (map[string]string, error) {
+	bytes := []byte(str)
+	var interf interface{}
+	err := json.Unmarshal(bytes, &interf)
+	if err != nil {
+		return nil, err
+	}
+
+	objMap, ok := interf.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("value is not an object")
+	}
+
+	ret := make(map[string]string)
+	for key, val := range objMap {
+		tmp, ok := val.(string)
+		if !ok {
+			return nil, fmt.Errorf("value of %s is not a string", key)
+		}
+		ret[key] = tmp
+	}
+	return ret, nil
+}
+
 // Get cached status precedence
 func (resController *ClusterWatcher) getStatusPrecedence() []string {
+	resController.configMutex.RLock()
+	defer resController.configMutex.RUnlock()
 	return resController.statusPrecedence
 }
 
+// Return the value of unknown status
+func (resController *ClusterWatcher) getUnknownStatus() string {
+	resController.configMutex.RLock()
+	defer resController.configMutex.RUnlock()
+	return resController.unknownStatus
+}
+
+// Return the status precedence and the value of unknown status, from the same configuration
+func (resController *ClusterWatcher) getStatusConfig() ([]string, string) {
+	resController.configMutex.RLock()
+	defer resController.configMutex.RUnlock()
+	return resController.statusPrecedence, resController.unknownStatus
+}
+
+// Return the map from component kind to display group
+func (resController *ClusterWatcher) getComponentKindGroups() map[string]string {
+	resController.configMutex.RLock()
+	defer resController.configMutex.RUnlock()
+	return resController.componentKindGroups
+}
+
 // Return true if a gvr is namespaced
 func (resController *ClusterWatcher) isNamespaced(gvr schema.GroupVersionResource) bool {
 	if klog.V(4) {
@@ -484,6 +674,7 @@ func (resController *ClusterWatcher) isNamespacePermitted(namespace string) bool
 	}
 	var ret = false
 
+	resController.configMutex.RLock()
 	if len(resController.namespaces) == 0 {
 		// No namespaces means all resources
 		ret = true
@@ -491,6 +682,7 @@ func (resController *ClusterWatcher) isNamespacePermitted(namespace string) bool
 		// Namespace must be in kappnav-config Configmap app-namespaces
 		ret = true
 	}
+	resController.configMutex.RUnlock()
 	if klog.V(4) {
 		klog.Infof("isNamespacePermitted %s: %t", namespace, ret)
 	}
@@ -517,7 +709,9 @@ func (resController *ClusterWatcher) isAllNamespacesPermitted() bool {
 	if klog.V(4) {
 		klog.Infof("isAllNamespacesPermitted")
 	}
+	resController.configMutex.RLock()
 	var ret = len(resController.namespaces) == 0
+	resController.configMutex.RUnlock()
 	if klog.V(4) {
 		klog.Infof("isAllNamespacesPermitted %t:", ret)
 	}
@@ -529,6 +723,12 @@ func (resController *ClusterWatcher) AddToWatch(gvr schema.GroupVersionResource)
 	if klog.V(3) {
 		klog.Infof("AddToWatch %s\n", gvr)
 	}
+	if isAPIGroupDenied(gvr.Group) {
+		if klog.V(2) {
+			klog.Infof("AddToWatch refusing to watch %s in denied API group\n", gvr)
+		}
+		return nil
+	}
 
 	resController.mutex.Lock()
 	resController.gvrsToWatch[gvr] = true
@@ -542,15 +742,17 @@ func (resController *ClusterWatcher) AddToWatch(gvr schema.GroupVersionResource)
 // In client-go, each GVR has its own cache.
 type ResourceWatcher struct {
 	schema.GroupVersionResource
-	kind         string
-	namespaced   bool              // true if resource has namespace
-	subResources map[string]string // all the sub resources, e.g., "status"
+	kind          string
+	namespaced    bool              // true if resource has namespace
+	subResources  map[string]string // all the sub resources, e.g., "status"
+	batchDuration time.Duration     // time to batch up changes of the resource. 0 for the default batch duration
 
 	store      cache.Store
 	controller cache.Controller
 	indexer    cache.Indexer
 	queue      workqueue.RateLimitingInterface // queued up events on resources
 	stopCh     chan struct{}                   // channel to stop the controller for this resource
+	scoped     *scopedController               // informers of each permitted namespace. nil when watching all namespaces
 	// handler *cache.ResourceEventHandlerFuncs
 	// handler *resourceActionFunc // callback
 }
@@ -569,17 +771,22 @@ func processNextItem(resController *ClusterWatcher, watcher *ResourceWatcher /*,
 		}
 		return false
 	}
-	defer watcher.queue.Done(tmp)
 
 	handlerData := tmp.(*eventHandlerData)
 	if klog.V(4) {
 		klog.Infof("processing %s, GVR %s from queue", handlerData.key, watcher.GroupVersionResource)
 	}
 
-	// call handler to process the data
+	// call handler to process the data. Each event is queued as its own item,
+	// so the pool keeps the handlers of one resource from overlapping
 	// err := (*handler)(resController, watcher, handlerData)
-	err := resController.handlerMgr.callHandlers(watcher.GroupVersionResource, resController, watcher, handlerData)
-	handleError(watcher, err, handlerData)
+	key := handlerKey{gvr: watcher.GroupVersionResource, key: handlerData.key}
+	resController.handlers.run(key, func() {
+		defer watcher.queue.Done(tmp)
+		err := resController.handlerMgr.callHandlers(watcher.GroupVersionResource, resController, watcher, handlerData)
+		eventsProcessed.Inc()
+		handleError(watcher, err, handlerData)
+	})
 	return true
 }
 
@@ -635,11 +842,37 @@ func (resController *ClusterWatcher) getWatchGVR(gvr schema.GroupVersionResource
 	return schema.GroupVersionResource{}, false
 }
 
+// Add the resource a kind resolved to from discovery to the resource map, so that it can be
+// watched once added to the watch, unless already known, e.g. from initResourceMap or a CRD
+func (resController *ClusterWatcher) ensureResourceMapEntry(kind string, mapping groupKindMapping) {
+	resController.mutex.Lock()
+	_, ok := resController.resourceMap[mapping.gvr]
+	resController.mutex.Unlock()
+	if ok {
+		return
+	}
+	if klog.V(2) {
+		klog.Infof("ensureResourceMapEntry adding kind: %s GVR: %s resolved from discovery", kind, mapping.gvr)
+	}
+	resController.mapResource(kind, mapping.gvr.Group, mapping.gvr.Version, mapping.gvr.Resource, mapping.namespaced)
+}
+
 // getGVRForGroupKind gets the GVR for a kind and group
 func (resController *ClusterWatcher) getGVRForGroupKind(inGroup string, kind string) (schema.GroupVersionResource, bool) {
 
+	if resController.groupKindResolver != nil {
+		mapping, err := resController.groupKindResolver.resolveMapping(inGroup, kind)
+		if err == nil {
+			resController.ensureResourceMapEntry(kind, mapping)
+			return mapping.gvr, true
+		}
+		if klog.V(2) {
+			klog.Infof("getGVRForGroupKind unable to resolve group: %s kind: %s from discovery: %s", inGroup, kind, err)
+		}
+	}
+
 	// map group/kind to apiVersion
-	var group string
+	var group = inGroup
 	if inGroup == "core" {
 		group = ""
 	}
@@ -679,6 +912,14 @@ func (resController *ClusterWatcher) getResourceWatcher(gvr schema.GroupVersionR
 	return resController.resourceMap[gvr]
 }
 
+// Return true if the informer of a gvr is running
+func (resController *ClusterWatcher) isWatching(gvr schema.GroupVersionResource) bool {
+	resController.mutex.Lock()
+	defer resController.mutex.Unlock()
+	rw, ok := resController.resourceMap[gvr]
+	return ok && rw.controller != nil
+}
+
 // list resources for a gvr. Return empty array if resource is not being watched.
 func (resController *ClusterWatcher) listResources(gvr schema.GroupVersionResource) []interface{} {
 	resController.mutex.Lock()
@@ -725,6 +966,19 @@ func (resController *ClusterWatcher) addResourceMapEntry(kind string, group stri
 	if klog.V(3) {
 		klog.Infof("addResourceMapEntry entry resource kind: %s, group: %s, version: %s, plural: %s namespaced: %t", logString(kind), logString(group), logString(version), logString(plural), namespaced)
 	}
+	rw, subResource := resController.mapResource(kind, group, version, plural, namespaced)
+	if subResource == "" {
+		// Watch the resource if it should be watched
+		resController.restartWatch(rw.GroupVersionResource)
+	}
+	if klog.V(3) {
+		klog.Infof("addResourceMapEntry exit")
+	}
+}
+
+// Map the kind to its resource in the resource map, without watching it.
+// Return the entry of the resource, and the subresource if the plural names one
+func (resController *ClusterWatcher) mapResource(kind string, group string, version string, plural string, namespaced bool) (*ResourceWatcher, string) {
 	var subResource string
 	if strings.Contains(plural, "/") {
 		split := strings.Split(plural, "/")
@@ -732,12 +986,15 @@ func (resController *ClusterWatcher) addResourceMapEntry(kind string, group stri
 		subResource = split[1]
 	}
 	resController.mutex.Lock()
+	defer resController.mutex.Unlock()
 	gvr := schema.GroupVersionResource{Group: group, Version: version, Resource: plural}
 	rw, ok := resController.resourceMap[gvr]
 	if !ok {
 		// create new entry
 		rw = &ResourceWatcher{}
 		resController.resourceMap[gvr] = rw
+		// discovery changed
+		resController.groupKindResolver.reset()
 	}
 	apiVersionKind := version + "/" + kind
 	if group != "" {
@@ -745,10 +1002,10 @@ func (resController *ClusterWatcher) addResourceMapEntry(kind string, group stri
 		groupKind := group + "/" + kind
 		// don't replace an existing entry for a core GVR
 		store := true
-		gvr, ok := resController.groupKindToGVR.Load(groupKind)
+		existingGvr, ok := resController.groupKindToGVR.Load(groupKind)
 		if ok {
 			coreGVR, ok := coreKindToGVR[kind]
-			if ok && coreGVR == gvr {
+			if ok && coreGVR == existingGvr {
 				if klog.V(2) {
 					klog.Infof("addResourceMapEntry not repacing group/Kind map core GVR: %s with GVR: %s", coreGVR, gvr)
 				}
@@ -770,20 +1027,14 @@ func (resController *ClusterWatcher) addResourceMapEntry(kind string, group stri
 	rw.Version = version
 	rw.Resource = plural
 	rw.kind = kind
+	rw.batchDuration = resourceBatchDuration(schema.GroupResource{Group: group, Resource: plural})
 	rw.namespaced = namespaced
 	rw.subResources = map[string]string{}
 	if subResource != "" {
 		// just store subresource
 		rw.subResources[subResource] = subResource
-		resController.mutex.Unlock()
-	} else {
-		// Watch the resource if it should be watched
-		resController.mutex.Unlock()
-		resController.restartWatch(rw.GroupVersionResource)
-	}
-	if klog.V(3) {
-		klog.Infof("addResourceMapEntry exit")
 	}
+	return rw, subResource
 }
 
 // Delete a resource map entry, when the resource definition is deleted
@@ -796,6 +1047,8 @@ func (resController *ClusterWatcher) deleteResourceMapEntry(gvr schema.GroupVers
 	if ok {
 		// can be deleted
 		delete(resController.resourceMap, gvr)
+		// discovery changed
+		resController.groupKindResolver.reset()
 	}
 	apiVersionKind := gvr.Version + "/" + kind
 	if gvr.Group != "" {
@@ -986,53 +1239,67 @@ func (resController *ClusterWatcher) startWatch(inputGVR schema.GroupVersionReso
 	}
 
 	// Set up call back functions to queue resource change events
-	rw.queue = workqueue.NewRateLimitingQueue(workqueue.DefaultControllerRateLimiter())
-	rw.store, rw.controller = cache.NewIndexerInformer(
-		createListWatcher(resController.plugin.dynamicClient, gvr),
-		nil,
-		0,
-		cache.ResourceEventHandlerFuncs{
-			AddFunc: func(obj interface{}) {
-				key, err := cache.MetaNamespaceKeyFunc(obj)
-				if err == nil {
-					eventObj := &eventHandlerData{
-						funcType: AddFunc,
-						kind:     rw.kind,
-						gvr:      gvr,
-						key:      key,
-						obj:      obj,
-					}
-					rw.queue.Add(eventObj)
+	rw.queue = workqueue.NewRateLimitingQueue(newControllerRateLimiter(resController, requeueBaseDelay, requeueMaxDelay))
+	handler := cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			recordEventTime(gvr)
+			key, err := cache.MetaNamespaceKeyFunc(obj)
+			if err == nil {
+				eventObj := &eventHandlerData{
+					funcType: AddFunc,
+					kind:     rw.kind,
+					gvr:      gvr,
+					key:      key,
+					obj:      obj,
 				}
-			},
-			UpdateFunc: func(old, obj interface{}) {
-				key, err := cache.MetaNamespaceKeyFunc(obj)
-				if err == nil {
-					eventObj := &eventHandlerData{
-						funcType: UpdateFunc,
-						kind:     rw.kind,
-						gvr:      gvr,
-						key:      key,
-						obj:      obj,
-						oldObj:   old,
-					}
-					rw.queue.Add(eventObj)
+				rw.queue.Add(eventObj)
+			}
+		},
+		UpdateFunc: func(old, obj interface{}) {
+			recordEventTime(gvr)
+			key, err := cache.MetaNamespaceKeyFunc(obj)
+			if err == nil {
+				eventObj := &eventHandlerData{
+					funcType: UpdateFunc,
+					kind:     rw.kind,
+					gvr:      gvr,
+					key:      key,
+					obj:      obj,
+					oldObj:   old,
 				}
-			},
-			DeleteFunc: func(obj interface{}) {
-				key, err := cache.DeletionHandlingMetaNamespaceKeyFunc(obj)
-				if err == nil {
-					eventObj := &eventHandlerData{
-						funcType: DeleteFunc,
-						kind:     rw.kind,
-						gvr:      gvr,
-						key:      key,
-						obj:      obj,
-					}
-					rw.queue.Add(eventObj)
+				rw.queue.Add(eventObj)
+			}
+		},
+		DeleteFunc: func(obj interface{}) {
+			recordEventTime(gvr)
+			key, err := cache.DeletionHandlingMetaNamespaceKeyFunc(obj)
+			if err == nil {
+				eventObj := &eventHandlerData{
+					funcType: DeleteFunc,
+					kind:     rw.kind,
+					gvr:      gvr,
+					key:      key,
+					obj:      obj,
 				}
-			},
-		}, cache.Indexers{})
+				rw.queue.Add(eventObj)
+			}
+		},
+	}
+	if namespaces := resController.watchNamespaces(gvr, rw.namespaced); namespaces != nil {
+		// only the permitted namespaces
+		if klog.V(2) {
+			klog.Infof("watching %s in namespaces %v", gvr, namespaces)
+		}
+		rw.scoped = newScopedController(gvr, resController.plugin.dynamicClient, resController.plugin.ResyncPeriod, handler, namespaces)
+		rw.store, rw.controller = rw.scoped.indexer, rw.scoped
+	} else {
+		rw.scoped = nil
+		rw.store, rw.controller = cache.NewIndexerInformer(
+			createListWatcher(resController.plugin.dynamicClient, gvr, metav1.NamespaceAll),
+			nil,
+			resController.plugin.ResyncPeriod,
+			handler, cache.Indexers{})
+	}
 
 	rw.stopCh = make(chan struct{})
 
@@ -1078,6 +1345,20 @@ func (resController *ClusterWatcher) restartWatch(gvr schema.GroupVersionResourc
 	return resController.startWatch(gvr)
 }
 
+// Return the value of the gvr label of metrics: group/version/resource,
+// or version/resource for the core group
+func gvrLabel(gvr schema.GroupVersionResource) string {
+	if gvr.Group == "" {
+		return gvr.Version + "/" + gvr.Resource
+	}
+	return gvr.Group + "/" + gvr.Version + "/" + gvr.Resource
+}
+
+// Record the time of an event received by the informer of a GVR
+func recordEventTime(gvr schema.GroupVersionResource) {
+	lastEventTimestamp.WithLabelValues(gvrLabel(gvr)).Set(float64(time.Now().UnixNano())/float64(time.Second))
+}
+
 // stop watch if it's already being watched
 // otherwise, noop
 func (resController *ClusterWatcher) stopWatch(gvr schema.GroupVersionResource) {
@@ -1094,6 +1375,8 @@ func (resController *ClusterWatcher) stopWatch(gvr schema.GroupVersionResource)
 			close(rw.stopCh)
 			rw.queue.ShutDown()
 			rw.controller = nil
+			rw.scoped = nil
+			lastEventTimestamp.DeleteLabelValues(gvrLabel(gvr))
 			if klog.V(2) {
 				klog.Infof("stopped watching %s", gvr)
 			}
@@ -1101,10 +1384,33 @@ func (resController *ClusterWatcher) stopWatch(gvr schema.GroupVersionResource)
 	}
 }
 
-// Shutdown this instance of the controller
+// Shutdown this instance of the controller: stop the intake of changes, let the
+// batch consumer process what is batched, then stop the rest. Only the first call
+// shuts down
 func (resController *ClusterWatcher) shutDown() {
-	// close downstream channel
-	resController.resourceChannel.close()
+	resController.shutDownOnce.Do(func() {
+		resController.stopIntake()
+
+		// no more sends. The batch consumer drains the channel and stops
+		resController.resourceChannel.close()
+		if resController.batchesDone != nil {
+			<-resController.batchesDone
+		}
+
+		resController.statusRetries.stop()
+		resController.apiHealth.stop()
+		resController.heartbeat.stop()
+		if resController.stopped != nil {
+			close(resController.stopped)
+		}
+	})
+}
+
+// Stop everything that sends changes to the batch consumer: the watches, the
+// reconciles of relist bursts, and the reaper of orphaned applications
+func (resController *ClusterWatcher) stopIntake() {
+	resController.relistBursts.stop()
+	resController.orphanReaper.stop()
 
 	resController.mutex.Lock()
 	// make a copy of the gvrs for sychronziation purpose*/
@@ -1128,17 +1434,89 @@ type resourceInfo struct {
 	kind            string
 	gvr             schema.GroupVersionResource
 	labels          map[string]string
+	templateLabels  map[string]string // labels of the pod template, e.g., spec.template.metadata.labels of a Deployment
 	annotations     map[string]interface{}
 	namespace       string
 	name            string
-	kappnavStatVal  string // value of kappnav status
-	flyOver         string // value of flyover text
-	flyOverNLS      string // NLS string for flyover
+	uid             string            // uid of the resource, to tell apart resources that reuse the same name
+	kappnavStatVal  string            // value of kappnav status
+	statusTime      time.Time         // time the kappnav status was last updated, from kappnav.status.timestamp. Zero if not set
+	flyOver         string            // value of flyover text
+	flyOverNLS      string            // NLS string for flyover
+	componentGroups string            // components bucketed by display group, applications only
+	statusBreakdown map[string]int    // number of components for each status, applications only
+	statusCauses    []string          // components with the status of the application, applications only
+	previousStatVal string            // status before the change being written, for the event recorded once written. applications only
+	components      []componentStatus // components counted in the status, applications only
+	availability    string            // weighted percentage of components that are healthy, e.g. Normal, or N/A. applications only
+	podStatus       *podStatus        // phase and container statuses, bare Pods only
+	deployment      *deploymentStatus // replica counts and conditions, Deployments only
 }
 
-// unique key for the resource.
+// unique key for the resource. Includes the uid, if known, so that a resource
+// re-created with the same name has a different key than the deleted one
 func (resInfo *resourceInfo) key() string {
-	return resInfo.gvr.String() + "/" + resInfo.namespace + "/" + resInfo.name
+	key := resInfo.gvr.String() + "/" + resInfo.namespace + "/" + resInfo.name
+	if resInfo.uid != "" {
+		key += "/" + resInfo.uid
+	}
+	return key
+}
+
+// Return the API group of the resource, from its GVR if known, else from its apiVersion
+func (resInfo *resourceInfo) group() string {
+	if resInfo.gvr.Resource != "" {
+		return resInfo.gvr.Group
+	}
+	gv, err := schema.ParseGroupVersion(resInfo.apiVersion)
+	if err != nil {
+		return ""
+	}
+	return gv.Group
+}
+
+// Return true if the resource is an application annotated to never be a component of another application
+func (resInfo *resourceInfo) isTopLevel() bool {
+	if resInfo.kind != APPLICATION {
+		return false
+	}
+	annotations, ok := resInfo.metadata[ANNOTATIONS].(map[string]interface{})
+	if !ok {
+		return false
+	}
+	value, _ := annotations[kappnavTopLevel].(string)
+	return value == "true"
+}
+
+// Return true if the resource is paused for maintenance and must not count toward the status of its applications
+func (resInfo *resourceInfo) inMaintenance() bool {
+	annotations, ok := resInfo.metadata[ANNOTATIONS].(map[string]interface{})
+	if !ok {
+		return false
+	}
+	value, _ := annotations[kappnavMaintenance].(string)
+	return value == "true"
+}
+
+// Return the weight of the resource in the availability of its applications.
+// 1 unless annotated with a non-negative number
+func (resInfo *resourceInfo) statusWeight() float64 {
+	annotations, ok := resInfo.metadata[ANNOTATIONS].(map[string]interface{})
+	if !ok {
+		return 1
+	}
+	value, ok := annotations[kappnavStatusWeight].(string)
+	if !ok {
+		return 1
+	}
+	weight, err := strconv.ParseFloat(value, 64)
+	if err != nil || weight < 0 || math.IsNaN(weight) || math.IsInf(weight, 0) {
+		if klog.V(2) {
+			klog.Infof("ignoring invalid %s %q of %s %s/%s", kappnavStatusWeight, value, resInfo.kind, resInfo.namespace, resInfo.name)
+		}
+		return 1
+	}
+	return weight
 }
 
 type groupKind struct {
@@ -1156,27 +1534,71 @@ const (
 	OperatorExists = "Exists"
 	// OperatorDoesNotExist - label does not exist
 	OperatorDoesNotExist = "DoesNotExist"
+	// OperatorGreaterThan - label is an integer greater than the single value
+	OperatorGreaterThan = "Gt"
+	// OperatorLessThan - label is an integer less than the single value
+	OperatorLessThan = "Lt"
+)
+
+// What the key of a match expression is matched against
+const (
+	expressionSourceLabel      = "label"      // labels of the component, the default
+	expressionSourceAnnotation = "annotation" // annotations of the component
+)
+
+// How matchLabels and matchExpressions of a selector combine
+const (
+	selectorCombineAnd = "and" // both must match
+	selectorCombineOr  = "or"  // either must match
 )
 
 type matchExpression struct {
 	key      string
-	operator string // In, NotIn, Exists, and DoesNotExist
+	operator string // In, NotIn, Exists, DoesNotExist, Gt, and Lt
 	values   []string
+	source   string // what the key is matched against: label or annotation. Empty for label
+}
+
+// A component listed explicitly by an application
+type componentRef struct {
+	kind string
+	name string
+	gvr  schema.GroupVersionResource
 }
 
 // Application resource fields
 type appResourceInfo struct {
 	resourceInfo
-	componentNamespaces map[string]string // additional namespaces for namespaced component gvrs
-	componentKinds      []groupKind
-	matchLabels         map[string]string // the match labels for this application
-	matchExpressions    []matchExpression
+	componentNamespaces     map[string]string // additional namespaces for namespaced component gvrs
+	componentKinds          []groupKind
+	components              []componentRef    // components listed by group, kind, and name
+	matchLabels             map[string]string // the match labels for this application
+	matchExpressions        []matchExpression
+	matchTemplateLabels     bool               // true to also match the pod template labels of components
+	excludeSubApplications  bool               // true to leave child applications out of the status
+	namespaceWeights        map[string]float64 // weight of the components of each namespace in the availability. 1 if absent
+	selectorCombine         string             // how matchLabels and matchExpressions combine: and, or
+	unresolvedKinds         []groupKind        // component kinds not known yet, e.g. whose CRD is not installed
+	excludeMatchLabels      map[string]string  // labels of resources left out even if they match the selector
+	excludeMatchExpressions []matchExpression  // expressions of resources left out even if they match the selector
 }
 
+// Return true if both are the same resource: same GVR, namespace, and name.
+// Resources whose uids are both known must also have the same uid, to tell apart
+// a resource from a deleted one with the same name
 func isSameResource(res1 *resourceInfo, res2 *resourceInfo) bool {
-	return strings.Compare(res1.apiVersion, res2.apiVersion) == 0 &&
-		strings.Compare(res1.gvr.String(), res2.gvr.String()) == 0 &&
-		strings.Compare(res1.name, res2.name) == 0
+	if res1.gvr != res2.gvr || res1.namespace != res2.namespace || res1.name != res2.name {
+		return false
+	}
+	return res1.uid == "" || res2.uid == "" || res1.uid == res2.uid
+}
+
+// Return the annotation the computed status is written to and compared against
+func statusAnnotationKey() string {
+	if statusAnnotation == "" {
+		return kappnavStatusValue
+	}
+	return statusAnnotation
 }
 
 // Set the kappnav status into the resource object
@@ -1197,14 +1619,50 @@ func setkAppNavStatus(unstructuredObj *unstructured.Unstructured, stat string, f
 	} else {
 		annotations = annotationsInterf.(map[string]interface{})
 	}
-	annotations[kappnavStatusValue] = stat
+	annotations[statusAnnotationKey()] = stat
 	annotations[kappnavStatusFlyover] = flyoverText
 	annotations[kappnavStatusFlyoverNls] = flyoverNLS
 }
 
+// Set the component groups annotation of an application. Remove it if there are no groups
+func setkAppNavComponentGroups(unstructuredObj *unstructured.Unstructured, componentGroups string) {
+	annotations := unstructuredObj.GetAnnotations()
+	if componentGroups == "" {
+		if _, ok := annotations[kappnavStatusComponentGroups]; !ok {
+			return
+		}
+		delete(annotations, kappnavStatusComponentGroups)
+	} else {
+		if annotations == nil {
+			annotations = make(map[string]string)
+		}
+		annotations[kappnavStatusComponentGroups] = componentGroups
+	}
+	unstructuredObj.SetAnnotations(annotations)
+}
+
+// Set the availability annotation of an application. Remove it if empty
+func setkAppNavAvailability(unstructuredObj *unstructured.Unstructured, availability string) {
+	annotations := unstructuredObj.GetAnnotations()
+	if availability == "" {
+		if _, ok := annotations[kappnavStatusAvailability]; !ok {
+			return
+		}
+		delete(annotations, kappnavStatusAvailability)
+	} else {
+		if annotations == nil {
+			annotations = make(map[string]string)
+		}
+		annotations[kappnavStatusAvailability] = availability
+	}
+	unstructuredObj.SetAnnotations(annotations)
+}
+
 // parseResource parses a resource into a structure
-func (resController *ClusterWatcher) parseResource(unstructuredObj *unstructured.Unstructured, resourceInfo *resourceInfo) {
-	parseResourceBasic(unstructuredObj, resourceInfo)
+// Return a *parseError if the resource is malformed
+func (resController *ClusterWatcher) parseResource(unstructuredObj *unstructured.Unstructured, resourceInfo *resourceInfo) error {
+	resourceParses.Inc()
+	err := parseResourceBasic(unstructuredObj, resourceInfo)
 	apiVersionKind := resourceInfo.apiVersion + "/" + resourceInfo.kind
 	gvr, ok := resController.apiVersionKindToGVR.Load(apiVersionKind)
 	if ok {
@@ -1212,22 +1670,51 @@ func (resController *ClusterWatcher) parseResource(unstructuredObj *unstructured
 			klog.Infof("parseResource got gvr: %s mapped to apiVersion/Kind: %s", gvr.(schema.GroupVersionResource), apiVersionKind)
 		}
 		resourceInfo.gvr = gvr.(schema.GroupVersionResource)
+		if isApplicationGVR(resourceInfo.gvr) {
+			// the same application whatever the version it is read at
+			resourceInfo.gvr = coreApplicationGVR
+		}
 	} else {
 		if klog.V(4) {
 			klog.Infof("parseResource no GVR is mapped to apiVersion/Kind: %s", apiVersionKind)
 		}
 	}
+	return err
+}
+
+// Return the string field of a map, or a *parseError if it is missing or not a string
+func requiredString(fields map[string]interface{}, name string, path string) (string, error) {
+	tmp, ok := fields[name]
+	if !ok || tmp == nil {
+		return "", newParseError(ErrMissingField, path, "")
+	}
+	value, ok := tmp.(string)
+	if !ok {
+		return "", newParseError(ErrInvalidFieldType, path, fmt.Sprintf("expecting string, got %T", tmp))
+	}
+	return value, nil
 }
 
-func parseResourceBasic(unstructuredObj *unstructured.Unstructured, resourceInfo *resourceInfo) {
+// parseResourceBasic parses the fields common to all resources.
+// Return a *parseError for the first malformed field. The other fields are still parsed
+func parseResourceBasic(unstructuredObj *unstructured.Unstructured, resourceInfo *resourceInfo) error {
+	var retErr error
+	keepFirst := func(err error) {
+		if retErr == nil {
+			retErr = err
+		}
+	}
 
 	resourceInfo.unstructuredObj = unstructuredObj
 	var objMap = unstructuredObj.Object
-	resourceInfo.apiVersion = objMap[APIVERSION].(string)
+	var err error
+	resourceInfo.apiVersion, err = requiredString(objMap, APIVERSION, APIVERSION)
+	keepFirst(err)
 	if klog.V(4) {
 		klog.Infof("parseResourceBasic apiVersion: %s", resourceInfo.apiVersion)
 	}
-	resourceInfo.kind = objMap[KIND].(string)
+	resourceInfo.kind, err = requiredString(objMap, KIND, KIND)
+	keepFirst(err)
 
 	metadataObj, ok := objMap[METADATA]
 	if !ok {
@@ -1244,19 +1731,33 @@ func parseResourceBasic(unstructuredObj *unstructured.Unstructured, resourceInfo
 	if ok {
 		resourceInfo.annotations = annotations
 		var kappnavStat interface{}
-		kappnavStat, ok = annotations[kappnavStatusValue]
+		kappnavStat, ok = annotations[statusAnnotationKey()]
 		if ok && (kappnavStat != nil) {
-			resourceInfo.kappnavStatVal = kappnavStat.(string)
+			resourceInfo.kappnavStatVal, _ = kappnavStat.(string)
 		}
 		var flyOver interface{}
 		flyOver, ok = annotations[kappnavStatusFlyover]
 		if ok && (flyOver != nil) {
-			resourceInfo.flyOver = flyOver.(string)
+			resourceInfo.flyOver, _ = flyOver.(string)
 		}
 		var flyOverNLS interface{}
 		flyOverNLS, ok = annotations[kappnavStatusFlyoverNls]
 		if ok && (flyOverNLS != nil) {
-			resourceInfo.flyOverNLS = flyOverNLS.(string)
+			resourceInfo.flyOverNLS, _ = flyOverNLS.(string)
+		}
+		var componentGroups interface{}
+		componentGroups, ok = annotations[kappnavStatusComponentGroups]
+		if ok && (componentGroups != nil) {
+			resourceInfo.componentGroups, _ = componentGroups.(string)
+		}
+		resourceInfo.availability, _ = annotations[kappnavStatusAvailability].(string)
+		if timestamp, ok := annotations[kappnavStatusTimestamp].(string); ok {
+			statusTime, err := time.Parse(time.RFC3339, timestamp)
+			if err == nil {
+				resourceInfo.statusTime = statusTime
+			} else if klog.V(2) {
+				klog.Infof("ignoring invalid %s %s of %s/%s: %s", kappnavStatusTimestamp, timestamp, resourceInfo.namespace, resourceInfo.name, err)
+			}
 		}
 	} else {
 		resourceInfo.annotations = make(map[string]interface{})
@@ -1266,25 +1767,57 @@ func parseResourceBasic(unstructuredObj *unstructured.Unstructured, resourceInfo
 	labels, ok = resourceInfo.metadata[LABELS].(map[string]interface{})
 	if ok {
 		for key, val := range labels {
-			resourceInfo.labels[key] = val.(string)
+			str, ok := val.(string)
+			if !ok {
+				keepFirst(newParseError(ErrInvalidFieldType, "metadata.labels."+key, fmt.Sprintf("expecting string, got %T", val)))
+				continue
+			}
+			resourceInfo.labels[key] = str
 		}
 	}
-	resourceInfo.name = resourceInfo.metadata[NAME].(string)
-	var ns interface{}
-	ns, ok = resourceInfo.metadata[NAMESPACE]
-	if ok {
-		resourceInfo.namespace = ns.(string)
-	} else {
-		resourceInfo.namespace = ""
+	resourceInfo.templateLabels = make(map[string]string)
+	if spec, ok := objMap[SPEC].(map[string]interface{}); ok {
+		if template, ok := spec[TEMPLATE].(map[string]interface{}); ok {
+			if templateMetadata, ok := template[METADATA].(map[string]interface{}); ok {
+				if templateLabels, ok := templateMetadata[LABELS].(map[string]interface{}); ok {
+					for key, val := range templateLabels {
+						if str, ok := val.(string); ok {
+							resourceInfo.templateLabels[key] = str
+						}
+					}
+				}
+			}
+		}
+	}
+	resourceInfo.name, err = requiredString(resourceInfo.metadata, NAME, "metadata.name")
+	keepFirst(err)
+	resourceInfo.namespace, _ = resourceInfo.metadata[NAMESPACE].(string)
+	resourceInfo.uid, _ = resourceInfo.metadata[UID].(string)
+	resourceInfo.podStatus = nil
+	if resourceInfo.kind == POD && len(unstructuredObj.GetOwnerReferences()) == 0 {
+		// Pod not behind a controller. Its health is read from its own status
+		resourceInfo.podStatus = parsePodStatus(objMap)
+	}
+	resourceInfo.deployment = nil
+	if resourceInfo.kind == DEPLOYMENT {
+		resourceInfo.deployment = parseDeploymentStatus(objMap)
 	}
+	return retErr
 }
 
 // parseAppResource parses Application resource into more convenient representation
+// Return a *parseError for the first malformed field. Malformed entries of
+// componentKinds and components are skipped, and the rest still parsed
 func (resController *ClusterWatcher) parseAppResource(unstructuredObj *unstructured.Unstructured, appResource *appResourceInfo) error {
 	if klog.V(4) {
 		klog.Infof("parseAppResource entry resource :%s %v", unstructuredObj.GetName(), unstructuredObj)
 	}
-	resController.parseResource(unstructuredObj, &appResource.resourceInfo)
+	retErr := resController.parseResource(unstructuredObj, &appResource.resourceInfo)
+	keepFirst := func(err error) {
+		if retErr == nil {
+			retErr = err
+		}
+	}
 
 	componentNS := ""
 	tmp, ok := appResource.resourceInfo.annotations[kappnavComponentNamespaces]
@@ -1305,25 +1838,73 @@ func (resController *ClusterWatcher) parseAppResource(unstructuredObj *unstructu
 		appResource.componentNamespaces[appResource.resourceInfo.namespace] = appResource.resourceInfo.namespace
 	}
 
+	// Whether to match the pod template labels of components in addition to their own labels
+	appResource.matchTemplateLabels = false
+	tmp, ok = appResource.resourceInfo.annotations[kappnavComponentTemplateLabels]
+	if ok {
+		matchTemplateLabels, _ := tmp.(string)
+		appResource.matchTemplateLabels = matchTemplateLabels == "true"
+	}
+
+	// Whether to compute the status from the non-application components only
+	appResource.excludeSubApplications = false
+	tmp, ok = appResource.resourceInfo.annotations[kappnavExcludeSubApplications]
+	if ok {
+		excludeSubApplications, _ := tmp.(string)
+		appResource.excludeSubApplications = excludeSubApplications == "true"
+	}
+
+	// Weight of the components of each namespace in the availability
+	appResource.namespaceWeights = nil
+	tmp, ok = appResource.resourceInfo.annotations[kappnavNamespaceWeights]
+	if ok {
+		namespaceWeights, _ := tmp.(string)
+		appResource.namespaceWeights = parseNamespaceWeights(namespaceWeights)
+	}
+
+	// Whether a component must match both matchLabels and matchExpressions, or either
+	appResource.selectorCombine = selectorCombineAnd
+	tmp, ok = appResource.resourceInfo.annotations[kappnavSelectorCombine]
+	if ok {
+		selectorCombine, _ := tmp.(string)
+		if selectorCombine == selectorCombineOr {
+			appResource.selectorCombine = selectorCombineOr
+		} else if selectorCombine != selectorCombineAnd && klog.V(2) {
+			klog.Infof("ignoring invalid %s %q of application %s/%s", kappnavSelectorCombine, selectorCombine, appResource.namespace, appResource.name)
+		}
+	}
+
 	var objMap = unstructuredObj.Object
 	var spec map[string]interface{}
 	tmp, ok = objMap[SPEC]
 	if !ok {
-		return fmt.Errorf("object has no spec %s", unstructuredObj)
+		return newParseError(ErrMissingSpec, SPEC, "")
+	}
+	spec, ok = tmp.(map[string]interface{})
+	if !ok {
+		return newParseError(ErrMissingSpec, SPEC, fmt.Sprintf("expecting object, got %T", tmp))
 	}
-	spec = tmp.(map[string]interface{})
 	appResource.componentKinds = make([]groupKind, 0)
 	tmp, ok = spec[COMPONENTKINDS]
 	if ok {
-		componentKinds := tmp.([]interface{})
-		for _, component := range componentKinds {
-			var kindMap = component.(map[string]interface{})
+		componentKinds, ok := tmp.([]interface{})
+		if !ok {
+			keepFirst(newParseError(ErrInvalidComponentKinds, "spec.componentKinds", fmt.Sprintf("expecting array, got %T", tmp)))
+		}
+		for index, component := range componentKinds {
+			kindMap, ok := component.(map[string]interface{})
+			if !ok {
+				keepFirst(newParseError(ErrInvalidComponentKinds, fmt.Sprintf("spec.componentKinds[%d]", index), fmt.Sprintf("expecting object, got %T", component)))
+				continue
+			}
 			if klog.V(4) {
 				klog.Infof("parseAppResource application: %s kindMap: %v", appResource.name, kindMap)
 			}
 			group, _ := kindMap[GROUP].(string)
 			kind, ok2 := kindMap[KIND].(string)
-			if ok2 {
+			if !ok2 || kind == "" {
+				keepFirst(newParseError(ErrInvalidComponentKinds, fmt.Sprintf("spec.componentKinds[%d].kind", index), "kind is required"))
+			} else {
 				if klog.V(4) {
 					klog.Infof("parseAppResource application: %s processing componentKind: group: %s  kind: %s", appResource.name, group, kind)
 				}
@@ -1344,63 +1925,183 @@ func (resController *ClusterWatcher) parseAppResource(unstructuredObj *unstructu
 						klog.Infof("parseAppResource application: %s groupKind: %v", appResource.name, groupKind)
 					}
 					appResource.componentKinds = append(appResource.componentKinds, groupKind)
+					if resController.nsFilter != nil {
+						resController.nsFilter.seedApplicationNamespace(resController, gvr, appResource.resourceInfo.namespace)
+					}
 				} else {
 					if klog.V(4) {
 						klog.Infof("parseAppResource application: %s error getting GVR for componentKind: group: %s kind: %s", appResource.name, group, kind)
 					}
+					appResource.unresolvedKinds = append(appResource.unresolvedKinds, groupKind{group: group, kind: kind})
+				}
+			}
+		}
+	}
+
+	// Components listed explicitly, in addition to those matched by the selector
+	appResource.components = make([]componentRef, 0)
+	tmp, ok = spec[COMPONENTS]
+	if ok {
+		components, ok := tmp.([]interface{})
+		if !ok {
+			keepFirst(newParseError(ErrInvalidComponents, "spec.components", fmt.Sprintf("expecting array, got %T", tmp)))
+		}
+		for index, component := range components {
+			refMap, _ := component.(map[string]interface{})
+			group, _ := refMap[GROUP].(string)
+			kind, _ := refMap[KIND].(string)
+			name, _ := refMap[NAME].(string)
+			if kind == "" || name == "" {
+				if klog.V(4) {
+					klog.Infof("parseAppResource application: %s skipping component without kind or name: %v", appResource.name, refMap)
+				}
+				keepFirst(newParseError(ErrInvalidComponents, fmt.Sprintf("spec.components[%d]", index), "kind and name are required"))
+				continue
+			}
+			gvr, ok := resController.getGVRForGroupKind(group, kind)
+			if !ok {
+				if klog.V(4) {
+					klog.Infof("parseAppResource application: %s error getting GVR for component: group: %s kind: %s name: %s", appResource.name, group, kind, name)
+				}
+				continue
+			}
+			appResource.components = append(appResource.components, componentRef{kind: kind, name: name, gvr: gvr})
+			if !isComponentKind(appResource.componentKinds, gvr.Group, kind) {
+				// watch the kinds of listed components as well
+				if group == "" {
+					group = "/" + gvr.Version
+				}
+				appResource.componentKinds = append(appResource.componentKinds, groupKind{group: group, kind: kind, gvr: gvr})
+				if resController.nsFilter != nil {
+					resController.nsFilter.seedApplicationNamespace(resController, gvr, appResource.resourceInfo.namespace)
+				}
+			}
+		}
+	}
+
+	// Resources to leave out even if they match the selector
+	appResource.excludeMatchLabels = make(map[string]string)
+	appResource.excludeMatchExpressions = make([]matchExpression, 0)
+	tmp, ok = spec[EXCLUDESELECTOR]
+	if ok && tmp != nil {
+		// A malformed exclusion is left out as a whole rather than in part, as what
+		// is left of it could exclude more than intended. It only disables the exclusion
+		var excludeErr *parseError
+		keepFirstExclude := func(err error) {
+			if parseErr, ok := err.(*parseError); ok && excludeErr == nil {
+				detail := "no resource is excluded"
+				if parseErr.detail != "" {
+					detail = parseErr.detail + ", " + detail
 				}
+				excludeErr = newParseError(ErrInvalidExcludeSelector, parseErr.field, detail)
 			}
 		}
+		excludeSelector, ok := tmp.(map[string]interface{})
+		if !ok {
+			keepFirstExclude(newParseError(ErrInvalidExcludeSelector, "spec.excludeSelector", fmt.Sprintf("expecting object, got %T", tmp)))
+		} else {
+			appResource.excludeMatchLabels, appResource.excludeMatchExpressions =
+				parseLabelSelector(excludeSelector, "spec.excludeSelector", keepFirstExclude)
+		}
+		if excludeErr != nil {
+			appResource.excludeMatchLabels = make(map[string]string)
+			appResource.excludeMatchExpressions = make([]matchExpression, 0)
+			keepFirst(excludeErr)
+		}
 	}
+
 	appResource.matchLabels = make(map[string]string)
 	appResource.matchExpressions = make([]matchExpression, 0)
 	var selector map[string]interface{}
 	tmp, ok = spec[SELECTOR]
+	if !ok || tmp == nil {
+		// no selector, handled as an empty one. See selectorlessApplications
+		return retErr
+	}
+	selector, ok = tmp.(map[string]interface{})
 	if !ok {
-		// no selector
-		return nil
+		keepFirst(newParseError(ErrInvalidSelector, "spec.selector", fmt.Sprintf("expecting object, got %T", tmp)))
+		return retErr
 	}
-	selector = tmp.(map[string]interface{})
-	tmp, ok = selector[MATCHLABELS]
+	appResource.matchLabels, appResource.matchExpressions =
+		parseLabelSelector(selector, "spec.selector", keepFirst)
+	return retErr
+}
+
+// Parse the matchLabels and matchExpressions of a label selector at the path of the spec
+func parseLabelSelector(selector map[string]interface{}, path string, keepFirst func(error)) (matchLabels map[string]string, matchExpressions []matchExpression) {
+	matchLabels = make(map[string]string)
+	matchExpressions = make([]matchExpression, 0)
+	tmp, ok := selector[MATCHLABELS]
 	if ok {
-		matchLabels := tmp.(map[string]interface{})
-		for key, val := range matchLabels {
-			appResource.matchLabels[key] = val.(string)
+		labels, ok := tmp.(map[string]interface{})
+		if !ok {
+			keepFirst(newParseError(ErrInvalidSelector, path+".matchLabels", fmt.Sprintf("expecting object, got %T", tmp)))
+		}
+		for key, val := range labels {
+			str, ok := val.(string)
+			if !ok {
+				keepFirst(newParseError(ErrInvalidSelector, path+".matchLabels."+key, fmt.Sprintf("expecting string, got %T", val)))
+				continue
+			}
+			matchLabels[key] = str
 		}
 	}
 
 	tmp, ok = selector[MATCHEXPRESSIONS]
 	if ok {
-		matchExpressions := tmp.([]interface{})
-		for _, tmpExpr := range matchExpressions {
-			expr := tmpExpr.(map[string]interface{})
-			tmp, ok := expr[KEY]
+		expressions, ok := tmp.([]interface{})
+		if !ok {
+			keepFirst(newParseError(ErrInvalidSelector, path+".matchExpressions", fmt.Sprintf("expecting array, got %T", tmp)))
+		}
+		for index, tmpExpr := range expressions {
+			exprPath := fmt.Sprintf("%s.matchExpressions[%d]", path, index)
+			expr, ok := tmpExpr.(map[string]interface{})
 			if !ok {
-				return nil
+				keepFirst(newParseError(ErrInvalidSelector, exprPath, fmt.Sprintf("expecting object, got %T", tmpExpr)))
+				continue
 			}
-			key := tmp.(string)
-			tmp, ok = expr[OPERATOR]
-			if !ok {
-				return nil
+			key, err := requiredString(expr, KEY, exprPath+".key")
+			if err != nil {
+				keepFirst(newParseError(ErrInvalidSelector, exprPath+".key", parseErrorReason(err).Error()))
+				continue
+			}
+			operator, err := requiredString(expr, OPERATOR, exprPath+".operator")
+			if err != nil {
+				keepFirst(newParseError(ErrInvalidSelector, exprPath+".operator", parseErrorReason(err).Error()))
+				continue
+			}
+			var source string
+			if tmpSource, ok := expr[SOURCE]; ok {
+				source, ok = tmpSource.(string)
+				if !ok || (source != expressionSourceLabel && source != expressionSourceAnnotation) {
+					keepFirst(newParseError(ErrInvalidSelector, exprPath+".source", fmt.Sprintf("expecting %s or %s, got %v", expressionSourceLabel, expressionSourceAnnotation, tmpSource)))
+					continue
+				}
 			}
-			operator := tmp.(string)
 			var values = make([]string, 0)
 			tmp, ok = expr[VALUES]
 			if ok {
-				tmpArr := tmp.([]interface{})
+				tmpArr, _ := tmp.([]interface{})
 				for _, elem := range tmpArr {
-					values = append(values, elem.(string))
+					str, ok := elem.(string)
+					if !ok {
+						keepFirst(newParseError(ErrInvalidSelector, exprPath+".values", fmt.Sprintf("expecting string, got %T", elem)))
+						continue
+					}
+					values = append(values, str)
 				}
 			}
 			var theExpr = matchExpression{
 				key:      key,
 				operator: operator,
 				values:   values,
+				source:   source,
 			}
-			appResource.matchExpressions = append(appResource.matchExpressions, theExpr)
+			matchExpressions = append(matchExpressions, theExpr)
 		}
 	}
-	return nil
+	return matchLabels, matchExpressions
 }
 
 // Get group, version, plural, kind, and subresouces defined by CRD
@@ -1486,13 +2187,13 @@ func (resController *ClusterWatcher) deleteGVR(obj interface{}) (gvr schema.Grou
 
 // Create a ListWatcher to iterate over resources for client side cache
 // See kubernetes/pkg/controller/garbagecollector/graph_builder.go
-func createListWatcher(dynamicClient dynamic.Interface, gvr schema.GroupVersionResource) *cache.ListWatch {
+func createListWatcher(dynamicClient dynamic.Interface, gvr schema.GroupVersionResource, namespace string) *cache.ListWatch {
 	return &cache.ListWatch{
 		ListFunc: func(options metav1.ListOptions) (k8sruntime.Object, error) {
-			return dynamicClient.Resource(gvr).List(options)
+			return dynamicClient.Resource(gvr).Namespace(namespace).List(options)
 		},
 		WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
-			return dynamicClient.Resource(gvr).Watch(options)
+			return dynamicClient.Resource(gvr).Namespace(namespace).Watch(options)
 		},
 	}
 }
@@ -17,6 +17,7 @@ limitations under the License.
 package main
 
 import (
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/klog"
 )
 
@@ -25,6 +26,8 @@ var CRDNewHandler resourceActionFunc = func(resController *ClusterWatcher, rw *R
 	if klog.V(2) {
 		klog.Infof("CRDNewHandler entry eventData.funcType: %v resourceWatcher: %v", eventData.funcType, rw)
 	}
+	// the printer columns of the CRD may have changed
+	resController.printerColumns.reset()
 	key := eventData.key
 	obj, exists, err := rw.store.GetByKey(key)
 	if err != nil {
@@ -44,16 +47,25 @@ var CRDNewHandler resourceActionFunc = func(resController *ClusterWatcher, rw *R
 		if klog.V(4) {
 			klog.Infof("CRDNewHandler added GVR %s", gvr)
 		}
+		if unstructuredObj, ok := obj.(*unstructured.Unstructured); ok {
+			// applications created before the CRD
+			_, _, _, kind, _, _ := getCRDGVRKindSubresource(unstructuredObj)
+			resController.watchPendingKind(kind)
+		}
 		if eventData.funcType == AddFunc {
-			if gvr == coreApplicationGVR {
+			if isApplicationGVR(gvr) {
 				if klog.V(4) {
 					klog.Infof("CRDNewHandler Application CRD add event")
 				}
 				// TODO: need something less hard coded to trigger start watch of deployment when aplication CRD is defind
-				resController.AddToWatch(coreApplicationGVR)
-				resController.AddToWatch(coreDeploymentGVR)
-				resController.AddToWatch(coreStatefulSetGVR)
-				resController.AddToWatch(coreDeploymentConfigGVR)
+				for _, appGVR := range applicationGVRs() {
+					// discovery only maps the preferred version
+					resController.ensureResourceMapEntry(APPLICATION, groupKindMapping{gvr: appGVR, namespaced: true})
+					resController.PinWatch(appGVR)
+				}
+				resController.PinWatch(coreDeploymentGVR)
+				resController.PinWatch(coreStatefulSetGVR)
+				resController.PinWatch(coreDeploymentConfigGVR)
 				//resController.AddToWatch(KAppNav)
 				err = deleteOrphanedAutoCreatedApplications(resController)
 				if err != nil {
@@ -0,0 +1,37 @@
+/*
+Copyright 2019 IBM Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+)
+
+const (
+	// DefaultDeleteAttempts - attempts to delete a resource while the API server returns a transient error
+	DefaultDeleteAttempts = 3
+
+	// delay before the first retry of a delete. Doubles with each retry
+	deleteRetryDelay = time.Millisecond * 200
+)
+
+// Return true if an error of the API server is transient, for the request to be retried:
+// a conflict, e.g. with a webhook updating the resource, a server timeout, or throttling
+func isRetryableError(err error) bool {
+	return errors.IsConflict(err) || errors.IsServerTimeout(err) || errors.IsTooManyRequests(err)
+}
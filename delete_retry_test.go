@@ -0,0 +1,174 @@
+/*
+Copyright 2019 IBM Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic/fake"
+	ktesting "k8s.io/client-go/testing"
+)
+
+// Create a cluster watcher watching configmaps, with a configmap to delete
+func newDeleteTestWatcher(t *testing.T) (*ClusterWatcher, *fake.FakeDynamicClient, *resourceInfo) {
+	t.Helper()
+	client := fake.NewSimpleDynamicClient(runtime.NewScheme())
+	configMap := &unstructured.Unstructured{}
+	configMap.SetAPIVersion("v1")
+	configMap.SetKind("ConfigMap")
+	configMap.SetNamespace("default")
+	configMap.SetName("cm")
+	if _, err := client.Resource(coreConfigMapGVR).Namespace("default").Create(configMap, metav1.CreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	resController := &ClusterWatcher{
+		plugin: &ControllerPlugin{dynamicClient: client},
+		resourceMap: map[schema.GroupVersionResource]*ResourceWatcher{
+			coreConfigMapGVR: {GroupVersionResource: coreConfigMapGVR},
+		},
+	}
+	resInfo := &resourceInfo{gvr: coreConfigMapGVR, kind: "ConfigMap", namespace: "default", name: "cm"}
+	return resController, client, resInfo
+}
+
+// Fail the deletes of configmaps with the given errors, one per attempt,
+// then delete. Return the number of attempts
+func failDeletes(client *fake.FakeDynamicClient, failures ...error) *int {
+	attempts := 0
+	client.PrependReactor("delete", "configmaps", func(action ktesting.Action) (bool, runtime.Object, error) {
+		attempts++
+		if attempts <= len(failures) {
+			return true, nil, failures[attempts-1]
+		}
+		return false, nil, nil
+	})
+	return &attempts
+}
+
+func TestDeleteResourceRetries(t *testing.T) {
+	configMaps := coreConfigMapGVR.GroupResource()
+	conflict := errors.NewConflict(configMaps, "cm", fmt.Errorf("modified by webhook"))
+	timeout := errors.NewServerTimeout(configMaps, "delete", 1)
+	throttled := errors.NewTooManyRequests("slow down", 1)
+	forbidden := errors.NewForbidden(configMaps, "cm", fmt.Errorf("denied"))
+
+	savedAttempts := deleteAttempts
+	deleteAttempts = 3
+	defer func() {
+		deleteAttempts = savedAttempts
+	}()
+
+	tests := []struct {
+		name             string
+		failures         []error
+		expectedAttempts int
+		expectDeleted    bool
+		expectError      bool
+	}{
+		{"fails twice then succeeds", []error{conflict, timeout}, 3, true, false},
+		{"throttled once", []error{throttled}, 2, true, false},
+		{"attempts exhausted", []error{conflict, conflict, conflict}, 3, false, true},
+		{"terminal error", []error{forbidden}, 1, false, true},
+	}
+	for _, test := range tests {
+		resController, client, resInfo := newDeleteTestWatcher(t)
+		attempts := failDeletes(client, test.failures...)
+		err := deleteResource(resController, resInfo)
+		if (err != nil) != test.expectError {
+			t.Errorf("%s: expecting error %t, got %v", test.name, test.expectError, err)
+		}
+		if *attempts != test.expectedAttempts {
+			t.Errorf("%s: expecting %d attempts, got %d", test.name, test.expectedAttempts, *attempts)
+		}
+		_, err = client.Resource(coreConfigMapGVR).Namespace("default").Get("cm", metav1.GetOptions{})
+		if deleted := errors.IsNotFound(err); deleted != test.expectDeleted {
+			t.Errorf("%s: expecting deleted %t, got %t", test.name, test.expectDeleted, deleted)
+		}
+	}
+}
+
+// Test deleting a resource already deleted succeeds without retries
+func TestDeleteResourceNotFound(t *testing.T) {
+	resController, client, resInfo := newDeleteTestWatcher(t)
+	resInfo.name = "missing"
+	attempts := failDeletes(client)
+	if err := deleteResource(resController, resInfo); err != nil {
+		t.Errorf("expecting deleting a missing resource to succeed, got %s", err)
+	}
+	if *attempts != 1 {
+		t.Errorf("expecting 1 attempt, got %d", *attempts)
+	}
+}
+
+func TestResourceDeleted(t *testing.T) {
+	configMaps := coreConfigMapGVR.GroupResource()
+	forbidden := errors.NewForbidden(configMaps, "cm", fmt.Errorf("denied"))
+	serverError := errors.NewInternalError(fmt.Errorf("etcd unavailable"))
+
+	tests := []struct {
+		name          string
+		getError      error // error returned on get. nil to get from the fake client
+		deleted       bool  // whether the configmap was deleted
+		watched       bool
+		expected      bool
+		expectedError error
+	}{
+		{"exists", nil, false, true, false, nil},
+		{"not found", nil, true, true, true, nil},
+		{"forbidden", forbidden, false, true, false, forbidden},
+		{"server error", serverError, false, true, false, serverError},
+		{"not watched", forbidden, false, false, true, nil},
+	}
+	for _, test := range tests {
+		resController, client, resInfo := newDeleteTestWatcher(t)
+		if test.deleted {
+			if err := client.Resource(coreConfigMapGVR).Namespace("default").Delete("cm", nil); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if test.getError != nil {
+			getError := test.getError
+			client.PrependReactor("get", "configmaps", func(action ktesting.Action) (bool, runtime.Object, error) {
+				return true, nil, getError
+			})
+		}
+		if !test.watched {
+			delete(resController.resourceMap, coreConfigMapGVR)
+		}
+
+		deleted, err := resourceDeleted(resController, resInfo)
+		if deleted != test.expected {
+			t.Errorf("%s: expecting deleted %t, got %t", test.name, test.expected, deleted)
+		}
+		switch {
+		case test.expectedError != nil:
+			if err != test.expectedError {
+				t.Errorf("%s: expecting error %v, got %v", test.name, test.expectedError, err)
+			}
+		case test.expected && err != nil:
+			t.Errorf("%s: expecting no error, got %s", test.name, err)
+		case !test.expected && err == nil:
+			t.Errorf("%s: expecting an error", test.name)
+		}
+	}
+}
@@ -0,0 +1,111 @@
+/*
+Copyright 2019 IBM Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"sync"
+	"time"
+
+	"k8s.io/klog"
+)
+
+/*
+ Components deleted within the grace period are still counted with their
+ last known status when computing the status of their applications, so that
+ a normal scale-down does not momentarily degrade the applications while
+ replacements appear.
+*/
+
+// A component pending deletion
+type deletedComponent struct {
+	resInfo   *resourceInfo
+	deletedAt time.Time
+}
+
+// Components deleted within the grace period
+type deletedComponents struct {
+	gracePeriod time.Duration
+	pending     map[string]*deletedComponent // resource key to deleted component
+	keyer       resourceKeyer                // key of a resource. nil for the default
+	mutex       sync.Mutex
+}
+
+func newDeletedComponents(gracePeriod time.Duration) *deletedComponents {
+	return &deletedComponents{
+		gracePeriod: gracePeriod,
+		pending:     make(map[string]*deletedComponent),
+	}
+}
+
+// Record a deleted component. onExpire is called once the grace period
+// expires, to recompute the status of the applications of the component.
+// Nothing is recorded if there is no grace period.
+func (dc *deletedComponents) add(resInfo *resourceInfo, onExpire func()) {
+	if dc.gracePeriod <= 0 {
+		return
+	}
+	key := dc.keyer.keyOf(resInfo)
+	deleted := &deletedComponent{resInfo: resInfo, deletedAt: time.Now()}
+	dc.mutex.Lock()
+	dc.pending[key] = deleted
+	dc.mutex.Unlock()
+	if klog.V(3) {
+		klog.Infof("component %s deleted, counting status %s for %s", key, resInfo.kappnavStatVal, dc.gracePeriod)
+	}
+
+	time.AfterFunc(dc.gracePeriod, func() {
+		dc.mutex.Lock()
+		current, ok := dc.pending[key]
+		if ok && current == deleted {
+			delete(dc.pending, key)
+		}
+		dc.mutex.Unlock()
+		if ok && current == deleted {
+			if klog.V(3) {
+				klog.Infof("grace period of deleted component %s expired", key)
+			}
+			onExpire()
+		}
+	})
+}
+
+// Remove a component that has been added back, and any deleted component with the
+// same name that it replaces. Components are kept apart by uid, so that the
+// expiry of a deleted component is never attributed to its replacement
+func (dc *deletedComponents) remove(resInfo *resourceInfo) {
+	dc.mutex.Lock()
+	defer dc.mutex.Unlock()
+	delete(dc.pending, dc.keyer.keyOf(resInfo))
+	for key, deleted := range dc.pending {
+		if deleted.resInfo.gvr == resInfo.gvr && deleted.resInfo.namespace == resInfo.namespace && deleted.resInfo.name == resInfo.name {
+			delete(dc.pending, key)
+		}
+	}
+}
+
+// Return the components deleted within the grace period
+func (dc *deletedComponents) list() []*resourceInfo {
+	dc.mutex.Lock()
+	defer dc.mutex.Unlock()
+	ret := make([]*resourceInfo, 0, len(dc.pending))
+	for _, deleted := range dc.pending {
+		if time.Since(deleted.deletedAt) < dc.gracePeriod {
+			ret = append(ret, deleted.resInfo)
+		}
+	}
+	return ret
+}
@@ -0,0 +1,156 @@
+/*
+Copyright 2019 IBM Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDeletedComponentsExpire(t *testing.T) {
+	dc := newDeletedComponents(time.Millisecond * 200)
+	expired := make(chan bool, 1)
+	dc.add(newRetryTestResource("dep1"), func() { expired <- true })
+	if len(dc.list()) != 1 {
+		t.Fatalf("expecting 1 deleted component within grace period, got %d", len(dc.list()))
+	}
+
+	select {
+	case <-expired:
+	case <-time.After(time.Second * 5):
+		t.Fatal("timed out waiting for grace period to expire")
+	}
+	if len(dc.list()) != 0 {
+		t.Errorf("expecting no deleted component after grace period, got %d", len(dc.list()))
+	}
+}
+
+func TestDeletedComponentsNoGracePeriod(t *testing.T) {
+	dc := newDeletedComponents(0)
+	dc.add(newRetryTestResource("dep1"), func() { t.Error("not expecting expiry without grace period") })
+	if len(dc.list()) != 0 {
+		t.Errorf("expecting no deleted component without grace period, got %d", len(dc.list()))
+	}
+}
+
+func TestDeletedComponentsRemove(t *testing.T) {
+	dc := newDeletedComponents(time.Minute)
+	dep := newRetryTestResource("dep1")
+	dc.add(dep, func() {})
+	// replacement appears
+	dc.remove(dep)
+	if len(dc.list()) != 0 {
+		t.Errorf("expecting no deleted component after it is added back, got %d", len(dc.list()))
+	}
+}
+
+func TestDeletedComponentsNameReuse(t *testing.T) {
+	dc := newDeletedComponents(time.Millisecond * 200)
+	deleted := newRetryTestResource("dep1")
+	deleted.uid = "uid-1"
+	recreated := newRetryTestResource("dep1")
+	recreated.uid = "uid-2"
+	if deleted.key() == recreated.key() {
+		t.Fatalf("expecting different keys for resources with different uids, got %s", deleted.key())
+	}
+
+	expired := make(chan string, 2)
+	dc.add(deleted, func() { expired <- deleted.uid })
+	dc.add(recreated, func() { expired <- recreated.uid })
+	if len(dc.list()) != 2 {
+		t.Fatalf("expecting both incarnations deleted within grace period, got %d", len(dc.list()))
+	}
+
+	// a third incarnation replaces both
+	replacement := newRetryTestResource("dep1")
+	replacement.uid = "uid-3"
+	dc.remove(replacement)
+	if len(dc.list()) != 0 {
+		t.Errorf("expecting no deleted component after the name is reused, got %d", len(dc.list()))
+	}
+	select {
+	case uid := <-expired:
+		t.Errorf("expecting no expiry of replaced component %s", uid)
+	case <-time.After(time.Millisecond * 400):
+	}
+}
+
+// Test application status is unchanged within the grace period after its components are deleted
+func TestDeletionGracePeriod(t *testing.T) {
+	testName := "TestDeletionGracePeriod"
+	beforeTest()
+	deletionGracePeriod = time.Minute
+	defer func() {
+		deletionGracePeriod = 0
+	}()
+
+	// kinds to check for status
+	var kindsToCheckStatus = map[string]bool{
+		APPLICATION:  true,
+		"Service":    true,
+		"Deployment": true,
+	}
+
+	var files = []string{
+		/* 0 */ CrdApplication,
+		/* 1 */ appProductpage,
+		/* 2 */ deploymentProcuctpageV1,
+		/* 3 */ serviceProductpage,
+		/* 4 */ KappnavConfigFile,
+	}
+	iteration0IDs, err := readResourceIDs(files)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	/* Iteration 0: all normal */
+	testActions := newTestActions(testName, kindsToCheckStatus)
+	var emptyIDs = []resourceID{}
+	testActions.addIteration(iteration0IDs, emptyIDs)
+
+	/* Iteration 1: delete all components. Application still normal */
+	var iteration1IDs = []resourceID{
+		iteration0IDs[0],
+		iteration0IDs[1],
+		iteration0IDs[4],
+	}
+	testActions.addIteration(iteration1IDs, emptyIDs)
+
+	clusterWatcher, err := createClusterWatcher(iteration0IDs, testActions, StatusFailureRate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer clusterWatcher.shutDown()
+
+	err = testActions.transitionAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// give the deletions time to be processed
+	time.Sleep(BatchDuration * 3)
+	if pending := len(clusterWatcher.deletedComponents.list()); pending != 2 {
+		t.Errorf("expecting 2 deleted components within grace period, got %d", pending)
+	}
+	status, err := resourcekAppNavStatus(clusterWatcher, iteration0IDs[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status != Normal {
+		t.Errorf("expecting application status %s within grace period, got %s", Normal, status)
+	}
+}
@@ -0,0 +1,60 @@
+/*
+Copyright 2019 IBM Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"strings"
+)
+
+const (
+	// type of the condition recording whether all component kinds of an application are watched
+	componentKindsWatchedCondition = "ComponentKindsWatched"
+
+	deniedAPIGroupReason  = "DeniedAPIGroup"
+	allKindsWatchedReason = "AllComponentKindsWatched"
+)
+
+// Return true if resources in the API group must never be watched
+func isAPIGroupDenied(group string) bool {
+	if deniedAPIGroups == "" {
+		return false
+	}
+	for _, denied := range strings.Split(deniedAPIGroups, ",") {
+		denied = strings.TrimSpace(denied)
+		if denied != "" && denied == group {
+			return true
+		}
+	}
+	return false
+}
+
+// Return the condition recording which component kinds of the application are not watched because
+// their API group is denied.
+// deniedKinds: group/kind of the denied component kinds. Empty if all kinds are watched
+func newComponentKindsWatchedCondition(deniedKinds []string) applicationCondition {
+	cond := &statusCondition{conditionType: componentKindsWatchedCondition}
+	if len(deniedKinds) == 0 {
+		cond.status = conditionTrue
+		cond.reason = allKindsWatchedReason
+	} else {
+		cond.status = conditionFalse
+		cond.reason = deniedAPIGroupReason
+		cond.message = "component kinds in denied API groups are not watched: " + strings.Join(deniedKinds, ", ")
+	}
+	// nothing to clear if nothing was ever denied for this application
+	return applicationCondition{cond, len(deniedKinds) == 0}
+}
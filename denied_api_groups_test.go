@@ -0,0 +1,110 @@
+/*
+Copyright 2019 IBM Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestIsAPIGroupDenied(t *testing.T) {
+	savedDeniedAPIGroups := deniedAPIGroups
+	defer func() {
+		deniedAPIGroups = savedDeniedAPIGroups
+	}()
+
+	var tests = []struct {
+		denied   string
+		group    string
+		expected bool
+	}{
+		{"", "rbac.authorization.k8s.io", false},
+		{"", "", false},
+		{"rbac.authorization.k8s.io", "rbac.authorization.k8s.io", true},
+		{"apps, rbac.authorization.k8s.io", "rbac.authorization.k8s.io", true},
+		{"apps,,rbac.authorization.k8s.io", "", false},
+		{"rbac.authorization.k8s.io", "apps", false},
+	}
+	for _, test := range tests {
+		deniedAPIGroups = test.denied
+		if denied := isAPIGroupDenied(test.group); denied != test.expected {
+			t.Errorf("isAPIGroupDenied(%q) with denied groups %q: expecting %t, got %t", test.group, test.denied, test.expected, denied)
+		}
+	}
+}
+
+func TestDeniedAPIGroupNotWatched(t *testing.T) {
+	testName := "TestDeniedAPIGroupNotWatched"
+	beforeTest()
+	savedDeniedAPIGroups := deniedAPIGroups
+	deniedAPIGroups = "apps"
+	defer func() {
+		deniedAPIGroups = savedDeniedAPIGroups
+	}()
+
+	var files = []string{
+		/* 0 */ KappnavConfigFile,
+		/* 1 */ CrdApplication,
+		/* 2 */ appProductpage,
+		/* 3 */ deploymentProcuctpageV1,
+		/* 4 */ serviceProductpage,
+	}
+	iteration0IDs, err := readResourceIDs(files)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testActions := newTestActions(testName, map[string]bool{})
+	testActions.addIteration(iteration0IDs, []resourceID{})
+
+	clusterWatcher, err := createClusterWatcher(iteration0IDs, testActions, StatusFailureRate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer clusterWatcher.shutDown()
+
+	// wait for the application to be processed
+	intf := clusterWatcher.plugin.dynamicClient.Resource(coreApplicationGVR).Namespace("default")
+	var cond *statusCondition
+	for i := 0; i < 20 && cond == nil; i++ {
+		app, err := intf.Get("productpage-app", metav1.GetOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cond = getStatusCondition(app, componentKindsWatchedCondition); cond == nil {
+			time.Sleep(time.Millisecond * 500)
+		}
+	}
+	if cond == nil {
+		t.Fatalf("timed out waiting for condition %s on the application", componentKindsWatchedCondition)
+	}
+	if cond.status != conditionFalse || cond.reason != deniedAPIGroupReason {
+		t.Errorf("expecting condition %s %s, got %s %s", conditionFalse, deniedAPIGroupReason, cond.status, cond.reason)
+	}
+
+	if !clusterWatcher.isWatching(coreServiceGVR) {
+		t.Errorf("expecting %s to be watched", coreServiceGVR)
+	}
+	if err := clusterWatcher.AddToWatch(coreDeploymentGVR); err != nil {
+		t.Fatal(err)
+	}
+	if clusterWatcher.isWatching(coreDeploymentGVR) {
+		t.Errorf("expecting %s in denied API group not to be watched", coreDeploymentGVR)
+	}
+}
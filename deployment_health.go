@@ -0,0 +1,157 @@
+/*
+Copyright 2019 IBM Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+/*
+ The health of a Deployment is also read from the Deployment itself, by its
+ own health reader: from its replica counts, from its Available and
+ Progressing conditions, or from both, taking the worst.
+*/
+
+// Settings of the deploymentHealth flag: what the health of a Deployment is read from
+const (
+	deploymentHealthReplicas   = "replicas"   // available replicas against desired replicas
+	deploymentHealthConditions = "conditions" // Available and Progressing conditions
+	deploymentHealthBoth       = "both"       // worst of replicas and conditions
+
+	defaultDeploymentHealth = deploymentHealthReplicas
+
+	deploymentAvailable   = "Available"
+	deploymentProgressing = "Progressing"
+)
+
+// A condition of a Deployment
+type deploymentCondition struct {
+	conditionType string
+	status        string
+	reason        string
+}
+
+// Replica counts and conditions of a Deployment
+type deploymentStatus struct {
+	desiredReplicas   int64
+	availableReplicas int64
+	conditions        []deploymentCondition
+}
+
+// Return true if the value is a valid setting of the deploymentHealth flag
+func validDeploymentHealth(value string) bool {
+	switch strings.ToLower(value) {
+	case deploymentHealthReplicas, deploymentHealthConditions, deploymentHealthBoth:
+		return true
+	}
+	return false
+}
+
+// Return a number from an unstructured map, which may hold it as int64 or float64
+func int64Field(fields map[string]interface{}, name string) (int64, bool) {
+	switch value := fields[name].(type) {
+	case int64:
+		return value, true
+	case float64:
+		return int64(value), true
+	}
+	return 0, false
+}
+
+// Parse spec.replicas, status.availableReplicas, and status.conditions of a Deployment
+func parseDeploymentStatus(objMap map[string]interface{}) *deploymentStatus {
+	ret := &deploymentStatus{desiredReplicas: 1}
+	if spec, ok := objMap[SPEC].(map[string]interface{}); ok {
+		if replicas, ok := int64Field(spec, "replicas"); ok {
+			ret.desiredReplicas = replicas
+		}
+	}
+	status, ok := objMap[STATUS].(map[string]interface{})
+	if !ok {
+		return ret
+	}
+	ret.availableReplicas, _ = int64Field(status, "availableReplicas")
+	conditions, _ := status["conditions"].([]interface{})
+	for _, tmp := range conditions {
+		condition, ok := tmp.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		var dc deploymentCondition
+		dc.conditionType, _ = condition["type"].(string)
+		dc.status, _ = condition["status"].(string)
+		dc.reason, _ = condition["reason"].(string)
+		ret.conditions = append(ret.conditions, dc)
+	}
+	return ret
+}
+
+// Return the condition of the given type, or nil if not reported
+func (deployment *deploymentStatus) condition(conditionType string) *deploymentCondition {
+	for i := range deployment.conditions {
+		if deployment.conditions[i].conditionType == conditionType {
+			return &deployment.conditions[i]
+		}
+	}
+	return nil
+}
+
+// Return the status and flyover text of a Deployment from its replica counts
+func deploymentReplicaHealth(deployment *deploymentStatus) (string, string) {
+	flyover := fmt.Sprintf("%d/%d replicas available", deployment.availableReplicas, deployment.desiredReplicas)
+	if deployment.availableReplicas < deployment.desiredReplicas {
+		return statusProblem, flyover
+	}
+	return statusNormal, flyover
+}
+
+// Return the status and flyover text of a Deployment from its Available and Progressing conditions
+func deploymentConditionHealth(deployment *deploymentStatus) (string, string) {
+	available := deployment.condition(deploymentAvailable)
+	if available == nil {
+		return statusProblem, "Deployment availability not reported"
+	}
+	if available.status != conditionTrue {
+		return statusProblem, fmt.Sprintf("Deployment not available: %s", available.reason)
+	}
+	if progressing := deployment.condition(deploymentProgressing); progressing != nil && progressing.status == conditionFalse {
+		// e.g. ProgressDeadlineExceeded
+		return statusProblem, fmt.Sprintf("Deployment not progressing: %s", progressing.reason)
+	}
+	return statusNormal, "Deployment available"
+}
+
+// Return the status and flyover text of a Deployment read from the given source
+func deploymentHealth(deployment *deploymentStatus, source string) (string, string) {
+	switch strings.ToLower(source) {
+	case deploymentHealthConditions:
+		return deploymentConditionHealth(deployment)
+	case deploymentHealthBoth:
+		replicaStatus, replicaFlyover := deploymentReplicaHealth(deployment)
+		conditionStatus, conditionFlyover := deploymentConditionHealth(deployment)
+		if conditionStatus == statusProblem && replicaStatus != statusProblem {
+			return conditionStatus, conditionFlyover
+		}
+		if replicaStatus == statusProblem && conditionStatus != statusProblem {
+			return replicaStatus, replicaFlyover
+		}
+		return replicaStatus, replicaFlyover + ", " + conditionFlyover
+	default:
+		return deploymentReplicaHealth(deployment)
+	}
+}
@@ -0,0 +1,122 @@
+/*
+Copyright 2019 IBM Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"testing"
+)
+
+const (
+	deploymentAvailableFile = "test_data/deployment-available.json"
+	deploymentScalingFile   = "test_data/deployment-scaling.json" // available condition, but missing replicas
+	deploymentStalledFile   = "test_data/deployment-stalled.json" // all replicas, but not progressing
+)
+
+type deploymentHealthTestData struct {
+	fileName        string
+	source          string
+	expectedStatus  string
+	expectedFlyover string
+}
+
+var deploymentHealthTestDataArray = []deploymentHealthTestData{
+	{deploymentAvailableFile, deploymentHealthReplicas, statusNormal, "3/3 replicas available"},
+	{deploymentAvailableFile, deploymentHealthConditions, statusNormal, "Deployment available"},
+	{deploymentAvailableFile, deploymentHealthBoth, statusNormal, "3/3 replicas available, Deployment available"},
+
+	{deploymentScalingFile, deploymentHealthReplicas, statusProblem, "1/3 replicas available"},
+	{deploymentScalingFile, deploymentHealthConditions, statusNormal, "Deployment available"},
+	{deploymentScalingFile, deploymentHealthBoth, statusProblem, "1/3 replicas available"},
+
+	{deploymentStalledFile, deploymentHealthReplicas, statusNormal, "3/3 replicas available"},
+	{deploymentStalledFile, deploymentHealthConditions, statusProblem, "Deployment not progressing: ProgressDeadlineExceeded"},
+	{deploymentStalledFile, deploymentHealthBoth, statusProblem, "Deployment not progressing: ProgressDeadlineExceeded"},
+}
+
+func TestDeploymentHealth(t *testing.T) {
+	for _, data := range deploymentHealthTestDataArray {
+		unstructuredObj, err := readJSON(data.fileName)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var resInfo = &resourceInfo{}
+		parseResourceBasic(unstructuredObj, resInfo)
+		if resInfo.deployment == nil {
+			t.Errorf("expecting deployment status to be parsed for %s", data.fileName)
+			continue
+		}
+		if !validDeploymentHealth(data.source) {
+			t.Errorf("expecting %s to be a valid setting", data.source)
+		}
+		status, flyover := deploymentHealth(resInfo.deployment, data.source)
+		if status != data.expectedStatus || flyover != data.expectedFlyover {
+			t.Errorf("%s %s: expecting status %s flyover %q, got %s %q", data.fileName, data.source, data.expectedStatus, data.expectedFlyover, status, flyover)
+		}
+	}
+
+	if validDeploymentHealth("pods") {
+		t.Error("expecting pods to be an invalid setting")
+	}
+}
+
+func TestDeploymentHealthWithoutAPIStatus(t *testing.T) {
+	savedDeploymentHealth := deploymentHealthSource
+	defer func() {
+		deploymentHealthSource = savedDeploymentHealth
+		healthReaderMode = defaultHealthReaderMode
+	}()
+
+	unstructuredObj, err := readJSON(deploymentScalingFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var resInfo = &resourceInfo{}
+	parseResourceBasic(unstructuredObj, resInfo)
+
+	// read from the Deployment when the API server has no status for it
+	var resController = &ClusterWatcher{
+		plugin:        &ControllerPlugin{statusFunc: noReaderStatusFunc},
+		unknownStatus: "Unknown",
+	}
+	for _, test := range []struct {
+		source   string
+		expected string
+	}{
+		{deploymentHealthReplicas, statusProblem},
+		{deploymentHealthConditions, statusNormal},
+		{deploymentHealthBoth, statusProblem},
+	} {
+		deploymentHealthSource = test.source
+		status, _, _, err := resController.readComponentStatus(resInfo)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if status != test.expected {
+			t.Errorf("deploymentHealth %s: expecting status %s, got %s", test.source, test.expected, status)
+		}
+	}
+
+	// status from the API server, of higher priority, is taken first
+	healthReaderMode = healthReaderFirstDefinitive
+	resController.plugin.statusFunc = func(destURL string, resInfo *resourceInfo) (string, string, string, error) {
+		return Normal, "", "", nil
+	}
+	deploymentHealthSource = deploymentHealthReplicas
+	if status, _, _, _ := resController.readComponentStatus(resInfo); status != Normal {
+		t.Errorf("expecting status %s from the API server, got %s", Normal, status)
+	}
+}
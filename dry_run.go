@@ -0,0 +1,38 @@
+/*
+Copyright 2019 IBM Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"k8s.io/klog"
+)
+
+/*
+ In dry-run mode the controller watches, batches and computes status as
+ usual, but logs the writes it would make through the dynamic client instead
+ of making them, to see what it would do on a new cluster before letting it
+ change anything.
+*/
+
+// Return true in dry-run mode, after logging the write that is skipped
+func (resController *ClusterWatcher) skipWrite(format string, args ...interface{}) bool {
+	if !resController.plugin.DryRun {
+		return false
+	}
+	klog.Infof("dry run, not writing: "+format, args...)
+	dryRunWritesSkipped.Inc()
+	return true
+}
@@ -0,0 +1,143 @@
+/*
+Copyright 2019 IBM Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/dynamic/fake"
+)
+
+// Return the verbs of the writes made through the fake dynamic client
+func clientWrites(client *fake.FakeDynamicClient) []string {
+	var writes []string
+	for _, action := range client.Actions() {
+		switch verb := action.GetVerb(); verb {
+		case "create", "update", "patch", "delete":
+			writes = append(writes, verb+" "+action.GetResource().Resource)
+		}
+	}
+	return writes
+}
+
+// Test no status is written in dry-run mode
+func TestDryRunStatus(t *testing.T) {
+	testName := "TestDryRunStatus"
+	beforeTest()
+	// kinds to check for status
+	var kindsToCheckStatus = map[string]bool{
+		APPLICATION:  true,
+		"Deployment": true,
+	}
+
+	var files = []string{
+		/* 0 */ CrdApplication,
+		/* 1 */ appProductpage,
+		/* 2 */ deploymentProcuctpageV1,
+		/* 3 */ KappnavConfigFile,
+	}
+	iteration0IDs, err := readResourceIDs(files)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	/* Iteration 0: all normal */
+	testActions := newTestActions(testName, kindsToCheckStatus)
+	var emptyIDs = []resourceID{}
+	testActions.addIteration(iteration0IDs, emptyIDs)
+
+	clusterWatcher, err := createClusterWatcher(iteration0IDs, testActions, StatusFailureRate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer clusterWatcher.shutDown()
+
+	err = testActions.transitionAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	unstructuredObj, err := readJSON(deploymentProcuctpageV1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var resInfo = &resourceInfo{}
+	clusterWatcher.parseResource(unstructuredObj, resInfo)
+
+	client := clusterWatcher.plugin.dynamicClient.(*fake.FakeDynamicClient)
+	client.ClearActions()
+	clusterWatcher.plugin.DryRun = true
+	defer func() {
+		clusterWatcher.plugin.DryRun = false
+	}()
+	if err = sendResourceStatus(clusterWatcher, resInfo, warning, "", ""); err != nil {
+		t.Fatal(err)
+	}
+	if writes := clientWrites(client); len(writes) != 0 {
+		t.Errorf("expecting no writes in dry-run mode, got %v", writes)
+	}
+	current, err := getResource(clusterWatcher, iteration0IDs[2])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if current.GetAnnotations()[kappnavStatusValue] == warning {
+		t.Errorf("expecting status %s not written in dry-run mode", warning)
+	}
+}
+
+// Test no action configmap is created or deleted in dry-run mode
+func TestDryRunActionConfigMap(t *testing.T) {
+	deployment, err := readJSON(deploymentLiberty)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := fake.NewSimpleDynamicClient(runtime.NewScheme())
+	resController := &ClusterWatcher{
+		plugin: &ControllerPlugin{dynamicClient: client, DryRun: true},
+	}
+
+	if err = createActionConfigMap(resController, deployment); err != nil {
+		t.Fatal(err)
+	}
+	if writes := clientWrites(client); len(writes) != 0 {
+		t.Errorf("expecting no writes in dry-run mode, got %v", writes)
+	}
+	namespace, _ := actionConfigMapLocation(deployment.GetNamespace(), deployment.GetName())
+	configMaps, err := client.Resource(coreConfigMapGVR).Namespace(namespace).List(metav1.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(configMaps.Items) != 0 {
+		t.Errorf("expecting no action configmap created in dry-run mode, got %d", len(configMaps.Items))
+	}
+
+	// created for real, but not deleted in dry-run mode
+	resController.plugin.DryRun = false
+	if err = createActionConfigMap(resController, deployment); err != nil {
+		t.Fatal(err)
+	}
+	client.ClearActions()
+	resController.plugin.DryRun = true
+	if err = deleteActionConfigMap(resController, actionConfigMapKindOf(deployment), deployment); err != nil {
+		t.Fatal(err)
+	}
+	if writes := clientWrites(client); len(writes) != 0 {
+		t.Errorf("expecting no writes in dry-run mode, got %v", writes)
+	}
+}
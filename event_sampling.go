@@ -0,0 +1,70 @@
+/*
+Copyright 2019 IBM Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"math"
+
+	"k8s.io/klog"
+)
+
+/*
+ For load testing, or to reduce the cost of non-critical clusters, only a
+ fraction of the events of non-application resources may be processed.
+ Whether an event is processed depends only on the GVR and key of its
+ resource, so the same resources are always processed and the others never
+ are. The status of applications is then computed from all their components,
+ but is only recomputed when a sampled component changes: changes of the other
+ components are not reflected until an application or a sampled component
+ changes. The lower the rate, the more stale the status of applications.
+*/
+
+// DefaultEventSampleRate - process all events
+const DefaultEventSampleRate = 1.0
+
+// Return true if the value is a valid setting of the eventSampleRate flag
+func validEventSampleRate(rate float64) bool {
+	return !math.IsNaN(rate) && rate >= 0 && rate <= 1
+}
+
+// Return true if events of the resource are to be processed at the given rate.
+// The same resource is always either sampled or not
+func eventSampled(eventData *eventHandlerData, rate float64) bool {
+	if rate >= 1 {
+		return true
+	}
+	if rate <= 0 {
+		return false
+	}
+	// the high bits of shorter hashes are poorly spread across similar keys
+	sum := sha256.Sum256([]byte(eventData.gvr.String() + "/" + eventData.key))
+	return float64(binary.BigEndian.Uint32(sum[:4])) < rate*float64(math.MaxUint32+1)
+}
+
+// Return true if the event is sampled at the configured eventSampleRate
+func (resController *ClusterWatcher) isEventSampled(eventData *eventHandlerData) bool {
+	if eventSampled(eventData, eventSampleRate) {
+		return true
+	}
+	eventsSampledOut.Inc()
+	if klog.V(4) {
+		klog.Infof("dropping event for %s %s not sampled at rate %v", eventData.gvr, eventData.key, eventSampleRate)
+	}
+	return false
+}
@@ -0,0 +1,66 @@
+/*
+Copyright 2019 IBM Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestEventSampledDeterministic(t *testing.T) {
+	const rate = 0.3
+	const keys = 1000
+	sampled := 0
+	for i := 0; i < keys; i++ {
+		eventData := &eventHandlerData{funcType: AddFunc, gvr: coreDeploymentGVR, key: fmt.Sprintf("default/deployment-%d", i)}
+		first := eventSampled(eventData, rate)
+		for _, funcType := range []eventHandlerFuncType{UpdateFunc, DeleteFunc} {
+			other := &eventHandlerData{funcType: funcType, gvr: eventData.gvr, key: eventData.key}
+			if eventSampled(other, rate) != first {
+				t.Fatalf("expecting every event of %s to be sampled the same", eventData.key)
+			}
+		}
+		if first {
+			sampled++
+		}
+		// a higher rate keeps all keys sampled at a lower rate
+		if first && !eventSampled(eventData, rate+0.1) {
+			t.Errorf("expecting %s sampled at rate %v to be sampled at a higher rate", eventData.key, rate)
+		}
+	}
+	if sampled < keys*rate*0.8 || sampled > keys*rate*1.2 {
+		t.Errorf("expecting about %v of %d keys to be sampled, got %d", rate*keys, keys, sampled)
+	}
+}
+
+func TestEventSampledBounds(t *testing.T) {
+	eventData := &eventHandlerData{funcType: AddFunc, gvr: coreDeploymentGVR, key: "default/productpage-v1"}
+	if !eventSampled(eventData, 1) {
+		t.Error("expecting all events to be sampled at rate 1")
+	}
+	if eventSampled(eventData, 0) {
+		t.Error("expecting no event to be sampled at rate 0")
+	}
+	for _, data := range []struct {
+		rate  float64
+		valid bool
+	}{{0, true}, {0.5, true}, {1, true}, {-0.1, false}, {1.5, false}} {
+		if validEventSampleRate(data.rate) != data.valid {
+			t.Errorf("rate %v: expecting valid %t", data.rate, data.valid)
+		}
+	}
+}
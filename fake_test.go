@@ -297,6 +297,22 @@ func (fd *fakeDiscovery) addAPIGroup(group string, version string) (*fakeAPIGrou
 	return existing, nil
 }
 
+// Serve an existing group in another version, listed before the preferred version.
+// The kinds of the group are served in all its versions
+func (fd *fakeDiscovery) addVersion(group string, version string) error {
+	existing, ok := fd.apiGroups[group]
+	if !ok {
+		return fmt.Errorf("Fake discovery client has no group %s", group)
+	}
+	var groupVersion = version
+	if group != "" {
+		groupVersion = group + "/" + version
+	}
+	versions := []metav1.GroupVersionForDiscovery{{GroupVersion: groupVersion, Version: version}}
+	existing.apiGroup.Versions = append(versions, existing.apiGroup.Versions...)
+	return nil
+}
+
 var noNamespace = map[string]bool{
 	"ComponentStatus":                true,
 	"Namespace":                      true,
@@ -392,6 +408,7 @@ func (fd *fakeDiscovery) ServerResourcesForGroupVersion(groupVersion string) (*m
 	var ret = &metav1.APIResourceList{}
 	ret.Kind = "APIResourceList"
 	ret.APIVersion = "v1"
+	ret.GroupVersion = groupVersion
 	ret.APIResources = make([]metav1.APIResource, 0)
 	fakeAPIGroup := fd.apiGroups[group]
 	for _, apiResource := range fakeAPIGroup.apiResources {
@@ -417,7 +434,7 @@ func (fd *fakeDiscovery) ServerPreferredNamespacedResources() ([]*metav1.APIReso
 }
 
 func (fd *fakeDiscovery) ServerVersion() (*version.Info, error) {
-	return nil, fmt.Errorf("Unsupported ServerVersion")
+	return &version.Info{Major: "1", Minor: "15"}, nil
 }
 
 func (fd *fakeDiscovery) OpenAPISchema() (*openapi_v2.Document, error) {
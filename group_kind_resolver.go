@@ -0,0 +1,103 @@
+/*
+Copyright 2019 IBM Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"sync"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/discovery/cached/memory"
+	"k8s.io/client-go/restmapper"
+	"k8s.io/klog"
+)
+
+/*
+ The componentKinds of an application are group and kind pairs, but resources
+ are watched by group, version, and resource. The resolver maps one to the
+ other with a RESTMapper built from discovery, preferring the preferred version
+ of the group when the kind is served in several versions. Resolved mappings,
+ with whether the kind is namespaced, are cached until discovery changes, e.g.
+ when a CustomResourceDefinition is added or deleted.
+*/
+
+// Resolve group/kind to GVR
+type groupKindResolver struct {
+	mapper   *restmapper.DeferredDiscoveryRESTMapper
+	resolved map[schema.GroupKind]groupKindMapping // cache of resolved mappings
+	mutex    sync.Mutex
+}
+
+// Resource a group and kind resolved to
+type groupKindMapping struct {
+	gvr        schema.GroupVersionResource
+	namespaced bool // false for cluster scoped kinds
+}
+
+func newGroupKindResolver(discClient discovery.DiscoveryInterface) *groupKindResolver {
+	return &groupKindResolver{
+		mapper:   restmapper.NewDeferredDiscoveryRESTMapper(memory.NewMemCacheClient(discClient)),
+		resolved: make(map[schema.GroupKind]groupKindMapping),
+	}
+}
+
+// Return the GVR of the preferred version of a group and kind.
+// Group "core" is the same as the empty group
+func (resolver *groupKindResolver) resolve(group string, kind string) (schema.GroupVersionResource, error) {
+	mapping, err := resolver.resolveMapping(group, kind)
+	return mapping.gvr, err
+}
+
+// Return the GVR of the preferred version of a group and kind, and whether the kind is namespaced.
+// Group "core" is the same as the empty group
+func (resolver *groupKindResolver) resolveMapping(group string, kind string) (groupKindMapping, error) {
+	if group == "core" {
+		group = ""
+	}
+	gk := schema.GroupKind{Group: group, Kind: kind}
+
+	resolver.mutex.Lock()
+	defer resolver.mutex.Unlock()
+	if mapping, ok := resolver.resolved[gk]; ok {
+		return mapping, nil
+	}
+	restMapping, err := resolver.mapper.RESTMapping(gk)
+	if err != nil {
+		return groupKindMapping{}, err
+	}
+	mapping := groupKindMapping{
+		gvr:        restMapping.Resource,
+		namespaced: restMapping.Scope.Name() == meta.RESTScopeNameNamespace,
+	}
+	if klog.V(3) {
+		klog.Infof("groupKindResolver resolved group/Kind: %s to GVR: %s namespaced: %t", gk, mapping.gvr, mapping.namespaced)
+	}
+	resolver.resolved[gk] = mapping
+	return mapping, nil
+}
+
+// Discard what was resolved, so that it is resolved again from discovery
+func (resolver *groupKindResolver) reset() {
+	if resolver == nil {
+		return
+	}
+	resolver.mutex.Lock()
+	defer resolver.mutex.Unlock()
+	resolver.mapper.Reset()
+	resolver.resolved = make(map[schema.GroupKind]groupKindMapping)
+}
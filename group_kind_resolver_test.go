@@ -0,0 +1,154 @@
+/*
+Copyright 2019 IBM Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"testing"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestGroupKindResolver(t *testing.T) {
+	fakeDisc := newFakeDiscovery()
+	for _, data := range []testDiscoveryData{
+		{kind: "Service", group: "", version: "v1", name: "service", plural: "services"},
+		{kind: "ConfigMap", group: "", version: "v1", name: "configmap", plural: "configmaps"},
+		{kind: "Deployment", group: "apps", version: "v1", name: "deployment", plural: "deployments"},
+		{kind: "StatefulSet", group: "apps", version: "v1", name: "statefulset", plural: "statefulsets"},
+		{kind: "Ingress", group: "extensions", version: "v1beta1", name: "ingress", plural: "ingresses"},
+		{kind: "Application", group: "app.k8s.io", version: "v1beta1", name: "application", plural: "applications"},
+	} {
+		if err := fakeDisc.addKind(data.kind, data.group, data.version, data.name, data.plural); err != nil {
+			t.Fatal(err)
+		}
+	}
+	resolver := newGroupKindResolver(fakeDisc)
+
+	tests := []struct {
+		group    string
+		kind     string
+		expected schema.GroupVersionResource
+	}{
+		{"", "Service", schema.GroupVersionResource{Group: "", Version: "v1", Resource: "services"}},
+		{"core", "Service", schema.GroupVersionResource{Group: "", Version: "v1", Resource: "services"}},
+		{"core", "ConfigMap", schema.GroupVersionResource{Group: "", Version: "v1", Resource: "configmaps"}},
+		{"apps", "Deployment", schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "deployments"}},
+		{"apps", "StatefulSet", schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "statefulsets"}},
+		{"extensions", "Ingress", schema.GroupVersionResource{Group: "extensions", Version: "v1beta1", Resource: "ingresses"}},
+		{"app.k8s.io", "Application", schema.GroupVersionResource{Group: "app.k8s.io", Version: "v1beta1", Resource: "applications"}},
+	}
+	for _, test := range tests {
+		// resolve twice, the second time from the cache
+		for i := 0; i < 2; i++ {
+			gvr, err := resolver.resolve(test.group, test.kind)
+			if err != nil {
+				t.Errorf("unable to resolve group: %s kind: %s: %s", test.group, test.kind, err)
+				continue
+			}
+			if gvr != test.expected {
+				t.Errorf("expecting group: %s kind: %s to resolve to %s, got %s", test.group, test.kind, test.expected, gvr)
+			}
+		}
+	}
+
+	// kind in another group, and unknown kind
+	for _, gk := range []schema.GroupKind{{Group: "", Kind: "Deployment"}, {Group: "apps", Kind: "Service"}, {Group: "samplecontroller.k8s.io", Kind: "Foo"}} {
+		if gvr, err := resolver.resolve(gk.Group, gk.Kind); err == nil {
+			t.Errorf("expecting group: %s kind: %s not to resolve, got %s", gk.Group, gk.Kind, gvr)
+		}
+	}
+
+	// resolved once discovery changes
+	if err := fakeDisc.addKind("Foo", "samplecontroller.k8s.io", "v1alpha1", "foo", "foos"); err != nil {
+		t.Fatal(err)
+	}
+	resolver.reset()
+	gvr, err := resolver.resolve("samplecontroller.k8s.io", "Foo")
+	if err != nil {
+		t.Fatalf("unable to resolve Foo after discovery changed: %s", err)
+	}
+	if expected := (schema.GroupVersionResource{Group: "samplecontroller.k8s.io", Version: "v1alpha1", Resource: "foos"}); gvr != expected {
+		t.Errorf("expecting Foo to resolve to %s, got %s", expected, gvr)
+	}
+}
+
+// Test kinds served in several versions resolve to the preferred version, with their scope
+func TestGroupKindResolverMapping(t *testing.T) {
+	fakeDisc := newFakeDiscovery()
+	for _, data := range []testDiscoveryData{
+		{kind: "Widget", group: "example.com", version: "v1", name: "widget", plural: "widgets"},
+		{kind: "StorageClass", group: "storage.k8s.io", version: "v1", name: "storageclass", plural: "storageclasses"},
+	} {
+		if err := fakeDisc.addKind(data.kind, data.group, data.version, data.name, data.plural); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// older versions listed first
+	for _, group := range []string{"example.com", "storage.k8s.io"} {
+		if err := fakeDisc.addVersion(group, "v1beta1"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	resolver := newGroupKindResolver(fakeDisc)
+
+	tests := []struct {
+		group    string
+		kind     string
+		expected groupKindMapping
+	}{
+		{"example.com", "Widget", groupKindMapping{gvr: schema.GroupVersionResource{Group: "example.com", Version: "v1", Resource: "widgets"}, namespaced: true}},
+		{"storage.k8s.io", "StorageClass", groupKindMapping{gvr: schema.GroupVersionResource{Group: "storage.k8s.io", Version: "v1", Resource: "storageclasses"}, namespaced: false}},
+	}
+	for _, test := range tests {
+		mapping, err := resolver.resolveMapping(test.group, test.kind)
+		if err != nil {
+			t.Errorf("unable to resolve group: %s kind: %s: %s", test.group, test.kind, err)
+			continue
+		}
+		if mapping != test.expected {
+			t.Errorf("expecting group: %s kind: %s to resolve to %+v, got %+v", test.group, test.kind, test.expected, mapping)
+		}
+	}
+}
+
+// Test a kind resolved from discovery is added to the resource map, to be watched
+func TestGetGVRForGroupKindFromDiscovery(t *testing.T) {
+	fakeDisc := newFakeDiscovery()
+	if err := fakeDisc.addKind("Widget", "example.com", "v1", "widget", "widgets"); err != nil {
+		t.Fatal(err)
+	}
+	var resController = &ClusterWatcher{
+		resourceMap:       make(map[schema.GroupVersionResource]*ResourceWatcher),
+		gvrsToWatch:       make(map[schema.GroupVersionResource]bool),
+		groupKindResolver: newGroupKindResolver(fakeDisc),
+	}
+	expected := schema.GroupVersionResource{Group: "example.com", Version: "v1", Resource: "widgets"}
+	gvr, ok := resController.getGVRForGroupKind("example.com", "Widget")
+	if !ok || gvr != expected {
+		t.Fatalf("expecting Widget to resolve to %s, got %s %t", expected, gvr, ok)
+	}
+	rw, ok := resController.resourceMap[expected]
+	if !ok {
+		t.Fatalf("expecting %s in the resource map", expected)
+	}
+	if rw.kind != "Widget" || !rw.namespaced {
+		t.Errorf("expecting namespaced kind Widget for %s, got kind %s namespaced %t", expected, rw.kind, rw.namespaced)
+	}
+	if watchGVR, ok := resController.getGVRForGroupKind("example.com", "Widget"); !ok || watchGVR != expected {
+		t.Errorf("expecting Widget to resolve again to %s, got %s %t", expected, watchGVR, ok)
+	}
+}
@@ -41,7 +41,9 @@ func newHandlerManager() *HandlerManager {
 		defaultPrimaryHandler: &namespaceFilterHandler,
 		handlers:              make(map[schema.GroupVersionResource]*HandlersForOneGVR)}
 
-	ret.setPrimaryHandler(coreApplicationGVR, &batchApplicationHandler)
+	for _, gvr := range applicationGVRs() {
+		ret.setPrimaryHandler(gvr, &batchApplicationHandler)
+	}
 	ret.setPrimaryHandler(coreCustomResourceDefinitionGVR, &CRDNewHandler)
 	ret.addOtherHandler(coreDeploymentGVR, &autoCreateAppHandler)
 	ret.addOtherHandler(coreStatefulSetGVR, &autoCreateAppHandler)
@@ -75,6 +77,12 @@ func (mgr *HandlerManager) addOtherHandler(gvr schema.GroupVersionResource, hand
 	handlersForGVR.otherHandlers = append(handlersForGVR.otherHandlers, handler)
 }
 
+// Return true if the events of a GVR are only processed by the default primary handler,
+// which filters out the namespaces not permitted
+func (mgr *HandlerManager) onlyNamespaceFiltered(gvr schema.GroupVersionResource) bool {
+	return mgr.handlers[gvr] == nil && mgr.defaultPrimaryHandler == &namespaceFilterHandler
+}
+
 /* Call all the handlers for a GVR
  */
 func (mgr *HandlerManager) callHandlers(gvr schema.GroupVersionResource, resController *ClusterWatcher, rw *ResourceWatcher, eventData *eventHandlerData) error {
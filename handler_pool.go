@@ -0,0 +1,103 @@
+/*
+Copyright 2019 IBM Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"sync"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/klog"
+)
+
+// Bounded pool of workers calling the event handlers. The worker of each GVR
+// only takes events off its queue and hands them to the pool, so the number of
+// handlers running at once does not grow with the number of GVRs watched.
+//
+// The queue of a GVR hands out every event, as each is its own eventHandlerData,
+// so the pool keeps at most one handler running per resource. Jobs of a resource
+// whose handler is running wait in order, and the worker running it picks them
+// up when done.
+type handlerPool struct {
+	jobs    chan func()
+	mutex   sync.Mutex
+	waiting map[handlerKey][]func() // jobs waiting for the running job of a resource. Present while one runs
+}
+
+// Resource whose event handlers must not overlap
+type handlerKey struct {
+	gvr schema.GroupVersionResource
+	key string // namespace/name
+}
+
+// Create a pool of the given number of workers. Return nil for no pool,
+// in which case handlers are called by the worker of each GVR
+func newHandlerPool(size int) *handlerPool {
+	if size <= 0 {
+		return nil
+	}
+	pool := &handlerPool{jobs: make(chan func()), waiting: make(map[handlerKey][]func())}
+	for i := 0; i < size; i++ {
+		go func() {
+			for job := range pool.jobs {
+				job()
+			}
+		}()
+	}
+	if klog.V(2) {
+		klog.Infof("started %d event handler workers", size)
+	}
+	return pool
+}
+
+// Run the job of a resource on the pool, blocking until a worker takes it,
+// or until it waits for the running job of the same resource.
+// Without a pool, run it on the calling goroutine
+func (pool *handlerPool) run(key handlerKey, job func()) {
+	if pool == nil {
+		job()
+		return
+	}
+	pool.mutex.Lock()
+	if jobs, running := pool.waiting[key]; running {
+		pool.waiting[key] = append(jobs, job)
+		pool.mutex.Unlock()
+		return
+	}
+	pool.waiting[key] = nil
+	pool.mutex.Unlock()
+
+	pool.jobs <- func() {
+		for job != nil {
+			job()
+			job = pool.next(key)
+		}
+	}
+}
+
+// Return the next job waiting for the resource, or nil after marking it as no
+// longer running
+func (pool *handlerPool) next(key handlerKey) func() {
+	pool.mutex.Lock()
+	defer pool.mutex.Unlock()
+	jobs := pool.waiting[key]
+	if len(jobs) == 0 {
+		delete(pool.waiting, key)
+		return nil
+	}
+	pool.waiting[key] = jobs[1:]
+	return jobs[0]
+}
@@ -0,0 +1,182 @@
+/*
+Copyright 2019 IBM Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/util/workqueue"
+)
+
+func TestHandlerPoolBounded(t *testing.T) {
+	const size = 2
+	const jobs = 10
+	pool := newHandlerPool(size)
+
+	var running, maxRunning int32
+	var wg sync.WaitGroup
+	release := make(chan struct{})
+	wg.Add(jobs)
+	go func() {
+		for i := 0; i < jobs; i++ {
+			key := handlerKey{key: "default/job" + strconv.Itoa(i)}
+			pool.run(key, func() {
+				defer wg.Done()
+				current := atomic.AddInt32(&running, 1)
+				for {
+					max := atomic.LoadInt32(&maxRunning)
+					if current <= max || atomic.CompareAndSwapInt32(&maxRunning, max, current) {
+						break
+					}
+				}
+				<-release
+				atomic.AddInt32(&running, -1)
+			})
+		}
+	}()
+
+	time.Sleep(time.Millisecond * 100)
+	if current := atomic.LoadInt32(&running); current != size {
+		t.Errorf("expecting %d jobs running while blocked, got %d", size, current)
+	}
+	close(release)
+	wg.Wait()
+	if max := atomic.LoadInt32(&maxRunning); max > size {
+		t.Errorf("expecting at most %d jobs running at once, got %d", size, max)
+	}
+}
+
+func TestProcessNextItemHandlerPool(t *testing.T) {
+	gvr := schema.GroupVersionResource{Group: "samplecontroller.k8s.io", Version: "v1alpha1", Resource: "slowresources"}
+	release := make(chan struct{})
+	done := make(chan struct{})
+	var slowHandler resourceActionFunc = func(resController *ClusterWatcher, rw *ResourceWatcher, eventData *eventHandlerData) error {
+		<-release
+		close(done)
+		return nil
+	}
+
+	var resController = &ClusterWatcher{
+		handlerMgr: newHandlerManager(),
+		handlers:   newHandlerPool(1),
+	}
+	resController.handlerMgr.setPrimaryHandler(gvr, &slowHandler)
+	rw := &ResourceWatcher{
+		GroupVersionResource: gvr,
+		queue:                workqueue.NewRateLimitingQueue(newControllerRateLimiter(resController, requeueBaseDelay, requeueMaxDelay)),
+	}
+	defer rw.queue.ShutDown()
+
+	obj := &unstructured.Unstructured{}
+	obj.SetNamespace("default")
+	obj.SetName("slow1")
+	rw.queue.Add(&eventHandlerData{funcType: AddFunc, gvr: gvr, key: "default/slow1", obj: obj})
+
+	// the worker of the GVR only hands the event to the pool
+	returned := make(chan bool)
+	go func() {
+		returned <- processNextItem(resController, rw)
+	}()
+	select {
+	case ok := <-returned:
+		if !ok {
+			t.Fatal("expecting processNextItem to continue")
+		}
+	case <-time.After(time.Second * 5):
+		t.Fatal("timed out waiting for processNextItem to return while the handler is running")
+	}
+	select {
+	case <-done:
+		t.Fatal("expecting handler still running on the pool")
+	default:
+	}
+
+	close(release)
+	select {
+	case <-done:
+	case <-time.After(time.Second * 5):
+		t.Fatal("timed out waiting for the handler to run on the pool")
+	}
+}
+
+// Test the handlers of two events of one resource never overlap, and run in order,
+// while the pool has a worker to spare
+func TestProcessNextItemSameKey(t *testing.T) {
+	gvr := schema.GroupVersionResource{Group: "samplecontroller.k8s.io", Version: "v1alpha1", Resource: "slowresources"}
+	var running, overlaps int32
+	var mutex sync.Mutex
+	var order []eventHandlerFuncType
+	var wg sync.WaitGroup
+	wg.Add(2)
+	var slowHandler resourceActionFunc = func(resController *ClusterWatcher, rw *ResourceWatcher, eventData *eventHandlerData) error {
+		defer wg.Done()
+		if atomic.AddInt32(&running, 1) > 1 {
+			atomic.AddInt32(&overlaps, 1)
+		}
+		time.Sleep(time.Millisecond * 100)
+		mutex.Lock()
+		order = append(order, eventData.funcType)
+		mutex.Unlock()
+		atomic.AddInt32(&running, -1)
+		return nil
+	}
+
+	var resController = &ClusterWatcher{
+		handlerMgr: newHandlerManager(),
+		handlers:   newHandlerPool(2),
+	}
+	resController.handlerMgr.setPrimaryHandler(gvr, &slowHandler)
+	rw := &ResourceWatcher{
+		GroupVersionResource: gvr,
+		queue:                workqueue.NewRateLimitingQueue(newControllerRateLimiter(resController, requeueBaseDelay, requeueMaxDelay)),
+	}
+	defer rw.queue.ShutDown()
+
+	obj := &unstructured.Unstructured{}
+	obj.SetNamespace("default")
+	obj.SetName("slow1")
+	rw.queue.Add(&eventHandlerData{funcType: AddFunc, gvr: gvr, key: "default/slow1", obj: obj})
+	rw.queue.Add(&eventHandlerData{funcType: UpdateFunc, gvr: gvr, key: "default/slow1", obj: obj, oldObj: obj})
+	for i := 0; i < 2; i++ {
+		if !processNextItem(resController, rw) {
+			t.Fatal("expecting processNextItem to continue")
+		}
+	}
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second * 5):
+		t.Fatal("timed out waiting for the handlers to run")
+	}
+	if n := atomic.LoadInt32(&overlaps); n != 0 {
+		t.Errorf("expecting handlers of one resource not to overlap, got %d overlaps", n)
+	}
+	if len(order) != 2 || order[0] != AddFunc || order[1] != UpdateFunc {
+		t.Errorf("expecting the add handler before the update handler, got %v", order)
+	}
+}
@@ -0,0 +1,195 @@
+/*
+Copyright 2019 IBM Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"sort"
+	"sync"
+)
+
+/*
+ The health of a component is read by each registered health reader, in
+ order of priority. The built-in readers read from the Pod itself for bare
+ Pods, from the kAppNav API server for other components, from the replicas
+ and conditions of Deployments without status from the API server and from
+ the printer columns of CRDs. The
+ results of the readers are either combined into the worst of all, or the
+ first definitive status, other than Unknown, is taken without evaluating
+ the readers of lower priority. A component in a configured transient phase
+ takes the status of the phase, without any reader.
+*/
+
+// Settings of the healthReaderMode flag: how the statuses of the health readers are combined
+const (
+	healthReaderWorstOfAll      = "worstOfAll"      // evaluate all readers and take the worst status
+	healthReaderFirstDefinitive = "firstDefinitive" // stop at the first status other than Unknown
+
+	defaultHealthReaderMode = healthReaderWorstOfAll
+
+	// names and priorities of the built-in readers
+	podHealthReader                   = "pod"
+	podHealthReaderPriority           = 0
+	apiServerHealthReader             = "apiServer"
+	apiServerHealthReaderPriority     = 10
+	deploymentHealthReader            = "deployment"
+	deploymentHealthReaderPriority    = 20
+	printerColumnHealthReader         = "printerColumns"
+	printerColumnHealthReaderPriority = 30
+)
+
+// A reader of the health of components. Returns an empty status for components it does not recognize
+type healthReader interface {
+	readHealth(resController *ClusterWatcher, resInfo *resourceInfo) (status string, flyover string, flyoverNLS string, err error)
+}
+
+// A health reader with its priority. Readers with lower values are evaluated first.
+// A fallback reader is evaluated only if no reader before it returned a status
+type registeredHealthReader struct {
+	name     string
+	priority int
+	fallback bool
+	reader   healthReader
+}
+
+// built-in reader of bare Pods, from the Pod itself
+type podStatusHealthReader struct{}
+
+func (podStatusHealthReader) readHealth(resController *ClusterWatcher, resInfo *resourceInfo) (status string, flyover string, flyoverNLS string, err error) {
+	if resInfo.podStatus == nil {
+		return "", "", "", nil
+	}
+	status, flyover = podHealth(resInfo.podStatus)
+	return status, flyover, "", nil
+}
+
+// built-in reader of components other than bare Pods, from the kAppNav API server
+type apiServerStatusHealthReader struct{}
+
+func (apiServerStatusHealthReader) readHealth(resController *ClusterWatcher, resInfo *resourceInfo) (status string, flyover string, flyoverNLS string, err error) {
+	if resInfo.podStatus != nil {
+		return "", "", "", nil
+	}
+	return resController.plugin.statusFunc(apiURL, resInfo)
+}
+
+// built-in reader of Deployments, from their replicas and conditions
+type deploymentStatusHealthReader struct{}
+
+func (deploymentStatusHealthReader) readHealth(resController *ClusterWatcher, resInfo *resourceInfo) (status string, flyover string, flyoverNLS string, err error) {
+	if resInfo.deployment == nil {
+		return "", "", "", nil
+	}
+	status, flyover = deploymentHealth(resInfo.deployment, deploymentHealthSource)
+	return status, flyover, "", nil
+}
+
+// built-in reader of custom resources, from the printer columns of their CRDs
+type printerColumnStatusHealthReader struct{}
+
+func (printerColumnStatusHealthReader) readHealth(resController *ClusterWatcher, resInfo *resourceInfo) (status string, flyover string, flyoverNLS string, err error) {
+	status, flyover = resController.printerColumnHealth(resInfo)
+	return status, flyover, "", nil
+}
+
+var (
+	healthReaders = map[string]registeredHealthReader{
+		podHealthReader:           {name: podHealthReader, priority: podHealthReaderPriority, reader: podStatusHealthReader{}},
+		apiServerHealthReader:     {name: apiServerHealthReader, priority: apiServerHealthReaderPriority, reader: apiServerStatusHealthReader{}},
+		deploymentHealthReader:    {name: deploymentHealthReader, priority: deploymentHealthReaderPriority, fallback: true, reader: deploymentStatusHealthReader{}},
+		printerColumnHealthReader: {name: printerColumnHealthReader, priority: printerColumnHealthReaderPriority, reader: printerColumnStatusHealthReader{}},
+	}
+	healthReadersMutex sync.Mutex
+)
+
+// Register a health reader under a name, replacing any reader with the same name
+func registerHealthReader(name string, priority int, reader healthReader) {
+	healthReadersMutex.Lock()
+	defer healthReadersMutex.Unlock()
+	healthReaders[name] = registeredHealthReader{name: name, priority: priority, reader: reader}
+}
+
+// Remove the health reader registered under a name
+func unregisterHealthReader(name string) {
+	healthReadersMutex.Lock()
+	defer healthReadersMutex.Unlock()
+	delete(healthReaders, name)
+}
+
+// Return the registered health readers, by priority then name
+func sortedHealthReaders() []registeredHealthReader {
+	healthReadersMutex.Lock()
+	ret := make([]registeredHealthReader, 0, len(healthReaders))
+	for _, reader := range healthReaders {
+		ret = append(ret, reader)
+	}
+	healthReadersMutex.Unlock()
+	sort.Slice(ret, func(i, j int) bool {
+		if ret[i].priority != ret[j].priority {
+			return ret[i].priority < ret[j].priority
+		}
+		return ret[i].name < ret[j].name
+	})
+	return ret
+}
+
+// Return true if the value is a valid setting of the healthReaderMode flag
+func validHealthReaderMode(value string) bool {
+	return value == healthReaderWorstOfAll || value == healthReaderFirstDefinitive
+}
+
+// Return true if status is worse than other, i.e. comes first in the status precedence.
+// Statuses not in the precedence are better than all others
+func (resController *ClusterWatcher) worseStatus(status string, other string) bool {
+	precedence, _ := resController.getStatusConfig()
+	rank := func(value string) int {
+		for index, candidate := range precedence {
+			if candidate == value {
+				return index
+			}
+		}
+		return len(precedence)
+	}
+	return rank(status) < rank(other)
+}
+
+// Read the health of a component with the registered health readers, combined as
+// configured by healthReaderMode. Return an empty status if no reader recognizes it
+func (resController *ClusterWatcher) readHealth(resInfo *resourceInfo) (status string, flyover string, flyoverNLS string, err error) {
+	if status, flyover = resController.transientPhaseHealth(resInfo); status != "" {
+		return status, flyover, "", nil
+	}
+	unknownStatus := resController.getUnknownStatus()
+	for _, registered := range sortedHealthReaders() {
+		if registered.fallback && status != "" {
+			continue
+		}
+		readStatus, readFlyover, readFlyoverNLS, readErr := registered.reader.readHealth(resController, resInfo)
+		if readErr != nil {
+			return "", "", "", readErr
+		}
+		if readStatus == "" {
+			continue
+		}
+		if healthReaderMode == healthReaderFirstDefinitive && readStatus != unknownStatus {
+			return readStatus, readFlyover, readFlyoverNLS, nil
+		}
+		if status == "" || resController.worseStatus(readStatus, status) {
+			status, flyover, flyoverNLS = readStatus, readFlyover, readFlyoverNLS
+		}
+	}
+	return status, flyover, flyoverNLS, nil
+}
@@ -0,0 +1,150 @@
+/*
+Copyright 2019 IBM Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"testing"
+)
+
+// health reader returning a fixed status, counting its calls
+type fixedHealthReader struct {
+	status string
+	calls  *int
+}
+
+func (reader fixedHealthReader) readHealth(resController *ClusterWatcher, resInfo *resourceInfo) (string, string, string, error) {
+	*reader.calls++
+	return reader.status, "read " + reader.status, "", nil
+}
+
+var healthReaderModeTestData = []struct {
+	mode            string
+	builtinStatus   string // status from the API server
+	secondaryStatus string // status of the reader of lower priority
+	expected        string
+	secondaryCalled bool
+}{
+	{healthReaderWorstOfAll, Normal, problem, problem, true},
+	{healthReaderWorstOfAll, problem, Normal, problem, true},
+	{healthReaderWorstOfAll, "Unknown", warning, warning, true},
+	{healthReaderWorstOfAll, "", Normal, Normal, true},
+	{healthReaderFirstDefinitive, Normal, problem, Normal, false},
+	{healthReaderFirstDefinitive, warning, Normal, warning, false},
+	{healthReaderFirstDefinitive, "Unknown", problem, problem, true},
+	{healthReaderFirstDefinitive, "", Normal, Normal, true},
+	{healthReaderFirstDefinitive, "Unknown", "", "Unknown", true},
+}
+
+func TestHealthReaderMode(t *testing.T) {
+	defer func() {
+		healthReaderMode = defaultHealthReaderMode
+		unregisterHealthReader("secondary")
+	}()
+	for _, data := range healthReaderModeTestData {
+		healthReaderMode = data.mode
+		builtinStatus := data.builtinStatus
+		resController := &ClusterWatcher{
+			plugin: &ControllerPlugin{statusFunc: func(destURL string, resInfo *resourceInfo) (string, string, string, error) {
+				return builtinStatus, "", "", nil
+			}},
+			statusPrecedence: []string{problem, warning, "Unknown", Normal},
+			unknownStatus:    "Unknown",
+		}
+		calls := 0
+		registerHealthReader("secondary", apiServerHealthReaderPriority+1, fixedHealthReader{status: data.secondaryStatus, calls: &calls})
+
+		resInfo := &resourceInfo{kind: "Widget", namespace: "default", name: "widget1"}
+		status, _, _, err := resController.readComponentStatus(resInfo)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if status != data.expected {
+			t.Errorf("mode %s, statuses %q and %q: expecting %q, got %q", data.mode, data.builtinStatus, data.secondaryStatus, data.expected, status)
+		}
+		if called := calls > 0; called != data.secondaryCalled {
+			t.Errorf("mode %s, statuses %q and %q: expecting reader of lower priority called: %t", data.mode, data.builtinStatus, data.secondaryStatus, data.secondaryCalled)
+		}
+	}
+}
+
+func TestHealthReaderPriority(t *testing.T) {
+	defer func() {
+		healthReaderMode = defaultHealthReaderMode
+		unregisterHealthReader("first")
+	}()
+	healthReaderMode = healthReaderFirstDefinitive
+	resController := &ClusterWatcher{
+		plugin: &ControllerPlugin{statusFunc: func(destURL string, resInfo *resourceInfo) (string, string, string, error) {
+			return Normal, "", "", nil
+		}},
+		statusPrecedence: []string{problem, warning, "Unknown", Normal},
+		unknownStatus:    "Unknown",
+	}
+	calls := 0
+	registerHealthReader("first", apiServerHealthReaderPriority-1, fixedHealthReader{status: warning, calls: &calls})
+	status, flyover, _, err := resController.readComponentStatus(&resourceInfo{kind: "Widget", namespace: "default", name: "widget1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status != warning || flyover != "read "+warning {
+		t.Errorf("expecting status %s from the reader of highest priority, got %s %s", warning, status, flyover)
+	}
+
+	if !validHealthReaderMode(healthReaderWorstOfAll) || !validHealthReaderMode(healthReaderFirstDefinitive) || validHealthReaderMode("bestOfAll") {
+		t.Error("expecting only worstOfAll and firstDefinitive to be valid modes")
+	}
+}
+
+func TestBuiltinHealthReaders(t *testing.T) {
+	defer func() { healthReaderMode = defaultHealthReaderMode }()
+	unstructuredObj, err := readJSON(deploymentScalingFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resInfo := &resourceInfo{}
+	parseResourceBasic(unstructuredObj, resInfo)
+
+	// the replicas of the Deployment are read only without status from the API server
+	var tests = []struct {
+		mode      string
+		apiStatus string
+		expected  string
+	}{
+		{healthReaderWorstOfAll, statusNormal, statusNormal},
+		{healthReaderFirstDefinitive, statusNormal, statusNormal},
+		{healthReaderWorstOfAll, "", statusProblem},
+		{healthReaderFirstDefinitive, "", statusProblem},
+	}
+	for _, test := range tests {
+		healthReaderMode = test.mode
+		apiStatus := test.apiStatus
+		resController := &ClusterWatcher{
+			plugin: &ControllerPlugin{statusFunc: func(destURL string, resInfo *resourceInfo) (string, string, string, error) {
+				return apiStatus, "", "", nil
+			}},
+			statusPrecedence: []string{statusProblem, statusWarning, "Unknown", statusNormal},
+			unknownStatus:    "Unknown",
+		}
+		status, _, _, err := resController.readHealth(resInfo)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if status != test.expected {
+			t.Errorf("mode %s, API server status %q: expecting %s, got %s", test.mode, test.apiStatus, test.expected, status)
+		}
+	}
+}
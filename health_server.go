@@ -0,0 +1,114 @@
+/*
+Copyright 2019 IBM Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"net/http"
+	"sync"
+
+	"k8s.io/klog"
+)
+
+/*
+ Liveness and readiness probes of the controller. The controller is live once
+ the ClusterWatcher is initialized, and ready once the informers of all
+ watched resources have synced their initial list.
+*/
+
+const (
+	// path of GET /healthz
+	healthzPath = "/healthz"
+
+	// path of GET /readyz
+	readyzPath = "/readyz"
+
+	// DefaultHealthAddr - address to serve the health probes
+	DefaultHealthAddr = ":8081"
+)
+
+// State of the controller reported by the probes
+type healthState struct {
+	resController *ClusterWatcher // nil until initialized
+	mutex         sync.Mutex
+}
+
+// Record the ClusterWatcher once initialized
+func (state *healthState) setClusterWatcher(resController *ClusterWatcher) {
+	state.mutex.Lock()
+	defer state.mutex.Unlock()
+	state.resController = resController
+}
+
+// Return the ClusterWatcher, or nil if not initialized
+func (state *healthState) clusterWatcher() *ClusterWatcher {
+	state.mutex.Lock()
+	defer state.mutex.Unlock()
+	return state.resController
+}
+
+// HasSynced returns true once the informers of all watched resources have synced
+func (resController *ClusterWatcher) HasSynced() bool {
+	resController.mutex.Lock()
+	defer resController.mutex.Unlock()
+	for _, rw := range resController.resourceMap {
+		if rw.controller != nil && !rw.controller.HasSynced() {
+			return false
+		}
+	}
+	return true
+}
+
+// Handler of a probe, returning 200 if check returns true, and 503 otherwise
+func probeHandler(check func() bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", http.MethodGet)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if !check() {
+			http.Error(w, "not ready", http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte("ok"))
+	}
+}
+
+// Create the handler for GET /healthz and GET /readyz
+func newHealthHandler(state *healthState) http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle(healthzPath, probeHandler(func() bool {
+		return state.clusterWatcher() != nil
+	}))
+	mux.Handle(readyzPath, probeHandler(func() bool {
+		resController := state.clusterWatcher()
+		return resController != nil && resController.HasSynced()
+	}))
+	return mux
+}
+
+// Start serving the health probes on the given address
+func startHealthServer(addr string, state *healthState) {
+	go func() {
+		if klog.V(2) {
+			klog.Infof("starting health server on %s", addr)
+		}
+		if err := http.ListenAndServe(addr, newHealthHandler(state)); err != nil {
+			klog.Errorf("health server on %s stopped: %s", addr, err)
+		}
+	}()
+}
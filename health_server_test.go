@@ -0,0 +1,91 @@
+/*
+Copyright 2019 IBM Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// Informer controller whose sync state is set by the test
+type fakeInformerController struct {
+	synced bool
+}
+
+func (controller *fakeInformerController) Run(stopCh <-chan struct{}) {}
+
+func (controller *fakeInformerController) HasSynced() bool {
+	return controller.synced
+}
+
+func (controller *fakeInformerController) LastSyncResourceVersion() string {
+	return ""
+}
+
+// Return the code of a GET of the path
+func getProbe(handler http.Handler, path string) int {
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, path, nil))
+	return recorder.Code
+}
+
+// Test the probes return 503 until initialized and synced
+func TestHealthProbes(t *testing.T) {
+	state := &healthState{}
+	handler := newHealthHandler(state)
+
+	// not initialized
+	if code := getProbe(handler, healthzPath); code != http.StatusServiceUnavailable {
+		t.Errorf("expecting %s code %d before initialization, got %d", healthzPath, http.StatusServiceUnavailable, code)
+	}
+	if code := getProbe(handler, readyzPath); code != http.StatusServiceUnavailable {
+		t.Errorf("expecting %s code %d before initialization, got %d", readyzPath, http.StatusServiceUnavailable, code)
+	}
+
+	// initialized, one informer not synced
+	synced := &fakeInformerController{synced: true}
+	syncing := &fakeInformerController{synced: false}
+	resController := &ClusterWatcher{
+		resourceMap: map[schema.GroupVersionResource]*ResourceWatcher{
+			coreApplicationGVR: {GroupVersionResource: coreApplicationGVR, controller: synced},
+			coreDeploymentGVR:  {GroupVersionResource: coreDeploymentGVR, controller: syncing},
+			coreServiceGVR:     {GroupVersionResource: coreServiceGVR},
+		},
+	}
+	state.setClusterWatcher(resController)
+	if code := getProbe(handler, healthzPath); code != http.StatusOK {
+		t.Errorf("expecting %s code %d once initialized, got %d", healthzPath, http.StatusOK, code)
+	}
+	if code := getProbe(handler, readyzPath); code != http.StatusServiceUnavailable {
+		t.Errorf("expecting %s code %d before sync, got %d", readyzPath, http.StatusServiceUnavailable, code)
+	}
+
+	// all synced. Resources not watched do not count
+	syncing.synced = true
+	if code := getProbe(handler, readyzPath); code != http.StatusOK {
+		t.Errorf("expecting %s code %d once synced, got %d", readyzPath, http.StatusOK, code)
+	}
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, readyzPath, nil))
+	if recorder.Code != http.StatusMethodNotAllowed {
+		t.Errorf("expecting code %d for POST, got %d", http.StatusMethodNotAllowed, recorder.Code)
+	}
+}
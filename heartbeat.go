@@ -0,0 +1,100 @@
+/*
+Copyright 2019 IBM Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"sync"
+	"time"
+
+	"k8s.io/klog"
+)
+
+/*
+ A heartbeat is logged and counted periodically, whether or not events
+ arrive, for dead man's switch alerting. Each heartbeat reports the number of
+ events processed since the previous one, to tell apart a quiet cluster from
+ a hung controller.
+*/
+
+// DefaultHeartbeatInterval - interval between heartbeats
+const DefaultHeartbeatInterval = time.Minute
+
+// Periodic heartbeat of the controller
+type heartbeat struct {
+	interval   time.Duration
+	lastEvents float64 // value of eventsProcessed at the last heartbeat
+	stopCh     chan struct{}
+	mutex      sync.Mutex
+}
+
+// Create a heartbeat. Return nil if the interval is not positive, for no heartbeat
+func newHeartbeat(interval time.Duration) *heartbeat {
+	if interval <= 0 {
+		return nil
+	}
+	return &heartbeat{
+		interval:   interval,
+		lastEvents: counterValue(eventsProcessed),
+		stopCh:     make(chan struct{}),
+	}
+}
+
+// Start beating periodically
+func (hb *heartbeat) start() {
+	if hb == nil {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(hb.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				hb.beat()
+			case <-hb.stopCh:
+				return
+			}
+		}
+	}()
+}
+
+// Stop beating
+func (hb *heartbeat) stop() {
+	if hb == nil {
+		return
+	}
+	hb.mutex.Lock()
+	defer hb.mutex.Unlock()
+	select {
+	case <-hb.stopCh:
+	default:
+		close(hb.stopCh)
+	}
+}
+
+// Log and count one heartbeat
+func (hb *heartbeat) beat() {
+	hb.mutex.Lock()
+	events := counterValue(eventsProcessed)
+	sinceLast := events - hb.lastEvents
+	hb.lastEvents = events
+	hb.mutex.Unlock()
+
+	heartbeats.Inc()
+	eventsSinceHeartbeat.Set(sinceLast)
+	klog.Infof("heartbeat: %v events processed in the last %s", sinceLast, hb.interval)
+}
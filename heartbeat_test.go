@@ -0,0 +1,66 @@
+/*
+Copyright 2019 IBM Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestHeartbeatAdvances(t *testing.T) {
+	hb := newHeartbeat(time.Millisecond * 20)
+	before := testutil.ToFloat64(heartbeats)
+	hb.start()
+	time.Sleep(time.Millisecond * 200)
+	hb.stop()
+	if beats := testutil.ToFloat64(heartbeats) - before; beats < 2 {
+		t.Errorf("expecting the heartbeat counter to advance without events, got %v heartbeats", beats)
+	}
+
+	// no more heartbeats once stopped
+	stopped := testutil.ToFloat64(heartbeats)
+	time.Sleep(time.Millisecond * 100)
+	if testutil.ToFloat64(heartbeats) != stopped {
+		t.Error("expecting no heartbeat after stop")
+	}
+}
+
+func TestHeartbeatEventsSinceLast(t *testing.T) {
+	hb := newHeartbeat(time.Hour)
+	for i := 0; i < 3; i++ {
+		eventsProcessed.Inc()
+	}
+	hb.beat()
+	if events := testutil.ToFloat64(eventsSinceHeartbeat); events < 3 {
+		t.Errorf("expecting at least 3 events since the last heartbeat, got %v", events)
+	}
+	hb.beat()
+	if events := testutil.ToFloat64(eventsSinceHeartbeat); events != 0 {
+		t.Errorf("expecting no events since the last heartbeat, got %v", events)
+	}
+}
+
+func TestHeartbeatDisabled(t *testing.T) {
+	hb := newHeartbeat(0)
+	if hb != nil {
+		t.Fatal("expecting no heartbeat with an interval of 0")
+	}
+	hb.start()
+	hb.stop()
+}
@@ -0,0 +1,88 @@
+/*
+Copyright 2019 IBM Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"net"
+	"net/http"
+	"sort"
+
+	"k8s.io/klog"
+)
+
+// Create the handler for the HTTP endpoints of the controller
+func newHTTPHandler(resController *ClusterWatcher) http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("/status-for-selector", statusForSelectorHandler(resController))
+	mux.Handle(reconcilePathPrefix, reconcileApplicationHandler(resController))
+	mux.Handle(statusCachePath, statusCacheHandler(resController))
+	mux.Handle(reloadPath, reloadConfigHandler(resController))
+	mux.Handle(apiServerHealthPath, apiHealthHandler(resController))
+	mux.Handle(impactPath, impactHandler(resController))
+	mux.Handle(deleteApplicationImpactPath, deleteApplicationImpactHandler(resController))
+	mux.Handle(applicationStatusPathPrefix, applicationStatusHandler(resController))
+	if enablePprof {
+		registerPprofHandlers(mux)
+	}
+	return mux
+}
+
+// Start serving the HTTP endpoints of the controller on the given address
+func startHTTPServer(addr string, handler http.Handler) {
+	go func() {
+		if klog.V(2) {
+			klog.Infof("starting HTTP server on %s", addr)
+		}
+		if err := http.ListenAndServe(addr, handler); err != nil {
+			klog.Errorf("HTTP server on %s stopped: %s", addr, err)
+		}
+	}()
+}
+
+// Return true if servers on both addresses would listen on the same port of the same host.
+// An empty host, 0.0.0.0, or :: listens on all hosts
+func sameServerAddr(addr1 string, addr2 string) bool {
+	host1, port1, err1 := net.SplitHostPort(addr1)
+	host2, port2, err2 := net.SplitHostPort(addr2)
+	if err1 != nil || err2 != nil {
+		return addr1 == addr2
+	}
+	allHosts := func(host string) bool {
+		return host == "" || host == "0.0.0.0" || host == "::"
+	}
+	return port1 == port2 && (host1 == host2 || allHosts(host1) || allHosts(host2))
+}
+
+// Return the flags of two servers configured on the same address, from flag name to address.
+// Empty addresses are disabled, and never conflict
+func conflictingServerAddrs(addrs map[string]string) (string, string, bool) {
+	names := make([]string, 0, len(addrs))
+	for name, addr := range addrs {
+		if addr != "" {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for i := range names {
+		for j := i + 1; j < len(names); j++ {
+			if sameServerAddr(addrs[names[i]], addrs[names[j]]) {
+				return names[i], names[j], true
+			}
+		}
+	}
+	return "", "", false
+}
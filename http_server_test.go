@@ -0,0 +1,43 @@
+/*
+Copyright 2019 IBM Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"testing"
+)
+
+func TestConflictingServerAddrs(t *testing.T) {
+	var tests = []struct {
+		addrs    map[string]string
+		conflict bool
+	}{
+		{map[string]string{"httpAddr": ":8082", "metricsAddr": DefaultMetricsAddr, "healthAddr": DefaultHealthAddr}, false},
+		{map[string]string{"httpAddr": ":8080", "metricsAddr": DefaultMetricsAddr, "healthAddr": DefaultHealthAddr}, true},
+		{map[string]string{"httpAddr": "0.0.0.0:8081", "metricsAddr": DefaultMetricsAddr, "healthAddr": DefaultHealthAddr}, true},
+		{map[string]string{"httpAddr": "127.0.0.1:8080", "metricsAddr": "127.0.0.2:8080"}, false},
+		{map[string]string{"httpAddr": "", "metricsAddr": "", "healthAddr": DefaultHealthAddr}, false},
+	}
+	for _, test := range tests {
+		name1, name2, conflict := conflictingServerAddrs(test.addrs)
+		if conflict != test.conflict {
+			t.Errorf("%v: expecting conflict %t, got %t for %s and %s", test.addrs, test.conflict, conflict, name1, name2)
+		}
+	}
+	if DefaultMetricsAddr == DefaultHealthAddr {
+		t.Errorf("expecting distinct default addresses, got %s", DefaultMetricsAddr)
+	}
+}
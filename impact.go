@@ -0,0 +1,262 @@
+/*
+Copyright 2019 IBM Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/klog"
+)
+
+/*
+ Impact of a hypothetical label change: the applications that would start
+ and stop selecting a resource if its labels were changed. Computed against
+ the cached applications. Nothing is changed.
+*/
+
+const impactPath = "/impact"
+
+// Request body of POST /impact
+type impactRequest struct {
+	Group     string            `json:"group"`
+	Kind      string            `json:"kind"`
+	Namespace string            `json:"namespace"`
+	Name      string            `json:"name"`
+	Labels    map[string]string `json:"labels"` // proposed labels, replacing the current labels
+}
+
+// Response of POST /impact
+type impactResponse struct {
+	Gained []impactApplication `json:"gained"` // applications that would start selecting the resource
+	Lost   []impactApplication `json:"lost"`   // applications that would stop selecting the resource
+}
+
+// An application affected by the label change
+type impactApplication struct {
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+}
+
+// errors of impact requests, to tell apart the response code
+var errImpactResourceNotFound = fmt.Errorf("resource not found")
+
+// Return the keys of the applications selecting a resource
+func applicationsSelecting(resController *ClusterWatcher, resInfo *resourceInfo) map[string]impactApplication {
+	ret := make(map[string]impactApplication)
+	for _, appInfo := range getApplicationsForResource(resController, resInfo) {
+		ret[resController.resourceKey(&appInfo.resourceInfo)] = impactApplication{Namespace: appInfo.namespace, Name: appInfo.name}
+	}
+	return ret
+}
+
+// Return the applications in from that are not in other, sorted by namespace and name
+func applicationsNotIn(from map[string]impactApplication, other map[string]impactApplication) []impactApplication {
+	ret := make([]impactApplication, 0)
+	for key, app := range from {
+		if _, ok := other[key]; !ok {
+			ret = append(ret, app)
+		}
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		if ret[i].Namespace != ret[j].Namespace {
+			return ret[i].Namespace < ret[j].Namespace
+		}
+		return ret[i].Name < ret[j].Name
+	})
+	return ret
+}
+
+// Compute the applications that would start and stop selecting the resource of the request
+func (resController *ClusterWatcher) labelChangeImpact(req *impactRequest) (*impactResponse, error) {
+	if req.Kind == "" || req.Name == "" {
+		return nil, fmt.Errorf("kind and name are required")
+	}
+	gvr, ok := resController.getGVRForGroupKind(req.Group, req.Kind)
+	if !ok {
+		return nil, fmt.Errorf("unknown kind %s/%s", req.Group, req.Kind)
+	}
+	obj, exists, err := resController.getResource(gvr, req.Namespace, req.Name)
+	if err != nil || !exists {
+		return nil, errImpactResourceNotFound
+	}
+	unstructuredObj, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		return nil, errImpactResourceNotFound
+	}
+
+	var current = &resourceInfo{}
+	resController.parseResource(unstructuredObj, current)
+	proposed := &resourceInfo{}
+	*proposed = *current
+	proposed.labels = make(map[string]string, len(req.Labels))
+	for key, value := range req.Labels {
+		proposed.labels[key] = value
+	}
+
+	before := applicationsSelecting(resController, current)
+	after := applicationsSelecting(resController, proposed)
+	return &impactResponse{
+		Gained: applicationsNotIn(after, before),
+		Lost:   applicationsNotIn(before, after),
+	}, nil
+}
+
+// Handler for POST /impact
+func impactHandler(resController *ClusterWatcher) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		var req impactRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid request: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		resp, err := resController.labelChangeImpact(&req)
+		if err == errImpactResourceNotFound {
+			http.Error(w, fmt.Sprintf("%s %s/%s not found", req.Kind, req.Namespace, req.Name), http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			http.Error(w, "invalid request: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if klog.V(4) {
+			klog.Infof("labelChangeImpact %s %s/%s labels: %v gained: %v lost: %v", req.Kind, req.Namespace, req.Name, req.Labels, resp.Gained, resp.Lost)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(resp); err != nil && klog.V(2) {
+			klog.Infof("labelChangeImpact unable to write response: %s", err)
+		}
+	}
+}
+
+// path of POST /impact/delete-application
+const deleteApplicationImpactPath = "/impact/delete-application"
+
+// Request body of POST /impact/delete-application
+type deleteApplicationImpactRequest struct {
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+}
+
+// Response of POST /impact/delete-application
+type deleteApplicationImpactResponse struct {
+	Changed []impactStatusChange `json:"changed"` // ancestors whose status would change
+}
+
+// An ancestor application whose status would change
+type impactStatusChange struct {
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+	Status    string `json:"status"`    // current status
+	NewStatus string `json:"newStatus"` // status once the application is deleted
+}
+
+// Compute the ancestors of an application whose status would change if the application were deleted,
+// by recomputing them from the cached components without the application. Nothing is written
+func (resController *ClusterWatcher) deleteApplicationImpact(req *deleteApplicationImpactRequest) (*deleteApplicationImpactResponse, error) {
+	if req.Name == "" {
+		return nil, fmt.Errorf("name is required")
+	}
+	obj, exists, err := resController.getApplication(req.Namespace, req.Name)
+	if err != nil || !exists {
+		return nil, errImpactResourceNotFound
+	}
+	unstructuredObj, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		return nil, errImpactResourceNotFound
+	}
+	var deleted = &resourceInfo{}
+	resController.parseResource(unstructuredObj, deleted)
+
+	ancestors := make(map[string]*resourceInfo)
+	findAllApplicationsForResource(resController, unstructuredObj, ancestors)
+	delete(ancestors, resController.resourceKey(deleted))
+
+	// recompute all ancestors rather than folding in their cached status. The
+	// deleted application is already visited, so it is skipped as a component
+	toCompute := make(map[string]*resourceInfo, len(ancestors)+1)
+	for key, ancestor := range ancestors {
+		toCompute[key] = ancestor
+	}
+	toCompute[resController.resourceKey(deleted)] = deleted
+	hasStatus := make(map[string]*resourceInfo)
+
+	resp := &deleteApplicationImpactResponse{Changed: make([]impactStatusChange, 0)}
+	for _, ancestor := range ancestors {
+		visited := map[string]*resourceInfo{resController.resourceKey(deleted): deleted}
+		_, status, _, _, err := processOneApplication(resController, ancestor, visited, hasStatus,
+			make(map[string]*resourceInfo), toCompute, make(map[string]*resourceInfo))
+		if err != nil {
+			return nil, err
+		}
+		if status != ancestor.kappnavStatVal {
+			resp.Changed = append(resp.Changed, impactStatusChange{
+				Namespace: ancestor.namespace,
+				Name:      ancestor.name,
+				Status:    ancestor.kappnavStatVal,
+				NewStatus: status,
+			})
+		}
+	}
+	sort.Slice(resp.Changed, func(i, j int) bool {
+		if resp.Changed[i].Namespace != resp.Changed[j].Namespace {
+			return resp.Changed[i].Namespace < resp.Changed[j].Namespace
+		}
+		return resp.Changed[i].Name < resp.Changed[j].Name
+	})
+	return resp, nil
+}
+
+// Handler for POST /impact/delete-application
+func deleteApplicationImpactHandler(resController *ClusterWatcher) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		var req deleteApplicationImpactRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid request: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		resp, err := resController.deleteApplicationImpact(&req)
+		if err == errImpactResourceNotFound {
+			http.Error(w, fmt.Sprintf("%s %s/%s not found", APPLICATION, req.Namespace, req.Name), http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			http.Error(w, "invalid request: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if klog.V(4) {
+			klog.Infof("deleteApplicationImpact %s/%s changed: %v", req.Namespace, req.Name, resp.Changed)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(resp); err != nil && klog.V(2) {
+			klog.Infof("deleteApplicationImpact unable to write response: %s", err)
+		}
+	}
+}
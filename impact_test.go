@@ -0,0 +1,236 @@
+/*
+Copyright 2019 IBM Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// POST the request body to /impact
+func postImpact(resController *ClusterWatcher, method string, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, impactPath, strings.NewReader(body))
+	recorder := httptest.NewRecorder()
+	newHTTPHandler(resController).ServeHTTP(recorder, req)
+	return recorder
+}
+
+// Return namespace/name of the applications
+func impactApplicationNames(apps []impactApplication) string {
+	names := make([]string, 0, len(apps))
+	for _, app := range apps {
+		names = append(names, app.Namespace+"/"+app.Name)
+	}
+	return strings.Join(names, ",")
+}
+
+type impactTestData struct {
+	body           string
+	expectedCode   int
+	expectedGained string
+	expectedLost   string
+}
+
+var impactTestDataArray = []impactTestData{
+	// moves from details-app to productpage-app
+	{body: `{"group": "apps", "kind": "Deployment", "namespace": "default", "name": "details-v1", "labels": {"app": "productpage", "version": "v1"}}`,
+		expectedCode: http.StatusOK, expectedGained: "default/productpage-app", expectedLost: "default/details-app"},
+	// same labels
+	{body: `{"group": "apps", "kind": "Deployment", "namespace": "default", "name": "details-v1", "labels": {"app": "details", "version": "v1"}}`,
+		expectedCode: http.StatusOK},
+	// no labels
+	{body: `{"group": "apps", "kind": "Deployment", "namespace": "default", "name": "details-v1"}`,
+		expectedCode: http.StatusOK, expectedLost: "default/details-app"},
+	// invalid requests
+	{body: `{"group": "apps", "kind": "Deployment", "namespace": "default", "name": "missing-v1", "labels": {}}`, expectedCode: http.StatusNotFound},
+	{body: `{"group": "apps", "namespace": "default", "name": "details-v1"}`, expectedCode: http.StatusBadRequest},
+	{body: `{"kind": `, expectedCode: http.StatusBadRequest},
+}
+
+// Test the applications gaining and losing a resource on a label change are listed, without changing anything
+func TestLabelChangeImpact(t *testing.T) {
+	testName := "TestLabelChangeImpact"
+	beforeTest()
+	// kinds to check for status
+	var kindsToCheckStatus = map[string]bool{
+		"Deployment": true,
+	}
+
+	var files = []string{
+		/* 0 */ CrdApplication,
+		/* 1 */ appProductpage,
+		/* 2 */ appDetails,
+		/* 3 */ deploymentDetailsV1,
+		/* 4 */ KappnavConfigFile,
+	}
+	iteration0IDs, err := readResourceIDs(files)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testActions := newTestActions(testName, kindsToCheckStatus)
+	var emptyIDs = []resourceID{}
+	testActions.addIteration(iteration0IDs, emptyIDs)
+
+	clusterWatcher, err := createClusterWatcher(iteration0IDs, testActions, StatusFailureRate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer clusterWatcher.shutDown()
+
+	err = testActions.transitionAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, data := range impactTestDataArray {
+		recorder := postImpact(clusterWatcher, http.MethodPost, data.body)
+		if recorder.Code != data.expectedCode {
+			t.Errorf("request %s: expecting code %d, got %d: %s", data.body, data.expectedCode, recorder.Code, recorder.Body.String())
+			continue
+		}
+		if data.expectedCode != http.StatusOK {
+			continue
+		}
+		var resp impactResponse
+		if err := json.Unmarshal(recorder.Body.Bytes(), &resp); err != nil {
+			t.Errorf("request %s: unable to parse response %s: %s", data.body, recorder.Body.String(), err)
+			continue
+		}
+		if gained := impactApplicationNames(resp.Gained); gained != data.expectedGained {
+			t.Errorf("request %s: expecting gained %q, got %q", data.body, data.expectedGained, gained)
+		}
+		if lost := impactApplicationNames(resp.Lost); lost != data.expectedLost {
+			t.Errorf("request %s: expecting lost %q, got %q", data.body, data.expectedLost, lost)
+		}
+	}
+
+	// the resource is not changed
+	obj, err := getResource(clusterWatcher, iteration0IDs[3])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if labels := obj.GetLabels(); labels["app"] != "details" {
+		t.Errorf("expecting labels of details-v1 unchanged, got %v", labels)
+	}
+
+	recorder := postImpact(clusterWatcher, http.MethodGet, "")
+	if recorder.Code != http.StatusMethodNotAllowed {
+		t.Errorf("expecting code %d for GET, got %d", http.StatusMethodNotAllowed, recorder.Code)
+	}
+}
+
+// POST the request body to /impact/delete-application
+func postDeleteApplicationImpact(resController *ClusterWatcher, method string, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, deleteApplicationImpactPath, strings.NewReader(body))
+	recorder := httptest.NewRecorder()
+	newHTTPHandler(resController).ServeHTTP(recorder, req)
+	return recorder
+}
+
+// Test the ancestors whose status would change on deleting an application are listed, without changing anything
+func TestDeleteApplicationImpact(t *testing.T) {
+	testName := "TestDeleteApplicationImpact"
+	beforeTest()
+	// kinds to check for status
+	var kindsToCheckStatus = map[string]bool{
+		APPLICATION:  true,
+		"Deployment": true,
+	}
+
+	var files = []string{
+		/* 0 */ CrdApplication,
+		/* 1 */ appBookinfo,
+		/* 2 */ appProductpage,
+		/* 3 */ appDetails,
+		/* 4 */ deploymentProcuctpageV1,
+		/* 5 */ deploymentDetailsV1,
+		/* 6 */ KappnavConfigFile,
+	}
+	iteration0IDs, err := readResourceIDs(files)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testActions := newTestActions(testName, kindsToCheckStatus)
+	iteration0IDs[1].expectedStatus = warning // bookinfo warning due to productpage app
+	iteration0IDs[2].expectedStatus = warning // productpage app warning due to its deployment
+	iteration0IDs[4].expectedStatus = warning
+	var emptyIDs = []resourceID{}
+	testActions.addIteration(iteration0IDs, emptyIDs)
+
+	clusterWatcher, err := createClusterWatcher(iteration0IDs, testActions, StatusFailureRate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer clusterWatcher.shutDown()
+
+	err = testActions.transitionAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// bookinfo is Normal without productpage app
+	recorder := postDeleteApplicationImpact(clusterWatcher, http.MethodPost, `{"namespace": "default", "name": "productpage-app"}`)
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("expecting code %d, got %d: %s", http.StatusOK, recorder.Code, recorder.Body.String())
+	}
+	var resp deleteApplicationImpactResponse
+	if err := json.Unmarshal(recorder.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("unable to parse response %s: %s", recorder.Body.String(), err)
+	}
+	expected := impactStatusChange{Namespace: "default", Name: "bookinfo", Status: warning, NewStatus: Normal}
+	if len(resp.Changed) != 1 || resp.Changed[0] != expected {
+		t.Errorf("expecting changed %v, got %v", expected, resp.Changed)
+	}
+
+	// bookinfo stays warning without details app
+	recorder = postDeleteApplicationImpact(clusterWatcher, http.MethodPost, `{"namespace": "default", "name": "details-app"}`)
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("expecting code %d, got %d: %s", http.StatusOK, recorder.Code, recorder.Body.String())
+	}
+	resp = deleteApplicationImpactResponse{}
+	if err := json.Unmarshal(recorder.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("unable to parse response %s: %s", recorder.Body.String(), err)
+	}
+	if len(resp.Changed) != 0 {
+		t.Errorf("expecting no change without details-app, got %v", resp.Changed)
+	}
+
+	// nothing is written
+	for _, index := range []int{1, 2} {
+		obj, err := getResource(clusterWatcher, iteration0IDs[index])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if status := obj.GetAnnotations()[kappnavStatusValue]; status != warning {
+			t.Errorf("expecting %s still %s, got %s", obj.GetName(), warning, status)
+		}
+	}
+
+	recorder = postDeleteApplicationImpact(clusterWatcher, http.MethodPost, `{"namespace": "default", "name": "missing-app"}`)
+	if recorder.Code != http.StatusNotFound {
+		t.Errorf("expecting code %d for a missing application, got %d", http.StatusNotFound, recorder.Code)
+	}
+	recorder = postDeleteApplicationImpact(clusterWatcher, http.MethodGet, "")
+	if recorder.Code != http.StatusMethodNotAllowed {
+		t.Errorf("expecting code %d for GET, got %d", http.StatusMethodNotAllowed, recorder.Code)
+	}
+}
@@ -17,6 +17,7 @@ limitations under the License.
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"flag"
 	"os"
@@ -25,6 +26,7 @@ import (
 	"runtime"
 	"strings"
 	"syscall"
+	"time"
 
 	routev1 "github.com/openshift/client-go/route/clientset/versioned/typed/route/v1"
 	corev1 "k8s.io/api/core/v1"
@@ -32,14 +34,20 @@ import (
 	"k8s.io/apimachinery/pkg/watch"
 	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/client-go/tools/record"
 	"k8s.io/client-go/util/homedir"
 	"k8s.io/klog"
 )
 
 const (
 	kubeAPIURL = "http://localhost:9080"
+
+	// time to wait for the batch in progress to finish on shutdown
+	shutdownTimeout = time.Second * 30
 )
 
 var (
@@ -51,24 +59,200 @@ var (
 	routeV1Client *routev1.RouteV1Client
 	isLatestOKD   bool = false
 	isOKD         bool = false
+
+	emitStatusConditions bool   // also write kappnav status as a condition in status.conditions
+	statusConditionType  string // type of the condition for kappnav status
+
+	requeueBaseDelay time.Duration // delay before first retry of an object that failed to process
+	requeueMaxDelay  time.Duration // maximum delay between retries of an object
+
+	httpAddr string // address of the HTTP endpoints. Empty to disable
+
+	deletionGracePeriod time.Duration // time a deleted component still counts toward its applications
+
+	actionConfigMapsInkAppNavNamespace bool // create action configmaps in the kappnav namespace instead of the namespace of the component
+
+	batchDuration time.Duration // time to batch up changes before computing status
+
+	statusSnapshot bool // compute the status of each application from a copy of its components taken at the start
+
+	parentRequeueOnStatusChangeOnly bool // recompute parents of an updated application only if its labels or overall status changed
+
+	actionConfigMapsOptIn bool // create action configmaps only for Deployments annotated to enable actions
+
+	statusWritesPerNamespace int // maximum concurrent status writes per namespace. 0 to write one at a time
+
+	deniedAPIGroups string // comma separated API groups never to watch
+
+	noReaderStatus string // status of components whose kind no health reader recognizes: Normal, Unknown, or Problem
+
+	handlerWorkers int // number of workers calling event handlers for all GVRs. 0 for one worker per GVR
+
+	actionConfigMapFailureThreshold int           // consecutive failures to create action configmaps before suppressing them in a namespace
+	actionConfigMapCooldown         time.Duration // time action configmap creation stays suppressed in a namespace
+
+	statusAnnotation string // annotation the computed status is written to
+
+	apiHealthCheckInterval time.Duration // interval between checks of the availability of the API server. 0 to not check
+
+	parsedResourceCacheSize int // number of parsed resources kept while processing a batch. 0 to not cache
+
+	deploymentHealthSource string // what the health of Deployments is read from: replicas, conditions, or both
+
+	ignoreManagedConfigMaps bool // ignore events for configmaps created by the controller, except deletions
+
+	maxStatusConditions int // number of conditions written by the controller kept in status.conditions. 0 for no limit
+
+	eventSampleRate float64 // fraction of resources whose events are processed, for load testing
+
+	heartbeatInterval time.Duration // interval between heartbeats. 0 for none
+
+	healthReaderMode string // how the statuses of health readers are combined: worstOfAll or firstDefinitive
+
+	transientPhaseStatus string // status of resources in a transient phase, e.g. Pod:Pending=Warning. Empty for none
+
+	startupWarmup time.Duration // time to only batch changes at startup, before computing all applications. 0 for none
+
+	maxBatchSize int // number of applications and resources at which a batch is processed early. 0 for no limit
+
+	componentStatusJSONPath string // JSONPath of the status within a kappnav status holding a JSON document. Empty for the whole value
+
+	startupRetries    int           // attempts to connect to the API server at startup before exiting
+	startupRetryDelay time.Duration // delay before the first retry at startup, doubling with each retry
+
+	statusFreshnessTTL string // time the kappnav status of each kind is trusted after it was last updated, e.g. Deployment=5m. Empty for no TTL
+
+	selectorMetrics bool // count evaluations of application selectors
+
+	selectorlessApplications string // what to do with applications with an empty or missing selector: matchNothing or warn
+
+	relistBurstThreshold int           // unchanged updates of a GVR within the window that make a relist burst. 0 to not coalesce
+	relistBurstWindow    time.Duration // time without unchanged updates that ends a relist burst
+
+	minComponentsForStatusEvents int // applications with fewer components record no status events. 0 to record for all
+
+	resyncPeriod time.Duration // period at which informers re-deliver all objects as updates. 0 for no resync
+
+	metricsAddr string // address to serve the metrics. Empty to disable
+
+	healthAddr string // address to serve the liveness and readiness probes. Empty to disable
+
+	orphanedApplicationsInterval time.Duration // interval between deletions of orphaned auto-created applications. 0 for none
+
+	dumpStacksOnSignal bool // dump the stacks of all goroutines on SIGINT or SIGTERM before shutting down
+
+	dryRun bool // log writes to the API server instead of making them
+
+	scopedWatchMaxNamespaces int // maximum permitted namespaces of a kind to watch one namespace at a time. 0 to watch all namespaces
+
+	deleteAttempts int // attempts to delete a resource while the API server returns a transient error
+
+	maxAncestorDepth int // levels of ancestor applications followed from a resource. 0 for no limit
+
+	statusWriteQPS   float64 // maximum status writes per second. 0 for no limit
+	statusWriteBurst int     // status writes allowed at once before the rate limit applies
+
+	resourceBatchDurations string // time to batch up changes of each resource, e.g. pods=10s. Resources not listed use batchDuration
+
+	logFormat string // format of structured log messages, text or json
+	logFile   string // file of json log messages. Empty for stdout
+
+	enablePprof bool // serve the profiling endpoints on the HTTP endpoints
+
+	applicationVersions string // versions of the applications to watch besides the version of coreApplicationGVR, e.g. v1
 )
 
-func init() {
-	// Print stacks and exit on SIGINT
+// Return a context cancelled on SIGINT or SIGTERM, for the controller to stop
+// watching and finish the batch in progress before exiting.
+// A second signal exits immediately
+func shutdownContext() context.Context {
+	ctx, cancel := context.WithCancel(context.Background())
+	sigChan := make(chan os.Signal, 2)
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
 	go func() {
-		sigChan := make(chan os.Signal, 1)
-		signal.Notify(sigChan, syscall.SIGINT)
-		buf := make([]byte, 1<<20)
-		<-sigChan
-		stacklen := runtime.Stack(buf, true)
-		klog.Infof("=== received SIGQUIT ===\n*** goroutine dump...\n%s\n*** end\n", buf[:stacklen])
+		sig := <-sigChan
+		if dumpStacksOnSignal {
+			buf := make([]byte, 1<<20)
+			stacklen := runtime.Stack(buf, true)
+			klog.Infof("=== received %s ===\n*** goroutine dump...\n%s\n*** end\n", sig, buf[:stacklen])
+		}
+		klog.Infof("received %s, shutting down", sig)
+		cancel()
+		sig = <-sigChan
+		klog.Infof("received %s again, exiting", sig)
 		os.Exit(1)
 	}()
+	return ctx
 }
 
 func main() {
 
 	flag.Parse()
+	ctx := shutdownContext()
+	if !validNoReaderStatus(noReaderStatus) {
+		klog.Fatalf("invalid noReaderStatus %s, must be one of %s, %s, %s", noReaderStatus, noReaderStatusNormal, noReaderStatusUnknown, noReaderStatusProblem)
+	}
+	if !validDeploymentHealth(deploymentHealthSource) {
+		klog.Fatalf("invalid deploymentHealth %s, must be one of %s, %s, %s", deploymentHealthSource, deploymentHealthReplicas, deploymentHealthConditions, deploymentHealthBoth)
+	}
+	if !validHealthReaderMode(healthReaderMode) {
+		klog.Fatalf("invalid healthReaderMode %s, must be one of %s, %s", healthReaderMode, healthReaderWorstOfAll, healthReaderFirstDefinitive)
+	}
+	if statuses, err := parseTransientPhaseStatus(transientPhaseStatus); err != nil {
+		klog.Fatalf("invalid transientPhaseStatus %s: %s", transientPhaseStatus, err)
+	} else {
+		transientPhaseStatuses = statuses
+	}
+	if ttls, err := parseStatusFreshnessTTL(statusFreshnessTTL); err != nil {
+		klog.Fatalf("invalid statusFreshnessTTL %s: %s", statusFreshnessTTL, err)
+	} else {
+		statusFreshnessTTLs = ttls
+	}
+	if durations, err := parseResourceBatchDurations(resourceBatchDurations); err != nil {
+		klog.Fatalf("invalid resourceBatchDurations %s: %s", resourceBatchDurations, err)
+	} else {
+		batchDurationsByResource = durations
+	}
+	if !validLogFormat(logFormat) {
+		klog.Fatalf("invalid logFormat %s, must be one of %s, %s", logFormat, logFormatText, logFormatJSON)
+	}
+	if logOutput, err := openLogOutput(logFile); err != nil {
+		klog.Fatalf("unable to open logFile %s: %s", logFile, err)
+	} else {
+		structuredLog = newStructuredLogger(logFormat, logOutput)
+	}
+	if gvrs, err := parseApplicationVersions(applicationVersions); err != nil {
+		klog.Fatalf("invalid applicationVersions %s: %s", applicationVersions, err)
+	} else {
+		additionalApplicationGVRs = gvrs
+	}
+	serverAddrs := map[string]string{"httpAddr": httpAddr, "metricsAddr": metricsAddr, "healthAddr": healthAddr}
+	if name1, name2, conflict := conflictingServerAddrs(serverAddrs); conflict {
+		klog.Fatalf("%s %s and %s %s must be different addresses", name1, serverAddrs[name1], name2, serverAddrs[name2])
+	}
+	if enablePprof && httpAddr == "" {
+		klog.Infof("enablePprof has no effect without httpAddr")
+	}
+	if componentStatusJSONPath != "" {
+		if _, err := jsonPathValue("componentStatusJSONPath", componentStatusJSONPath, map[string]interface{}{}); err != nil {
+			klog.Fatalf("invalid componentStatusJSONPath %s: %s", componentStatusJSONPath, err)
+		}
+	}
+	if !validSelectorlessApplications(selectorlessApplications) {
+		klog.Fatalf("invalid selectorlessApplications %s, must be one of %s, %s", selectorlessApplications, selectorlessMatchNothing, selectorlessWarn)
+	}
+	if !validEventSampleRate(eventSampleRate) {
+		klog.Fatalf("invalid eventSampleRate %v, must be from 0.0 to 1.0", eventSampleRate)
+	}
+	if eventSampleRate < 1 {
+		klog.Infof("processing events of only a fraction %v of resources. The status of applications may be stale", eventSampleRate)
+	}
+
+	// probes answer, not live, while connecting to the API server
+	health := &healthState{}
+	if healthAddr != "" {
+		startHealthServer(healthAddr, health)
+	}
 
 	var cfg *rest.Config
 	var err error
@@ -85,23 +269,35 @@ func main() {
 		// running inside the Kube cluster
 		klog.Infof("starting kappnav status controler inside cluster\n")
 		apiURL = kubeAPIURL
-		cfg, err = rest.InClusterConfig()
+		err = retryWithBackoff("reading in-cluster configuration", startupRetries, startupRetryDelay, func() error {
+			cfg, err = rest.InClusterConfig()
+			return err
+		})
 		if err != nil {
 			klog.Fatal(err)
 		}
 	}
+	setUserAgent(cfg)
+	klog.Infof("effective configuration: %s\n", newControllerConfig())
 
-	kubeClient, err = kubernetes.NewForConfig(cfg)
-	if err != nil {
-		klog.Fatal(err)
-	}
-
-	var discClient = kubeClient.DiscoveryClient
+	// the API server may not be ready yet when the controller starts with the cluster
 	var dynamicClient dynamic.Interface
-	dynamicClient, err = dynamic.NewForConfig(cfg)
+	err = retryWithBackoff("connecting to the API server", startupRetries, startupRetryDelay, func() error {
+		kubeClient, err = kubernetes.NewForConfig(cfg)
+		if err != nil {
+			return err
+		}
+		dynamicClient, err = dynamic.NewForConfig(cfg)
+		if err != nil {
+			return err
+		}
+		_, err = kubeClient.DiscoveryClient.ServerVersion()
+		return err
+	})
 	if err != nil {
 		klog.Fatal(err)
 	}
+	var discClient = kubeClient.DiscoveryClient
 
 	kubeEnv := os.Getenv("KUBE_ENV")
 	klog.Info("KUBE_ENV = " + kubeEnv)
@@ -125,14 +321,45 @@ func main() {
 		}
 	}
 
-	plugin := &ControllerPlugin{dynamicClient, discClient, DefaultBatchDuration, calculateComponentStatus}
-	// resController, err := NewClusterWatcher(plugin)
-	_, err = NewClusterWatcher(plugin)
+	// record changes of application status as events of the applications
+	eventBroadcaster := record.NewBroadcaster()
+	eventLogging := eventBroadcaster.StartLogging(func(format string, args ...interface{}) {
+		if klog.V(3) {
+			klog.Infof(format, args...)
+		}
+	})
+	eventRecording := eventBroadcaster.StartRecordingToSink(&typedcorev1.EventSinkImpl{Interface: kubeClient.CoreV1().Events("")})
+	eventRecorder := eventBroadcaster.NewRecorder(scheme.Scheme, corev1.EventSource{Component: eventSourceComponent})
+
+	plugin := &ControllerPlugin{dynamicClient, discClient, batchDuration, calculateComponentStatus, resyncPeriod, dryRun, eventRecorder}
+	resController, err := NewClusterWatcher(ctx, plugin)
 	if err != nil {
 		klog.Fatal(err)
 	}
+	if resController != nil {
+		reloadConfigOnSignal(resController)
+		health.setClusterWatcher(resController)
+	}
+	if metricsAddr != "" {
+		startMetricsServer(metricsAddr)
+	}
+	if httpAddr != "" && resController != nil {
+		startHTTPServer(httpAddr, newHTTPHandler(resController))
+	}
 
-	select {}
+	<-ctx.Done()
+	if resController != nil {
+		select {
+		case <-resController.stopped:
+		case <-time.After(shutdownTimeout):
+			klog.Errorf("batch in progress not done after %s, exiting", shutdownTimeout)
+		}
+	}
+	eventRecording.Stop()
+	eventLogging.Stop()
+	stopMetricsServer()
+	klog.Infof("kappnav status controller stopped")
+	klog.Flush()
 }
 
 func printEvent(event watch.Event) {
@@ -203,6 +430,60 @@ func init() {
 	}
 	flag.StringVar(&masterURL, "master", "", "The address of the Kubernetes API server. Overrides any value in kubeconfig. Only required if out-of-cluster.")
 	flag.StringVar(&apiURL, "apiURL", "", "The address of the kAppNav API server.")
+	flag.BoolVar(&emitStatusConditions, "statusConditions", false, "Also write kappnav status as a condition in status.conditions, in addition to the kappnav.status annotations.")
+	flag.StringVar(&statusConditionType, "statusConditionType", defaultStatusConditionType, "The type of the condition written for kappnav status.")
+	flag.DurationVar(&requeueBaseDelay, "requeueBaseDelay", DefaultRequeueBaseDelay, "Delay before the first retry of an object that failed to process. Doubles with each consecutive failure.")
+	flag.DurationVar(&requeueMaxDelay, "requeueMaxDelay", DefaultRequeueMaxDelay, "Maximum delay between retries of an object that keeps failing.")
+	flag.StringVar(&httpAddr, "httpAddr", "", "The address to serve the HTTP endpoints, e.g. :8082. Must differ from metricsAddr and healthAddr. Empty to disable.")
+	flag.DurationVar(&deletionGracePeriod, "deletionGracePeriod", 0, "Time a deleted component still counts with its last status toward the status of its applications, giving replacements time to appear.")
+	flag.DurationVar(&batchDuration, "batchDuration", DefaultBatchDuration, "Time to batch up changes before computing status. Each application is computed at most once per batch.")
+	flag.StringVar(&resourceBatchDurations, "resourceBatchDurations", "", "Comma separated times, per resource.group, e.g. pods=10s,applications.app.k8s.io=500ms, to batch up changes of the resource, in any version, instead of batchDuration. A batch is computed once the shortest time of the changes it holds expires.")
+	flag.StringVar(&logFormat, "logFormat", logFormatText, "Format of the structured messages of the batch handlers: text, through klog, or json, one object per line with fields such as key, gvr, namespace and name. Json messages go to logFile, apart from klog's text on stderr; other messages stay klog text.")
+	flag.StringVar(&logFile, "logFile", "", "File the json messages of logFormat json are appended to. Empty for stdout.")
+	flag.StringVar(&applicationVersions, "applicationVersions", "", "Comma separated versions of the "+coreApplicationGVR.GroupResource().String()+" applications to watch besides "+coreApplicationGVR.Version+", e.g. v1, while both versions are served during a migration of the Application CRD. Applications are treated the same whatever their version.")
+	flag.BoolVar(&enablePprof, "enablePprof", false, "Serve the runtime profiling endpoints under "+pprofPathPrefix+" on httpAddr. Off by default, as profiles expose the internals of the controller.")
+	flag.BoolVar(&actionConfigMapsInkAppNavNamespace, "actionConfigMapsInKAppNavNamespace", false, "Create action configmaps in the kappnav namespace instead of the namespace of the component, for installs that can only write to the kappnav namespace.")
+	flag.IntVar(&statusWritesPerNamespace, "statusWritesPerNamespace", 0, "Maximum concurrent status writes in each namespace. Writes to different namespaces proceed in parallel. 0 to write one status at a time.")
+	flag.BoolVar(&actionConfigMapsOptIn, "actionConfigMapsOptIn", false, "Create action configmaps only for Deployments annotated with "+kappnavEnableActions+"=true, instead of for all Liberty Deployments.")
+	flag.BoolVar(&parentRequeueOnStatusChangeOnly, "parentRequeueOnStatusChangeOnly", true, "Recompute the parent applications of an updated application only if its labels or overall status changed. False to recompute them on any change.")
+	flag.Float64Var(&statusWriteQPS, "statusWriteQPS", 0, "Maximum status writes per second to the API server, across all namespaces, to cap the write pressure of mass rollouts. Applications are still computed at most once per batch. 0 for no limit.")
+	flag.IntVar(&statusWriteBurst, "statusWriteBurst", DefaultStatusWriteBurst, "Status writes allowed at once before statusWriteQPS applies.")
+	flag.IntVar(&maxAncestorDepth, "maxAncestorDepth", DefaultMaxAncestorDepth, "Maximum levels of ancestor applications followed from a changed resource. Deeper ancestors are not recomputed, and a warning with the path is logged, in case mislabeled applications make a runaway hierarchy. 0 for no limit.")
+	flag.IntVar(&deleteAttempts, "deleteAttempts", DefaultDeleteAttempts, "Attempts to delete a resource, e.g. an orphaned auto-created application, while the API server returns a conflict, a server timeout, or too many requests. The delay between attempts starts at "+deleteRetryDelay.String()+" and doubles with each retry. 1 to not retry.")
+	flag.IntVar(&scopedWatchMaxNamespaces, "scopedWatchMaxNamespaces", 0, "Maximum number of permitted namespaces of a component kind for the kind to be watched one namespace at a time instead of in all namespaces, to not cache the resources of namespaces whose events are not processed. Applications, Deployments and StatefulSets are always watched in all namespaces. 0 to watch all kinds in all namespaces.")
+	flag.BoolVar(&dryRun, "dryRun", false, "Log the status, auto-created applications and action configmaps the controller would write instead of writing them. Resources are watched and status computed as usual.")
+	flag.BoolVar(&dumpStacksOnSignal, "dumpStacksOnSignal", false, "Log the stacks of all goroutines on SIGINT or SIGTERM before shutting down, to debug a hung controller.")
+	flag.DurationVar(&orphanedApplicationsInterval, "orphanedApplicationsInterval", DefaultOrphanedApplicationsInterval, "Interval between deletions of auto-created applications whose Deployment, StatefulSet or DeploymentConfig no longer exists. Applications not labeled "+labelAutoCreate+"=true are never deleted. 0 to only delete them when the Application CRD is added.")
+	flag.StringVar(&healthAddr, "healthAddr", DefaultHealthAddr, "The address to serve the liveness probe on "+healthzPath+" and the readiness probe on "+readyzPath+". Empty to disable.")
+	flag.StringVar(&metricsAddr, "metricsAddr", DefaultMetricsAddr, "The address to serve the metrics on "+metricsPath+" in Prometheus text format. Empty to disable.")
+	flag.DurationVar(&resyncPeriod, "resyncPeriod", DefaultResyncPeriod, "Period at which the informers re-deliver every watched object as an update, to recover from events missed e.g. while the API server restarts. Each resync recomputes the status of the applications of every object. 0 for no resync.")
+	flag.IntVar(&minComponentsForStatusEvents, "minComponentsForStatusEvents", 0, "Minimum number of components of an application for events to be recorded when its status changes. The status of smaller applications is still computed, cached, written and rolled up into their parents. 0 to record events for all applications.")
+	flag.IntVar(&relistBurstThreshold, "relistBurstThreshold", DefaultRelistBurstThreshold, "Updates of a kind that do not change the resource version, as delivered when the informer relists after its watch expires, within relistBurstWindow that make a relist burst. The rest of the burst is not processed one by one, and all applications are reconciled once it is over. 0 to process all updates.")
+	flag.DurationVar(&relistBurstWindow, "relistBurstWindow", DefaultRelistBurstWindow, "Time without updates that do not change the resource version that ends a relist burst.")
+	flag.StringVar(&selectorlessApplications, "selectorlessApplications", defaultSelectorlessApplications, "What to do with applications whose selector is missing or has neither matchLabels nor matchExpressions, and that list no components, which therefore have no components: matchNothing to silently match nothing, or warn to also record a "+selectorSpecifiedCondition+" condition on the application.")
+	flag.BoolVar(&selectorMetrics, "selectorMetrics", false, "Count evaluations of matchLabels and matchExpressions of application selectors, and how many matched, in the labels_match_* and expressions_match_* metrics, to measure the selectivity of selectors. Adds overhead to every evaluation.")
+	flag.StringVar(&statusFreshnessTTL, "statusFreshnessTTL", "", "Comma separated times, per kind, e.g. Deployment=5m,StatefulSet=10m, the kappnav status updated by an external agent is trusted after the time in its "+kappnavStatusTimestamp+" annotation. Older statuses are Unknown. Empty for no TTL.")
+	flag.IntVar(&startupRetries, "startupRetries", DefaultStartupRetries, "Attempts to connect to the API server at startup before exiting, for when it is not ready yet, e.g. during cluster bootstrap. 1 to not retry.")
+	flag.DurationVar(&startupRetryDelay, "startupRetryDelay", DefaultStartupRetryDelay, "Delay before the first retry to connect to the API server at startup. Doubles with each retry.")
+	flag.StringVar(&componentStatusJSONPath, "componentStatusJSONPath", "", "JSONPath, e.g. .health.value, of the status within the kappnav status of components whose value is a JSON document. Bare values are used as is. Empty to use the whole value as the status.")
+	flag.IntVar(&maxBatchSize, "maxBatchSize", 0, "Number of applications and resources at which a batch is processed before the end of the batch duration, to bound memory on a large influx of changes such as a relist. 0 for no limit.")
+	flag.DurationVar(&startupWarmup, "startupWarmup", 0, "Time to only batch changes at startup, after which all applications are computed in one batch, child applications before their parents, so that parents do not roll up statuses of children not computed yet. 0 for no warmup.")
+	flag.StringVar(&transientPhaseStatus, "transientPhaseStatus", "", "Comma separated statuses of resources in a transient phase, per kind, e.g. Pod:Pending=Warning,PersistentVolumeClaim:Pending=Unknown. The status is one of Normal, Warning, Problem, or Unknown. Empty to read the status of all phases from the health readers.")
+	flag.StringVar(&healthReaderMode, "healthReaderMode", defaultHealthReaderMode, "How the statuses of the health readers of a component are combined: worstOfAll to evaluate all readers and take the worst status, or firstDefinitive to take the first status other than Unknown in order of priority.")
+	flag.DurationVar(&heartbeatInterval, "heartbeatInterval", DefaultHeartbeatInterval, "Interval between heartbeats logged and counted in the heartbeats_total metric, with the number of events processed since the last one, whether or not events arrive. 0 for no heartbeat.")
+	flag.Float64Var(&eventSampleRate, "eventSampleRate", DefaultEventSampleRate, "Fraction, from 0.0 to 1.0, of non-application resources whose events are processed, chosen by a hash of their key, for load testing. Applications are only recomputed when a sampled component changes, so their status may be stale. 1.0 to process all events.")
+	flag.IntVar(&maxStatusConditions, "maxStatusConditions", DefaultMaxStatusConditions, "Number of conditions of the types written by the controller kept in status.conditions of a resource. Setting a condition beyond the limit drops those set the longest ago. Conditions written by others are always kept. 0 for no limit.")
+	flag.BoolVar(&ignoreManagedConfigMaps, "ignoreManagedConfigMaps", true, "Ignore add and update events for configmaps labeled "+labelManagedBy+"="+managedByKAppNav+", so that action configmaps written by the controller do not trigger status processing. Deletions are still processed.")
+	flag.StringVar(&deploymentHealthSource, "deploymentHealth", defaultDeploymentHealth, "What the health of a Deployment without status from the kAppNav API server is read from: replicas for available against desired replicas, conditions for the Available and Progressing conditions, or both for the worst of the two.")
+	flag.IntVar(&parsedResourceCacheSize, "parsedResourceCacheSize", DefaultParsedResourceCacheSize, "Number of parsed resources kept while processing a batch, so that a component of several applications is parsed once per batch. 0 to not cache.")
+	flag.DurationVar(&apiHealthCheckInterval, "apiHealthCheckInterval", DefaultAPIHealthCheckInterval, "Interval between checks of the availability of the API server. Status processing is paused while it is unavailable. 0 to not check.")
+	flag.StringVar(&statusAnnotation, "statusAnnotation", kappnavStatusValue, "The annotation the computed status is written to, for consumers expecting a different key.")
+	flag.IntVar(&actionConfigMapFailureThreshold, "actionConfigMapFailureThreshold", defaultBreakerFailureThreshold, "Consecutive failures to create action configmaps in a namespace before creation is suppressed there for a cooldown, as recorded by the "+actionConfigMapCreationCondition+" condition of applications. 0 to never suppress.")
+	flag.DurationVar(&actionConfigMapCooldown, "actionConfigMapCooldown", defaultBreakerCooldown, "Time creation of action configmaps stays suppressed in a namespace before it is retried.")
+	flag.IntVar(&handlerWorkers, "handlerWorkers", 0, "Number of workers calling event handlers, shared by all watched kinds, to bound the CPU used to process events. 0 for one worker per watched kind.")
+	flag.StringVar(&noReaderStatus, "noReaderStatus", defaultNoReaderStatus, "Status of components whose kind no health reader recognizes: Normal to assume healthy, Unknown to not count them, or Problem.")
+	flag.StringVar(&deniedAPIGroups, "deniedAPIGroups", "", "Comma separated API groups, e.g. rbac.authorization.k8s.io, whose resources are never watched even if an application references them. Empty to allow all groups.")
+	flag.BoolVar(&statusSnapshot, "statusSnapshot", false, "Compute the status of each application from a copy of its components taken at the start of the computation, for consistent results while caches change. Uses more memory.")
 
 	// init falgs for klog
 	klog.InitFlags(nil)
@@ -17,6 +17,7 @@ limitations under the License.
 package main
 
 import (
+	"context"
 	"fmt"
 	"math/rand"
 	"testing"
@@ -194,8 +195,8 @@ func createClusterWatcher(resources []resourceID, testActions *testActions, fail
 	}
 
 	plugin := &ControllerPlugin{
-		dynClient, fakeDiscovery, BatchDuration, newComponentStatusFunc(testActions, failureRate)}
-	resController, err := NewClusterWatcher(plugin)
+		dynClient, fakeDiscovery, BatchDuration, newComponentStatusFunc(testActions, failureRate), 0, false, nil}
+	resController, err := NewClusterWatcher(context.Background(), plugin)
 	if err != nil {
 		if klog.V(3) {
 			klog.Infof("createClusterWatcher Error calling NewClusterWatcher: %s", err)
@@ -0,0 +1,169 @@
+/*
+Copyright 2019 IBM Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+/*
+ Metrics kept by the controller, in a registry of its own rather than the
+ default registry of the Prometheus client library, so that only they are
+ served on /metrics.
+*/
+
+// prefix of the names of all metrics
+const metricsPrefix = "kappnav_controller_"
+
+var (
+	// buckets of the number of resources in a batch
+	batchSizeBuckets = []float64{1, 5, 10, 50, 100, 500, 1000, 5000}
+
+	// buckets of durations in seconds
+	durationBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+)
+
+var (
+	// all metrics of the controller
+	controllerMetrics = prometheus.NewRegistry()
+
+	// number of status updates waiting in the retry queue
+	statusRetryQueueDepth = newGauge("status_retry_queue_depth",
+		"Number of status updates waiting to be retried")
+	// number of status updates dropped from the retry queue
+	statusRetryQueueDropped = newCounter("status_retry_queue_dropped_total",
+		"Number of status updates dropped because the retry queue is full")
+	// number of times the status of an application is computed from its components
+	applicationStatusComputations = newCounter("application_status_computations_total",
+		"Number of times the status of an application is computed from its components")
+	// number of times a resource is parsed
+	resourceParses = newCounter("resource_parses_total",
+		"Number of times a resource is parsed")
+	// number of events not processed due to eventSampleRate
+	eventsSampledOut = newCounter("events_sampled_out_total",
+		"Number of resource events dropped because they are not sampled at the configured event sample rate")
+	// number of events processed by the event handlers
+	eventsProcessed = newCounter("events_processed_total",
+		"Number of resource events processed by the event handlers")
+	// number of heartbeats, advancing even without events while the controller is alive
+	heartbeats = newCounter("heartbeats_total",
+		"Number of periodic heartbeats of the controller")
+	// number of events processed between the last two heartbeats
+	eventsSinceHeartbeat = newGauge("events_since_last_heartbeat",
+		"Number of resource events processed between the last two heartbeats")
+	// time of the last event received for each watched GVR
+	lastEventTimestamp = newGaugeVec("last_event_timestamp_seconds",
+		"Unix time of the last event received for the resources of a GVR", "gvr")
+	// number of batches flushed before the end of the batch duration because they reached maxBatchSize
+	batchesFlushedEarly = newCounter("batches_flushed_early_total",
+		"Number of batches processed early because they reached the maximum batch size")
+	// number of evaluations of matchLabels selectors, and how many matched, with selectorMetrics
+	labelsMatchEvaluations = newCounter("labels_match_evaluations_total",
+		"Number of times matchLabels of an application selector is evaluated against the labels of a resource")
+	labelsMatchMatches = newCounter("labels_match_matches_total",
+		"Number of evaluations of matchLabels of an application selector that matched")
+	// number of evaluations of matchExpressions selectors, and how many matched, with selectorMetrics
+	expressionsMatchEvaluations = newCounter("expressions_match_evaluations_total",
+		"Number of times matchExpressions of an application selector is evaluated against the labels of a resource")
+	expressionsMatchMatches = newCounter("expressions_match_matches_total",
+		"Number of evaluations of matchExpressions of an application selector that matched")
+	// number of relist bursts coalesced into one reconcile of all applications
+	relistBurstsCoalesced = newCounter("relist_bursts_coalesced_total",
+		"Number of bursts of unchanged updates after a relist coalesced into one reconcile of all applications")
+	// number of status changes of applications recording no event because they have too few components
+	statusEventsSuppressed = newCounter("status_events_suppressed_total",
+		"Number of status changes of applications with fewer than minComponentsForStatusEvents components that recorded no event")
+	// number of events received by the batch handlers, by GVR
+	eventsReceived = newCounterVec("events_received_total",
+		"Number of resource events received by the batch handlers", "gvr")
+	// number of applications queued by the batch handlers to recalculate their status
+	applicationsQueued = newCounter("applications_queued_total",
+		"Number of applications queued by the batch handlers to recalculate their status")
+	// number of applications and resources in each batch processed
+	batchSize = newHistogram("batch_size",
+		"Number of applications and resources in each batch processed", batchSizeBuckets)
+	// time to read the status of a component from the status API
+	componentStatusDuration = newHistogram("component_status_duration_seconds",
+		"Time in seconds to read the status of a component from the status API", durationBuckets)
+	// number of auto-created applications deleted because their resource no longer exists
+	orphanedApplicationsDeleted = newCounter("orphaned_applications_deleted_total",
+		"Number of auto-created applications deleted because the resource they were created from no longer exists")
+	// number of writes logged instead of made in dry-run mode
+	dryRunWritesSkipped = newCounter("dry_run_writes_skipped_total",
+		"Number of writes to the API server logged instead of made in dry-run mode")
+	// number of searches for ancestor applications stopped at the maximum depth
+	ancestorDepthExceeded = newCounter("ancestor_depth_exceeded_total",
+		"Number of searches for the ancestor applications of a resource stopped at the maximum depth")
+	// number of GVRs no longer watched because no application references them
+	watchesStopped = newCounter("watches_stopped_total",
+		"Number of GVRs no longer watched because no application includes their kind")
+	// number of status writes delayed by the rate limit
+	statusWritesThrottled = newCounter("status_writes_throttled_total",
+		"Number of status writes delayed by the statusWriteQPS rate limit")
+
+	// whether the API server is available. Status processing is paused while it is not
+	apiServerAvailable = newGauge("api_server_available",
+		"1 if the API server is available, 0 if it is not and status processing is paused")
+	// state of the circuit breaker of action configmap creation in each namespace
+	actionConfigMapBreakerState = newGaugeVec("action_configmap_breaker_state",
+		"State of the circuit breaker of action configmap creation: 0 closed, 1 open, 2 half-open", "namespace")
+)
+
+// Create and register a new counter. Panics on duplicate names
+func newCounter(name string, help string) prometheus.Counter {
+	counter := prometheus.NewCounter(prometheus.CounterOpts{Name: metricsPrefix + name, Help: help})
+	controllerMetrics.MustRegister(counter)
+	return counter
+}
+
+// Create and register a new gauge. Panics on duplicate names
+func newGauge(name string, help string) prometheus.Gauge {
+	gauge := prometheus.NewGauge(prometheus.GaugeOpts{Name: metricsPrefix + name, Help: help})
+	controllerMetrics.MustRegister(gauge)
+	return gauge
+}
+
+// Create and register a new counter with one value for each value of the label
+func newCounterVec(name string, help string, label string) *prometheus.CounterVec {
+	counterVec := prometheus.NewCounterVec(prometheus.CounterOpts{Name: metricsPrefix + name, Help: help}, []string{label})
+	controllerMetrics.MustRegister(counterVec)
+	return counterVec
+}
+
+// Create and register a new gauge with one value for each value of the label
+func newGaugeVec(name string, help string, label string) *prometheus.GaugeVec {
+	gaugeVec := prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: metricsPrefix + name, Help: help}, []string{label})
+	controllerMetrics.MustRegister(gaugeVec)
+	return gaugeVec
+}
+
+// Create and register a new histogram with the given bucket upper bounds, in increasing order
+func newHistogram(name string, help string, buckets []float64) prometheus.Histogram {
+	histogram := prometheus.NewHistogram(prometheus.HistogramOpts{Name: metricsPrefix + name, Help: help, Buckets: buckets})
+	controllerMetrics.MustRegister(histogram)
+	return histogram
+}
+
+// Return the current value of a counter
+func counterValue(counter prometheus.Counter) float64 {
+	var m dto.Metric
+	if err := counter.Write(&m); err != nil {
+		return 0
+	}
+	return m.GetCounter().GetValue()
+}
@@ -0,0 +1,91 @@
+/*
+Copyright 2019 IBM Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"k8s.io/klog"
+)
+
+const (
+	// path of GET /metrics
+	metricsPath = "/metrics"
+
+	// DefaultMetricsAddr - address to serve the metrics
+	DefaultMetricsAddr = ":8080"
+
+	// time to wait for requests in progress when stopping the metrics server
+	metricsShutdownTimeout = time.Second * 5
+)
+
+var (
+	// server of the metrics. nil if not started
+	metricsServer      *http.Server
+	metricsServerMutex sync.Mutex
+)
+
+// Handler for GET /metrics, in Prometheus text format
+func metricsHandler() http.HandlerFunc {
+	handler := promhttp.HandlerFor(controllerMetrics, promhttp.HandlerOpts{})
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", http.MethodGet)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		handler.ServeHTTP(w, r)
+	}
+}
+
+// Start serving the metrics on the given address
+func startMetricsServer(addr string) {
+	mux := http.NewServeMux()
+	mux.Handle(metricsPath, metricsHandler())
+	server := &http.Server{Addr: addr, Handler: mux}
+	metricsServerMutex.Lock()
+	metricsServer = server
+	metricsServerMutex.Unlock()
+	go func() {
+		if klog.V(2) {
+			klog.Infof("starting metrics server on %s", addr)
+		}
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			klog.Errorf("metrics server on %s stopped: %s", addr, err)
+		}
+	}()
+}
+
+// Stop the metrics server, waiting a little for requests in progress
+func stopMetricsServer() {
+	metricsServerMutex.Lock()
+	server := metricsServer
+	metricsServer = nil
+	metricsServerMutex.Unlock()
+	if server == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), metricsShutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(ctx); err != nil {
+		klog.Errorf("unable to stop metrics server: %s", err)
+	}
+}
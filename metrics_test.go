@@ -0,0 +1,187 @@
+/*
+Copyright 2019 IBM Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Test an event for a resource updates the last event timestamp of its GVR
+func TestLastEventTimestamp(t *testing.T) {
+	testName := "TestLastEventTimestamp"
+	beforeTest()
+	// kinds to check for status
+	var kindsToCheckStatus = map[string]bool{
+		APPLICATION:  true,
+		"Deployment": true,
+	}
+
+	var files = []string{
+		/* 0 */ CrdApplication,
+		/* 1 */ appProductpage,
+		/* 2 */ deploymentProcuctpageV1,
+		/* 3 */ KappnavConfigFile,
+	}
+	iteration0IDs, err := readResourceIDs(files)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	/* Iteration 0: all normal */
+	testActions := newTestActions(testName, kindsToCheckStatus)
+	var emptyIDs = []resourceID{}
+	testActions.addIteration(iteration0IDs, emptyIDs)
+
+	clusterWatcher, err := createClusterWatcher(iteration0IDs, testActions, StatusFailureRate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer clusterWatcher.shutDown()
+
+	err = testActions.transitionAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	deploymentID := iteration0IDs[2]
+	gvr, ok := clusterWatcher.getWatchGVR(deploymentID.gvr)
+	if !ok {
+		t.Fatalf("Unable to find GVR for kind %s", deploymentID.kind)
+	}
+	label := gvrLabel(gvr)
+	before := testutil.ToFloat64(lastEventTimestamp.WithLabelValues(label))
+	if before == 0 {
+		t.Fatalf("expecting last event timestamp for %s after initial list", label)
+	}
+
+	// modify the Deployment
+	time.Sleep(time.Millisecond * 10)
+	deployment, err := getResource(clusterWatcher, deploymentID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	labels := deployment.GetLabels()
+	if labels == nil {
+		labels = make(map[string]string)
+	}
+	labels["test-update"] = testName
+	deployment.SetLabels(labels)
+	intf := clusterWatcher.plugin.dynamicClient.Resource(gvr).Namespace(deploymentID.namespace)
+	if _, err = intf.Update(deployment, metav1.UpdateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	updated := false
+	for i := 0; i < 20 && !updated; i++ {
+		updated = testutil.ToFloat64(lastEventTimestamp.WithLabelValues(label)) > before
+		if !updated {
+			time.Sleep(time.Millisecond * 500)
+		}
+	}
+	if !updated {
+		t.Fatalf("expecting last event timestamp for %s to be updated after %v", label, before)
+	}
+
+	recorder := httptest.NewRecorder()
+	metricsHandler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, metricsPath, nil))
+	expected := metricsPrefix + "last_event_timestamp_seconds{gvr=\"" + label + "\"}"
+	if !strings.Contains(recorder.Body.String(), expected) {
+		t.Errorf("expecting %s in metrics output, got:\n%s", expected, recorder.Body.String())
+	}
+}
+
+// Test selector evaluations and matches are counted only with selectorMetrics
+func TestSelectorMetrics(t *testing.T) {
+	defer func(saved bool) { selectorMetrics = saved }(selectorMetrics)
+	labels := map[string]string{"app": "details", "version": "v1"}
+	expressions := []matchExpression{{key: "app", operator: OperatorIn, values: []string{"details", "ratings"}}}
+	otherExpressions := []matchExpression{{key: "app", operator: OperatorNotIn, values: []string{"details"}}}
+
+	selectorMetrics = false
+	labelsBefore, expressionsBefore := testutil.ToFloat64(labelsMatchEvaluations), testutil.ToFloat64(expressionsMatchEvaluations)
+	labelsMatch(map[string]string{"app": "details"}, labels)
+	expressionsMatch(expressions, labels)
+	if testutil.ToFloat64(labelsMatchEvaluations) != labelsBefore || testutil.ToFloat64(expressionsMatchEvaluations) != expressionsBefore {
+		t.Error("expecting no evaluations counted without selectorMetrics")
+	}
+
+	selectorMetrics = true
+	labelsBefore, expressionsBefore = testutil.ToFloat64(labelsMatchEvaluations), testutil.ToFloat64(expressionsMatchEvaluations)
+	labelsMatchesBefore, expressionsMatchesBefore := testutil.ToFloat64(labelsMatchMatches), testutil.ToFloat64(expressionsMatchMatches)
+	labelsMatch(map[string]string{"app": "details"}, labels)
+	labelsMatch(map[string]string{"app": "ratings"}, labels)
+	labelsMatch(nil, labels)
+	expressionsMatch(expressions, labels)
+	expressionsMatch(otherExpressions, labels)
+	if evaluations := testutil.ToFloat64(labelsMatchEvaluations) - labelsBefore; evaluations != 3 {
+		t.Errorf("expecting 3 labelsMatch evaluations, got %v", evaluations)
+	}
+	if matches := testutil.ToFloat64(labelsMatchMatches) - labelsMatchesBefore; matches != 1 {
+		t.Errorf("expecting 1 labelsMatch match, got %v", matches)
+	}
+	if evaluations := testutil.ToFloat64(expressionsMatchEvaluations) - expressionsBefore; evaluations != 2 {
+		t.Errorf("expecting 2 expressionsMatch evaluations, got %v", evaluations)
+	}
+	if matches := testutil.ToFloat64(expressionsMatchMatches) - expressionsMatchesBefore; matches != 1 {
+		t.Errorf("expecting 1 expressionsMatch match, got %v", matches)
+	}
+}
+
+// Test the metrics endpoint serves the counters and histograms of batch processing
+func TestMetricsHandler(t *testing.T) {
+	gvr := coreDeploymentGVR.String()
+	before := testutil.ToFloat64(eventsReceived.WithLabelValues(gvr))
+	eventsReceived.WithLabelValues(gvr).Inc()
+	batchSize.Observe(3)
+	if after := testutil.ToFloat64(eventsReceived.WithLabelValues(gvr)); after != before+1 {
+		t.Errorf("expecting %v events received for %s, got %v", before+1, gvr, after)
+	}
+
+	recorder := httptest.NewRecorder()
+	metricsHandler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, metricsPath, nil))
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("expecting code %d, got %d", http.StatusOK, recorder.Code)
+	}
+	for _, prefix := range []string{
+		`kappnav_controller_events_received_total{gvr="` + gvr + `"} `,
+		"kappnav_controller_applications_queued_total ",
+		`kappnav_controller_batch_size_bucket{le="5"} `,
+		"kappnav_controller_component_status_duration_seconds_count ",
+	} {
+		if !strings.Contains(recorder.Body.String(), prefix) {
+			t.Errorf("expecting %q in metrics", prefix)
+		}
+	}
+
+	recorder = httptest.NewRecorder()
+	metricsHandler().ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, metricsPath, nil))
+	if recorder.Code != http.StatusMethodNotAllowed {
+		t.Errorf("expecting code %d for POST, got %d", http.StatusMethodNotAllowed, recorder.Code)
+	}
+
+	// stopping is safe whether or not the server is started
+	startMetricsServer("127.0.0.1:0")
+	stopMetricsServer()
+	stopMetricsServer()
+}
@@ -81,6 +81,17 @@ func (nsFilter *namespaceFilter) addNamespaceForGVR(gvr schema.GroupVersionResou
 	return true
 }
 
+// Return a copy of the namespaces permitted for a gvr
+func (nsFilter *namespaceFilter) namespacesOf(gvr schema.GroupVersionResource) map[string]string {
+	nsFilter.mutex.Lock()
+	defer nsFilter.mutex.Unlock()
+	namespaces := make(map[string]string, len(nsFilter.namespacesForGVR[gvr]))
+	for namespace := range nsFilter.namespacesForGVR[gvr] {
+		namespaces[namespace] = namespace
+	}
+	return namespaces
+}
+
 /* permitNamespace adds a new namespace for given GVR to be processed for application
    gvr: GVR for which to add namespace
    namespace: namespace to add for processing the given GVR
@@ -98,7 +109,36 @@ func (nsFilter *namespaceFilter) permitNamespace(resController *ClusterWatcher,
 		}
 		return
 	}
+	nsFilter.permitNamespaceForGVR(resController, gvr, namespace)
+}
+
+// permitApplicationNamespace adds the namespace of an application for given GVR to be processed.
+// The application's own namespace is implicitly permitted, whether or not it is
+// listed in the namespaces of this kappnav instance.
+func (nsFilter *namespaceFilter) permitApplicationNamespace(resController *ClusterWatcher, gvr schema.GroupVersionResource, namespace string) {
+	if klog.V(3) {
+		klog.Infof("permitApplicationNamespace GVR: %s, namespace: %s", gvr, namespace)
+	}
+	nsFilter.permitNamespaceForGVR(resController, gvr, namespace)
+}
 
+// seedApplicationNamespace adds the namespace of an application for a GVR that is not yet watched,
+// so that the resources of the namespace are processed as soon as the GVR is watched.
+// There are no cached resources to replay. Namespaces for GVRs already watched are added by
+// permitApplicationNamespace, which also replays the cached resources of the namespace.
+func (nsFilter *namespaceFilter) seedApplicationNamespace(resController *ClusterWatcher, gvr schema.GroupVersionResource, namespace string) {
+	if namespace == "" || resController.isWatching(gvr) {
+		return
+	}
+	if nsFilter.addNamespaceForGVR(gvr, namespace) {
+		if klog.V(3) {
+			klog.Infof("seedApplicationNamespace GVR: %s, namespace: %s", gvr, namespace)
+		}
+	}
+}
+
+// add a namespace for given GVR and replay cached objects of the GVR in the namespace
+func (nsFilter *namespaceFilter) permitNamespaceForGVR(resController *ClusterWatcher, gvr schema.GroupVersionResource, namespace string) {
 	if !resController.isNamespaced(gvr) {
 		// resource is not namespaced
 		if klog.V(3) {
@@ -117,7 +157,7 @@ func (nsFilter *namespaceFilter) permitNamespace(resController *ClusterWatcher,
 
 	if ok := nsFilter.addNamespaceForGVR(gvr, namespace); ok {
 		/* first time adding this namespace. Replay cached objects of this gvr matching this namespace */
-		if gvr == coreApplicationGVR {
+		if isApplicationGVR(gvr) {
 			/* applications already has its own handler for all namespaces */
 			if klog.V(3) {
 				klog.Infof("not replaying applications after adding namespace %s for gvr %s", namespace, gvr)
@@ -125,6 +165,9 @@ func (nsFilter *namespaceFilter) permitNamespace(resController *ClusterWatcher,
 			return
 		}
 
+		// objects of the namespace are delivered by its informer if the gvr is watched one namespace at a time
+		resController.extendScopedWatch(gvr, namespace)
+
 		var rw = resController.getResourceWatcher(gvr)
 		if rw != nil {
 			/* we are already watching the resoruce. */
@@ -142,6 +185,9 @@ func (nsFilter *namespaceFilter) permitNamespace(resController *ClusterWatcher,
 							obj:      resource,
 							oldObj:   nil,
 						}
+						if !resController.isEventSampled(&data) {
+							continue
+						}
 						if klog.V(3) {
 							klog.Infof("replaying %s after adding namespace %s for GVR %s", key, namespace, gvr)
 						}
@@ -225,7 +271,8 @@ func (nsFilter *namespaceFilter) shouldProcess(resController *ClusterWatcher, rw
 /* Main callback to process resource events */
 var namespaceFilterHandler resourceActionFunc = func(resController *ClusterWatcher, rw *ResourceWatcher, eventData *eventHandlerData) error {
 	var err error = nil
-	if resController.nsFilter.shouldProcess(resController, rw, eventData) {
+	if resController.nsFilter.shouldProcess(resController, rw, eventData) && resController.isEventSampled(eventData) &&
+		!resController.relistBursts.coalesce(eventData) {
 		err = batchResourceHandler(resController, rw, eventData)
 	}
 	return err
@@ -0,0 +1,64 @@
+/*
+Copyright 2019 IBM Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"sync"
+)
+
+// Limits the number of concurrent operations in each namespace, while
+// operations in different namespaces proceed independently
+type namespaceSemaphore struct {
+	size  int                      // maximum concurrent operations per namespace. 0 for no limit
+	slots map[string]chan struct{} // namespace to its slots in use
+	mutex sync.Mutex
+}
+
+func newNamespaceSemaphore(size int) *namespaceSemaphore {
+	return &namespaceSemaphore{
+		size:  size,
+		slots: make(map[string]chan struct{}),
+	}
+}
+
+// Return the slots of a namespace
+func (sem *namespaceSemaphore) namespaceSlots(namespace string) chan struct{} {
+	sem.mutex.Lock()
+	defer sem.mutex.Unlock()
+	slots, ok := sem.slots[namespace]
+	if !ok {
+		slots = make(chan struct{}, sem.size)
+		sem.slots[namespace] = slots
+	}
+	return slots
+}
+
+// Wait until an operation may start in the namespace
+func (sem *namespaceSemaphore) acquire(namespace string) {
+	if sem == nil || sem.size <= 0 {
+		return
+	}
+	sem.namespaceSlots(namespace) <- struct{}{}
+}
+
+// Mark the end of an operation in the namespace
+func (sem *namespaceSemaphore) release(namespace string) {
+	if sem == nil || sem.size <= 0 {
+		return
+	}
+	<-sem.namespaceSlots(namespace)
+}
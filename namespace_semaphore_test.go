@@ -0,0 +1,86 @@
+/*
+Copyright 2019 IBM Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+// Test concurrent operations stay within the bound in each namespace, while namespaces proceed in parallel
+func TestNamespaceSemaphore(t *testing.T) {
+	const size = 2
+	sem := newNamespaceSemaphore(size)
+	namespaces := []string{"ns1", "ns2", "ns3"}
+
+	var mutex sync.Mutex
+	active := make(map[string]int)
+	maxActive := make(map[string]int)
+	totalActive, maxTotalActive := 0, 0
+
+	var wg sync.WaitGroup
+	for _, namespace := range namespaces {
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func(namespace string) {
+				defer wg.Done()
+				sem.acquire(namespace)
+				defer sem.release(namespace)
+
+				mutex.Lock()
+				active[namespace]++
+				totalActive++
+				if active[namespace] > maxActive[namespace] {
+					maxActive[namespace] = active[namespace]
+				}
+				if totalActive > maxTotalActive {
+					maxTotalActive = totalActive
+				}
+				mutex.Unlock()
+
+				time.Sleep(time.Millisecond * 20)
+
+				mutex.Lock()
+				active[namespace]--
+				totalActive--
+				mutex.Unlock()
+			}(namespace)
+		}
+	}
+	wg.Wait()
+
+	for _, namespace := range namespaces {
+		if maxActive[namespace] > size {
+			t.Errorf("expecting at most %d concurrent operations in %s, got %d", size, namespace, maxActive[namespace])
+		}
+	}
+	if maxTotalActive <= size {
+		t.Errorf("expecting namespaces to proceed in parallel, got at most %d concurrent operations overall", maxTotalActive)
+	}
+}
+
+// Test a size of 0 does not limit concurrency
+func TestNamespaceSemaphoreUnlimited(t *testing.T) {
+	sem := newNamespaceSemaphore(0)
+	for i := 0; i < 10; i++ {
+		sem.acquire("ns1")
+	}
+	for i := 0; i < 10; i++ {
+		sem.release("ns1")
+	}
+}
@@ -0,0 +1,70 @@
+/*
+Copyright 2019 IBM Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"math"
+	"strconv"
+	"strings"
+
+	"k8s.io/klog"
+)
+
+/*
+ Components of an application spanning namespaces may not matter equally,
+ e.g. those in a production namespace more than those in staging. An
+ application annotated with kappnav.io/namespace-weights, e.g. "prod=3,staging=1",
+ weighs each component by the weight of its namespace, times its own
+ kappnav.io/status-weight, in the availability of the application.
+*/
+
+// Parse namespace weights of the form namespace=weight,namespace=weight.
+// Entries that are not a namespace and a non-negative number are ignored
+func parseNamespaceWeights(value string) map[string]float64 {
+	weights := make(map[string]float64)
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		parts := strings.SplitN(entry, "=", 2)
+		var weight float64
+		var err error
+		if len(parts) == 2 {
+			weight, err = strconv.ParseFloat(strings.TrimSpace(parts[1]), 64)
+		}
+		namespace := strings.TrimSpace(parts[0])
+		if len(parts) != 2 || namespace == "" || err != nil || weight < 0 || math.IsNaN(weight) || math.IsInf(weight, 0) {
+			if klog.V(2) {
+				klog.Infof("ignoring invalid %s entry %q", kappnavNamespaceWeights, entry)
+			}
+			continue
+		}
+		weights[namespace] = weight
+	}
+	return weights
+}
+
+// Return the weight of a component in the availability of the application:
+// its own weight times the weight of its namespace, 1 for namespaces without weight
+func (appResource *appResourceInfo) componentWeight(resInfo *resourceInfo) float64 {
+	weight := resInfo.statusWeight()
+	if namespaceWeight, ok := appResource.namespaceWeights[resInfo.namespace]; ok {
+		weight *= namespaceWeight
+	}
+	return weight
+}
@@ -0,0 +1,86 @@
+/*
+Copyright 2019 IBM Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"testing"
+)
+
+func TestParseNamespaceWeights(t *testing.T) {
+	var testData = []struct {
+		annotation string
+		expected   map[string]float64
+	}{
+		{"", map[string]float64{}},
+		{"prod=3", map[string]float64{"prod": 3}},
+		{" prod = 3 , staging=0.5 ", map[string]float64{"prod": 3, "staging": 0.5}},
+		{"prod=3,staging=-1,dev=heavy,=2,test", map[string]float64{"prod": 3}},
+	}
+	for _, data := range testData {
+		weights := parseNamespaceWeights(data.annotation)
+		if len(weights) != len(data.expected) {
+			t.Errorf("annotation %q: expecting %v, got %v", data.annotation, data.expected, weights)
+			continue
+		}
+		for namespace, weight := range data.expected {
+			if weights[namespace] != weight {
+				t.Errorf("annotation %q: expecting weight %v for %s, got %v", data.annotation, weight, namespace, weights[namespace])
+			}
+		}
+	}
+}
+
+// Test components in a namespace of higher weight count more in the availability,
+// combined with their own weight
+func TestNamespaceWeightedAvailability(t *testing.T) {
+	appInfo := &appResourceInfo{namespaceWeights: parseNamespaceWeights("prod=3,staging=1")}
+	component := func(namespace string, weight string) *resourceInfo {
+		resInfo := &resourceInfo{namespace: namespace, metadata: map[string]interface{}{}}
+		if weight != "" {
+			resInfo.metadata[ANNOTATIONS] = map[string]interface{}{kappnavStatusWeight: weight}
+		}
+		return resInfo
+	}
+
+	var testData = []struct {
+		prodStatus    string
+		stagingStatus string
+		prodWeight    string // own weight of the prod component
+		expected      string
+	}{
+		{Normal, warning, "", "75.0"},
+		{warning, Normal, "", "25.0"},
+		{warning, Normal, "0", "100.0"},
+		{Normal, warning, "2", "85.7"},
+	}
+	for _, data := range testData {
+		checker := newStatusChecker([]string{problem, warning, Normal, unknown}, unknown)
+		checker.addWeightedStatus(data.prodStatus, appInfo.componentWeight(component("prod", data.prodWeight)))
+		checker.addWeightedStatus(data.stagingStatus, appInfo.componentWeight(component("staging", "")))
+		if availability := checker.availability(); availability != data.expected {
+			t.Errorf("prod %s with weight %q, staging %s: expecting availability %s, got %s", data.prodStatus, data.prodWeight, data.stagingStatus, data.expected, availability)
+		}
+	}
+
+	// namespaces without weight, and applications without the annotation, weigh 1
+	if weight := appInfo.componentWeight(component("dev", "")); weight != 1 {
+		t.Errorf("expecting weight 1 for a namespace without weight, got %v", weight)
+	}
+	if weight := (&appResourceInfo{}).componentWeight(component("prod", "2")); weight != 2 {
+		t.Errorf("expecting own weight 2 without namespace weights, got %v", weight)
+	}
+}
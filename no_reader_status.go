@@ -0,0 +1,77 @@
+/*
+Copyright 2019 IBM Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"strings"
+	"time"
+
+	"k8s.io/klog"
+)
+
+// Settings of the noReaderStatus flag: status of a component when no health reader recognizes its kind
+const (
+	noReaderStatusNormal  = "Normal"  // assume healthy
+	noReaderStatusUnknown = "Unknown" // do not count toward the status of applications
+	noReaderStatusProblem = "Problem" // report as a problem
+
+	defaultNoReaderStatus = noReaderStatusUnknown
+)
+
+// Return true if the value is a valid setting of the noReaderStatus flag
+func validNoReaderStatus(value string) bool {
+	switch strings.Title(strings.ToLower(value)) {
+	case noReaderStatusNormal, noReaderStatusUnknown, noReaderStatusProblem:
+		return true
+	}
+	return false
+}
+
+// Return the status of a component whose kind is not recognized by any health reader
+func (resController *ClusterWatcher) statusWithoutReader() string {
+	switch strings.Title(strings.ToLower(noReaderStatus)) {
+	case noReaderStatusNormal:
+		return statusNormal
+	case noReaderStatusProblem:
+		return statusProblem
+	default:
+		return resController.getUnknownStatus()
+	}
+}
+
+// Read the status of a component: Unknown for components in maintenance or whose status is stale,
+// otherwise from the registered health readers. An empty status means no health reader recognizes the kind, and the
+// configured noReaderStatus is returned instead.
+func (resController *ClusterWatcher) readComponentStatus(resInfo *resourceInfo) (status string, flyover string, flyoverNLS string, err error) {
+	if resInfo.inMaintenance() {
+		// paused on purpose. Its health is not meaningful
+		return resController.getUnknownStatus(), "In maintenance", "", nil
+	}
+	if stale, flyover := isStatusStale(resInfo, time.Now()); stale {
+		// external agent stopped updating the status. It is no longer trusted
+		return resController.getUnknownStatus(), flyover, "", nil
+	}
+	resController.requeueWhenStatusExpires(resInfo)
+	status, flyover, flyoverNLS, err = resController.readHealth(resInfo)
+	if err == nil && status == "" {
+		status = resController.statusWithoutReader()
+		if klog.V(4) {
+			klog.Infof("readComponentStatus no health reader for %s %s %s, using status %s", resInfo.kind, resInfo.namespace, resInfo.name, status)
+		}
+	}
+	return status, flyover, flyoverNLS, err
+}
@@ -0,0 +1,74 @@
+/*
+Copyright 2019 IBM Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"testing"
+)
+
+// status function with no health reader for any kind
+func noReaderStatusFunc(destURL string, resInfo *resourceInfo) (string, string, string, error) {
+	return "", "", "", nil
+}
+
+func TestNoReaderStatus(t *testing.T) {
+	savedNoReaderStatus := noReaderStatus
+	defer func() {
+		noReaderStatus = savedNoReaderStatus
+	}()
+
+	var resController = &ClusterWatcher{
+		plugin:           &ControllerPlugin{statusFunc: noReaderStatusFunc},
+		statusPrecedence: []string{"Red Alert", problem, warning, "Unknown", Normal},
+		unknownStatus:    "Unknown",
+	}
+	var tests = []struct {
+		setting  string
+		expected string
+	}{
+		{"Normal", Normal},
+		{"Unknown", "Unknown"},
+		{"Problem", problem},
+		{"problem", problem},
+	}
+	for _, test := range tests {
+		noReaderStatus = test.setting
+		if !validNoReaderStatus(test.setting) {
+			t.Errorf("expecting %s to be a valid setting", test.setting)
+		}
+
+		// unrecognized kind
+		resInfo := &resourceInfo{kind: "Widget", namespace: "default", name: "widget1"}
+		toFetch := map[string]*resourceInfo{resInfo.key(): resInfo}
+		hasStatus := make(map[string]*resourceInfo)
+		toChange := make(map[string]*resourceInfo)
+		stat, err := processOneResource(resController, resInfo, hasStatus, toFetch, toChange)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if stat != test.expected {
+			t.Errorf("noReaderStatus %s: expecting status %s, got %s", test.setting, test.expected, stat)
+		}
+		if changed, ok := toChange[resInfo.key()]; !ok || changed.kappnavStatVal != test.expected {
+			t.Errorf("noReaderStatus %s: expecting status %s to be written, got %v", test.setting, test.expected, changed)
+		}
+	}
+
+	if validNoReaderStatus("Healthy") {
+		t.Error("expecting Healthy to be an invalid setting")
+	}
+}
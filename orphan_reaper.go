@@ -0,0 +1,102 @@
+/*
+Copyright 2019 IBM Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"sync"
+	"time"
+
+	"k8s.io/klog"
+)
+
+/*
+ Auto-created applications whose resource was deleted while the controller
+ was not watching, e.g. while it was down, are not deleted by the auto-create
+ handlers. They are reaped periodically instead. Only applications labeled
+ as auto-created are considered, so user-authored applications are never
+ deleted.
+*/
+
+// DefaultOrphanedApplicationsInterval - interval between deletions of orphaned auto-created applications
+const DefaultOrphanedApplicationsInterval = time.Minute * 10
+
+// Periodic deletion of orphaned auto-created applications
+type orphanReaper struct {
+	interval time.Duration
+	reap     func() error // deletes the orphaned applications
+	stopCh   chan struct{}
+	mutex    sync.Mutex
+}
+
+// Create a reaper. Return nil if the interval is not positive, to only delete
+// orphans when the Application CRD is added
+func newOrphanReaper(interval time.Duration, reap func() error) *orphanReaper {
+	if interval <= 0 {
+		return nil
+	}
+	return &orphanReaper{
+		interval: interval,
+		reap:     reap,
+		stopCh:   make(chan struct{}),
+	}
+}
+
+// Start reaping periodically
+func (reaper *orphanReaper) start() {
+	if reaper == nil {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(reaper.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if err := reaper.reap(); err != nil {
+					klog.Errorf("Error deleting orphaned applications: %s", err)
+				}
+			case <-reaper.stopCh:
+				return
+			}
+		}
+	}()
+}
+
+// Stop reaping
+func (reaper *orphanReaper) stop() {
+	if reaper == nil {
+		return
+	}
+	reaper.mutex.Lock()
+	defer reaper.mutex.Unlock()
+	select {
+	case <-reaper.stopCh:
+	default:
+		close(reaper.stopCh)
+	}
+}
+
+// Delete orphaned auto-created applications, once applications are watched
+func reapOrphanedApplications(resController *ClusterWatcher) error {
+	if !resController.isWatching(coreApplicationGVR) {
+		if klog.V(4) {
+			klog.Infof("reapOrphanedApplications: applications not watched yet")
+		}
+		return nil
+	}
+	return deleteOrphanedAutoCreatedApplications(resController)
+}
@@ -0,0 +1,91 @@
+/*
+Copyright 2019 IBM Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestIsOrphanedAutoCreatedApplication(t *testing.T) {
+	autoCreatedLabels := map[string]string{AppAutoCreated: "true"}
+	fromAnnotations := map[string]interface{}{
+		AppAutoCreatedFromName: "details",
+		AppAutoCreatedFromKind: "Deployment",
+	}
+	tests := []struct {
+		name        string
+		labels      map[string]string
+		annotations map[string]interface{}
+		existing    bool // whether the resource the application was created from exists
+		expected    bool
+	}{
+		{"auto-created, resource deleted", autoCreatedLabels, fromAnnotations, false, true},
+		{"auto-created, resource exists", autoCreatedLabels, fromAnnotations, true, false},
+		{"user-authored", map[string]string{}, fromAnnotations, false, false},
+		{"auto-created label false", map[string]string{AppAutoCreated: "false"}, fromAnnotations, false, false},
+		{"missing from kind", autoCreatedLabels, map[string]interface{}{AppAutoCreatedFromName: "details"}, false, false},
+		{"missing from name", autoCreatedLabels, map[string]interface{}{AppAutoCreatedFromKind: "Deployment"}, false, false},
+		{"empty from name", autoCreatedLabels, map[string]interface{}{AppAutoCreatedFromName: "", AppAutoCreatedFromKind: "Deployment"}, false, false},
+	}
+	for _, test := range tests {
+		appResInfo := &appResourceInfo{}
+		appResInfo.namespace = "default"
+		appResInfo.name = "details-app"
+		appResInfo.labels = test.labels
+		appResInfo.annotations = test.annotations
+		var checked string
+		orphaned := isOrphanedAutoCreatedApplication(appResInfo, func(kind string, namespace string, name string) bool {
+			checked = kind + " " + namespace + "/" + name
+			return test.existing
+		})
+		if orphaned != test.expected {
+			t.Errorf("%s: expecting orphaned %t, got %t", test.name, test.expected, orphaned)
+		}
+		if orphaned && checked != "Deployment default/details" {
+			t.Errorf("%s: expecting existence of Deployment default/details checked, got %s", test.name, checked)
+		}
+	}
+}
+
+func TestOrphanReaper(t *testing.T) {
+	var reaped int32
+	reaper := newOrphanReaper(time.Millisecond*20, func() error {
+		atomic.AddInt32(&reaped, 1)
+		return nil
+	})
+	reaper.start()
+	time.Sleep(time.Millisecond * 100)
+	reaper.stop()
+	reaper.stop()
+	count := atomic.LoadInt32(&reaped)
+	if count == 0 {
+		t.Error("expecting orphaned applications reaped periodically")
+	}
+	time.Sleep(time.Millisecond * 60)
+	if atomic.LoadInt32(&reaped) != count {
+		t.Error("expecting no reaping once stopped")
+	}
+
+	var none = newOrphanReaper(0, nil)
+	if none != nil {
+		t.Error("expecting no reaper with an interval of 0")
+	}
+	none.start()
+	none.stop()
+}
@@ -0,0 +1,127 @@
+/*
+Copyright 2019 IBM Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+)
+
+/*
+ Errors returned when parsing a resource or an application, so callers can
+ tell apart the malformations and report them precisely. Each error has a
+ reason, one of the Err values below, and the path of the offending field.
+*/
+
+// Reasons a resource or application can not be parsed
+var (
+	// ErrMissingField - a required field, e.g. metadata.name, is missing
+	ErrMissingField = fmt.Errorf("missing field")
+	// ErrInvalidFieldType - a field does not have the expected type
+	ErrInvalidFieldType = fmt.Errorf("invalid field type")
+	// ErrMissingSpec - the application has no spec
+	ErrMissingSpec = fmt.Errorf("missing spec")
+	// ErrInvalidSelector - spec.selector of the application is malformed
+	ErrInvalidSelector = fmt.Errorf("invalid selector")
+	// ErrInvalidExcludeSelector - spec.excludeSelector of the application is malformed, and no resource is excluded
+	ErrInvalidExcludeSelector = fmt.Errorf("invalid excludeSelector")
+	// ErrInvalidComponentKinds - spec.componentKinds of the application is malformed
+	ErrInvalidComponentKinds = fmt.Errorf("invalid componentKinds")
+	// ErrInvalidComponents - spec.components of the application is malformed
+	ErrInvalidComponents = fmt.Errorf("invalid components")
+)
+
+// Condition reasons for each reason of parse errors
+var parseErrorConditionReasons = map[error]string{
+	ErrMissingField:           "MissingField",
+	ErrInvalidFieldType:       "InvalidFieldType",
+	ErrMissingSpec:            "MissingSpec",
+	ErrInvalidSelector:        "InvalidSelector",
+	ErrInvalidExcludeSelector: "InvalidExcludeSelector",
+	ErrInvalidComponentKinds:  "InvalidComponentKinds",
+	ErrInvalidComponents:      "InvalidComponents",
+}
+
+// Error parsing a resource
+type parseError struct {
+	reason error  // one of the Err values
+	field  string // path of the offending field, e.g. spec.selector.matchLabels
+	detail string // what is wrong with the field. May be empty
+}
+
+func newParseError(reason error, field string, detail string) *parseError {
+	return &parseError{reason: reason, field: field, detail: detail}
+}
+
+func (err *parseError) Error() string {
+	if err.detail == "" {
+		return fmt.Sprintf("%s: %s", err.reason, err.field)
+	}
+	return fmt.Sprintf("%s: %s: %s", err.reason, err.field, err.detail)
+}
+
+// Unwrap returns the reason of the error
+func (err *parseError) Unwrap() error {
+	return err.reason
+}
+
+// Return the reason of a parse error, or nil if the error is not a parse error
+func parseErrorReason(err error) error {
+	if parseErr, ok := err.(*parseError); ok {
+		return parseErr.reason
+	}
+	return nil
+}
+
+// Return true if the error is a parse error for the given reason
+func isParseError(err error, reason error) bool {
+	return err != nil && parseErrorReason(err) == reason
+}
+
+// Return true if the application can not be processed at all, e.g. it has no spec.
+// Other parse errors only leave out the malformed fields, and what parsed is processed
+func isFatalParseError(err error) bool {
+	return err != nil && (parseErrorReason(err) == nil || isParseError(err, ErrMissingSpec))
+}
+
+// Return the condition reason for a parse error, e.g. MissingSelector
+func parseErrorConditionReason(err error) string {
+	if reason, ok := parseErrorConditionReasons[parseErrorReason(err)]; ok {
+		return reason
+	}
+	return "ParseError"
+}
+
+const (
+	// type of the condition recording whether the spec of an application could be parsed
+	validCondition = "Valid"
+	validReason    = "Valid"
+)
+
+// Return the condition recording whether the application could be parsed, with the reason if it could not.
+// The condition is only added once a parse error occurs
+func newValidCondition(parseErr error) applicationCondition {
+	cond := &statusCondition{conditionType: validCondition}
+	if parseErr == nil {
+		cond.status = conditionTrue
+		cond.reason = validReason
+	} else {
+		cond.status = conditionFalse
+		cond.reason = parseErrorConditionReason(parseErr)
+		cond.message = parseErr.Error()
+	}
+	return applicationCondition{cond, parseErr == nil}
+}
@@ -0,0 +1,195 @@
+/*
+Copyright 2019 IBM Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// Return an application with the given spec. No spec if nil
+func appWithSpec(spec interface{}) *unstructured.Unstructured {
+	obj := map[string]interface{}{
+		"apiVersion": "app.k8s.io/v1beta1",
+		"kind":       "Application",
+		"metadata": map[string]interface{}{
+			"name":            "bad-app",
+			"namespace":       "default",
+			"resourceVersion": "1",
+		},
+	}
+	if spec != nil {
+		obj["spec"] = spec
+	}
+	return &unstructured.Unstructured{Object: obj}
+}
+
+var componentKindsSpec = []interface{}{
+	map[string]interface{}{"group": "apps", "kind": "Deployment"},
+}
+
+var parseAppErrorTestData = []struct {
+	name     string
+	spec     interface{}
+	expected error // nil if no error expected
+}{
+	{"valid", map[string]interface{}{
+		"componentKinds": componentKindsSpec,
+		"selector":       map[string]interface{}{"matchLabels": map[string]interface{}{"app": "bad"}},
+	}, nil},
+	{"missing spec", nil, ErrMissingSpec},
+	{"spec not an object", "spec", ErrMissingSpec},
+	{"no selector and no components", map[string]interface{}{
+		"componentKinds": componentKindsSpec,
+	}, nil},
+	{"excludeSelector not an object", map[string]interface{}{
+		"componentKinds":  componentKindsSpec,
+		"selector":        map[string]interface{}{"matchLabels": map[string]interface{}{"app": "bad"}},
+		"excludeSelector": "version=v2",
+	}, ErrInvalidExcludeSelector},
+	{"selector not an object", map[string]interface{}{
+		"componentKinds": componentKindsSpec,
+		"selector":       "app=bad",
+	}, ErrInvalidSelector},
+	{"matchLabels value not a string", map[string]interface{}{
+		"componentKinds": componentKindsSpec,
+		"selector":       map[string]interface{}{"matchLabels": map[string]interface{}{"app": int64(1)}},
+	}, ErrInvalidSelector},
+	{"matchExpression without key", map[string]interface{}{
+		"componentKinds": componentKindsSpec,
+		"selector": map[string]interface{}{"matchExpressions": []interface{}{
+			map[string]interface{}{"operator": "In", "values": []interface{}{"bad"}},
+		}},
+	}, ErrInvalidSelector},
+	{"matchExpression with invalid source", map[string]interface{}{
+		"componentKinds": componentKindsSpec,
+		"selector": map[string]interface{}{"matchExpressions": []interface{}{
+			map[string]interface{}{"key": "owner", "operator": "Exists", "source": "field"},
+		}},
+	}, ErrInvalidSelector},
+	{"excludeSelector matchExpression without operator", map[string]interface{}{
+		"componentKinds": componentKindsSpec,
+		"selector":       map[string]interface{}{"matchLabels": map[string]interface{}{"app": "bad"}},
+		"excludeSelector": map[string]interface{}{"matchExpressions": []interface{}{
+			map[string]interface{}{"key": "track"},
+		}},
+	}, ErrInvalidExcludeSelector},
+	{"componentKinds not a list", map[string]interface{}{
+		"componentKinds": "Deployment",
+		"selector":       map[string]interface{}{"matchLabels": map[string]interface{}{"app": "bad"}},
+	}, ErrInvalidComponentKinds},
+	{"componentKinds entry without kind", map[string]interface{}{
+		"componentKinds": []interface{}{map[string]interface{}{"group": "apps"}},
+		"selector":       map[string]interface{}{"matchLabels": map[string]interface{}{"app": "bad"}},
+	}, ErrInvalidComponentKinds},
+	{"components entry without name", map[string]interface{}{
+		"componentKinds": componentKindsSpec,
+		"components": []interface{}{
+			map[string]interface{}{"group": "apps", "kind": "Deployment"},
+		},
+	}, ErrInvalidComponents},
+}
+
+func TestParseAppResourceErrors(t *testing.T) {
+	var resController = &ClusterWatcher{}
+	initControllerMaps(resController)
+	for _, data := range parseAppErrorTestData {
+		appInfo := &appResourceInfo{}
+		err := resController.parseAppResource(appWithSpec(data.spec), appInfo)
+		if data.expected == nil {
+			if err != nil {
+				t.Errorf("%s: expecting no error, got %s", data.name, err)
+			}
+			continue
+		}
+		if !isParseError(err, data.expected) {
+			t.Errorf("%s: expecting error %q, got %v", data.name, data.expected, err)
+		}
+	}
+}
+
+func TestParseResourceErrors(t *testing.T) {
+	var resController = &ClusterWatcher{}
+	var testData = []struct {
+		name     string
+		modify   func(obj map[string]interface{})
+		expected error
+	}{
+		{"missing kind", func(obj map[string]interface{}) { delete(obj, "kind") }, ErrMissingField},
+		{"missing name", func(obj map[string]interface{}) {
+			delete(obj["metadata"].(map[string]interface{}), "name")
+		}, ErrMissingField},
+		{"label not a string", func(obj map[string]interface{}) {
+			obj["metadata"].(map[string]interface{})["labels"] = map[string]interface{}{"version": int64(2)}
+		}, ErrInvalidFieldType},
+	}
+	for _, data := range testData {
+		obj := appWithSpec(nil)
+		data.modify(obj.Object)
+		err := resController.parseResource(obj, &resourceInfo{})
+		if !isParseError(err, data.expected) {
+			t.Errorf("%s: expecting error %q, got %v", data.name, data.expected, err)
+		}
+	}
+}
+
+func TestParseErrorConditionReason(t *testing.T) {
+	var resController = &ClusterWatcher{}
+	initControllerMaps(resController)
+	err := resController.parseAppResource(appWithSpec(map[string]interface{}{"selector": "app=bad"}), &appResourceInfo{})
+	if reason := parseErrorConditionReason(err); reason != "InvalidSelector" {
+		t.Errorf("expecting condition reason InvalidSelector, got %s (%v)", reason, err)
+	}
+
+	unstructuredObj, err := readJSON(appProductpage)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = resController.parseAppResource(unstructuredObj, &appResourceInfo{}); err != nil {
+		t.Errorf("expecting %s to parse, got %s", appProductpage, err)
+	}
+}
+
+// Test only a missing spec stops an application from being processed, and what parsed is kept otherwise
+func TestFatalParseErrors(t *testing.T) {
+	var resController = &ClusterWatcher{}
+	initControllerMaps(resController)
+	for _, data := range parseAppErrorTestData {
+		appInfo := &appResourceInfo{}
+		err := resController.parseAppResource(appWithSpec(data.spec), appInfo)
+		if fatal := isFatalParseError(err); fatal != (data.expected == ErrMissingSpec) {
+			t.Errorf("%s: expecting fatal %t, got %t for %v", data.name, data.expected == ErrMissingSpec, fatal, err)
+		}
+	}
+
+	spec := map[string]interface{}{
+		"componentKinds": componentKindsSpec,
+		"selector": map[string]interface{}{"matchLabels": map[string]interface{}{
+			"app":     "good",
+			"version": int64(1),
+		}},
+	}
+	appInfo := &appResourceInfo{}
+	err := resController.parseAppResource(appWithSpec(spec), appInfo)
+	if !isParseError(err, ErrInvalidSelector) {
+		t.Fatalf("expecting error %q, got %v", ErrInvalidSelector, err)
+	}
+	if len(appInfo.componentKinds) != 1 || appInfo.matchLabels["app"] != "good" {
+		t.Errorf("expecting the valid fields to be parsed, got component kinds %v and matchLabels %v", appInfo.componentKinds, appInfo.matchLabels)
+	}
+}
@@ -0,0 +1,151 @@
+/*
+Copyright 2019 IBM Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"container/list"
+	"sync"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+/*
+ Within a batch, the same component is parsed once for each application it
+ is checked against. The parsed resources are kept in a bounded LRU while
+ the batch is processed, keyed by the object and its resourceVersion so that
+ a change to the object is parsed again. The cache is cleared at the end of
+ each batch.
+*/
+
+// DefaultParsedResourceCacheSize - number of parsed resources kept while processing a batch
+const DefaultParsedResourceCacheSize = 1024
+
+// LRU of parsed resources
+type parsedResourceCache struct {
+	capacity int                      // maximum number of parsed resources. 0 to not cache
+	active   bool                     // true while a batch is processed
+	entries  map[string]*list.Element // key to element of order
+	order    *list.List               // most recently used first. Values are *parsedResource
+	mutex    sync.Mutex
+}
+
+type parsedResource struct {
+	key     string
+	resInfo resourceInfo
+}
+
+func newParsedResourceCache(capacity int) *parsedResourceCache {
+	return &parsedResourceCache{
+		capacity: capacity,
+		entries:  make(map[string]*list.Element),
+		order:    list.New(),
+	}
+}
+
+// Start caching parsed resources for a batch
+func (cache *parsedResourceCache) begin() {
+	if cache == nil {
+		return
+	}
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+	cache.active = true
+	cache.clear()
+}
+
+// Stop caching parsed resources at the end of a batch
+func (cache *parsedResourceCache) end() {
+	if cache == nil {
+		return
+	}
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+	cache.active = false
+	cache.clear()
+}
+
+// Remove all parsed resources. Must be called with the mutex held
+func (cache *parsedResourceCache) clear() {
+	cache.entries = make(map[string]*list.Element)
+	cache.order.Init()
+}
+
+// Return the key of an object, or "" if it can not be cached
+func parsedResourceKey(unstructuredObj *unstructured.Unstructured) string {
+	resourceVersion := unstructuredObj.GetResourceVersion()
+	if resourceVersion == "" {
+		// changes can not be told apart
+		return ""
+	}
+	return unstructuredObj.GetAPIVersion() + "/" + unstructuredObj.GetKind() + "/" +
+		unstructuredObj.GetNamespace() + "/" + unstructuredObj.GetName() + "/" + resourceVersion
+}
+
+// Return a copy of the parsed resource, and true if found
+func (cache *parsedResourceCache) get(key string, resInfo *resourceInfo) bool {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+	if !cache.active {
+		return false
+	}
+	elem, ok := cache.entries[key]
+	if !ok {
+		return false
+	}
+	cache.order.MoveToFront(elem)
+	*resInfo = elem.Value.(*parsedResource).resInfo
+	return true
+}
+
+// Keep a copy of a parsed resource, evicting the least recently used if full
+func (cache *parsedResourceCache) add(key string, resInfo *resourceInfo) {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+	if !cache.active || cache.capacity <= 0 {
+		return
+	}
+	if elem, ok := cache.entries[key]; ok {
+		cache.order.MoveToFront(elem)
+		elem.Value.(*parsedResource).resInfo = *resInfo
+		return
+	}
+	cache.entries[key] = cache.order.PushFront(&parsedResource{key: key, resInfo: *resInfo})
+	if cache.order.Len() > cache.capacity {
+		oldest := cache.order.Back()
+		cache.order.Remove(oldest)
+		delete(cache.entries, oldest.Value.(*parsedResource).key)
+	}
+}
+
+// parseResourceCached parses a resource into a structure, reusing what was
+// already parsed for the same resourceVersion in the current batch.
+// Malformed resources are not cached
+func (resController *ClusterWatcher) parseResourceCached(unstructuredObj *unstructured.Unstructured, resInfo *resourceInfo) error {
+	cache := resController.parsedResources
+	key := parsedResourceKey(unstructuredObj)
+	if cache == nil || key == "" {
+		return resController.parseResource(unstructuredObj, resInfo)
+	}
+	if cache.get(key, resInfo) {
+		return nil
+	}
+	if err := resController.parseResource(unstructuredObj, resInfo); err != nil {
+		return err
+	}
+	cache.add(key, resInfo)
+	return nil
+}
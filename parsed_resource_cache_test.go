@@ -0,0 +1,125 @@
+/*
+Copyright 2019 IBM Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// read a resource with a resourceVersion
+func readVersionedJSON(t testing.TB, fileName string, resourceVersion string) *unstructured.Unstructured {
+	obj, err := readJSON(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	obj.SetResourceVersion(resourceVersion)
+	return obj
+}
+
+// parse a resource the given number of times, and return the number of times it was actually parsed
+func countParses(resController *ClusterWatcher, obj *unstructured.Unstructured, times int) float64 {
+	before := testutil.ToFloat64(resourceParses)
+	for i := 0; i < times; i++ {
+		var resInfo = &resourceInfo{}
+		resController.parseResourceCached(obj, resInfo)
+	}
+	return testutil.ToFloat64(resourceParses) - before
+}
+
+func TestParsedResourceCache(t *testing.T) {
+	resController := &ClusterWatcher{parsedResources: newParsedResourceCache(2)}
+	deployment := readVersionedJSON(t, "test_data/AB-deployment.json", "100")
+
+	// parsed every time outside of a batch
+	if parses := countParses(resController, deployment, 3); parses != 3 {
+		t.Errorf("expecting 3 parses outside of a batch, got %v", parses)
+	}
+
+	// parsed once within a batch
+	resController.parsedResources.begin()
+	if parses := countParses(resController, deployment, 3); parses != 1 {
+		t.Errorf("expecting 1 parse within a batch, got %v", parses)
+	}
+	var parsed, cached resourceInfo
+	resController.parseResource(deployment, &parsed)
+	resController.parseResourceCached(deployment, &cached)
+	if cached.key() != parsed.key() || cached.kind != parsed.kind || !sameLabels(cached.labels, parsed.labels) {
+		t.Errorf("expecting cached resource %s to be the same as parsed %s", cached.key(), parsed.key())
+	}
+
+	// parsed again once changed
+	changed := readVersionedJSON(t, "test_data/AB-deployment.json", "101")
+	if parses := countParses(resController, changed, 2); parses != 1 {
+		t.Errorf("expecting 1 parse of changed resource, got %v", parses)
+	}
+
+	// least recently used is evicted
+	service := readVersionedJSON(t, "test_data/A-service.json", "200")
+	if parses := countParses(resController, service, 1); parses != 1 {
+		t.Errorf("expecting 1 parse of new resource, got %v", parses)
+	}
+	if parses := countParses(resController, deployment, 1); parses != 1 {
+		t.Errorf("expecting evicted resource to be parsed again, got %v", parses)
+	}
+	if parses := countParses(resController, service, 1); parses != 0 {
+		t.Errorf("expecting recently used resource to be cached, got %v parses", parses)
+	}
+
+	// cleared at the end of the batch
+	resController.parsedResources.end()
+	resController.parsedResources.begin()
+	if parses := countParses(resController, service, 1); parses != 1 {
+		t.Errorf("expecting resource parsed again in the next batch, got %v", parses)
+	}
+	resController.parsedResources.end()
+
+	// not cached without resourceVersion
+	resController.parsedResources.begin()
+	defer resController.parsedResources.end()
+	unversioned := readVersionedJSON(t, "test_data/A-service.json", "")
+	if parses := countParses(resController, unversioned, 2); parses != 2 {
+		t.Errorf("expecting resource without resourceVersion parsed every time, got %v", parses)
+	}
+}
+
+// Benchmark parsing a component checked against many applications in a batch
+func BenchmarkParseResource(b *testing.B) {
+	resController := &ClusterWatcher{}
+	deployment := readVersionedJSON(b, "test_data/AB-deployment.json", "100")
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		var resInfo = &resourceInfo{}
+		resController.parseResource(deployment, resInfo)
+	}
+}
+
+func BenchmarkParseResourceCached(b *testing.B) {
+	resController := &ClusterWatcher{parsedResources: newParsedResourceCache(DefaultParsedResourceCacheSize)}
+	resController.parsedResources.begin()
+	defer resController.parsedResources.end()
+	deployment := readVersionedJSON(b, "test_data/AB-deployment.json", "100")
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		var resInfo = &resourceInfo{}
+		resController.parseResourceCached(deployment, resInfo)
+	}
+}
@@ -0,0 +1,136 @@
+/*
+Copyright 2019 IBM Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"sync"
+
+	"k8s.io/klog"
+)
+
+/*
+ An application may list a component kind that is not known yet, e.g. a
+ custom resource whose CRD is installed after the application is created.
+ The kind is kept pending for the application, and once a CRD of the kind
+ is added or changed, the component kinds of the applications waiting for
+ it are watched, and their status computed again.
+*/
+
+// Applications waiting for each component kind that is not known yet, keyed as the events of the application informer
+type pendingKinds struct {
+	apps  map[string]map[string]struct{} // kind to keys of applications
+	kinds map[string][]string            // key of application to its pending kinds
+	mutex sync.Mutex
+}
+
+func newPendingKinds() *pendingKinds {
+	return &pendingKinds{
+		apps:  make(map[string]map[string]struct{}),
+		kinds: make(map[string][]string),
+	}
+}
+
+// Record the component kinds of an application that are not known yet, replacing those recorded before
+func (pending *pendingKinds) set(key string, unresolved []groupKind) {
+	if pending == nil {
+		return
+	}
+	pending.mutex.Lock()
+	defer pending.mutex.Unlock()
+	pending.removeLocked(key)
+	if len(unresolved) == 0 {
+		return
+	}
+	kinds := make([]string, 0, len(unresolved))
+	for _, elem := range unresolved {
+		keys, ok := pending.apps[elem.kind]
+		if !ok {
+			keys = make(map[string]struct{})
+			pending.apps[elem.kind] = keys
+		}
+		keys[key] = struct{}{}
+		kinds = append(kinds, elem.kind)
+	}
+	pending.kinds[key] = kinds
+}
+
+// Remove an application. Must be called with the mutex held
+func (pending *pendingKinds) removeLocked(key string) {
+	for _, kind := range pending.kinds[key] {
+		keys := pending.apps[kind]
+		delete(keys, key)
+		if len(keys) == 0 {
+			delete(pending.apps, kind)
+		}
+	}
+	delete(pending.kinds, key)
+}
+
+// Return the keys of the applications waiting for a kind, and stop waiting for it
+func (pending *pendingKinds) take(kind string) []string {
+	if pending == nil {
+		return nil
+	}
+	pending.mutex.Lock()
+	defer pending.mutex.Unlock()
+	ret := make([]string, 0, len(pending.apps[kind]))
+	for key := range pending.apps[kind] {
+		ret = append(ret, key)
+	}
+	for _, key := range ret {
+		pending.removeLocked(key)
+	}
+	return ret
+}
+
+// Return the number of applications waiting for a kind
+func (pending *pendingKinds) waiting(kind string) int {
+	pending.mutex.Lock()
+	defer pending.mutex.Unlock()
+	return len(pending.apps[kind])
+}
+
+// Watch the component kinds of the applications waiting for a kind now known, and compute their status
+func (resController *ClusterWatcher) watchPendingKind(kind string) {
+	keys := resController.pendingKinds.take(kind)
+	if len(keys) == 0 {
+		return
+	}
+	if klog.V(2) {
+		klog.Infof("watchPendingKind kind %s now known, watching the components of applications %v", kind, keys)
+	}
+	applications := make(map[string]*resourceInfo)
+	for _, key := range keys {
+		// parsed before the kind was known
+		resController.appResources.remove(key)
+		obj, exists := resController.getApplicationByKey(key)
+		if !exists {
+			continue
+		}
+		if err := startWatchApplicationComponentKinds(resController, obj, applications); err != nil {
+			klog.Errorf("watchPendingKind unable to watch the components of application %s: %s", key, err)
+		}
+	}
+	if len(applications) == 0 {
+		return
+	}
+	applicationsQueued.Add(float64(len(applications)))
+	resController.resourceChannel.send(&batchResources{
+		applications:    applications,
+		nonApplications: make(map[string]*resourceInfo),
+	})
+}
@@ -0,0 +1,116 @@
+/*
+Copyright 2019 IBM Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+const widgetApp = "test_data/widget-app.json"
+
+func TestPendingKinds(t *testing.T) {
+	pending := newPendingKinds()
+	pending.set("default/app1", []groupKind{{group: "example.com", kind: "Widget"}, {group: "example.com", kind: "Gadget"}})
+	pending.set("default/app2", []groupKind{{group: "example.com", kind: "Widget"}})
+	if waiting := pending.waiting("Widget"); waiting != 2 {
+		t.Errorf("expecting 2 applications waiting for Widget, got %d", waiting)
+	}
+
+	// replaced when the application changes
+	pending.set("default/app2", nil)
+	if waiting := pending.waiting("Widget"); waiting != 1 {
+		t.Errorf("expecting 1 application waiting for Widget once app2 changed, got %d", waiting)
+	}
+
+	// taken once known
+	if keys := pending.take("Widget"); len(keys) != 1 || keys[0] != "default/app1" {
+		t.Errorf("expecting app1 waiting for Widget, got %v", keys)
+	}
+	if waiting := pending.waiting("Gadget"); waiting != 0 {
+		t.Errorf("expecting app1 no longer waiting for Gadget once taken, got %d", waiting)
+	}
+	if keys := pending.take("Widget"); len(keys) != 0 {
+		t.Errorf("expecting no application waiting for Widget once taken, got %v", keys)
+	}
+}
+
+// wait for a condition of the controller, checked every 100ms
+func waitFor(what string, condition func() bool) error {
+	for i := 0; i < 100; i++ {
+		if condition() {
+			return nil
+		}
+		time.Sleep(time.Millisecond * 100)
+	}
+	return fmt.Errorf("timed out waiting for %s", what)
+}
+
+// Test the components of an application created before the CRD of their kind are watched once the CRD is created
+func TestDeferredComponentKindWatch(t *testing.T) {
+	testName := "TestDeferredComponentKindWatch"
+	beforeTest()
+	var files = []string{
+		/* 0 */ CrdApplication,
+		/* 1 */ widgetApp,
+		/* 2 */ KappnavConfigFile,
+	}
+	iteration0IDs, err := readResourceIDs(files)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testActions := newTestActions(testName, map[string]bool{})
+	testActions.addIteration(iteration0IDs, []resourceID{})
+
+	clusterWatcher, err := createClusterWatcher(iteration0IDs, testActions, StatusFailureRate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer clusterWatcher.shutDown()
+	if err = testActions.transitionAll(); err != nil {
+		t.Fatal(err)
+	}
+
+	isWatched := func() bool {
+		clusterWatcher.mutex.Lock()
+		defer clusterWatcher.mutex.Unlock()
+		return clusterWatcher.gvrsToWatch[widgetGVR]
+	}
+	if err = waitFor("widget-app to wait for Widget", func() bool { return clusterWatcher.pendingKinds.waiting("Widget") == 1 }); err != nil {
+		t.Fatal(err)
+	}
+	if isWatched() {
+		t.Fatal("expecting Widgets not watched before their CRD is created")
+	}
+
+	// CRD created
+	crdIDs, err := readResourceIDs([]string{crdWidget, exampleWidget})
+	if err != nil {
+		t.Fatal(err)
+	}
+	fakeDiscovery := clusterWatcher.plugin.discoveryClient.(*fakeDiscovery)
+	if err = populateResources(crdIDs, clusterWatcher.plugin.dynamicClient, fakeDiscovery); err != nil {
+		t.Fatal(err)
+	}
+	if err = waitFor("Widgets to be watched", isWatched); err != nil {
+		t.Fatal(err)
+	}
+	if waiting := clusterWatcher.pendingKinds.waiting("Widget"); waiting != 0 {
+		t.Errorf("expecting no application waiting for Widget once its CRD is created, got %d", waiting)
+	}
+}
@@ -0,0 +1,107 @@
+/*
+Copyright 2019 IBM Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+)
+
+/*
+ The status of bare Pods, not owned by a controller, is read directly from
+ status.phase and status.containerStatuses of the Pod, rather than from the
+ kAppNav API server.
+*/
+
+const (
+	// POD - kind of Pods
+	POD = "Pod"
+
+	statusNormal  = "Normal"
+	statusWarning = "Warning"
+	statusProblem = "Problem"
+
+	podPhaseSucceeded = "Succeeded"
+	podPhaseFailed    = "Failed"
+	crashLoopBackOff  = "CrashLoopBackOff"
+)
+
+// Status of a container of a Pod
+type containerStatus struct {
+	name          string
+	ready         bool
+	waitingReason string // reason the container is waiting, e.g. CrashLoopBackOff. Empty if not waiting
+}
+
+// Status of a Pod
+type podStatus struct {
+	phase      string
+	containers []containerStatus
+}
+
+// Parse status.phase and status.containerStatuses of a Pod
+func parsePodStatus(objMap map[string]interface{}) *podStatus {
+	ret := &podStatus{}
+	status, ok := objMap[STATUS].(map[string]interface{})
+	if !ok {
+		return ret
+	}
+	ret.phase, _ = status["phase"].(string)
+	containerStatuses, _ := status["containerStatuses"].([]interface{})
+	for _, tmp := range containerStatuses {
+		container, ok := tmp.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		var cs containerStatus
+		cs.name, _ = container[NAME].(string)
+		cs.ready, _ = container["ready"].(bool)
+		if state, ok := container["state"].(map[string]interface{}); ok {
+			if waiting, ok := state["waiting"].(map[string]interface{}); ok {
+				cs.waitingReason, _ = waiting["reason"].(string)
+			}
+		}
+		ret.containers = append(ret.containers, cs)
+	}
+	return ret
+}
+
+// Return the status and flyover text of a Pod from its phase and container statuses
+func podHealth(pod *podStatus) (string, string) {
+	switch pod.phase {
+	case podPhaseSucceeded:
+		return statusNormal, "Pod completed"
+	case podPhaseFailed:
+		return statusProblem, "Pod failed"
+	}
+	if len(pod.containers) == 0 {
+		return statusProblem, fmt.Sprintf("Pod %s, no containers ready", pod.phase)
+	}
+	ready := 0
+	for _, container := range pod.containers {
+		if container.waitingReason == crashLoopBackOff {
+			return statusProblem, fmt.Sprintf("Container %s: %s", container.name, crashLoopBackOff)
+		}
+		if container.ready {
+			ready++
+		}
+	}
+	flyover := fmt.Sprintf("%d/%d containers ready", ready, len(pod.containers))
+	if ready < len(pod.containers) {
+		return statusProblem, flyover
+	}
+	return statusNormal, flyover
+}
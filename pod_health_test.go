@@ -0,0 +1,72 @@
+/*
+Copyright 2019 IBM Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	podRunning   = "test_data/pod-running.json"
+	podCrashLoop = "test_data/pod-crashloop.json"
+	podPending   = "test_data/pod-pending.json"
+)
+
+type podHealthTestData struct {
+	fileName        string
+	expectedStatus  string
+	expectedFlyover string
+}
+
+var podHealthTestDataArray = []podHealthTestData{
+	{podRunning, statusNormal, "2/2 containers ready"},
+	{podCrashLoop, statusProblem, "Container main: CrashLoopBackOff"},
+	{podPending, statusProblem, "Pod Pending, no containers ready"},
+}
+
+func TestPodHealth(t *testing.T) {
+	for _, data := range podHealthTestDataArray {
+		unstructuredObj, err := readJSON(data.fileName)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var resInfo = &resourceInfo{}
+		parseResourceBasic(unstructuredObj, resInfo)
+		if resInfo.podStatus == nil {
+			t.Errorf("expecting pod status to be parsed for bare Pod %s", data.fileName)
+			continue
+		}
+		status, flyover := podHealth(resInfo.podStatus)
+		if status != data.expectedStatus || flyover != data.expectedFlyover {
+			t.Errorf("%s: expecting status %s flyover %q, got %s %q", data.fileName, data.expectedStatus, data.expectedFlyover, status, flyover)
+		}
+	}
+
+	// Pods behind a controller get their status from the API server
+	unstructuredObj, err := readJSON(podRunning)
+	if err != nil {
+		t.Fatal(err)
+	}
+	unstructuredObj.SetOwnerReferences([]metav1.OwnerReference{{APIVersion: "apps/v1", Kind: "ReplicaSet", Name: "productpage-v1-5f4cd5d6c7", UID: "6c7e2e7a-3a7b-11e9-9d73-0800275638b6"}})
+	var resInfo = &resourceInfo{}
+	parseResourceBasic(unstructuredObj, resInfo)
+	if resInfo.podStatus != nil {
+		t.Errorf("expecting no pod status for Pod owned by a controller")
+	}
+}
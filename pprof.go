@@ -0,0 +1,50 @@
+/*
+Copyright 2019 IBM Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"net/http"
+	"net/http/pprof"
+)
+
+/*
+ Runtime profiling of the controller, served on the HTTP endpoints when
+ enabled with -enablePprof. Off by default, as profiles expose the internals
+ of the controller and cost CPU while they are collected.
+
+ GET /debug/pprof/                     index of the available profiles
+ GET /debug/pprof/profile?seconds=30   CPU profile
+ GET /debug/pprof/heap                 memory allocations of live objects
+ GET /debug/pprof/goroutine?debug=2    stacks of all goroutines
+ GET /debug/pprof/trace?seconds=5      execution trace
+ GET /debug/pprof/cmdline, /symbol     command line and symbol lookup
+
+ e.g. go tool pprof http://localhost:8082/debug/pprof/profile?seconds=30
+*/
+
+// path prefix of the profiling endpoints
+const pprofPathPrefix = "/debug/pprof/"
+
+// Register the profiling endpoints on the mux
+func registerPprofHandlers(mux *http.ServeMux) {
+	// the index also serves the named profiles, e.g. heap and goroutine
+	mux.HandleFunc(pprofPathPrefix, pprof.Index)
+	mux.HandleFunc(pprofPathPrefix+"cmdline", pprof.Cmdline)
+	mux.HandleFunc(pprofPathPrefix+"profile", pprof.Profile)
+	mux.HandleFunc(pprofPathPrefix+"symbol", pprof.Symbol)
+	mux.HandleFunc(pprofPathPrefix+"trace", pprof.Trace)
+}
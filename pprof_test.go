@@ -0,0 +1,48 @@
+/*
+Copyright 2019 IBM Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestPprofEndpoints(t *testing.T) {
+	saved := enablePprof
+	defer func() { enablePprof = saved }()
+
+	var tests = []struct {
+		enabled  bool
+		path     string
+		expected int
+	}{
+		{true, pprofPathPrefix, http.StatusOK},
+		{true, pprofPathPrefix + "cmdline", http.StatusOK},
+		{false, pprofPathPrefix, http.StatusNotFound},
+		{false, pprofPathPrefix + "cmdline", http.StatusNotFound},
+	}
+	for _, test := range tests {
+		enablePprof = test.enabled
+		handler := newHTTPHandler(&ClusterWatcher{})
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, test.path, nil))
+		if w.Code != test.expected {
+			t.Errorf("expecting status %d for %s with enablePprof %t, got %d", test.expected, test.path, test.enabled, w.Code)
+		}
+	}
+}
@@ -0,0 +1,256 @@
+/*
+Copyright 2019 IBM Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/util/jsonpath"
+	"k8s.io/klog"
+)
+
+/*
+ Custom resources of kinds no other health reader recognizes may still expose
+ their health through an additional printer column of their CRD, e.g. a
+ column named Status with JSONPath .status.phase. The column is looked up
+ once per GVR, and the value it points to mapped to a status.
+*/
+
+// Names of printer columns holding the health of a custom resource, in order of preference
+var healthPrinterColumnNames = []string{"health", "status", "ready"}
+
+// Values of printer columns, lower case, for each status
+var printerColumnValueStatus = map[string]string{
+	"true":        statusNormal,
+	"ready":       statusNormal,
+	"healthy":     statusNormal,
+	"normal":      statusNormal,
+	"ok":          statusNormal,
+	"running":     statusNormal,
+	"available":   statusNormal,
+	"succeeded":   statusNormal,
+	"completed":   statusNormal,
+	"warning":     statusWarning,
+	"degraded":    statusWarning,
+	"pending":     statusWarning,
+	"progressing": statusWarning,
+	"false":       statusProblem,
+	"notready":    statusProblem,
+	"unhealthy":   statusProblem,
+	"problem":     statusProblem,
+	"failed":      statusProblem,
+	"error":       statusProblem,
+}
+
+// Printer column of a CRD holding the health of its resources
+type healthPrinterColumn struct {
+	name     string
+	jsonPath string
+}
+
+// Health printer column of each GVR, nil if its CRD has none
+type printerColumnReader struct {
+	columns map[schema.GroupVersionResource]*healthPrinterColumn
+	mutex   sync.Mutex
+}
+
+func newPrinterColumnReader() *printerColumnReader {
+	return &printerColumnReader{columns: make(map[schema.GroupVersionResource]*healthPrinterColumn)}
+}
+
+// Forget the columns looked up, e.g. after a CRD changed
+func (reader *printerColumnReader) reset() {
+	if reader == nil {
+		return
+	}
+	reader.mutex.Lock()
+	defer reader.mutex.Unlock()
+	reader.columns = make(map[schema.GroupVersionResource]*healthPrinterColumn)
+}
+
+// Return the health printer column of a GVR, looking up its CRD the first time.
+// Return nil if the GVR is not defined by a CRD, or its CRD has no health column
+func (reader *printerColumnReader) column(resController *ClusterWatcher, gvr schema.GroupVersionResource) *healthPrinterColumn {
+	if reader == nil {
+		return nil
+	}
+	reader.mutex.Lock()
+	column, ok := reader.columns[gvr]
+	reader.mutex.Unlock()
+	if ok {
+		return column
+	}
+
+	crd, err := resController.getCRD(gvr)
+	if err != nil {
+		// not cached, to try again next time
+		if klog.V(2) {
+			klog.Infof("unable to get CRD of %s: %s", gvr, err)
+		}
+		return nil
+	}
+	if crd != nil {
+		column = findHealthPrinterColumn(crd, gvr.Version)
+	}
+	if klog.V(3) {
+		klog.Infof("health printer column of %s: %+v", gvr, column)
+	}
+	reader.mutex.Lock()
+	reader.columns[gvr] = column
+	reader.mutex.Unlock()
+	return column
+}
+
+// Return the CRD defining a GVR, from the cache if CRDs are watched, or from the API server.
+// Return nil if there is none
+func (resController *ClusterWatcher) getCRD(gvr schema.GroupVersionResource) (*unstructured.Unstructured, error) {
+	if gvr.Group == "" {
+		// core kinds are not defined by CRDs
+		return nil, nil
+	}
+	name := gvr.Resource + "." + gvr.Group
+	if obj, exists, err := resController.getResource(coreCustomResourceDefinitionGVR, "", name); err == nil && exists {
+		if crd, ok := obj.(*unstructured.Unstructured); ok {
+			return crd, nil
+		}
+	}
+	crd, err := resController.plugin.dynamicClient.Resource(coreCustomResourceDefinitionGVR).Get(name, metav1.GetOptions{})
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return crd, nil
+}
+
+// Return the health printer column of a CRD for the given version, nil if none.
+// Columns of the version take precedence over those of the whole CRD
+func findHealthPrinterColumn(crd *unstructured.Unstructured, version string) *healthPrinterColumn {
+	columns, _, _ := unstructured.NestedSlice(crd.Object, SPEC, "additionalPrinterColumns")
+	versions, _, _ := unstructured.NestedSlice(crd.Object, SPEC, "versions")
+	for _, obj := range versions {
+		versionMap, ok := obj.(map[string]interface{})
+		if !ok || versionMap[NAME] != version {
+			continue
+		}
+		if versionColumns, ok := versionMap["additionalPrinterColumns"].([]interface{}); ok {
+			columns = versionColumns
+		}
+	}
+
+	for _, wanted := range healthPrinterColumnNames {
+		for _, obj := range columns {
+			columnMap, ok := obj.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			name, _ := columnMap[NAME].(string)
+			if strings.ToLower(name) != wanted {
+				continue
+			}
+			// JSONPath in v1beta1, jsonPath in v1
+			path, _ := columnMap["JSONPath"].(string)
+			if path == "" {
+				path, _ = columnMap["jsonPath"].(string)
+			}
+			if path != "" {
+				return &healthPrinterColumn{name: name, jsonPath: path}
+			}
+		}
+	}
+	return nil
+}
+
+// Return the value of the printer column of a resource, or "" if it has none
+func (column *healthPrinterColumn) value(unstructuredObj *unstructured.Unstructured) (string, error) {
+	return jsonPathValue(column.name, column.jsonPath, unstructuredObj.Object)
+}
+
+// Return the first value at a JSONPath, e.g. .status.phase or {.status.phase}, or "" if there is none
+func jsonPathValue(name string, path string, obj interface{}) (string, error) {
+	if !strings.HasPrefix(path, "{") {
+		if !strings.HasPrefix(path, ".") {
+			path = "." + path
+		}
+		path = "{" + path + "}"
+	}
+	parser := jsonpath.New(name)
+	parser.AllowMissingKeys(true)
+	if err := parser.Parse(path); err != nil {
+		return "", err
+	}
+	results, err := parser.FindResults(obj)
+	if err != nil {
+		return "", err
+	}
+	if len(results) == 0 || len(results[0]) == 0 {
+		return "", nil
+	}
+	return fmt.Sprint(results[0][0].Interface()), nil
+}
+
+// Return the status for the value of a health printer column, or "" if not recognized.
+// Counts of ready replicas, e.g. 2/3, are Normal only when all are ready
+func printerColumnStatus(value string) string {
+	value = strings.TrimSpace(value)
+	if parts := strings.Split(value, "/"); len(parts) == 2 {
+		ready, err1 := strconv.Atoi(parts[0])
+		desired, err2 := strconv.Atoi(parts[1])
+		if err1 == nil && err2 == nil {
+			if ready >= desired {
+				return statusNormal
+			}
+			if ready == 0 {
+				return statusProblem
+			}
+			return statusWarning
+		}
+	}
+	return printerColumnValueStatus[strings.ToLower(value)]
+}
+
+// Read the health of a custom resource from the health printer column of its CRD.
+// Return an empty status if there is no such column, or its value is not recognized
+func (resController *ClusterWatcher) printerColumnHealth(resInfo *resourceInfo) (status string, flyover string) {
+	if resInfo.unstructuredObj == nil {
+		return "", ""
+	}
+	column := resController.printerColumns.column(resController, resInfo.gvr)
+	if column == nil {
+		return "", ""
+	}
+	value, err := column.value(resInfo.unstructuredObj)
+	if err != nil {
+		if klog.V(2) {
+			klog.Infof("unable to read printer column %s of %s %s/%s: %s", column.name, resInfo.kind, resInfo.namespace, resInfo.name, err)
+		}
+		return "", ""
+	}
+	status = printerColumnStatus(value)
+	if status == "" {
+		return "", ""
+	}
+	return status, fmt.Sprintf("%s: %s", column.name, value)
+}
@@ -0,0 +1,146 @@
+/*
+Copyright 2019 IBM Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic/fake"
+)
+
+const (
+	crdWidget     = "test_data/CRD_widget.json"
+	exampleWidget = "test_data/example-widget.json"
+)
+
+var widgetGVR = schema.GroupVersionResource{Group: "example.com", Version: "v1", Resource: "widgets"}
+
+var printerColumnStatusTestData = []struct {
+	value    string
+	expected string
+}{
+	{"Healthy", statusNormal},
+	{"True", statusNormal},
+	{"Running", statusNormal},
+	{"Degraded", statusWarning},
+	{"Failed", statusProblem},
+	{"false", statusProblem},
+	{"3/3", statusNormal},
+	{"1/3", statusWarning},
+	{"0/3", statusProblem},
+	{"Reticulating", ""},
+	{"", ""},
+}
+
+func TestPrinterColumnStatus(t *testing.T) {
+	for _, data := range printerColumnStatusTestData {
+		if status := printerColumnStatus(data.value); status != data.expected {
+			t.Errorf("value %q: expecting status %q, got %q", data.value, data.expected, status)
+		}
+	}
+}
+
+func TestFindHealthPrinterColumn(t *testing.T) {
+	crd, err := readJSON(crdWidget)
+	if err != nil {
+		t.Fatal(err)
+	}
+	column := findHealthPrinterColumn(crd, "v1")
+	if column == nil || column.name != "Health" || column.jsonPath != ".status.health" {
+		t.Fatalf("expecting column Health with JSONPath .status.health, got %+v", column)
+	}
+
+	// columns of the version take precedence
+	versioned := crd.DeepCopy()
+	versions, _, _ := unstructured.NestedSlice(versioned.Object, SPEC, "versions")
+	versions[0].(map[string]interface{})["additionalPrinterColumns"] = []interface{}{
+		map[string]interface{}{"name": "Ready", "type": "string", "jsonPath": ".status.ready"},
+	}
+	unstructured.SetNestedSlice(versioned.Object, versions, SPEC, "versions")
+	column = findHealthPrinterColumn(versioned, "v1")
+	if column == nil || column.name != "Ready" || column.jsonPath != ".status.ready" {
+		t.Errorf("expecting column Ready of the version, got %+v", column)
+	}
+
+	// no health column
+	foo, err := readJSON(crdFoo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if column = findHealthPrinterColumn(foo, "v1alpha1"); column != nil {
+		t.Errorf("expecting no health column for %s, got %+v", crdFoo, column)
+	}
+}
+
+func TestPrinterColumnHealth(t *testing.T) {
+	crd, err := readJSON(crdWidget)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resController := &ClusterWatcher{
+		plugin:         &ControllerPlugin{dynamicClient: fake.NewSimpleDynamicClient(runtime.NewScheme())},
+		printerColumns: newPrinterColumnReader(),
+	}
+	crdInterface := resController.plugin.dynamicClient.Resource(coreCustomResourceDefinitionGVR)
+	if _, err = crdInterface.Create(crd, metav1.CreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	widget, err := readJSON(exampleWidget)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var testData = []struct {
+		health   string
+		expected string
+	}{
+		{"Healthy", statusNormal},
+		{"Degraded", statusWarning},
+		{"Failed", statusProblem},
+		{"", ""},
+	}
+	for _, data := range testData {
+		obj := widget.DeepCopy()
+		if data.health == "" {
+			unstructured.RemoveNestedField(obj.Object, STATUS)
+		} else {
+			unstructured.SetNestedField(obj.Object, data.health, STATUS, "health")
+		}
+		resInfo := &resourceInfo{unstructuredObj: obj, gvr: widgetGVR, kind: "Widget", namespace: obj.GetNamespace(), name: obj.GetName()}
+		status, flyover := resController.printerColumnHealth(resInfo)
+		if status != data.expected {
+			t.Errorf("health %q: expecting status %q, got %q (%s)", data.health, data.expected, status, flyover)
+		}
+	}
+
+	// the CRD is looked up once
+	if err = crdInterface.Delete(crd.GetName(), &metav1.DeleteOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	resInfo := &resourceInfo{unstructuredObj: widget, gvr: widgetGVR, kind: "Widget", namespace: widget.GetNamespace(), name: widget.GetName()}
+	if status, _ := resController.printerColumnHealth(resInfo); status != statusNormal {
+		t.Errorf("expecting status %s from the cached column, got %q", statusNormal, status)
+	}
+	resController.printerColumns.reset()
+	if status, _ := resController.printerColumnHealth(resInfo); status != "" {
+		t.Errorf("expecting no status once the CRD is deleted, got %q", status)
+	}
+}
@@ -0,0 +1,94 @@
+/*
+Copyright 2019 IBM Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"strconv"
+
+	"k8s.io/klog"
+)
+
+/*
+ In quorum mode, the components of each kind of an application count as
+ healthy as long as at least the quorum fraction of them are healthy, e.g.
+ with kappnav.status.quorum: "0.5", an application with 4 replicas of a
+ stateless Deployment stays Normal while 2 of them are. Kinds below the
+ quorum contribute the status of highest precedence of their components, as
+ in the default mode.
+*/
+
+// name of the built-in quorum calculator
+const quorumStatusCalculatorName = "quorum"
+
+// built-in calculator applying the quorum fraction of the kappnav.status.quorum annotation to each component kind
+type quorumStatusCalculator struct{}
+
+// Return the quorum fraction of an application, and false if it has none or it is not between 0 and 1
+func statusQuorum(appResInfo *appResourceInfo) (float64, bool) {
+	value, ok := appResInfo.annotations[kappnavStatusQuorum].(string)
+	if !ok {
+		return 0, false
+	}
+	quorum, err := strconv.ParseFloat(value, 64)
+	if err != nil || quorum < 0 || quorum > 1 {
+		if klog.V(2) {
+			klog.Infof("statusQuorum application %s/%s: invalid %s annotation %q, expecting a fraction between 0 and 1", appResInfo.namespace, appResInfo.name, kappnavStatusQuorum, value)
+		}
+		return 0, false
+	}
+	return quorum, true
+}
+
+func (quorumStatusCalculator) ApplicationStatus(appResInfo *appResourceInfo, components []componentStatus, precedence []string, unknownStatus string) string {
+	quorum, ok := statusQuorum(appResInfo)
+	if !ok {
+		// all or nothing
+		return precedenceStatusCalculator{}.ApplicationStatus(appResInfo, components, precedence, unknownStatus)
+	}
+	healthy := healthyStatusOf(precedence, unknownStatus)
+
+	// components of each kind, in the order the kinds are first seen
+	kinds := make([]string, 0)
+	byKind := make(map[string][]componentStatus)
+	for _, component := range components {
+		kind := component.resInfo.kind
+		if _, ok := byKind[kind]; !ok {
+			kinds = append(kinds, kind)
+		}
+		byKind[kind] = append(byKind[kind], component)
+	}
+
+	checker := newStatusChecker(precedence, unknownStatus)
+	for _, kind := range kinds {
+		healthyCount := 0
+		for _, component := range byKind[kind] {
+			if component.status == healthy {
+				healthyCount++
+			}
+		}
+		// divide rather than multiply the quorum, for a fraction such as 0.3 of 10 components to be reached exactly
+		if float64(healthyCount)/float64(len(byKind[kind])) >= quorum {
+			// enough of the kind healthy
+			checker.addStatus(healthy)
+			continue
+		}
+		for _, component := range byKind[kind] {
+			checker.addStatus(component.status)
+		}
+	}
+	return checker.finalStatus()
+}
@@ -0,0 +1,93 @@
+/*
+Copyright 2019 IBM Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"testing"
+)
+
+type quorumTestData struct {
+	quorum     string         // value of the annotation. "" for none
+	deployment map[string]int // statuses of the Deployments
+	service    map[string]int // statuses of the Services
+	expected   string
+}
+
+var quorumTestDataArray = []quorumTestData{
+	// all or nothing without quorum
+	{quorum: "", deployment: map[string]int{Normal: 3, problem: 1}, expected: problem},
+	{quorum: "", deployment: map[string]int{Normal: 4}, expected: Normal},
+	// exactly at the quorum
+	{quorum: "0.5", deployment: map[string]int{Normal: 2, problem: 2}, expected: Normal},
+	{quorum: "0.75", deployment: map[string]int{Normal: 3, problem: 1}, expected: Normal},
+	{quorum: "0.3", deployment: map[string]int{Normal: 3, warning: 7}, expected: Normal},
+	// just below the quorum
+	{quorum: "0.5", deployment: map[string]int{Normal: 1, problem: 2}, expected: problem},
+	{quorum: "0.75", deployment: map[string]int{Normal: 2, warning: 1, problem: 1}, expected: problem},
+	{quorum: "0.3", deployment: map[string]int{Normal: 2, warning: 8}, expected: warning},
+	// unknown is not healthy
+	{quorum: "0.5", deployment: map[string]int{Normal: 1, "Unknown": 2}, expected: "Unknown"},
+	// each kind on its own
+	{quorum: "0.5", deployment: map[string]int{Normal: 2, problem: 2}, service: map[string]int{Normal: 1, warning: 2}, expected: warning},
+	{quorum: "0.5", deployment: map[string]int{Normal: 2, problem: 2}, service: map[string]int{Normal: 1, warning: 1}, expected: Normal},
+	// every component must be healthy with a quorum of 1, and none with 0
+	{quorum: "1", deployment: map[string]int{Normal: 3, warning: 1}, expected: warning},
+	{quorum: "0", deployment: map[string]int{problem: 2}, expected: Normal},
+	// invalid quorum falls back to all or nothing
+	{quorum: "half", deployment: map[string]int{Normal: 3, problem: 1}, expected: problem},
+	{quorum: "1.5", deployment: map[string]int{Normal: 3, problem: 1}, expected: problem},
+}
+
+// return components of a kind with the given number of each status
+func quorumComponents(kind string, statuses map[string]int) []componentStatus {
+	ret := make([]componentStatus, 0)
+	for status, count := range statuses {
+		for i := 0; i < count; i++ {
+			ret = append(ret, componentStatus{&resourceInfo{kind: kind}, status, 1})
+		}
+	}
+	return ret
+}
+
+func TestQuorumStatus(t *testing.T) {
+	precedence := []string{"Red Alert", problem, warning, "Unknown", Normal}
+	for _, data := range quorumTestDataArray {
+		var appInfo = &appResourceInfo{}
+		appInfo.kind = APPLICATION
+		appInfo.annotations = map[string]interface{}{}
+		if data.quorum != "" {
+			appInfo.annotations[kappnavStatusQuorum] = data.quorum
+		}
+		components := append(quorumComponents("Deployment", data.deployment), quorumComponents("Service", data.service)...)
+		name, calculator := statusCalculatorFor(appInfo)
+		if status := calculator.ApplicationStatus(appInfo, components, precedence, "Unknown"); status != data.expected {
+			t.Errorf("quorum %q, deployments %v, services %v: expecting %s from calculator %s, got %s", data.quorum, data.deployment, data.service, data.expected, name, status)
+		}
+	}
+}
+
+func TestQuorumStatusCalculatorSelected(t *testing.T) {
+	var appInfo = &appResourceInfo{}
+	appInfo.annotations = map[string]interface{}{kappnavStatusQuorum: "0.5"}
+	if name, _ := statusCalculatorFor(appInfo); name != quorumStatusCalculatorName {
+		t.Errorf("expecting quorum calculator for an application with a quorum, got %s", name)
+	}
+	appInfo.annotations[kappnavStatusCalculator] = defaultStatusCalculator
+	if name, _ := statusCalculatorFor(appInfo); name != defaultStatusCalculator {
+		t.Errorf("expecting calculator named by the application over its quorum, got %s", name)
+	}
+}
@@ -0,0 +1,89 @@
+/*
+Copyright 2019 IBM Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"time"
+
+	"golang.org/x/time/rate"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/workqueue"
+)
+
+const (
+	// DefaultRequeueBaseDelay - delay before first retry of an object that failed to process
+	DefaultRequeueBaseDelay = time.Millisecond * 5
+	// DefaultRequeueMaxDelay - maximum delay between retries of an object that keeps failing
+	DefaultRequeueMaxDelay = time.Second * 1000
+)
+
+// Rate limiter that tracks failures by object rather than by queued event.
+// Each event is queued as a new eventHandlerData, so the failures of
+// the same object would otherwise not accumulate.
+type keyedRateLimiter struct {
+	limiter       workqueue.RateLimiter
+	resController *ClusterWatcher // keys the objects
+}
+
+// Return the resource of an event, identified by its GVR, namespace, name, and uid if known
+func eventResourceInfo(handlerData *eventHandlerData) *resourceInfo {
+	resInfo := &resourceInfo{gvr: handlerData.gvr, kind: handlerData.kind}
+	resInfo.namespace, resInfo.name, _ = cache.SplitMetaNamespaceKey(handlerData.key)
+	obj := handlerData.obj
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+	if unstructuredObj, ok := obj.(*unstructured.Unstructured); ok {
+		resInfo.uid = string(unstructuredObj.GetUID())
+	}
+	return resInfo
+}
+
+// Return the key of the object to rate limit
+func (limiter *keyedRateLimiter) key(item interface{}) interface{} {
+	if handlerData, ok := item.(*eventHandlerData); ok {
+		return limiter.resController.resourceKey(eventResourceInfo(handlerData))
+	}
+	return item
+}
+
+// When returns how long to wait before retrying the item
+func (limiter *keyedRateLimiter) When(item interface{}) time.Duration {
+	return limiter.limiter.When(limiter.key(item))
+}
+
+// Forget resets the failures of the item
+func (limiter *keyedRateLimiter) Forget(item interface{}) {
+	limiter.limiter.Forget(limiter.key(item))
+}
+
+// NumRequeues returns number of failures of the item
+func (limiter *keyedRateLimiter) NumRequeues(item interface{}) int {
+	return limiter.limiter.NumRequeues(limiter.key(item))
+}
+
+// Create the rate limiter for the queue of resource events.
+// Repeated failures of the same object back off exponentially from
+// baseDelay up to maxDelay. Overall retries are limited to 10 per second.
+// Objects are keyed as the resources of the controller.
+func newControllerRateLimiter(resController *ClusterWatcher, baseDelay time.Duration, maxDelay time.Duration) workqueue.RateLimiter {
+	return workqueue.NewMaxOfRateLimiter(
+		&keyedRateLimiter{limiter: workqueue.NewItemExponentialFailureRateLimiter(baseDelay, maxDelay), resController: resController},
+		&workqueue.BucketRateLimiter{Limiter: rate.NewLimiter(rate.Limit(10), 100)},
+	)
+}
@@ -0,0 +1,97 @@
+/*
+Copyright 2019 IBM Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func newRateLimiterTestEvent(key string) *eventHandlerData {
+	return &eventHandlerData{
+		funcType: UpdateFunc,
+		kind:     DEPLOYMENT,
+		gvr:      coreDeploymentGVR,
+		key:      key,
+	}
+}
+
+// Test consecutive failures of the same object increase the requeue delay
+func TestControllerRateLimiter(t *testing.T) {
+	baseDelay := time.Millisecond
+	maxDelay := time.Millisecond * 16
+	limiter := newControllerRateLimiter(&ClusterWatcher{}, baseDelay, maxDelay)
+
+	// each failure is a new event for the same object
+	var previous time.Duration
+	for i := 0; i < 4; i++ {
+		delay := limiter.When(newRateLimiterTestEvent("default/dep1"))
+		if delay <= previous {
+			t.Errorf("failure %d: expecting delay more than %v, got %v", i, previous, delay)
+		}
+		previous = delay
+	}
+	if limiter.NumRequeues(newRateLimiterTestEvent("default/dep1")) != 4 {
+		t.Errorf("expecting 4 requeues, got %d", limiter.NumRequeues(newRateLimiterTestEvent("default/dep1")))
+	}
+
+	// delay is capped
+	for i := 0; i < 10; i++ {
+		previous = limiter.When(newRateLimiterTestEvent("default/dep1"))
+	}
+	if previous != maxDelay {
+		t.Errorf("expecting delay capped at %v, got %v", maxDelay, previous)
+	}
+
+	// other objects are not affected
+	if delay := limiter.When(newRateLimiterTestEvent("default/dep2")); delay != baseDelay {
+		t.Errorf("expecting base delay %v for another object, got %v", baseDelay, delay)
+	}
+
+	// success resets the delay
+	limiter.Forget(newRateLimiterTestEvent("default/dep1"))
+	if limiter.NumRequeues(newRateLimiterTestEvent("default/dep1")) != 0 {
+		t.Errorf("expecting 0 requeues after forget, got %d", limiter.NumRequeues(newRateLimiterTestEvent("default/dep1")))
+	}
+	if delay := limiter.When(newRateLimiterTestEvent("default/dep1")); delay != baseDelay {
+		t.Errorf("expecting base delay %v after forget, got %v", baseDelay, delay)
+	}
+}
+
+// Test events are keyed through the keyer of the controller
+func TestControllerRateLimiterKeyer(t *testing.T) {
+	resController := &ClusterWatcher{}
+	resController.setKeyer(namespacedNameKeyer)
+	limiter := newControllerRateLimiter(resController, time.Millisecond, time.Second)
+
+	deleted := newRateLimiterTestEvent("default/dep1")
+	deleted.obj = &unstructured.Unstructured{Object: map[string]interface{}{"metadata": map[string]interface{}{"uid": "uid-1"}}}
+	recreated := newRateLimiterTestEvent("default/dep1")
+	recreated.obj = &unstructured.Unstructured{Object: map[string]interface{}{"metadata": map[string]interface{}{"uid": "uid-2"}}}
+	limiter.When(deleted)
+	limiter.When(recreated)
+	if requeues := limiter.NumRequeues(recreated); requeues != 2 {
+		t.Errorf("expecting failures of both uids counted together by the keyer on the name, got %d", requeues)
+	}
+
+	resController.setKeyer(defaultKeyer)
+	if requeues := limiter.NumRequeues(recreated); requeues != 0 {
+		t.Errorf("expecting failures of the re-created resource counted apart by the default keyer, got %d", requeues)
+	}
+}
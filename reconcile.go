@@ -0,0 +1,103 @@
+/*
+Copyright 2019 IBM Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"net/http"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/klog"
+)
+
+const (
+	// path prefix of POST /reconcile/{namespace}/{name}
+	reconcilePathPrefix = "/reconcile/"
+)
+
+// Enqueue one application to recompute its status.
+// Return false if the application is not in the cache
+func (resController *ClusterWatcher) reconcileApplication(namespace string, name string) (bool, error) {
+	obj, exists, err := resController.getApplication(namespace, name)
+	if err != nil || !exists {
+		return false, err
+	}
+	unstructuredObj, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		return false, nil
+	}
+
+	var resInfo = &resourceInfo{}
+	resController.parseResource(unstructuredObj, resInfo)
+	if klog.V(2) {
+		klog.Infof("reconciling application %s/%s", namespace, name)
+	}
+	resController.resourceChannel.send(&batchResources{
+		applications:    map[string]*resourceInfo{resController.resourceKey(resInfo): resInfo},
+		nonApplications: make(map[string]*resourceInfo),
+	})
+	return true, nil
+}
+
+// Enqueue all applications to recompute their status
+func (resController *ClusterWatcher) reconcileAllApplications() {
+	var applications = make(map[string]*resourceInfo)
+	for _, obj := range resController.listApplications() {
+		unstructuredObj, ok := obj.(*unstructured.Unstructured)
+		if !ok {
+			continue
+		}
+		var resInfo = &resourceInfo{}
+		resController.parseResource(unstructuredObj, resInfo)
+		applications[resController.resourceKey(resInfo)] = resInfo
+	}
+	if klog.V(2) {
+		klog.Infof("reconciling all %d applications", len(applications))
+	}
+	if len(applications) > 0 {
+		resController.resourceChannel.send(&batchResources{
+			applications:    applications,
+			nonApplications: make(map[string]*resourceInfo),
+		})
+	}
+}
+
+// Handler for POST /reconcile/{namespace}/{name}
+func reconcileApplicationHandler(resController *ClusterWatcher) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		parts := strings.Split(strings.TrimPrefix(r.URL.Path, reconcilePathPrefix), "/")
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			http.NotFound(w, r)
+			return
+		}
+		found, err := resController.reconcileApplication(parts[0], parts[1])
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if !found {
+			http.Error(w, "application "+parts[0]+"/"+parts[1]+" not found", http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusAccepted)
+	}
+}
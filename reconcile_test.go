@@ -0,0 +1,113 @@
+/*
+Copyright 2019 IBM Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// Test only the targeted application is enqueued to be reconciled
+func TestReconcileApplication(t *testing.T) {
+	testName := "TestReconcileApplication"
+	beforeTest()
+	// kinds to check for status
+	var kindsToCheckStatus = map[string]bool{
+		APPLICATION:  true,
+		"Service":    true,
+		"Deployment": true,
+	}
+
+	var files = []string{
+		/* 0 */ CrdApplication,
+		/* 1 */ appBookinfo,
+		/* 2 */ appProductpage,
+		/* 3 */ deploymentProcuctpageV1,
+		/* 4 */ serviceProductpage,
+		/* 5 */ KappnavConfigFile,
+	}
+	iteration0IDs, err := readResourceIDs(files)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	/* Iteration 0: all normal */
+	testActions := newTestActions(testName, kindsToCheckStatus)
+	var emptyIDs = []resourceID{}
+	testActions.addIteration(iteration0IDs, emptyIDs)
+
+	clusterWatcher, err := createClusterWatcher(iteration0IDs, testActions, StatusFailureRate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer clusterWatcher.shutDown()
+
+	err = testActions.transitionAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// capture what is sent for batch processing
+	batchChannel := clusterWatcher.resourceChannel
+	captureChannel := newResourceChannel()
+	clusterWatcher.resourceChannel = captureChannel
+	defer func() {
+		clusterWatcher.resourceChannel = batchChannel
+	}()
+
+	handler := newHTTPHandler(clusterWatcher)
+	target := iteration0IDs[2]
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, reconcilePathPrefix+target.namespace+"/"+target.name, nil))
+	if recorder.Code != http.StatusAccepted {
+		t.Fatalf("expecting code %d, got %d: %s", http.StatusAccepted, recorder.Code, recorder.Body.String())
+	}
+
+	select {
+	case resources := <-captureChannel.batchResourceChan:
+		if len(resources.applications) != 1 || len(resources.nonApplications) != 0 {
+			t.Fatalf("expecting only 1 application enqueued, got %d applications and %d resources", len(resources.applications), len(resources.nonApplications))
+		}
+		for _, app := range resources.applications {
+			if app.namespace != target.namespace || app.name != target.name {
+				t.Errorf("expecting application %s/%s enqueued, got %s/%s", target.namespace, target.name, app.namespace, app.name)
+			}
+		}
+	case <-time.After(time.Second * 5):
+		t.Fatal("timed out waiting for application to be enqueued")
+	}
+
+	// unknown application
+	recorder = httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, reconcilePathPrefix+"default/unknown-app", nil))
+	if recorder.Code != http.StatusNotFound {
+		t.Errorf("expecting code %d for unknown application, got %d", http.StatusNotFound, recorder.Code)
+	}
+
+	// invalid method
+	recorder = httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, reconcilePathPrefix+target.namespace+"/"+target.name, nil))
+	if recorder.Code != http.StatusMethodNotAllowed {
+		t.Errorf("expecting code %d for GET, got %d", http.StatusMethodNotAllowed, recorder.Code)
+	}
+
+	if len(captureChannel.batchResourceChan) != 0 {
+		t.Errorf("expecting nothing else enqueued, got %d", len(captureChannel.batchResourceChan))
+	}
+}
@@ -0,0 +1,148 @@
+/*
+Copyright 2019 IBM Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/klog"
+)
+
+/*
+ After a watch expires with 410 Gone, the informer relists the GVR and
+ delivers an event for every resource, most of them updates that change
+ nothing, each walking up to the applications of the resource. As the
+ informer stays synced across a relist, the burst is detected from the
+ updates themselves: once enough updates of a GVR with an unchanged resource
+ version arrive within the window, the following ones are dropped, and all
+ applications are reconciled once when the burst is over. Updates that change
+ the resource, and deletions, are still processed one by one.
+*/
+
+const (
+	// DefaultRelistBurstThreshold - unchanged updates of a GVR within the window that make a relist burst
+	DefaultRelistBurstThreshold = 100
+
+	// DefaultRelistBurstWindow - time without unchanged updates that ends a relist burst
+	DefaultRelistBurstWindow = time.Second
+)
+
+// Unchanged updates of a GVR in the current window
+type relistBurst struct {
+	count      int
+	last       time.Time   // time of the last unchanged update
+	coalescing bool        // true once the threshold is reached
+	timer      *time.Timer // fires at the end of the burst while coalescing
+}
+
+// Detects relist bursts per GVR, and coalesces each into one reconcile of all applications
+type relistCoalescer struct {
+	threshold int           // unchanged updates within the window that make a burst
+	window    time.Duration // time without unchanged updates that ends a burst
+	reconcile func()        // reconcile of all applications, called at the end of a burst
+	bursts    map[schema.GroupVersionResource]*relistBurst
+	mutex     sync.Mutex
+}
+
+// Create a relist coalescer. Return nil if the threshold is not positive, to process all events
+func newRelistCoalescer(threshold int, window time.Duration, reconcile func()) *relistCoalescer {
+	if threshold <= 0 {
+		return nil
+	}
+	return &relistCoalescer{
+		threshold: threshold,
+		window:    window,
+		reconcile: reconcile,
+		bursts:    make(map[schema.GroupVersionResource]*relistBurst),
+	}
+}
+
+// Return true for an update that does not change the resource version, as delivered on a relist
+func isUnchangedUpdate(eventData *eventHandlerData) bool {
+	if eventData.funcType != UpdateFunc {
+		return false
+	}
+	obj, ok := eventData.obj.(*unstructured.Unstructured)
+	if !ok {
+		return false
+	}
+	oldObj, ok := eventData.oldObj.(*unstructured.Unstructured)
+	if !ok {
+		return false
+	}
+	return obj.GetResourceVersion() != "" && obj.GetResourceVersion() == oldObj.GetResourceVersion()
+}
+
+// Return true if the event is part of a relist burst, and must not be processed on its own
+func (coalescer *relistCoalescer) coalesce(eventData *eventHandlerData) bool {
+	if coalescer == nil || !isUnchangedUpdate(eventData) {
+		return false
+	}
+	coalescer.mutex.Lock()
+	defer coalescer.mutex.Unlock()
+
+	now := time.Now()
+	gvr := eventData.gvr
+	burst, ok := coalescer.bursts[gvr]
+	if !ok || (!burst.coalescing && now.Sub(burst.last) > coalescer.window) {
+		burst = &relistBurst{}
+		coalescer.bursts[gvr] = burst
+	}
+	burst.count++
+	burst.last = now
+	if burst.coalescing {
+		burst.timer.Reset(coalescer.window)
+		return true
+	}
+	if burst.count < coalescer.threshold {
+		return false
+	}
+	if klog.V(2) {
+		klog.Infof("relist burst of %s detected after %d unchanged updates, coalescing", gvr, burst.count)
+	}
+	burst.coalescing = true
+	burst.timer = time.AfterFunc(coalescer.window, func() {
+		coalescer.mutex.Lock()
+		count := burst.count
+		delete(coalescer.bursts, gvr)
+		coalescer.mutex.Unlock()
+		if klog.V(2) {
+			klog.Infof("relist burst of %s over after %d unchanged updates, reconciling all applications", gvr, count)
+		}
+		relistBurstsCoalesced.Inc()
+		coalescer.reconcile()
+	})
+	return true
+}
+
+// Stop the timers of the bursts in progress
+func (coalescer *relistCoalescer) stop() {
+	if coalescer == nil {
+		return
+	}
+	coalescer.mutex.Lock()
+	defer coalescer.mutex.Unlock()
+	for gvr, burst := range coalescer.bursts {
+		if burst.timer != nil {
+			burst.timer.Stop()
+		}
+		delete(coalescer.bursts, gvr)
+	}
+}
@@ -0,0 +1,100 @@
+/*
+Copyright 2019 IBM Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// Return an update event of a Deployment from one resource version to another
+func deploymentUpdate(name string, oldVersion string, newVersion string) *eventHandlerData {
+	oldObj := &unstructured.Unstructured{Object: map[string]interface{}{}}
+	oldObj.SetNamespace("default")
+	oldObj.SetName(name)
+	oldObj.SetResourceVersion(oldVersion)
+	obj := oldObj.DeepCopy()
+	obj.SetResourceVersion(newVersion)
+	return &eventHandlerData{funcType: UpdateFunc, gvr: coreDeploymentGVR, key: "default/" + name, obj: obj, oldObj: oldObj}
+}
+
+// Test a relist burst is coalesced into a single reconcile
+func TestRelistBurstCoalesced(t *testing.T) {
+	const threshold = 5
+	const window = time.Millisecond * 50
+	var reconciles int32
+	coalescer := newRelistCoalescer(threshold, window, func() {
+		atomic.AddInt32(&reconciles, 1)
+	})
+	defer coalescer.stop()
+	before := testutil.ToFloat64(relistBurstsCoalesced)
+
+	// relist burst
+	processed := 0
+	for i := 0; i < 50; i++ {
+		if !coalescer.coalesce(deploymentUpdate(fmt.Sprintf("deployment%d", i), "100", "100")) {
+			processed++
+		}
+	}
+	if processed != threshold-1 {
+		t.Errorf("expecting %d updates processed before the burst is detected, got %d", threshold-1, processed)
+	}
+
+	// real changes and other events are still processed during the burst
+	if coalescer.coalesce(deploymentUpdate("changed", "100", "101")) {
+		t.Error("expecting an update changing the resource version to be processed")
+	}
+	if coalescer.coalesce(&eventHandlerData{funcType: DeleteFunc, gvr: coreDeploymentGVR, key: "default/deleted"}) {
+		t.Error("expecting a deletion to be processed")
+	}
+	if reconciles := atomic.LoadInt32(&reconciles); reconciles != 0 {
+		t.Errorf("expecting no reconcile during the burst, got %d", reconciles)
+	}
+
+	// one reconcile once the burst is over
+	time.Sleep(window * 4)
+	if reconciles := atomic.LoadInt32(&reconciles); reconciles != 1 {
+		t.Errorf("expecting 1 reconcile after the burst, got %d", reconciles)
+	}
+	if coalesced := testutil.ToFloat64(relistBurstsCoalesced) - before; coalesced != 1 {
+		t.Errorf("expecting 1 coalesced burst, got %v", coalesced)
+	}
+
+	// a few unchanged updates are not a burst
+	for i := 0; i < threshold-1; i++ {
+		if coalescer.coalesce(deploymentUpdate(fmt.Sprintf("deployment%d", i), "100", "100")) {
+			t.Errorf("expecting unchanged update %d below the threshold to be processed", i)
+		}
+	}
+}
+
+func TestRelistCoalescerDisabled(t *testing.T) {
+	coalescer := newRelistCoalescer(0, time.Second, func() {})
+	if coalescer != nil {
+		t.Fatal("expecting no coalescer with a threshold of 0")
+	}
+	for i := 0; i < 10; i++ {
+		if coalescer.coalesce(deploymentUpdate("deployment1", "100", "100")) {
+			t.Fatal("expecting all updates processed without coalescer")
+		}
+	}
+}
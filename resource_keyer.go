@@ -0,0 +1,61 @@
+/*
+Copyright 2019 IBM Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+/*
+ Resources are identified by a key in the maps of a batch, the caches, and the
+ retry queue. By default the key is the GVR, namespace, name, and uid, so
+ that a resource deleted and re-created with the same name is a different
+ resource. A different keyer, e.g. on the namespace and name only, can be set
+ on the ClusterWatcher to change what is de-duplicated.
+*/
+
+// Return the key identifying a resource
+type resourceKeyer func(resInfo *resourceInfo) string
+
+// Default keyer, on the GVR, namespace, name, and uid of the resource
+func defaultKeyer(resInfo *resourceInfo) string {
+	return resInfo.key()
+}
+
+// Return the key of the resource. Without keyer, the default key
+func (keyer resourceKeyer) keyOf(resInfo *resourceInfo) string {
+	if keyer == nil {
+		return resInfo.key()
+	}
+	return keyer(resInfo)
+}
+
+// Return the key identifying the resource in the maps, caches, and queues of the controller
+func (resController *ClusterWatcher) resourceKey(resInfo *resourceInfo) string {
+	return resController.keyer.keyOf(resInfo)
+}
+
+// Change how resources are keyed, in the controller and in its caches and queues.
+// Must be called before any resource is processed
+func (resController *ClusterWatcher) setKeyer(keyer resourceKeyer) {
+	resController.keyer = keyer
+	if resController.statusCache != nil {
+		resController.statusCache.keyer = keyer
+	}
+	if resController.statusRetries != nil {
+		resController.statusRetries.keyer = keyer
+	}
+	if resController.deletedComponents != nil {
+		resController.deletedComponents.keyer = keyer
+	}
+}
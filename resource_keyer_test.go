@@ -0,0 +1,83 @@
+/*
+Copyright 2019 IBM Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// Keyer on the uid, falling back to the namespaced name for resources without uid
+func uidKeyer(resInfo *resourceInfo) string {
+	if resInfo.uid == "" {
+		return resInfo.key()
+	}
+	return resInfo.gvr.String() + "/" + resInfo.uid
+}
+
+// Keyer on the namespace and name only
+func namespacedNameKeyer(resInfo *resourceInfo) string {
+	return resInfo.gvr.String() + "/" + resInfo.namespace + "/" + resInfo.name
+}
+
+// Test a resource deleted and re-created with the same name is kept apart with the
+// default keyer and a keyer on the uid, and de-duplicated with a keyer on the name
+func TestResourceKeyer(t *testing.T) {
+	var testData = []struct {
+		keyer    resourceKeyer
+		expected int
+	}{
+		{nil, 2},
+		{defaultKeyer, 2},
+		{uidKeyer, 2},
+		{namespacedNameKeyer, 1},
+	}
+	for index, data := range testData {
+		resController := &ClusterWatcher{
+			resourceChannel: newResourceChannel(),
+			statusCache:     newStatusCache(),
+			statusRetries:   newStatusRetryQueue(10, time.Minute, nil),
+		}
+		resController.setKeyer(data.keyer)
+		ts := newBatchStore(resController, time.Millisecond*100)
+
+		deleted := &resourceInfo{gvr: coreApplicationGVR, kind: APPLICATION, namespace: "default", name: "bookinfo", uid: "uid-1"}
+		recreated := &resourceInfo{gvr: coreApplicationGVR, kind: APPLICATION, namespace: "default", name: "bookinfo", uid: "uid-2"}
+		for _, resInfo := range []*resourceInfo{deleted, recreated} {
+			resController.resourceChannel.send(&batchResources{
+				applications:    map[string]*resourceInfo{resController.resourceKey(resInfo): resInfo},
+				nonApplications: map[string]*resourceInfo{},
+			})
+			resController.statusCache.set(resInfo, Normal, nil)
+			resController.statusRetries.enqueue(resInfo, Normal, "", "")
+		}
+
+		resources, ok := ts.getNextBatch()
+		if !ok {
+			t.Fatal("batch store closed")
+		}
+		if len(resources.applications) != data.expected {
+			t.Errorf("keyer %d: expecting %d applications in batch, got %d", index, data.expected, len(resources.applications))
+		}
+		if cached := len(resController.statusCache.dump("", maxStatusCacheDumpEntries).Applications); cached != data.expected {
+			t.Errorf("keyer %d: expecting %d applications in status cache, got %d", index, data.expected, cached)
+		}
+		if pending := resController.statusRetries.len(); pending != data.expected {
+			t.Errorf("keyer %d: expecting %d pending status updates, got %d", index, data.expected, pending)
+		}
+	}
+}
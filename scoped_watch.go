@@ -0,0 +1,293 @@
+/*
+Copyright 2019 IBM Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/klog"
+)
+
+/*
+ A GVR whose events are only processed in a few permitted namespaces is
+ watched with one informer per namespace instead of one for all namespaces,
+ so that the resources of other namespaces, e.g. the pods of a large cluster,
+ are not kept in memory only to be filtered out. The informers share one
+ cache, which looks the same as that of a cluster-wide informer. Once the
+ permitted namespaces exceed scopedWatchMaxNamespaces, the GVR is watched in
+ all namespaces again.
+*/
+
+// Return the permitted namespaces to watch a GVR in with one informer each, or nil to
+// watch all namespaces with a single informer. Only a namespaced GVR whose events are
+// filtered by namespace, without all namespaces permitted, and with at most maxNamespaces
+// permitted namespaces is watched one namespace at a time. 0 to always watch all namespaces
+func scopedWatchNamespaces(namespaced bool, filtered bool, allPermitted bool, permitted map[string]string, maxNamespaces int) []string {
+	if maxNamespaces <= 0 || !namespaced || !filtered || allPermitted || len(permitted) > maxNamespaces {
+		return nil
+	}
+	namespaces := make([]string, 0, len(permitted))
+	for namespace := range permitted {
+		if namespace == metav1.NamespaceAll {
+			return nil
+		}
+		namespaces = append(namespaces, namespace)
+	}
+	sort.Strings(namespaces)
+	return namespaces
+}
+
+// Return the namespaces to watch a GVR in with one informer each, or nil to watch all namespaces
+func (resController *ClusterWatcher) watchNamespaces(gvr schema.GroupVersionResource, namespaced bool) []string {
+	if isApplicationGVR(gvr) {
+		// applications are processed in all namespaces
+		return nil
+	}
+	allPermitted := resController.nsFilter.isAllNamespacesPermitted(gvr) && resController.isAllNamespacesPermitted()
+	return scopedWatchNamespaces(namespaced, resController.handlerMgr.onlyNamespaceFiltered(gvr), allPermitted,
+		resController.nsFilter.namespacesOf(gvr), scopedWatchMaxNamespaces)
+}
+
+// Extend the scoped watch of a GVR to a newly permitted namespace, or watch the GVR
+// in all namespaces once there are too many to watch one by one
+func (resController *ClusterWatcher) extendScopedWatch(gvr schema.GroupVersionResource, namespace string) {
+	resController.mutex.Lock()
+	rw, ok := resController.resourceMap[gvr]
+	var scoped *scopedController
+	if ok {
+		scoped = rw.scoped
+	}
+	resController.mutex.Unlock()
+	if scoped == nil {
+		return
+	}
+	if resController.watchNamespaces(gvr, rw.namespaced) == nil {
+		if klog.V(2) {
+			klog.Infof("watching %s in all namespaces", gvr)
+		}
+		scoped.watchAllNamespaces()
+		return
+	}
+	if klog.V(2) {
+		klog.Infof("watching %s in namespace %s", gvr, namespace)
+	}
+	scoped.addNamespace(namespace)
+}
+
+// Informers of a GVR, one for each watched namespace or one for all namespaces,
+// sharing one cache. Implements cache.Controller
+type scopedController struct {
+	gvr           schema.GroupVersionResource
+	dynamicClient dynamic.Interface
+	resyncPeriod  time.Duration
+	handler       cache.ResourceEventHandler
+	indexer       cache.Indexer                 // cache shared by the informers
+	informers     map[string]*namespaceInformer // informer of each namespace. metav1.NamespaceAll for all namespaces
+	stopCh        <-chan struct{}               // stops all informers. nil until running
+	stopped       bool                          // true once stopped. No more informers are started
+	mutex         sync.Mutex
+}
+
+// Informer of one namespace
+type namespaceInformer struct {
+	controller cache.Controller
+	stopCh     chan struct{}
+}
+
+func newScopedController(gvr schema.GroupVersionResource, dynamicClient dynamic.Interface, resyncPeriod time.Duration,
+	handler cache.ResourceEventHandler, namespaces []string) *scopedController {
+	scoped := &scopedController{
+		gvr:           gvr,
+		dynamicClient: dynamicClient,
+		resyncPeriod:  resyncPeriod,
+		handler:       handler,
+		indexer:       cache.NewIndexer(cache.DeletionHandlingMetaNamespaceKeyFunc, cache.Indexers{}),
+		informers:     make(map[string]*namespaceInformer),
+	}
+	for _, namespace := range namespaces {
+		scoped.addNamespace(namespace)
+	}
+	return scoped
+}
+
+// Create the informer of a namespace, storing into the shared cache.
+// Must be called with the mutex held
+func (scoped *scopedController) newInformer(namespace string) *namespaceInformer {
+	// on a relist, only the objects of the namespace that are not listed were deleted
+	fifo := cache.NewDeltaFIFO(cache.MetaNamespaceKeyFunc, namespaceKeys{scoped.indexer, namespace})
+	controller := cache.New(&cache.Config{
+		Queue:            fifo,
+		ListerWatcher:    createListWatcher(scoped.dynamicClient, scoped.gvr, namespace),
+		FullResyncPeriod: scoped.resyncPeriod,
+		RetryOnError:     false,
+		Process: func(obj interface{}) error {
+			// as cache.NewIndexerInformer
+			for _, delta := range obj.(cache.Deltas) {
+				switch delta.Type {
+				case cache.Sync, cache.Added, cache.Updated:
+					if old, exists, err := scoped.indexer.Get(delta.Object); err == nil && exists {
+						if err := scoped.indexer.Update(delta.Object); err != nil {
+							return err
+						}
+						scoped.handler.OnUpdate(old, delta.Object)
+					} else {
+						if err := scoped.indexer.Add(delta.Object); err != nil {
+							return err
+						}
+						scoped.handler.OnAdd(delta.Object)
+					}
+				case cache.Deleted:
+					if err := scoped.indexer.Delete(delta.Object); err != nil {
+						return err
+					}
+					scoped.handler.OnDelete(delta.Object)
+				}
+			}
+			return nil
+		},
+	})
+	return &namespaceInformer{controller: controller, stopCh: make(chan struct{})}
+}
+
+// Start an informer, if the informers are running. Must be called with the mutex held
+func (scoped *scopedController) startInformer(informer *namespaceInformer) {
+	if scoped.stopCh != nil {
+		go informer.controller.Run(informer.stopCh)
+	}
+}
+
+// Watch another namespace, unless already watching all namespaces
+func (scoped *scopedController) addNamespace(namespace string) {
+	scoped.mutex.Lock()
+	defer scoped.mutex.Unlock()
+	if scoped.stopped {
+		return
+	}
+	if _, ok := scoped.informers[metav1.NamespaceAll]; ok {
+		return
+	}
+	if _, ok := scoped.informers[namespace]; ok {
+		return
+	}
+	informer := scoped.newInformer(namespace)
+	scoped.informers[namespace] = informer
+	scoped.startInformer(informer)
+}
+
+// Watch all namespaces with one informer, replacing the informers of each namespace.
+// Objects already cached are delivered again as updates
+func (scoped *scopedController) watchAllNamespaces() {
+	scoped.mutex.Lock()
+	defer scoped.mutex.Unlock()
+	if scoped.stopped {
+		return
+	}
+	if _, ok := scoped.informers[metav1.NamespaceAll]; ok {
+		return
+	}
+	informer := scoped.newInformer(metav1.NamespaceAll)
+	scoped.startInformer(informer)
+	for namespace, old := range scoped.informers {
+		close(old.stopCh)
+		delete(scoped.informers, namespace)
+	}
+	scoped.informers[metav1.NamespaceAll] = informer
+}
+
+// Run all informers until stopCh is closed or sent to
+func (scoped *scopedController) Run(stopCh <-chan struct{}) {
+	scoped.mutex.Lock()
+	scoped.stopCh = stopCh
+	for _, informer := range scoped.informers {
+		scoped.startInformer(informer)
+	}
+	scoped.mutex.Unlock()
+
+	<-stopCh
+
+	scoped.mutex.Lock()
+	defer scoped.mutex.Unlock()
+	for _, informer := range scoped.informers {
+		close(informer.stopCh)
+	}
+	scoped.informers = make(map[string]*namespaceInformer)
+	scoped.stopped = true
+}
+
+// HasSynced returns true once all informers have synced
+func (scoped *scopedController) HasSynced() bool {
+	scoped.mutex.Lock()
+	defer scoped.mutex.Unlock()
+	for _, informer := range scoped.informers {
+		if !informer.controller.HasSynced() {
+			return false
+		}
+	}
+	return true
+}
+
+// LastSyncResourceVersion is not meaningful across namespaces
+func (scoped *scopedController) LastSyncResourceVersion() string {
+	return ""
+}
+
+// Return the namespaces watched one by one, or nil when watching all namespaces
+func (scoped *scopedController) namespaces() []string {
+	scoped.mutex.Lock()
+	defer scoped.mutex.Unlock()
+	if _, ok := scoped.informers[metav1.NamespaceAll]; ok {
+		return nil
+	}
+	namespaces := make([]string, 0, len(scoped.informers))
+	for namespace := range scoped.informers {
+		namespaces = append(namespaces, namespace)
+	}
+	sort.Strings(namespaces)
+	return namespaces
+}
+
+// Keys of the objects of one namespace in the shared cache, known to the informer of the namespace
+type namespaceKeys struct {
+	indexer   cache.Indexer
+	namespace string // metav1.NamespaceAll for all keys
+}
+
+func (keys namespaceKeys) ListKeys() []string {
+	all := keys.indexer.ListKeys()
+	if keys.namespace == metav1.NamespaceAll {
+		return all
+	}
+	prefix := keys.namespace + "/"
+	ret := make([]string, 0, len(all))
+	for _, key := range all {
+		if strings.HasPrefix(key, prefix) {
+			ret = append(ret, key)
+		}
+	}
+	return ret
+}
+
+func (keys namespaceKeys) GetByKey(key string) (interface{}, bool, error) {
+	return keys.indexer.GetByKey(key)
+}
@@ -0,0 +1,141 @@
+/*
+Copyright 2019 IBM Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestScopedWatchNamespaces(t *testing.T) {
+	twoNamespaces := map[string]string{"ns2": "ns2", "ns1": "ns1"}
+	tests := []struct {
+		name          string
+		namespaced    bool
+		filtered      bool
+		allPermitted  bool
+		permitted     map[string]string
+		maxNamespaces int
+		expected      []string
+	}{
+		{"scoped", true, true, false, twoNamespaces, 2, []string{"ns1", "ns2"}},
+		{"no namespace permitted yet", true, true, false, map[string]string{}, 2, []string{}},
+		{"too many namespaces", true, true, false, twoNamespaces, 1, nil},
+		{"disabled", true, true, false, twoNamespaces, 0, nil},
+		{"not namespaced", false, true, false, twoNamespaces, 2, nil},
+		{"processed in all namespaces", true, false, false, twoNamespaces, 2, nil},
+		{"all namespaces permitted", true, true, true, twoNamespaces, 2, nil},
+		{"wildcard namespace", true, true, false, map[string]string{"": ""}, 2, nil},
+	}
+	for _, test := range tests {
+		namespaces := scopedWatchNamespaces(test.namespaced, test.filtered, test.allPermitted, test.permitted, test.maxNamespaces)
+		if !reflect.DeepEqual(namespaces, test.expected) {
+			t.Errorf("%s: expecting namespaces %#v, got %#v", test.name, test.expected, namespaces)
+		}
+	}
+}
+
+func TestWatchNamespaces(t *testing.T) {
+	savedMaxNamespaces := scopedWatchMaxNamespaces
+	scopedWatchMaxNamespaces = 2
+	defer func() {
+		scopedWatchMaxNamespaces = savedMaxNamespaces
+	}()
+	resController := &ClusterWatcher{
+		handlerMgr: newHandlerManager(),
+		nsFilter:   newNamespaceFilter(),
+	}
+	for _, gvr := range []schema.GroupVersionResource{coreServiceGVR, coreDeploymentGVR, coreApplicationGVR} {
+		resController.nsFilter.addNamespaceForGVR(gvr, "ns1")
+	}
+
+	if namespaces := resController.watchNamespaces(coreServiceGVR, true); !reflect.DeepEqual(namespaces, []string{"ns1"}) {
+		t.Errorf("expecting Services watched in namespace ns1, got %v", namespaces)
+	}
+	// also processed by the auto-create handler in all namespaces
+	if namespaces := resController.watchNamespaces(coreDeploymentGVR, true); namespaces != nil {
+		t.Errorf("expecting Deployments watched in all namespaces, got %v", namespaces)
+	}
+	if namespaces := resController.watchNamespaces(coreApplicationGVR, true); namespaces != nil {
+		t.Errorf("expecting Applications watched in all namespaces, got %v", namespaces)
+	}
+}
+
+// wait until the cache holds the configmaps with the given keys
+func waitForCachedKeys(t *testing.T, store cache.Store, expected []string) {
+	t.Helper()
+	sort.Strings(expected)
+	deadline := time.Now().Add(time.Second * 5)
+	for {
+		keys := store.ListKeys()
+		sort.Strings(keys)
+		if reflect.DeepEqual(keys, expected) {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for cached keys %v, got %v", expected, keys)
+		}
+		time.Sleep(time.Millisecond * 10)
+	}
+}
+
+func TestScopedController(t *testing.T) {
+	client := fake.NewSimpleDynamicClient(runtime.NewScheme())
+	for _, namespace := range []string{"ns1", "ns2", "ns3"} {
+		configMap := &unstructured.Unstructured{}
+		configMap.SetAPIVersion("v1")
+		configMap.SetKind("ConfigMap")
+		configMap.SetNamespace(namespace)
+		configMap.SetName("cm")
+		if _, err := client.Resource(coreConfigMapGVR).Namespace(namespace).Create(configMap, metav1.CreateOptions{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	scoped := newScopedController(coreConfigMapGVR, client, 0, cache.ResourceEventHandlerFuncs{}, []string{"ns1"})
+	stopCh := make(chan struct{})
+	go scoped.Run(stopCh)
+	if !cache.WaitForCacheSync(stopCh, scoped.HasSynced) {
+		t.Fatal("timed out waiting for the cache to sync")
+	}
+	waitForCachedKeys(t, scoped.indexer, []string{"ns1/cm"})
+
+	// another namespace permitted
+	scoped.addNamespace("ns2")
+	waitForCachedKeys(t, scoped.indexer, []string{"ns1/cm", "ns2/cm"})
+	if namespaces := scoped.namespaces(); !reflect.DeepEqual(namespaces, []string{"ns1", "ns2"}) {
+		t.Errorf("expecting namespaces ns1 and ns2 watched, got %v", namespaces)
+	}
+
+	// too many namespaces
+	scoped.watchAllNamespaces()
+	waitForCachedKeys(t, scoped.indexer, []string{"ns1/cm", "ns2/cm", "ns3/cm"})
+	if namespaces := scoped.namespaces(); namespaces != nil {
+		t.Errorf("expecting all namespaces watched, got %v", namespaces)
+	}
+
+	close(stopCh)
+}
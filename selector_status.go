@@ -0,0 +1,223 @@
+/*
+Copyright 2019 IBM Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/klog"
+)
+
+/*
+ Status for an ad-hoc label selector, computed the same way as for an
+ Application, but without any Application resource.
+*/
+
+// Request body of POST /status-for-selector
+type selectorStatusRequest struct {
+	// namespaces of components. Empty for all permitted namespaces
+	Namespaces []string `json:"namespaces"`
+	// kinds of components. Empty for all watched kinds
+	ComponentKinds []selectorComponentKind `json:"componentKinds"`
+	Selector       selectorStatusSelector  `json:"selector"`
+}
+
+// A component kind, same as in spec.componentKinds of an Application
+type selectorComponentKind struct {
+	Group string `json:"group"`
+	Kind  string `json:"kind"`
+}
+
+// A label selector, same as in spec.selector of an Application
+type selectorStatusSelector struct {
+	MatchLabels      map[string]string         `json:"matchLabels"`
+	MatchExpressions []selectorMatchExpression `json:"matchExpressions"`
+}
+
+// An expression in matchExpressions of a label selector
+type selectorMatchExpression struct {
+	Key      string   `json:"key"`
+	Operator string   `json:"operator"`
+	Values   []string `json:"values"`
+	Source   string   `json:"source,omitempty"` // label or annotation. Empty for label
+}
+
+// Response of POST /status-for-selector
+type selectorStatusResponse struct {
+	Status     string                    `json:"status"`
+	Breakdown  map[string]int            `json:"breakdown"`
+	Components []selectorStatusComponent `json:"components"`
+}
+
+// A component matching the selector, and its status
+type selectorStatusComponent struct {
+	Kind      string `json:"kind"`
+	Namespace string `json:"namespace,omitempty"`
+	Name      string `json:"name"`
+	Status    string `json:"status"`
+}
+
+// Convert the request to application resource info for matching components
+func (resController *ClusterWatcher) selectorRequestToAppResource(req *selectorStatusRequest) (*appResourceInfo, error) {
+	if len(req.Selector.MatchLabels) == 0 && len(req.Selector.MatchExpressions) == 0 {
+		return nil, fmt.Errorf("selector must have matchLabels or matchExpressions")
+	}
+
+	appInfo := &appResourceInfo{}
+	appInfo.kind = APPLICATION
+	appInfo.matchLabels = req.Selector.MatchLabels
+	for _, expr := range req.Selector.MatchExpressions {
+		switch expr.Operator {
+		case OperatorIn, OperatorNotIn, OperatorExists, OperatorDoesNotExist:
+		default:
+			return nil, fmt.Errorf("invalid operator %s for key %s", expr.Operator, expr.Key)
+		}
+		switch expr.Source {
+		case "", expressionSourceLabel, expressionSourceAnnotation:
+		default:
+			return nil, fmt.Errorf("invalid source %s for key %s", expr.Source, expr.Key)
+		}
+		appInfo.matchExpressions = append(appInfo.matchExpressions,
+			matchExpression{key: expr.Key, operator: expr.Operator, values: expr.Values, source: expr.Source})
+	}
+
+	appInfo.componentNamespaces = make(map[string]string)
+	for _, ns := range req.Namespaces {
+		if resController.isNamespacePermitted(ns) {
+			appInfo.componentNamespaces[ns] = ns
+		}
+	}
+
+	if len(req.ComponentKinds) > 0 {
+		for _, component := range req.ComponentKinds {
+			gvr, ok := resController.getGVRForGroupKind(component.Group, component.Kind)
+			if !ok {
+				return nil, fmt.Errorf("unknown component kind %s/%s", component.Group, component.Kind)
+			}
+			appInfo.componentKinds = append(appInfo.componentKinds, groupKind{group: component.Group, kind: component.Kind, gvr: gvr})
+		}
+	} else {
+		// all watched kinds
+		resController.mutex.Lock()
+		for gvr, rw := range resController.resourceMap {
+			if rw.kind != "" && rw.kind != CustomResourceDefinition {
+				appInfo.componentKinds = append(appInfo.componentKinds, groupKind{group: gvr.Group, kind: rw.kind, gvr: gvr})
+			}
+		}
+		resController.mutex.Unlock()
+	}
+	return appInfo, nil
+}
+
+// Compute the status of the resources matching the selector of the request,
+// using the cached resources and their kappnav status
+func (resController *ClusterWatcher) statusForSelector(req *selectorStatusRequest) (*selectorStatusResponse, error) {
+	appInfo, err := resController.selectorRequestToAppResource(req)
+	if err != nil {
+		return nil, err
+	}
+	allNamespaces := len(req.Namespaces) == 0
+
+	checker := newStatusChecker(resController.getStatusConfig())
+	components := make([]selectorStatusComponent, 0)
+	seen := make(map[string]bool)
+	for _, component := range appInfo.componentKinds {
+		for _, obj := range resController.listResources(component.gvr) {
+			unstructuredObj, ok := obj.(*unstructured.Unstructured)
+			if !ok {
+				continue
+			}
+			var resInfo = &resourceInfo{}
+			resController.parseResource(unstructuredObj, resInfo)
+			if seen[resController.resourceKey(resInfo)] {
+				continue
+			}
+			if allNamespaces && resInfo.namespace != "" && resController.isNamespacePermitted(resInfo.namespace) {
+				appInfo.componentNamespaces[resInfo.namespace] = resInfo.namespace
+			}
+			if !resourceComponentOfApplication(resController, appInfo, resInfo) {
+				continue
+			}
+			seen[resController.resourceKey(resInfo)] = true
+
+			stat := resInfo.kappnavStatVal
+			if stat == "" && resInfo.kind != APPLICATION {
+				// status not yet computed by the controller
+				stat, _, _, err = resController.readComponentStatus(resInfo)
+				if err != nil {
+					if klog.V(2) {
+						klog.Infof("statusForSelector unable to get status of %s %s %s: %s", resInfo.kind, resInfo.namespace, resInfo.name, err)
+					}
+					stat = resController.getUnknownStatus()
+				}
+			}
+			if stat == "" {
+				stat = resController.getUnknownStatus()
+			}
+			checker.addStatus(stat)
+			components = append(components, selectorStatusComponent{
+				Kind: resInfo.kind, Namespace: resInfo.namespace, Name: resInfo.name, Status: stat})
+		}
+	}
+	sort.Slice(components, func(i, j int) bool {
+		if components[i].Kind != components[j].Kind {
+			return components[i].Kind < components[j].Kind
+		}
+		if components[i].Namespace != components[j].Namespace {
+			return components[i].Namespace < components[j].Namespace
+		}
+		return components[i].Name < components[j].Name
+	})
+
+	return &selectorStatusResponse{
+		Status:     checker.finalStatus(),
+		Breakdown:  checker.breakdown(),
+		Components: components,
+	}, nil
+}
+
+// Handler for POST /status-for-selector
+func statusForSelectorHandler(resController *ClusterWatcher) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		var req selectorStatusRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid request: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		resp, err := resController.statusForSelector(&req)
+		if err != nil {
+			http.Error(w, "invalid request: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if klog.V(4) {
+			klog.Infof("statusForSelector selector: %+v status: %s", req.Selector, resp.Status)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(resp); err != nil && klog.V(2) {
+			klog.Infof("statusForSelector unable to write response: %s", err)
+		}
+	}
+}
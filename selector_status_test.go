@@ -0,0 +1,140 @@
+/*
+Copyright 2019 IBM Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// POST the request body to /status-for-selector
+func postStatusForSelector(resController *ClusterWatcher, method string, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, "/status-for-selector", strings.NewReader(body))
+	recorder := httptest.NewRecorder()
+	newHTTPHandler(resController).ServeHTTP(recorder, req)
+	return recorder
+}
+
+type selectorStatusTestData struct {
+	body               string
+	expectedCode       int
+	expectedStatus     string
+	expectedComponents []string // kind/namespace/name
+}
+
+var selectorStatusTestDataArray = []selectorStatusTestData{
+	// matchLabels, all kinds and namespaces
+	{body: `{"selector": {"matchLabels": {"app": "productpage"}}}`,
+		expectedCode: http.StatusOK, expectedStatus: Normal,
+		expectedComponents: []string{"Deployment/default/productpage-v1", "Service/default/productpage"}},
+	// matchExpressions, only deployments
+	{body: `{"namespaces": ["default"], "componentKinds": [{"group": "apps", "kind": "Deployment"}],
+		"selector": {"matchExpressions": [{"key": "app", "operator": "In", "values": ["details", "ratings"]}]}}`,
+		expectedCode: http.StatusOK, expectedStatus: warning,
+		expectedComponents: []string{"Deployment/default/details-v1", "Deployment/default/ratings-v1"}},
+	// both must match
+	{body: `{"selector": {"matchLabels": {"app": "ratings"}, "matchExpressions": [{"key": "version", "operator": "Exists"}]}}`,
+		expectedCode: http.StatusOK, expectedStatus: warning,
+		expectedComponents: []string{"Deployment/default/ratings-v1"}},
+	// no namespace in scope
+	{body: `{"namespaces": ["other"], "selector": {"matchLabels": {"app": "productpage"}}}`,
+		expectedCode: http.StatusOK, expectedStatus: "Unknown",
+		expectedComponents: []string{}},
+	// invalid requests
+	{body: `{"selector": {}}`, expectedCode: http.StatusBadRequest},
+	{body: `{"selector": {"matchExpressions": [{"key": "app", "operator": "Equals"}]}}`, expectedCode: http.StatusBadRequest},
+	{body: `{"selector": `, expectedCode: http.StatusBadRequest},
+}
+
+// Test status is computed for resources matching a selector without any application
+func TestStatusForSelector(t *testing.T) {
+	testName := "TestStatusForSelector"
+	beforeTest()
+	// the applications select none of the components, and only get Services
+	// and Deployments watched. No status is written to the components
+	var kindsToCheckStatus = map[string]bool{
+		APPLICATION: true,
+	}
+
+	var files = []string{
+		/* 0 */ CrdApplication,
+		/* 1 */ deploymentProcuctpageV1,
+		/* 2 */ serviceProductpage,
+		/* 3 */ deploymentDetailsV1,
+		/* 4 */ serviceDetails,
+		/* 5 */ deploymentRatingsV1,
+		/* 6 */ KappnavConfigFile,
+		/* 7 */ aApp,
+		/* 8 */ cApp,
+	}
+	iteration0IDs, err := readResourceIDs(files)
+	if err != nil {
+		t.Fatal(err)
+	}
+	iteration0IDs[5].expectedStatus = warning
+	iteration0IDs[7].expectedStatus = unknown
+	iteration0IDs[8].expectedStatus = unknown
+
+	testActions := newTestActions(testName, kindsToCheckStatus)
+	var emptyIDs = []resourceID{}
+	testActions.addIteration(iteration0IDs, emptyIDs)
+
+	clusterWatcher, err := createClusterWatcher(iteration0IDs, testActions, StatusFailureRate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer clusterWatcher.shutDown()
+
+	err = testActions.transitionAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, data := range selectorStatusTestDataArray {
+		recorder := postStatusForSelector(clusterWatcher, http.MethodPost, data.body)
+		if recorder.Code != data.expectedCode {
+			t.Errorf("request %s: expecting code %d, got %d: %s", data.body, data.expectedCode, recorder.Code, recorder.Body.String())
+			continue
+		}
+		if data.expectedCode != http.StatusOK {
+			continue
+		}
+		var resp selectorStatusResponse
+		if err := json.Unmarshal(recorder.Body.Bytes(), &resp); err != nil {
+			t.Errorf("request %s: unable to parse response %s: %s", data.body, recorder.Body.String(), err)
+			continue
+		}
+		if resp.Status != data.expectedStatus {
+			t.Errorf("request %s: expecting status %s, got %s", data.body, data.expectedStatus, resp.Status)
+		}
+		var components = make([]string, 0, len(resp.Components))
+		for _, component := range resp.Components {
+			components = append(components, componentKey(component.Kind, component.Namespace, component.Name))
+		}
+		if strings.Join(components, ",") != strings.Join(data.expectedComponents, ",") {
+			t.Errorf("request %s: expecting components %v, got %v", data.body, data.expectedComponents, components)
+		}
+	}
+
+	recorder := postStatusForSelector(clusterWatcher, http.MethodGet, "")
+	if recorder.Code != http.StatusMethodNotAllowed {
+		t.Errorf("expecting code %d for GET, got %d", http.StatusMethodNotAllowed, recorder.Code)
+	}
+}
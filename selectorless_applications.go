@@ -0,0 +1,65 @@
+/*
+Copyright 2019 IBM Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+/*
+ An application with an empty or missing selector, and no components
+ listed, matches no components. Unless configured to silently match nothing, a condition is
+ recorded on the application so that users notice.
+*/
+
+// Settings of the selectorlessApplications flag: what to do with applications with an empty or missing selector
+const (
+	selectorlessMatchNothing = "matchNothing" // match no components, silently
+	selectorlessWarn         = "warn"         // match no components, and record a condition
+
+	defaultSelectorlessApplications = selectorlessWarn
+
+	// type of the condition recording whether the application selects any component
+	selectorSpecifiedCondition = "SelectorSpecified"
+
+	noSelectorReason = "NoSelector"
+	selectorReason   = "SelectorSpecified"
+)
+
+// Return true if the value is a valid setting of the selectorlessApplications flag
+func validSelectorlessApplications(value string) bool {
+	return value == selectorlessMatchNothing || value == selectorlessWarn
+}
+
+// Return true if the application has neither matchLabels, matchExpressions, nor listed components,
+// whether its selector is empty or missing
+func isSelectorless(appInfo *appResourceInfo) bool {
+	return len(appInfo.matchLabels) == 0 && len(appInfo.matchExpressions) == 0 && len(appInfo.components) == 0
+}
+
+// Return the condition recording whether the application has a selector, unless selectorless
+// applications silently match nothing
+func newSelectorCondition(appInfo *appResourceInfo) applicationCondition {
+	warn := isSelectorless(appInfo) && selectorlessApplications == selectorlessWarn
+	cond := &statusCondition{conditionType: selectorSpecifiedCondition}
+	if warn {
+		cond.status = conditionFalse
+		cond.reason = noSelectorReason
+		cond.message = "the selector is missing or has neither matchLabels nor matchExpressions, and no components are listed, so the application has no components"
+	} else {
+		cond.status = conditionTrue
+		cond.reason = selectorReason
+	}
+	// nothing to clear if the application never had a warning
+	return applicationCondition{cond, !warn}
+}
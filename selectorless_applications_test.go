@@ -0,0 +1,117 @@
+/*
+Copyright 2019 IBM Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const appSelectorless = "test_data/selectorless-app.json"
+
+// Test an application without selector gets a warning condition, and one with a selector does not
+func TestSelectorlessApplicationCondition(t *testing.T) {
+	testName := "TestSelectorlessApplicationCondition"
+	beforeTest()
+	savedSelectorlessApplications := selectorlessApplications
+	selectorlessApplications = selectorlessWarn
+	defer func() {
+		selectorlessApplications = savedSelectorlessApplications
+	}()
+
+	var files = []string{
+		/* 0 */ KappnavConfigFile,
+		/* 1 */ CrdApplication,
+		/* 2 */ appSelectorless,
+		/* 3 */ appProductpage,
+	}
+	iteration0IDs, err := readResourceIDs(files)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testActions := newTestActions(testName, map[string]bool{})
+	testActions.addIteration(iteration0IDs, []resourceID{})
+
+	clusterWatcher, err := createClusterWatcher(iteration0IDs, testActions, StatusFailureRate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer clusterWatcher.shutDown()
+
+	// wait for the application to be processed
+	intf := clusterWatcher.plugin.dynamicClient.Resource(coreApplicationGVR).Namespace("default")
+	var cond *statusCondition
+	for i := 0; i < 20 && cond == nil; i++ {
+		app, err := intf.Get("selectorless-app", metav1.GetOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cond = getStatusCondition(app, selectorSpecifiedCondition); cond == nil {
+			time.Sleep(time.Millisecond * 500)
+		}
+	}
+	if cond == nil {
+		t.Fatalf("timed out waiting for condition %s on the application", selectorSpecifiedCondition)
+	}
+	if cond.status != conditionFalse || cond.reason != noSelectorReason {
+		t.Errorf("expecting condition %s %s, got %s %s", conditionFalse, noSelectorReason, cond.status, cond.reason)
+	}
+
+	app, err := intf.Get("productpage-app", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cond := getStatusCondition(app, selectorSpecifiedCondition); cond != nil {
+		t.Errorf("expecting no condition %s on an application with a selector, got %s %s", selectorSpecifiedCondition, cond.status, cond.reason)
+	}
+}
+
+func TestIsSelectorless(t *testing.T) {
+	var resController = &ClusterWatcher{}
+	initControllerMaps(resController)
+	for _, data := range []struct {
+		fileName string
+		expected bool
+	}{
+		{appSelectorless, true},
+		{appProductpage, false},
+	} {
+		appObj, err := readJSON(data.fileName)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var appInfo = &appResourceInfo{}
+		if err = resController.parseAppResource(appObj, appInfo); err != nil {
+			t.Fatal(err)
+		}
+		if selectorless := isSelectorless(appInfo); selectorless != data.expected {
+			t.Errorf("%s: expecting selectorless %t, got %t", data.fileName, data.expected, selectorless)
+		}
+	}
+
+	// a missing selector is the same as an empty one
+	var appInfo = &appResourceInfo{}
+	if err := resController.parseAppResource(appWithSpec(map[string]interface{}{"componentKinds": componentKindsSpec}), appInfo); err != nil {
+		t.Fatalf("expecting an application without selector to parse, got %s", err)
+	}
+	if !isSelectorless(appInfo) {
+		t.Error("expecting an application without selector to be selectorless")
+	}
+}
@@ -0,0 +1,70 @@
+/*
+Copyright 2019 IBM Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/klog"
+)
+
+const (
+	// DefaultStartupRetries - attempts to create the clients at startup before exiting
+	DefaultStartupRetries = 6
+
+	// DefaultStartupRetryDelay - delay before the first retry at startup. Doubles with each retry
+	DefaultStartupRetryDelay = time.Second
+)
+
+// Call fn until it succeeds, at most attempts times, waiting delay before the first retry and
+// doubling the delay for each following retry. Return the error of the last attempt if none succeeds.
+// Used at startup, when the API server may not be ready yet, e.g. during cluster bootstrap
+func retryWithBackoff(operation string, attempts int, delay time.Duration, fn func() error) error {
+	return retryOnError(operation, attempts, delay, func(error) bool { return true }, fn)
+}
+
+// Same as retryWithBackoff, but only retry errors for which retriable returns true.
+// Other errors are returned immediately
+func retryOnError(operation string, attempts int, delay time.Duration, retriable func(error) bool, fn func() error) error {
+	if attempts < 1 {
+		attempts = 1
+	}
+	backoff := wait.Backoff{Duration: delay, Factor: 2, Steps: attempts}
+	attempt := 0
+	var lastErr error
+	err := wait.ExponentialBackoff(backoff, func() (bool, error) {
+		attempt++
+		lastErr = fn()
+		if lastErr == nil {
+			return true, nil
+		}
+		if attempt >= attempts || !retriable(lastErr) {
+			return false, lastErr
+		}
+		klog.Errorf("%s failed, attempt %d of %d, retrying in %s: %s", operation, attempt, attempts, delay, lastErr)
+		delay *= 2
+		return false, nil
+	})
+	if err == wait.ErrWaitTimeout {
+		err = lastErr
+	}
+	if err == nil && attempt > 1 {
+		klog.Infof("%s succeeded after %d attempts", operation, attempt)
+	}
+	return err
+}
@@ -0,0 +1,69 @@
+/*
+Copyright 2019 IBM Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestRetryWithBackoff(t *testing.T) {
+	// fails twice, then succeeds
+	calls := 0
+	start := time.Now()
+	err := retryWithBackoff("test operation", 5, time.Millisecond*10, func() error {
+		calls++
+		if calls < 3 {
+			return fmt.Errorf("API server not ready")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Errorf("expecting success after retries, got %s", err)
+	}
+	if calls != 3 {
+		t.Errorf("expecting 3 attempts, got %d", calls)
+	}
+	// waits 10ms then 20ms
+	if elapsed := time.Since(start); elapsed < time.Millisecond*30 {
+		t.Errorf("expecting backoff of at least 30ms, got %s", elapsed)
+	}
+
+	// never succeeds
+	calls = 0
+	err = retryWithBackoff("test operation", 3, time.Millisecond, func() error {
+		calls++
+		return fmt.Errorf("attempt %d failed", calls)
+	})
+	if err == nil || err.Error() != "attempt 3 failed" {
+		t.Errorf("expecting the error of the last attempt, got %v", err)
+	}
+	if calls != 3 {
+		t.Errorf("expecting 3 attempts, got %d", calls)
+	}
+
+	// no retry
+	calls = 0
+	retryWithBackoff("test operation", 1, time.Hour, func() error {
+		calls++
+		return fmt.Errorf("failed")
+	})
+	if calls != 1 {
+		t.Errorf("expecting 1 attempt without retries, got %d", calls)
+	}
+}
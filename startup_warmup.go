@@ -0,0 +1,147 @@
+/*
+Copyright 2019 IBM Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"sort"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/klog"
+)
+
+/*
+ At startup, the computed status of child applications is not known yet, so a
+ parent computed before its children rolls up stale or missing statuses. During
+ the startup warmup, changes are only batched. Once it ends, all applications
+ are computed in one batch, children before their parents, after which batches
+ are processed as usual.
+*/
+
+// Hold changes for the warmup period, then queue all applications to be computed in one batch.
+// Return false if the channel is closed during the warmup
+func (ts *batchStore) warmup() bool {
+	if ts.warmupPeriod <= 0 {
+		return true
+	}
+	if klog.V(2) {
+		klog.Infof("batchStore warming up for %s\n", ts.warmupPeriod)
+	}
+	deadline := ts.clock.After(ts.warmupPeriod)
+	for {
+		select {
+		case resources, open := <-ts.resController.resourceChannel.batchResourceChan:
+			if !open {
+				ts.mutex.Lock()
+				ts.done = true
+				ts.mutex.Unlock()
+				return false
+			}
+			ts.mutex.Lock()
+			for _, resInfo := range resources.applications {
+				ts.store.applications[ts.resController.resourceKey(resInfo)] = resInfo
+			}
+			for _, resInfo := range resources.nonApplications {
+				ts.store.nonApplications[ts.resController.resourceKey(resInfo)] = resInfo
+			}
+			ts.mutex.Unlock()
+
+		case <-ts.stopCh:
+			ts.mutex.Lock()
+			ts.done = true
+			ts.mutex.Unlock()
+			return false
+
+		case <-deadline:
+			ts.mutex.Lock()
+			for _, obj := range ts.resController.listApplications() {
+				unstructuredObj, ok := obj.(*unstructured.Unstructured)
+				if !ok {
+					continue
+				}
+				var resInfo = &resourceInfo{}
+				ts.resController.parseResource(unstructuredObj, resInfo)
+				ts.store.applications[ts.resController.resourceKey(resInfo)] = resInfo
+			}
+			if klog.V(2) {
+				klog.Infof("batchStore warmup done, computing %d applications\n", len(ts.store.applications))
+			}
+			if len(ts.store.applications) > 0 || len(ts.store.nonApplications) > 0 {
+				ts.startTimer(0)
+			}
+			ts.mutex.Unlock()
+			return true
+		}
+	}
+}
+
+// Return the applications ordered so that each comes after the applications among them that
+// are its components. Applications in a cycle are ordered by key
+func applicationsInDependencyOrder(resController *ClusterWatcher, applications map[string]*resourceInfo) []*resourceInfo {
+	keys := make([]string, 0, len(applications))
+	for key := range applications {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	// only applications including the Application kind can have child applications
+	var parents map[string]bool
+	if resController.appKinds != nil {
+		parents = make(map[string]bool)
+		for _, key := range resController.appKinds.candidates(APPLICATION) {
+			parents[key] = true
+		}
+	}
+
+	// child applications of each application
+	children := make(map[string][]string, len(keys))
+	for _, key := range keys {
+		res := applications[key]
+		if res.unstructuredObj == nil {
+			continue
+		}
+		if parents != nil && !parents[appResourceKey(res.unstructuredObj)] {
+			continue
+		}
+		appInfo, err := resController.parseAppResourceCached(res.unstructuredObj)
+		if isFatalParseError(err) {
+			continue
+		}
+		for _, childKey := range keys {
+			if childKey != key && resourceComponentOfApplication(resController, appInfo, applications[childKey]) {
+				children[key] = append(children[key], childKey)
+			}
+		}
+	}
+
+	ret := make([]*resourceInfo, 0, len(keys))
+	visited := make(map[string]bool, len(keys))
+	var visit func(key string)
+	visit = func(key string) {
+		if visited[key] {
+			return
+		}
+		visited[key] = true
+		for _, childKey := range children[key] {
+			visit(childKey)
+		}
+		ret = append(ret, applications[key])
+	}
+	for _, key := range keys {
+		visit(key)
+	}
+	return ret
+}
@@ -0,0 +1,137 @@
+/*
+Copyright 2019 IBM Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// Test the applications computed at the end of the startup warmup are ordered children first,
+// and include applications without events during the warmup
+func TestStartupWarmupDependencyOrder(t *testing.T) {
+	testName := "TestStartupWarmupDependencyOrder"
+	beforeTest()
+	// kinds to check for status
+	var kindsToCheckStatus = map[string]bool{
+		APPLICATION:  true,
+		"Deployment": true,
+	}
+
+	var files = []string{
+		/* 0 */ CrdApplication,
+		/* 1 */ appBookinfo,
+		/* 2 */ appProductpage,
+		/* 3 */ appDetails,
+		/* 4 */ appRatings,
+		/* 5 */ appReviews,
+		/* 6 */ deploymentDetailsV1,
+		/* 7 */ KappnavConfigFile,
+	}
+	iteration0IDs, err := readResourceIDs(files)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testActions := newTestActions(testName, kindsToCheckStatus)
+	// only details-app has a component. The others count as Unknown
+	iteration0IDs[1].expectedStatus = unknown
+	iteration0IDs[2].expectedStatus = unknown
+	iteration0IDs[4].expectedStatus = unknown
+	iteration0IDs[5].expectedStatus = unknown
+	var emptyIDs = []resourceID{}
+	testActions.addIteration(iteration0IDs, emptyIDs)
+
+	clusterWatcher, err := createClusterWatcher(iteration0IDs, testActions, StatusFailureRate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer clusterWatcher.shutDown()
+
+	err = testActions.transitionAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// batch on a separate channel
+	batchChannel := clusterWatcher.resourceChannel
+	clusterWatcher.resourceChannel = newResourceChannel()
+	defer func() {
+		clusterWatcher.resourceChannel = batchChannel
+	}()
+	ts := newBatchStore(clusterWatcher, time.Millisecond*100)
+	ts.warmupPeriod = time.Millisecond * 200
+
+	unstructuredObj, err := getResource(clusterWatcher, iteration0IDs[6])
+	if err != nil {
+		t.Fatal(err)
+	}
+	var deployment = &resourceInfo{}
+	clusterWatcher.parseResource(unstructuredObj, deployment)
+	unstructuredObj, err = getResource(clusterWatcher, iteration0IDs[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	var bookinfo = &resourceInfo{}
+	clusterWatcher.parseResource(unstructuredObj, bookinfo)
+
+	// only a change of the parent and of a component are sent during the warmup
+	clusterWatcher.resourceChannel.send(&batchResources{
+		applications:    map[string]*resourceInfo{bookinfo.key(): bookinfo},
+		nonApplications: map[string]*resourceInfo{deployment.key(): deployment},
+	})
+	if !ts.warmup() {
+		t.Fatal("batch store closed during warmup")
+	}
+	resources, ok := ts.getNextBatch()
+	if !ok {
+		t.Fatal("batch store closed")
+	}
+	if len(resources.applications) != 5 || len(resources.nonApplications) != 1 {
+		t.Fatalf("expecting 5 applications and 1 resource in batch, got %d applications and %d resources", len(resources.applications), len(resources.nonApplications))
+	}
+
+	// the parent is computed after all its children, in the same order every time
+	ordered := applicationsInDependencyOrder(clusterWatcher, resources.applications)
+	if len(ordered) != 5 {
+		t.Fatalf("expecting 5 ordered applications, got %d", len(ordered))
+	}
+	if last := ordered[len(ordered)-1]; last.name != "bookinfo" {
+		t.Errorf("expecting bookinfo computed last, got %s", last.name)
+	}
+	again := applicationsInDependencyOrder(clusterWatcher, resources.applications)
+	for index := range ordered {
+		if ordered[index].key() != again[index].key() {
+			t.Errorf("expecting the same order, got %s then %s at %d", ordered[index].key(), again[index].key(), index)
+		}
+	}
+
+	if err = processBatchOfApplicationsAndResources(ts, resources); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Test applications that cannot be parsed are ordered by key
+func TestDependencyOrderUnparsed(t *testing.T) {
+	clusterWatcher := &ClusterWatcher{}
+	first := &resourceInfo{kind: APPLICATION, namespace: "default", name: "first"}
+	second := &resourceInfo{kind: APPLICATION, namespace: "default", name: "second"}
+	ordered := applicationsInDependencyOrder(clusterWatcher, map[string]*resourceInfo{first.key(): first, second.key(): second})
+	if len(ordered) != 2 || ordered[0] != first || ordered[1] != second {
+		t.Errorf("expecting applications without objects ordered by key, got %v", ordered)
+	}
+}
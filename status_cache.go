@@ -0,0 +1,126 @@
+/*
+Copyright 2019 IBM Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"k8s.io/klog"
+)
+
+const (
+	// path of GET /debug/status-cache
+	statusCachePath = "/debug/status-cache"
+
+	// maximum number of applications in a dump of the status cache
+	maxStatusCacheDumpEntries = 1000
+)
+
+// Last computed status of an application
+type statusCacheEntry struct {
+	Namespace   string         `json:"namespace"`
+	Name        string         `json:"name"`
+	Status      string         `json:"status"`
+	Breakdown   map[string]int `json:"breakdown,omitempty"`
+	LastUpdated time.Time      `json:"lastUpdated"`
+}
+
+// Last computed status of each application, kept for diagnostics
+type statusCache struct {
+	entries map[string]*statusCacheEntry // application key to status
+	keyer   resourceKeyer                // key of an application. nil for the default
+	mutex   sync.Mutex
+}
+
+// Response of GET /debug/status-cache
+type statusCacheDump struct {
+	Applications []*statusCacheEntry `json:"applications"`
+	Truncated    bool                `json:"truncated"` // true if more applications than the maximum matched
+}
+
+func newStatusCache() *statusCache {
+	return &statusCache{
+		entries: make(map[string]*statusCacheEntry),
+	}
+}
+
+// Record the status computed for an application
+func (cache *statusCache) set(resInfo *resourceInfo, status string, breakdown map[string]int) {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+	cache.entries[cache.keyer.keyOf(resInfo)] = &statusCacheEntry{
+		Namespace:   resInfo.namespace,
+		Name:        resInfo.name,
+		Status:      status,
+		Breakdown:   breakdown,
+		LastUpdated: time.Now(),
+	}
+}
+
+// Remove the status of a deleted application
+func (cache *statusCache) remove(key string) {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+	delete(cache.entries, key)
+}
+
+// Return the statuses of applications in the namespace, or in all namespaces if namespace is "",
+// sorted by namespace and name, and at most max of them
+func (cache *statusCache) dump(namespace string, max int) *statusCacheDump {
+	cache.mutex.Lock()
+	entries := make([]*statusCacheEntry, 0, len(cache.entries))
+	for _, entry := range cache.entries {
+		if namespace == "" || entry.Namespace == namespace {
+			copied := *entry
+			entries = append(entries, &copied)
+		}
+	}
+	cache.mutex.Unlock()
+
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Namespace != entries[j].Namespace {
+			return entries[i].Namespace < entries[j].Namespace
+		}
+		return entries[i].Name < entries[j].Name
+	})
+	ret := &statusCacheDump{Applications: entries}
+	if len(entries) > max {
+		ret.Applications = entries[:max]
+		ret.Truncated = true
+	}
+	return ret
+}
+
+// Handler for GET /debug/status-cache?namespace={namespace}
+func statusCacheHandler(resController *ClusterWatcher) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", http.MethodGet)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		dump := resController.statusCache.dump(r.URL.Query().Get("namespace"), maxStatusCacheDumpEntries)
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(dump); err != nil && klog.V(2) {
+			klog.Infof("statusCache unable to write response: %s", err)
+		}
+	}
+}
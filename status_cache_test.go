@@ -0,0 +1,87 @@
+/*
+Copyright 2019 IBM Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type statusCacheTestData struct {
+	query         string
+	expectedNames []string
+}
+
+var statusCacheTestDataArray = []statusCacheTestData{
+	{"", []string{"default/bookinfo", "default/productpage", "other/reviews"}},
+	{"?namespace=default", []string{"default/bookinfo", "default/productpage"}},
+	{"?namespace=other", []string{"other/reviews"}},
+	{"?namespace=none", []string{}},
+}
+
+// Test the dump of the status cache reflects the seeded entries and honors the namespace filter
+func TestStatusCacheDump(t *testing.T) {
+	var resController = &ClusterWatcher{statusCache: newStatusCache()}
+	resController.statusCache.set(&resourceInfo{gvr: coreApplicationGVR, kind: APPLICATION, namespace: "default", name: "productpage"}, Normal, map[string]int{Normal: 2})
+	resController.statusCache.set(&resourceInfo{gvr: coreApplicationGVR, kind: APPLICATION, namespace: "default", name: "bookinfo"}, warning, map[string]int{warning: 1})
+	resController.statusCache.set(&resourceInfo{gvr: coreApplicationGVR, kind: APPLICATION, namespace: "other", name: "reviews"}, Normal, nil)
+	resController.statusCache.set(&resourceInfo{gvr: coreApplicationGVR, kind: APPLICATION, namespace: "other", name: "deleted"}, Normal, nil)
+	resController.statusCache.remove((&resourceInfo{gvr: coreApplicationGVR, kind: APPLICATION, namespace: "other", name: "deleted"}).key())
+
+	handler := newHTTPHandler(resController)
+	for _, data := range statusCacheTestDataArray {
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, statusCachePath+data.query, nil))
+		if recorder.Code != http.StatusOK {
+			t.Fatalf("query %q: expecting code %d, got %d: %s", data.query, http.StatusOK, recorder.Code, recorder.Body.String())
+		}
+		var dump statusCacheDump
+		if err := json.Unmarshal(recorder.Body.Bytes(), &dump); err != nil {
+			t.Fatal(err)
+		}
+		if len(dump.Applications) != len(data.expectedNames) || dump.Truncated {
+			t.Errorf("query %q: expecting %v, got %d applications, truncated %t", data.query, data.expectedNames, len(dump.Applications), dump.Truncated)
+			continue
+		}
+		for i, entry := range dump.Applications {
+			if entry.Namespace+"/"+entry.Name != data.expectedNames[i] {
+				t.Errorf("query %q: expecting %s at %d, got %s/%s", data.query, data.expectedNames[i], i, entry.Namespace, entry.Name)
+			}
+			if entry.LastUpdated.IsZero() {
+				t.Errorf("query %q: expecting last updated time for %s/%s", data.query, entry.Namespace, entry.Name)
+			}
+		}
+		if data.query == "" && (dump.Applications[0].Status != warning || dump.Applications[0].Breakdown[warning] != 1) {
+			t.Errorf("expecting status %s with breakdown of 1 %s for bookinfo, got %s %v", warning, warning, dump.Applications[0].Status, dump.Applications[0].Breakdown)
+		}
+	}
+
+	// size is capped
+	dump := resController.statusCache.dump("", 2)
+	if len(dump.Applications) != 2 || !dump.Truncated {
+		t.Errorf("expecting 2 applications and truncated, got %d applications, truncated %t", len(dump.Applications), dump.Truncated)
+	}
+
+	// invalid method
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, statusCachePath, nil))
+	if recorder.Code != http.StatusMethodNotAllowed {
+		t.Errorf("expecting code %d for POST, got %d", http.StatusMethodNotAllowed, recorder.Code)
+	}
+}
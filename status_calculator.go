@@ -0,0 +1,127 @@
+/*
+Copyright 2019 IBM Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"sync"
+
+	"k8s.io/klog"
+)
+
+/*
+ The status of an application is aggregated from the status of its
+ components by a StatusCalculator. The default takes the status of highest
+ precedence. Alternative calculators, e.g. weighted or quorum-based, are
+ registered under a name, and selected by an application with the
+ kappnav.io/status-calculator annotation, or for all applications of a kind.
+ Applications with the kappnav.status.quorum annotation use the quorum
+ calculator unless they name another one.
+ The status of each component is still computed by the statusFunc of the
+ ControllerPlugin, calculateComponentStatus by default.
+*/
+
+// Status of a component of an application, as aggregated into the status of the application
+type componentStatus struct {
+	resInfo *resourceInfo
+	status  string  // "" if unknown
+	weight  float64 // weight of the component in the availability of the application
+}
+
+// StatusCalculator aggregates the status of the components of an application into the status of the application
+type StatusCalculator interface {
+	// Return the status of the application.
+	// precedence: valid statuses, highest precedence first. unknownStatus: status when it can not be computed
+	ApplicationStatus(appResInfo *appResourceInfo, components []componentStatus, precedence []string, unknownStatus string) string
+}
+
+// name of the built-in calculator
+const defaultStatusCalculator = "default"
+
+// built-in calculator returning the status of highest precedence among the components
+type precedenceStatusCalculator struct{}
+
+func (precedenceStatusCalculator) ApplicationStatus(appResInfo *appResourceInfo, components []componentStatus, precedence []string, unknownStatus string) string {
+	checker := newStatusChecker(precedence, unknownStatus)
+	for _, component := range components {
+		checker.addWeightedStatus(component.status, component.weight)
+	}
+	return checker.finalStatus()
+}
+
+var (
+	statusCalculators = map[string]StatusCalculator{
+		defaultStatusCalculator:    precedenceStatusCalculator{},
+		quorumStatusCalculatorName: quorumStatusCalculator{},
+	}
+	kindStatusCalculators  = make(map[string]string) // application kind to name of calculator
+	statusCalculatorsMutex sync.Mutex
+)
+
+// Register a status calculator under a name, replacing any calculator with the same name
+func registerStatusCalculator(name string, calculator StatusCalculator) {
+	statusCalculatorsMutex.Lock()
+	defer statusCalculatorsMutex.Unlock()
+	statusCalculators[name] = calculator
+}
+
+// Remove the status calculator registered under a name. The default can not be removed
+func unregisterStatusCalculator(name string) {
+	if name == defaultStatusCalculator {
+		return
+	}
+	statusCalculatorsMutex.Lock()
+	defer statusCalculatorsMutex.Unlock()
+	delete(statusCalculators, name)
+}
+
+// Use the calculator registered under a name for the applications of a kind without annotation.
+// "" to use the default again
+func setKindStatusCalculator(kind string, name string) {
+	statusCalculatorsMutex.Lock()
+	defer statusCalculatorsMutex.Unlock()
+	if name == "" {
+		delete(kindStatusCalculators, kind)
+		return
+	}
+	kindStatusCalculators[kind] = name
+}
+
+// Return the name of the calculator of an application and the calculator: the one named by its annotation,
+// else the quorum calculator if it has a quorum, else the one of its kind, else the default.
+// A calculator that is not registered falls back to the default
+func statusCalculatorFor(appResInfo *appResourceInfo) (string, StatusCalculator) {
+	statusCalculatorsMutex.Lock()
+	defer statusCalculatorsMutex.Unlock()
+	name, _ := appResInfo.annotations[kappnavStatusCalculator].(string)
+	if _, ok := appResInfo.annotations[kappnavStatusQuorum]; ok && name == "" {
+		name = quorumStatusCalculatorName
+	}
+	if name == "" {
+		name = kindStatusCalculators[appResInfo.kind]
+	}
+	if name == "" {
+		name = defaultStatusCalculator
+	}
+	calculator, ok := statusCalculators[name]
+	if !ok {
+		if klog.V(2) {
+			klog.Infof("statusCalculatorFor application %s/%s: no status calculator %s, using %s", appResInfo.namespace, appResInfo.name, name, defaultStatusCalculator)
+		}
+		return defaultStatusCalculator, statusCalculators[defaultStatusCalculator]
+	}
+	return name, calculator
+}
@@ -0,0 +1,115 @@
+/*
+Copyright 2019 IBM Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/tools/cache"
+)
+
+// calculator returning Normal while a majority of the components are Normal
+type majorityStatusCalculator struct{}
+
+func (majorityStatusCalculator) ApplicationStatus(appResInfo *appResourceInfo, components []componentStatus, precedence []string, unknownStatus string) string {
+	normal := 0
+	for _, component := range components {
+		if component.status == Normal {
+			normal++
+		}
+	}
+	if normal*2 > len(components) {
+		return Normal
+	}
+	return precedenceStatusCalculator{}.ApplicationStatus(appResInfo, components, precedence, unknownStatus)
+}
+
+func TestStatusCalculatorFor(t *testing.T) {
+	registerStatusCalculator("majority", majorityStatusCalculator{})
+	defer unregisterStatusCalculator("majority")
+
+	var appInfo = &appResourceInfo{}
+	appInfo.kind = APPLICATION
+	appInfo.annotations = map[string]interface{}{}
+	if name, _ := statusCalculatorFor(appInfo); name != defaultStatusCalculator {
+		t.Errorf("expecting default calculator without annotation, got %s", name)
+	}
+
+	// for the kind
+	setKindStatusCalculator(APPLICATION, "majority")
+	if name, _ := statusCalculatorFor(appInfo); name != "majority" {
+		t.Errorf("expecting calculator of the kind, got %s", name)
+	}
+	setKindStatusCalculator(APPLICATION, "")
+
+	// by annotation
+	appInfo.annotations[kappnavStatusCalculator] = "majority"
+	if name, calculator := statusCalculatorFor(appInfo); name != "majority" || calculator != (majorityStatusCalculator{}) {
+		t.Errorf("expecting calculator named by the annotation, got %s", name)
+	}
+
+	// not registered
+	appInfo.annotations[kappnavStatusCalculator] = "missing"
+	if name, _ := statusCalculatorFor(appInfo); name != defaultStatusCalculator {
+		t.Errorf("expecting default calculator for an unknown name, got %s", name)
+	}
+}
+
+// Test the status of an application is computed by the calculator named by its annotation
+func TestStatusCalculatorAnnotation(t *testing.T) {
+	registerStatusCalculator("majority", majorityStatusCalculator{})
+	defer unregisterStatusCalculator("majority")
+
+	app := readVersionedJSON(t, appProductpage, "1")
+	resController := newAppCacheTestWatcher(t, app)
+	resController.statusPrecedence = []string{"Red Alert", problem, warning, "Unknown", Normal}
+	resController.unknownStatus = "Unknown"
+	resController.deletedComponents = newDeletedComponents(0)
+	rw := &ResourceWatcher{GroupVersionResource: coreDeploymentGVR, store: cache.NewStore(cache.MetaNamespaceKeyFunc)}
+	for i, status := range []string{Normal, Normal, warning} {
+		deployment := readVersionedJSON(t, deploymentProcuctpageV1, "1")
+		deployment.SetName(fmt.Sprintf("productpage-v%d", i+1))
+		deployment.SetAnnotations(map[string]string{kappnavStatusValue: status})
+		if err := rw.store.Add(deployment); err != nil {
+			t.Fatal(err)
+		}
+	}
+	resController.resourceMap[coreDeploymentGVR] = rw
+
+	statusOf := func(app *unstructured.Unstructured) string {
+		var appInfo = &resourceInfo{}
+		resController.parseResource(app, appInfo)
+		_, status, breakdown, _, err := processOneApplication(resController, appInfo, make(map[string]*resourceInfo),
+			make(map[string]*resourceInfo), make(map[string]*resourceInfo), make(map[string]*resourceInfo), make(map[string]*resourceInfo))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if breakdown[Normal] != 2 || breakdown[warning] != 1 {
+			t.Errorf("expecting 2 Normal and 1 Warning components, got %v", breakdown)
+		}
+		return status
+	}
+	if status := statusOf(app); status != warning {
+		t.Errorf("expecting status of highest precedence %s by default, got %s", warning, status)
+	}
+	app.SetAnnotations(map[string]string{kappnavStatusCalculator: "majority"})
+	if status := statusOf(app); status != Normal {
+		t.Errorf("expecting status %s from the majority calculator, got %s", Normal, status)
+	}
+}
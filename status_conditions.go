@@ -0,0 +1,336 @@
+/*
+Copyright 2019 IBM Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/klog"
+)
+
+/*
+ Optionally the computed kappnav status is also written as a condition
+ in status.conditions of the resource, in addition to the kappnav.status.*
+ annotations.
+*/
+
+const (
+	// default type of the condition written for kappnav status
+	defaultStatusConditionType = "Healthy"
+
+	conditionTrue    = "True"
+	conditionFalse   = "False"
+	conditionUnknown = "Unknown"
+
+	// DefaultMaxStatusConditions - default number of conditions kept in status.conditions
+	DefaultMaxStatusConditions = 8
+)
+
+// A condition in status.conditions
+type statusCondition struct {
+	conditionType      string
+	status             string // True, False, or Unknown
+	reason             string
+	message            string
+	lastTransitionTime string
+}
+
+// Return true if the condition has the same content, ignoring transition time
+func (cond *statusCondition) sameAs(other *statusCondition) bool {
+	if other == nil {
+		return false
+	}
+	return cond.conditionType == other.conditionType &&
+		cond.status == other.status &&
+		cond.reason == other.reason &&
+		cond.message == other.message
+}
+
+// Return the status value considered healthy: the lowest precedence status
+func (resController *ClusterWatcher) healthyStatus() string {
+	return healthyStatusOf(resController.getStatusConfig())
+}
+
+// Return the lowest precedence status other than the unknown status
+func healthyStatusOf(precedence []string, unknownStatus string) string {
+	for i := len(precedence) - 1; i >= 0; i-- {
+		if precedence[i] != unknownStatus {
+			return precedence[i]
+		}
+	}
+	return ""
+}
+
+// Compute the condition for a kappnav status
+// status: the kappnav status
+// breakdown: number of components for each status. nil for non-application resources
+// flyover: flyover text of the resource
+func (resController *ClusterWatcher) newStatusCondition(status string, breakdown map[string]int, flyover string) *statusCondition {
+	cond := &statusCondition{conditionType: statusConditionType}
+	switch status {
+	case "", resController.getUnknownStatus():
+		cond.status = conditionUnknown
+	case resController.healthyStatus():
+		cond.status = conditionTrue
+	default:
+		cond.status = conditionFalse
+	}
+
+	statusReason := toConditionReason(status)
+	if statusReason == "" {
+		statusReason = toConditionReason(conditionUnknown)
+	}
+	if breakdown == nil {
+		// not an application
+		cond.reason = statusReason
+		cond.message = flyover
+		return cond
+	}
+
+	total := 0
+	parts := make([]string, 0, len(breakdown))
+	for _, value := range resController.getStatusPrecedence() {
+		if count := breakdown[value]; count > 0 {
+			total += count
+			parts = append(parts, strconv.Itoa(count)+" "+value)
+		}
+	}
+	if total == 0 {
+		cond.reason = "NoComponents"
+	} else if breakdown[status] == total {
+		cond.reason = "AllComponents" + statusReason
+	} else {
+		cond.reason = statusReason + "Components"
+	}
+	cond.message = strings.Join(parts, ", ")
+	return cond
+}
+
+// Convert a status value to CamelCase as required for a condition reason, e.g. "Red Alert" to "RedAlert"
+func toConditionReason(status string) string {
+	var ret strings.Builder
+	for _, word := range strings.FieldsFunc(status, func(ch rune) bool {
+		return !((ch >= 'a' && ch <= 'z') || (ch >= 'A' && ch <= 'Z') || (ch >= '0' && ch <= '9'))
+	}) {
+		ret.WriteString(strings.ToUpper(word[0:1]) + word[1:])
+	}
+	return ret.String()
+}
+
+// Return the condition of the given type in status.conditions, or nil if it does not exist
+func getStatusCondition(unstructuredObj *unstructured.Unstructured, conditionType string) *statusCondition {
+	conditions, found, err := unstructured.NestedSlice(unstructuredObj.Object, STATUS, CONDITIONS)
+	if err != nil || !found {
+		return nil
+	}
+	for _, obj := range conditions {
+		condMap, ok := obj.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if condType, _ := condMap["type"].(string); condType == conditionType {
+			cond := &statusCondition{conditionType: conditionType}
+			cond.status, _ = condMap["status"].(string)
+			cond.reason, _ = condMap["reason"].(string)
+			cond.message, _ = condMap["message"].(string)
+			cond.lastTransitionTime, _ = condMap["lastTransitionTime"].(string)
+			return cond
+		}
+	}
+	return nil
+}
+
+// Set the condition in status.conditions, replacing any existing condition of the same type.
+// The transition time is kept if the condition status did not change.
+// The condition set last is at the end of the list. Beyond maxStatusConditions of the
+// types written by the controller, those set the longest ago are dropped
+func setStatusCondition(unstructuredObj *unstructured.Unstructured, cond *statusCondition) {
+	existing := getStatusCondition(unstructuredObj, cond.conditionType)
+	if existing != nil && existing.status == cond.status && existing.lastTransitionTime != "" {
+		cond.lastTransitionTime = existing.lastTransitionTime
+	} else {
+		cond.lastTransitionTime = time.Now().UTC().Format(time.RFC3339)
+	}
+
+	condMap := map[string]interface{}{
+		"type":               cond.conditionType,
+		"status":             cond.status,
+		"reason":             cond.reason,
+		"message":            cond.message,
+		"lastTransitionTime": cond.lastTransitionTime,
+	}
+
+	conditions, _, _ := unstructured.NestedSlice(unstructuredObj.Object, STATUS, CONDITIONS)
+	newConditions := make([]interface{}, 0, len(conditions)+1)
+	for _, obj := range conditions {
+		if existingMap, ok := obj.(map[string]interface{}); ok {
+			if condType, _ := existingMap["type"].(string); condType == cond.conditionType {
+				continue
+			}
+		}
+		newConditions = append(newConditions, obj)
+	}
+	newConditions = append(newConditions, condMap)
+	newConditions = capConditions(newConditions, maxStatusConditions)
+	err := unstructured.SetNestedSlice(unstructuredObj.Object, newConditions, STATUS, CONDITIONS)
+	if err != nil && klog.V(2) {
+		klog.Infof("setStatusCondition unable to set condition for %s: %s", unstructuredObj.GetName(), err)
+	}
+}
+
+// Return true if conditions of the type are written by the controller
+func isControllerCondition(conditionType string) bool {
+	switch conditionType {
+	case statusConditionType, validCondition, componentKindsWatchedCondition, selectorSpecifiedCondition,
+		actionConfigMapCreationCondition:
+		return true
+	}
+	return false
+}
+
+// Return the type of a condition in status.conditions, or "" if it has none
+func conditionTypeOf(obj interface{}) string {
+	condMap, _ := obj.(map[string]interface{})
+	conditionType, _ := condMap["type"].(string)
+	return conditionType
+}
+
+// Return the conditions, oldest first, without the oldest of those written by the controller
+// beyond max. Conditions written by others are always kept. All of them if max is not positive
+func capConditions(conditions []interface{}, max int) []interface{} {
+	if max <= 0 {
+		return conditions
+	}
+	evicted := -max
+	for _, obj := range conditions {
+		if isControllerCondition(conditionTypeOf(obj)) {
+			evicted++
+		}
+	}
+	if evicted <= 0 {
+		return conditions
+	}
+	ret := make([]interface{}, 0, len(conditions)-evicted)
+	for _, obj := range conditions {
+		if evicted > 0 && isControllerCondition(conditionTypeOf(obj)) {
+			if klog.V(3) {
+				klog.Infof("dropping condition %s beyond the limit of %d conditions", conditionTypeOf(obj), max)
+			}
+			evicted--
+			continue
+		}
+		ret = append(ret, obj)
+	}
+	return ret
+}
+
+// A condition to write to the status of an application
+type applicationCondition struct {
+	cond *statusCondition
+	// write only if the application already has a condition of the same type,
+	// e.g. to clear a problem without adding the condition to applications that never had it
+	onlyToReplace bool
+}
+
+// Return true if the condition is to be written to the application
+func (appCond applicationCondition) changed(unstructuredObj *unstructured.Unstructured) bool {
+	existing := getStatusCondition(unstructuredObj, appCond.cond.conditionType)
+	return !(existing == nil && appCond.onlyToReplace) && !appCond.cond.sameAs(existing)
+}
+
+// Write a condition to the status of an application, if it changed
+func writeApplicationCondition(resController *ClusterWatcher, appInfo *appResourceInfo, cond *statusCondition, onlyToReplace bool) error {
+	return writeApplicationConditions(resController, appInfo, []applicationCondition{{cond, onlyToReplace}})
+}
+
+// Write the conditions that changed to the status of an application, in one update.
+// The cached application is compared first, so the application is only fetched if a condition changed
+func writeApplicationConditions(resController *ClusterWatcher, appInfo *appResourceInfo, conditions []applicationCondition) error {
+	if appInfo.unstructuredObj != nil {
+		changed := false
+		for _, appCond := range conditions {
+			if appCond.changed(appInfo.unstructuredObj) {
+				changed = true
+				break
+			}
+		}
+		if !changed {
+			return nil
+		}
+	}
+	gvr, ok := resController.getApplicationGVR(appInfo.namespace, appInfo.name)
+	if !ok {
+		return fmt.Errorf("Unable to find GVR for kind %s", APPLICATION)
+	}
+	intf := resController.plugin.dynamicClient.Resource(gvr).Namespace(appInfo.namespace)
+	unstructuredObj, err := intf.Get(appInfo.name, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+
+	var toWrite []*statusCondition
+	for _, appCond := range conditions {
+		if !appCond.changed(unstructuredObj) {
+			continue
+		}
+		if klog.V(2) {
+			klog.Infof("Setting condition %s on application %s %s: %s %s\n", appCond.cond.conditionType, appInfo.namespace, appInfo.name, appCond.cond.status, appCond.cond.message)
+		}
+		if resController.skipWrite("condition %s of application %s/%s: %s %s", appCond.cond.conditionType, appInfo.namespace, appInfo.name, appCond.cond.status, appCond.cond.message) {
+			continue
+		}
+		toWrite = append(toWrite, appCond.cond)
+	}
+	if len(toWrite) == 0 {
+		return nil
+	}
+	for _, cond := range toWrite {
+		setStatusCondition(unstructuredObj, cond)
+	}
+	resController.statusWriteLimiter.wait()
+	updated, err := intf.Update(unstructuredObj, metav1.UpdateOptions{})
+	if err != nil {
+		return err
+	}
+	writeStatus := false
+	for _, cond := range toWrite {
+		if !cond.sameAs(getStatusCondition(updated, cond.conditionType)) {
+			// status is a subresource. Write the conditions through it
+			setStatusCondition(updated, cond)
+			writeStatus = true
+		}
+	}
+	if writeStatus {
+		resController.statusWriteLimiter.wait()
+		_, err = intf.UpdateStatus(updated, metav1.UpdateOptions{})
+	}
+	return err
+}
+
+// Return true if the condition of the resource needs to be written for the given status
+func (resController *ClusterWatcher) statusConditionChanged(resInfo *resourceInfo, status string, breakdown map[string]int, flyover string) bool {
+	if !emitStatusConditions || resInfo.unstructuredObj == nil {
+		return false
+	}
+	cond := resController.newStatusCondition(status, breakdown, flyover)
+	return !cond.sameAs(getStatusCondition(resInfo.unstructuredObj, statusConditionType))
+}
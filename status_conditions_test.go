@@ -0,0 +1,298 @@
+/*
+Copyright 2019 IBM Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic/fake"
+)
+
+type conditionTestData struct {
+	status    string
+	breakdown map[string]int
+	flyover   string
+	expected  statusCondition
+}
+
+var conditionTestDataArray = []conditionTestData{
+	// non-application resources
+	{status: Normal, flyover: "ok",
+		expected: statusCondition{status: conditionTrue, reason: "Normal", message: "ok"}},
+	{status: problem, flyover: "down",
+		expected: statusCondition{status: conditionFalse, reason: "Problem", message: "down"}},
+	{status: "",
+		expected: statusCondition{status: conditionUnknown, reason: "Unknown"}},
+	// applications
+	{status: Normal, breakdown: map[string]int{Normal: 3},
+		expected: statusCondition{status: conditionTrue, reason: "AllComponentsNormal", message: "3 Normal"}},
+	{status: warning, breakdown: map[string]int{Normal: 2, warning: 1},
+		expected: statusCondition{status: conditionFalse, reason: "WarningComponents", message: "1 Warning, 2 Normal"}},
+	{status: "Red Alert", breakdown: map[string]int{"Red Alert": 1, problem: 1},
+		expected: statusCondition{status: conditionFalse, reason: "RedAlertComponents", message: "1 Red Alert, 1 Problem"}},
+	{status: "Unknown", breakdown: map[string]int{},
+		expected: statusCondition{status: conditionUnknown, reason: "NoComponents", message: ""}},
+}
+
+func TestNewStatusCondition(t *testing.T) {
+	var resController = &ClusterWatcher{
+		statusPrecedence: []string{"Red Alert", problem, warning, "Unknown", Normal},
+		unknownStatus:    "Unknown",
+	}
+	for _, data := range conditionTestDataArray {
+		cond := resController.newStatusCondition(data.status, data.breakdown, data.flyover)
+		data.expected.conditionType = statusConditionType
+		if !cond.sameAs(&data.expected) {
+			t.Errorf("condition for status %s breakdown %v: expecting %+v, got %+v", data.status, data.breakdown, data.expected, *cond)
+		}
+	}
+}
+
+func TestSetStatusCondition(t *testing.T) {
+	unstructuredObj, err := readJSON(appProductpage)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if getStatusCondition(unstructuredObj, statusConditionType) != nil {
+		t.Fatal("expecting no condition before it is set")
+	}
+
+	cond := &statusCondition{conditionType: statusConditionType, status: conditionTrue, reason: "AllComponentsNormal", message: "1 Normal"}
+	setStatusCondition(unstructuredObj, cond)
+	current := getStatusCondition(unstructuredObj, statusConditionType)
+	if !cond.sameAs(current) || current.lastTransitionTime == "" {
+		t.Fatalf("expecting condition %+v, got %+v", *cond, current)
+	}
+
+	// transition time is kept if status does not change
+	transitionTime := "2019-01-01T00:00:00Z"
+	conditions := unstructuredObj.Object[STATUS].(map[string]interface{})[CONDITIONS].([]interface{})
+	conditions[0].(map[string]interface{})["lastTransitionTime"] = transitionTime
+	cond = &statusCondition{conditionType: statusConditionType, status: conditionTrue, reason: "AllComponentsNormal", message: "2 Normal"}
+	setStatusCondition(unstructuredObj, cond)
+	current = getStatusCondition(unstructuredObj, statusConditionType)
+	if current.message != "2 Normal" || current.lastTransitionTime != transitionTime {
+		t.Errorf("expecting message 2 Normal with transition time %s, got %+v", transitionTime, *current)
+	}
+	conditions = unstructuredObj.Object[STATUS].(map[string]interface{})[CONDITIONS].([]interface{})
+	if len(conditions) != 1 {
+		t.Errorf("expecting condition to be replaced, got %d conditions", len(conditions))
+	}
+}
+
+func TestSetStatusConditionCapped(t *testing.T) {
+	defer func(max int) {
+		maxStatusConditions = max
+	}(maxStatusConditions)
+	maxStatusConditions = 3
+
+	unstructuredObj, err := readJSON(appProductpage)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// written by another controller
+	setStatusCondition(unstructuredObj, &statusCondition{conditionType: "Ready", status: conditionTrue, reason: "Deployed"})
+	for _, conditionType := range []string{statusConditionType, validCondition, componentKindsWatchedCondition, selectorSpecifiedCondition} {
+		setStatusCondition(unstructuredObj, &statusCondition{conditionType: conditionType, status: conditionFalse, reason: "Failed"})
+	}
+	conditions := unstructuredObj.Object[STATUS].(map[string]interface{})[CONDITIONS].([]interface{})
+	if len(conditions) != maxStatusConditions+1 {
+		t.Fatalf("expecting %d conditions, got %d", maxStatusConditions+1, len(conditions))
+	}
+	if getStatusCondition(unstructuredObj, statusConditionType) != nil {
+		t.Errorf("expecting oldest condition %s to be evicted", statusConditionType)
+	}
+	if getStatusCondition(unstructuredObj, "Ready") == nil {
+		t.Error("expecting condition Ready of another controller to be kept")
+	}
+
+	// replacing a condition makes it the most recent
+	setStatusCondition(unstructuredObj, &statusCondition{conditionType: validCondition, status: conditionTrue, reason: "Recovered"})
+	setStatusCondition(unstructuredObj, &statusCondition{conditionType: statusConditionType, status: conditionFalse, reason: "Failed"})
+	for _, kept := range []string{"Ready", validCondition, selectorSpecifiedCondition, statusConditionType} {
+		if getStatusCondition(unstructuredObj, kept) == nil {
+			t.Errorf("expecting condition %s to be kept", kept)
+		}
+	}
+	if getStatusCondition(unstructuredObj, componentKindsWatchedCondition) != nil {
+		t.Errorf("expecting condition %s to be evicted", componentKindsWatchedCondition)
+	}
+
+	// no limit
+	maxStatusConditions = 0
+	setStatusCondition(unstructuredObj, &statusCondition{conditionType: componentKindsWatchedCondition, status: conditionFalse, reason: "Failed"})
+	conditions = unstructuredObj.Object[STATUS].(map[string]interface{})[CONDITIONS].([]interface{})
+	if len(conditions) != 5 {
+		t.Errorf("expecting 5 conditions without a limit, got %d", len(conditions))
+	}
+}
+
+// wait for the condition of a resource to have the expected status and reason
+func waitForStatusCondition(resController *ClusterWatcher, resInfo resourceID, status string, reason string) error {
+	var current *statusCondition
+	for i := 0; i < 20; i++ {
+		unstructuredObj, err := getResource(resController, resInfo)
+		if err != nil {
+			return err
+		}
+		current = getStatusCondition(unstructuredObj, statusConditionType)
+		if current != nil && current.status == status && current.reason == reason {
+			return nil
+		}
+		time.Sleep(time.Millisecond * 500)
+	}
+	return fmt.Errorf("timed out waiting for condition of %s %s %s, expected: %s %s, current: %+v", resInfo.kind, resInfo.namespace, resInfo.name, status, reason, current)
+}
+
+// Test application condition reflects the computed status
+func TestStatusConditions(t *testing.T) {
+	testName := "TestStatusConditions"
+	beforeTest()
+	emitStatusConditions = true
+	defer func() {
+		emitStatusConditions = false
+	}()
+
+	// kinds to check for status
+	var kindsToCheckStatus = map[string]bool{
+		APPLICATION:   true,
+		"Service":     true,
+		"Deployment":  true,
+		"StatefulSet": true,
+	}
+
+	var files = []string{
+		/* 0 */ CrdApplication,
+		/* 1 */ appProductpage,
+		/* 2 */ deploymentProcuctpageV1,
+		/* 3 */ serviceProductpage,
+		/* 4 */ KappnavConfigFile,
+	}
+	iteration0IDs, err := readResourceIDs(files)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	/* Iteration 0: all normal */
+	testActions := newTestActions(testName, kindsToCheckStatus)
+	var emptyIDs = []resourceID{}
+	testActions.addIteration(iteration0IDs, emptyIDs)
+
+	/* Iteration 1: deployment warning */
+	arrayLength := len(iteration0IDs)
+	var iteration1IDs = make([]resourceID, arrayLength, arrayLength)
+	copy(iteration1IDs, iteration0IDs)
+	iteration1IDs[1].expectedStatus = warning // application now warning
+	iteration1IDs[2].expectedStatus = warning
+	testActions.addIteration(iteration1IDs, emptyIDs)
+
+	clusterWatcher, err := createClusterWatcher(iteration0IDs, testActions, StatusFailureRate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer clusterWatcher.shutDown()
+
+	if _, err = testActions.transition(); err != nil {
+		t.Fatal(err)
+	}
+	if err = waitForStatusCondition(clusterWatcher, iteration0IDs[1], conditionTrue, "AllComponentsNormal"); err != nil {
+		t.Fatal(err)
+	}
+	if err = waitForStatusCondition(clusterWatcher, iteration0IDs[2], conditionTrue, "Normal"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = testActions.transition(); err != nil {
+		t.Fatal(err)
+	}
+	if err = waitForStatusCondition(clusterWatcher, iteration1IDs[1], conditionFalse, "WarningComponents"); err != nil {
+		t.Fatal(err)
+	}
+	if err = waitForStatusCondition(clusterWatcher, iteration1IDs[2], conditionFalse, "Warning"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Test conditions of an application are written in one update, and not fetched if the cached application has them
+func TestWriteApplicationConditions(t *testing.T) {
+	app, err := readJSON(appProductpage)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := fake.NewSimpleDynamicClient(runtime.NewScheme())
+	intf := client.Resource(coreApplicationGVR).Namespace(app.GetNamespace())
+	if _, err = intf.Create(app, metav1.CreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	resController := &ClusterWatcher{
+		plugin:      &ControllerPlugin{dynamicClient: client},
+		resourceMap: map[schema.GroupVersionResource]*ResourceWatcher{coreApplicationGVR: {GroupVersionResource: coreApplicationGVR}},
+	}
+	initControllerMaps(resController)
+	var appInfo = &appResourceInfo{}
+	if err = resController.parseAppResource(app, appInfo); err != nil {
+		t.Fatal(err)
+	}
+	conditions := []applicationCondition{
+		{&statusCondition{conditionType: validCondition, status: conditionFalse, reason: "ParseError"}, false},
+		{&statusCondition{conditionType: selectorSpecifiedCondition, status: conditionFalse, reason: noSelectorReason}, false},
+		// never added
+		{&statusCondition{conditionType: componentKindsWatchedCondition, status: conditionTrue, reason: allKindsWatchedReason}, true},
+	}
+
+	client.ClearActions()
+	if err = writeApplicationConditions(resController, appInfo, conditions); err != nil {
+		t.Fatal(err)
+	}
+	var verbs []string
+	for _, action := range client.Actions() {
+		verbs = append(verbs, action.GetVerb())
+	}
+	if fmt.Sprint(verbs) != "[get update]" {
+		t.Errorf("expecting the conditions to be written in one update, got %v", verbs)
+	}
+	updated, err := intf.Get(app.GetName(), metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, appCond := range conditions {
+		cond := getStatusCondition(updated, appCond.cond.conditionType)
+		if appCond.onlyToReplace && cond != nil {
+			t.Errorf("expecting no condition %s, got %s %s", appCond.cond.conditionType, cond.status, cond.reason)
+		} else if !appCond.onlyToReplace && !appCond.cond.sameAs(cond) {
+			t.Errorf("expecting condition %s %s, got %+v", appCond.cond.conditionType, appCond.cond.status, cond)
+		}
+	}
+
+	// the application as updated in the cache
+	appInfo = &appResourceInfo{}
+	if err = resController.parseAppResource(updated, appInfo); err != nil {
+		t.Fatal(err)
+	}
+	client.ClearActions()
+	if err = writeApplicationConditions(resController, appInfo, conditions); err != nil {
+		t.Fatal(err)
+	}
+	if actions := client.Actions(); len(actions) != 0 {
+		t.Errorf("expecting no call to the API server for unchanged conditions, got %d", len(actions))
+	}
+}
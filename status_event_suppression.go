@@ -0,0 +1,54 @@
+/*
+Copyright 2019 IBM Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"k8s.io/klog"
+)
+
+/*
+ The status of tiny applications, with only a few components, tends to flap,
+ and some operators do not care about it. Their status is still computed,
+ cached, written, and rolled up into their parents, but no event is recorded
+ when it changes.
+*/
+
+// Return the number of components in the status breakdown of an application
+func componentCount(breakdown map[string]int) int {
+	count := 0
+	for _, value := range breakdown {
+		count += value
+	}
+	return count
+}
+
+// Return true if no event is to be recorded for the changed status of an application,
+// because it has fewer than minComponentsForStatusEvents components
+func suppressStatusEvent(res *resourceInfo, breakdown map[string]int) bool {
+	if minComponentsForStatusEvents <= 0 {
+		return false
+	}
+	count := componentCount(breakdown)
+	if count >= minComponentsForStatusEvents {
+		return false
+	}
+	if klog.V(3) {
+		klog.Infof("not recording status event of application %s %s with %d components, fewer than %d", res.namespace, res.name, count, minComponentsForStatusEvents)
+	}
+	statusEventsSuppressed.Inc()
+	return true
+}
@@ -0,0 +1,139 @@
+/*
+Copyright 2019 IBM Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+type statusEventSuppressionTestData struct {
+	minComponents int
+	breakdown     map[string]int
+	expected      bool
+}
+
+var statusEventSuppressionTestDataArray = []statusEventSuppressionTestData{
+	{0, nil, false},
+	{0, map[string]int{Normal: 1}, false},
+	{2, nil, true},
+	{2, map[string]int{Normal: 1}, true},
+	{2, map[string]int{Normal: 1, warning: 1}, false},
+	{3, map[string]int{Normal: 1, warning: 1}, true},
+	{3, map[string]int{Normal: 5}, false},
+}
+
+func TestSuppressStatusEvent(t *testing.T) {
+	saved := minComponentsForStatusEvents
+	defer func() {
+		minComponentsForStatusEvents = saved
+	}()
+	res := &resourceInfo{kind: APPLICATION, namespace: "default", name: "tiny"}
+	for _, data := range statusEventSuppressionTestDataArray {
+		minComponentsForStatusEvents = data.minComponents
+		if suppressed := suppressStatusEvent(res, data.breakdown); suppressed != data.expected {
+			t.Errorf("minimum %d, breakdown %v: expecting suppressed %t, got %t", data.minComponents, data.breakdown, data.expected, suppressed)
+		}
+	}
+}
+
+// Test no event is recorded for a status change of an application below the component threshold
+func TestStatusEventSuppressed(t *testing.T) {
+	saved := minComponentsForStatusEvents
+	minComponentsForStatusEvents = 2
+	defer func() {
+		minComponentsForStatusEvents = saved
+	}()
+	before := testutil.ToFloat64(statusEventsSuppressed)
+
+	resController, recorder, res := newStatusEventTest(t, Normal)
+	resController.recordStatusTransition(res, problem, map[string]int{problem: 1}, nil)
+	if event := nextEvent(recorder); event != "" {
+		t.Errorf("expecting no event for an application with 1 component, got %q", event)
+	}
+	if suppressed := testutil.ToFloat64(statusEventsSuppressed) - before; suppressed != 1 {
+		t.Errorf("expecting 1 suppressed status event counted, got %v", suppressed)
+	}
+	resController.recordStatusTransition(res, problem, map[string]int{problem: 1, Normal: 1}, nil)
+	if event := nextEvent(recorder); event == "" {
+		t.Error("expecting an event for an application with 2 components")
+	}
+}
+
+// Test the status of an application below the component threshold is computed, cached and written
+func TestStatusWrittenWithEventSuppressed(t *testing.T) {
+	testName := "TestStatusWrittenWithEventSuppressed"
+	beforeTest()
+	saved := minComponentsForStatusEvents
+	minComponentsForStatusEvents = 2
+	defer func() {
+		minComponentsForStatusEvents = saved
+	}()
+
+	// kinds to check for status
+	var kindsToCheckStatus = map[string]bool{
+		APPLICATION:  true,
+		"Deployment": true,
+	}
+
+	var files = []string{
+		/* 0 */ CrdApplication,
+		/* 1 */ appDetails,
+		/* 2 */ deploymentDetailsV1,
+		/* 3 */ KappnavConfigFile,
+	}
+	iteration0IDs, err := readResourceIDs(files)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testActions := newTestActions(testName, kindsToCheckStatus)
+	var emptyIDs = []resourceID{}
+	testActions.addIteration(iteration0IDs, emptyIDs)
+
+	clusterWatcher, err := createClusterWatcher(iteration0IDs, testActions, StatusFailureRate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer clusterWatcher.shutDown()
+
+	err = testActions.transitionAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// computed and cached
+	dump := clusterWatcher.statusCache.dump("default", maxStatusCacheDumpEntries)
+	if len(dump.Applications) != 1 || dump.Applications[0].Name != "details-app" {
+		t.Fatalf("expecting status of details-app cached, got %v", dump.Applications)
+	}
+	if cached := dump.Applications[0]; cached.Status == "" || componentCount(cached.Breakdown) != 1 {
+		t.Errorf("expecting status computed for 1 component of details-app, got %s %v", cached.Status, cached.Breakdown)
+	}
+
+	// written
+	obj, err := getResource(clusterWatcher, iteration0IDs[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	var app = &resourceInfo{}
+	clusterWatcher.parseResource(obj, app)
+	if app.kappnavStatVal != dump.Applications[0].Status {
+		t.Errorf("expecting status %s written to details-app, got %q", dump.Applications[0].Status, app.kappnavStatVal)
+	}
+}
@@ -0,0 +1,103 @@
+/*
+Copyright 2019 IBM Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/klog"
+)
+
+/*
+ When the computed status of an application changes, an event is recorded
+ against the application, with the reason of its status condition and the
+ components that have the new status, so that operators can see why an
+ application went to Problem with kubectl describe instead of reading the
+ logs of the controller. The event recorder aggregates and rate limits
+ repeated events as usual.
+*/
+
+const (
+	// source of the events
+	eventSourceComponent = "kappnav-status-controller"
+
+	// components named in the message of an event
+	maxStatusEventCauses = 5
+)
+
+// Return the components with the status, as "kind namespace/name", at most maxStatusEventCauses
+// followed by the number of others
+func statusCauses(components []componentStatus, status string) []string {
+	ret := make([]string, 0)
+	others := 0
+	for _, component := range components {
+		if component.status != status {
+			continue
+		}
+		if len(ret) == maxStatusEventCauses {
+			others++
+			continue
+		}
+		ret = append(ret, component.resInfo.kind+" "+component.resInfo.namespace+"/"+component.resInfo.name)
+	}
+	if others > 0 {
+		ret = append(ret, fmt.Sprintf("%d more", others))
+	}
+	return ret
+}
+
+// Record the transition of an application once its changed status is written, so that
+// a write that fails and is retried records a single event
+func (resController *ClusterWatcher) recordWrittenStatus(res *resourceInfo) {
+	if res.kind != APPLICATION {
+		return
+	}
+	previous := *res
+	previous.kappnavStatVal = res.previousStatVal
+	resController.recordStatusTransition(&previous, res.kappnavStatVal, res.statusBreakdown, res.statusCauses)
+}
+
+// Record an event against an application whose computed status changed from its current status.
+// No event is recorded for the first status of an application, nor without event recorder
+func (resController *ClusterWatcher) recordStatusTransition(res *resourceInfo, status string, breakdown map[string]int, causes []string) {
+	recorder := resController.plugin.EventRecorder
+	if recorder == nil || res.kind != APPLICATION || res.unstructuredObj == nil ||
+		res.kappnavStatVal == "" || res.kappnavStatVal == status || suppressStatusEvent(res, breakdown) {
+		return
+	}
+	cond := resController.newStatusCondition(status, breakdown, "")
+	eventType := corev1.EventTypeWarning
+	if cond.status == conditionTrue {
+		eventType = corev1.EventTypeNormal
+	}
+	message := fmt.Sprintf("status changed from %s to %s", res.kappnavStatVal, status)
+	if cond.message != "" {
+		message += ": " + cond.message
+	}
+	if cond.status != conditionTrue && len(causes) > 0 {
+		message += ". " + status + ": " + strings.Join(causes, ", ")
+	}
+	if resController.skipWrite("event %s %s for application %s/%s: %s", eventType, cond.reason, res.namespace, res.name, message) {
+		return
+	}
+	if klog.V(3) {
+		klog.Infof("recordStatusTransition application %s/%s: %s %s", res.namespace, res.name, cond.reason, message)
+	}
+	recorder.Event(res.unstructuredObj, eventType, cond.reason, message)
+}
@@ -0,0 +1,126 @@
+/*
+Copyright 2019 IBM Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"testing"
+
+	"k8s.io/client-go/tools/record"
+)
+
+// create a controller recording events, and an application with the given status
+func newStatusEventTest(t *testing.T, status string) (*ClusterWatcher, *record.FakeRecorder, *resourceInfo) {
+	recorder := record.NewFakeRecorder(10)
+	resController := &ClusterWatcher{
+		plugin:           &ControllerPlugin{EventRecorder: recorder},
+		statusPrecedence: []string{"Red Alert", problem, warning, "Unknown", Normal},
+		unknownStatus:    "Unknown",
+	}
+	app, err := readJSON(appProductpage)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var res = &resourceInfo{}
+	resController.parseResource(app, res)
+	res.kappnavStatVal = status
+	return resController, recorder, res
+}
+
+// return the next event recorded, or "" if none
+func nextEvent(recorder *record.FakeRecorder) string {
+	select {
+	case event := <-recorder.Events:
+		return event
+	default:
+		return ""
+	}
+}
+
+func TestRecordStatusTransition(t *testing.T) {
+	resController, recorder, res := newStatusEventTest(t, Normal)
+	causes := []string{"Deployment default/productpage-v1"}
+	resController.recordStatusTransition(res, problem, map[string]int{problem: 1, Normal: 2}, causes)
+	expected := "Warning ProblemComponents status changed from Normal to Problem: 1 Problem, 2 Normal. Problem: Deployment default/productpage-v1"
+	if event := nextEvent(recorder); event != expected {
+		t.Errorf("expecting event %q, got %q", expected, event)
+	}
+
+	// back to normal
+	res.kappnavStatVal = problem
+	resController.recordStatusTransition(res, Normal, map[string]int{Normal: 3}, nil)
+	expected = "Normal AllComponentsNormal status changed from Problem to Normal: 3 Normal"
+	if event := nextEvent(recorder); event != expected {
+		t.Errorf("expecting event %q, got %q", expected, event)
+	}
+
+	// no transition
+	resController.recordStatusTransition(res, problem, map[string]int{problem: 1}, causes)
+	if event := nextEvent(recorder); event != "" {
+		t.Errorf("expecting no event without status change, got %q", event)
+	}
+
+	// first status
+	res.kappnavStatVal = ""
+	resController.recordStatusTransition(res, Normal, map[string]int{Normal: 3}, nil)
+	if event := nextEvent(recorder); event != "" {
+		t.Errorf("expecting no event for the first status, got %q", event)
+	}
+
+	// dry run
+	resController.plugin.DryRun = true
+	res.kappnavStatVal = Normal
+	resController.recordStatusTransition(res, problem, map[string]int{problem: 1}, causes)
+	if event := nextEvent(recorder); event != "" {
+		t.Errorf("expecting no event in dry-run mode, got %q", event)
+	}
+}
+
+func TestStatusCauses(t *testing.T) {
+	components := make([]componentStatus, 0)
+	for i := 0; i < maxStatusEventCauses+2; i++ {
+		components = append(components, componentStatus{&resourceInfo{kind: "Deployment", namespace: "default", name: fmt.Sprintf("d%d", i)}, problem, 1})
+	}
+	components = append(components, componentStatus{&resourceInfo{kind: "Service", namespace: "default", name: "s1"}, Normal, 1})
+
+	causes := statusCauses(components, problem)
+	if len(causes) != maxStatusEventCauses+1 || causes[0] != "Deployment default/d0" || causes[maxStatusEventCauses] != "2 more" {
+		t.Errorf("expecting the first %d components with status Problem and 2 more, got %v", maxStatusEventCauses, causes)
+	}
+	if causes = statusCauses(components, Normal); len(causes) != 1 || causes[0] != "Service default/s1" {
+		t.Errorf("expecting the component with status Normal, got %v", causes)
+	}
+}
+
+func TestRecordWrittenStatus(t *testing.T) {
+	resController, recorder, res := newStatusEventTest(t, problem)
+	res.previousStatVal = Normal
+	res.statusBreakdown = map[string]int{problem: 1}
+	res.statusCauses = []string{"Deployment default/productpage-v1"}
+	resController.recordWrittenStatus(res)
+	expected := "Warning AllComponentsProblem status changed from Normal to Problem: 1 Problem. Problem: Deployment default/productpage-v1"
+	if event := nextEvent(recorder); event != expected {
+		t.Errorf("expecting event %q, got %q", expected, event)
+	}
+
+	// not an application
+	deployment := &resourceInfo{kind: "Deployment", kappnavStatVal: problem, previousStatVal: Normal}
+	resController.recordWrittenStatus(deployment)
+	if event := nextEvent(recorder); event != "" {
+		t.Errorf("expecting no event for a component, got %q", event)
+	}
+}
@@ -0,0 +1,166 @@
+/*
+Copyright 2019 IBM Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/klog"
+)
+
+/*
+ The kappnav status of some kinds is kept up to date by an external agent,
+ which records when it last updated it in the kappnav.status.timestamp
+ annotation. With a TTL configured for the kind with the statusFreshnessTTL
+ flag, e.g. Deployment=5m, a status not updated within the TTL is no longer
+ trusted, and the component is Unknown. A component whose status is still
+ fresh is requeued once it expires, so that its applications see it turn
+ Unknown even if no event arrives.
+*/
+
+// TTL of the kappnav status of each kind, parsed from statusFreshnessTTL at startup. Empty for no TTL
+var statusFreshnessTTLs map[string]time.Duration
+
+// Parse the setting of the statusFreshnessTTL flag into a map from kind to TTL
+func parseStatusFreshnessTTL(value string) (map[string]time.Duration, error) {
+	ret := make(map[string]time.Duration)
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		parts := strings.Split(entry, "=")
+		if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" {
+			return nil, fmt.Errorf("%s is not of the form kind=duration", entry)
+		}
+		ttl, err := time.ParseDuration(strings.TrimSpace(parts[1]))
+		if err != nil {
+			return nil, fmt.Errorf("TTL of %s: %s", parts[0], err)
+		}
+		if ttl <= 0 {
+			return nil, fmt.Errorf("TTL %s of %s must be positive", parts[1], parts[0])
+		}
+		ret[strings.TrimSpace(parts[0])] = ttl
+	}
+	return ret, nil
+}
+
+// Return true and a flyover text if the kappnav status of the resource is older than the TTL of its kind.
+// Statuses without timestamp, or of kinds without TTL, are never stale
+func isStatusStale(resInfo *resourceInfo, now time.Time) (bool, string) {
+	expiry, ok := statusExpiry(resInfo)
+	if !ok || !now.After(expiry) {
+		return false, ""
+	}
+	age, ttl := now.Sub(resInfo.statusTime), expiry.Sub(resInfo.statusTime)
+	if klog.V(4) {
+		klog.Infof("kappnav status of %s %s/%s not updated for %s, longer than the TTL of %s", resInfo.kind, resInfo.namespace, resInfo.name, age, ttl)
+	}
+	return true, fmt.Sprintf("Status not updated since %s", resInfo.statusTime.Format(time.RFC3339))
+}
+
+// Return the time the kappnav status of the resource expires, and false if it never does
+func statusExpiry(resInfo *resourceInfo) (time.Time, bool) {
+	if resInfo.statusTime.IsZero() {
+		return time.Time{}, false
+	}
+	ttl, ok := statusFreshnessTTLs[resInfo.kind]
+	if !ok {
+		return time.Time{}, false
+	}
+	return resInfo.statusTime.Add(ttl), true
+}
+
+// Timers requeueing components once their kappnav status expires
+type statusExpiryTimers struct {
+	scheduled map[string]time.Time // resource key to expiry of the status
+	mutex     sync.Mutex
+}
+
+func newStatusExpiryTimers() *statusExpiryTimers {
+	return &statusExpiryTimers{scheduled: make(map[string]time.Time)}
+}
+
+// Call onExpire at the expiry of the status of the resource with the given key, unless already
+// scheduled for that expiry
+func (timers *statusExpiryTimers) schedule(key string, expiry time.Time, onExpire func()) {
+	if timers == nil {
+		return
+	}
+	timers.mutex.Lock()
+	if scheduled, ok := timers.scheduled[key]; ok && scheduled.Equal(expiry) {
+		timers.mutex.Unlock()
+		return
+	}
+	timers.scheduled[key] = expiry
+	timers.mutex.Unlock()
+
+	time.AfterFunc(time.Until(expiry), func() {
+		timers.mutex.Lock()
+		current, ok := timers.scheduled[key]
+		if ok && current.Equal(expiry) {
+			delete(timers.scheduled, key)
+		}
+		timers.mutex.Unlock()
+		if ok && current.Equal(expiry) {
+			onExpire()
+		}
+	})
+}
+
+// Requeue the component once its fresh kappnav status expires
+func (resController *ClusterWatcher) requeueWhenStatusExpires(resInfo *resourceInfo) {
+	expiry, ok := statusExpiry(resInfo)
+	if !ok {
+		return
+	}
+	resController.statusExpiries.schedule(resController.resourceKey(resInfo), expiry, func() {
+		resController.requeueExpiredStatus(resInfo)
+	})
+}
+
+// Batch up a component whose kappnav status expired, with its applications, unless it is gone
+// or its status was updated since
+func (resController *ClusterWatcher) requeueExpiredStatus(resInfo *resourceInfo) {
+	obj, exists, err := resController.getResource(resInfo.gvr, resInfo.namespace, resInfo.name)
+	if err != nil || !exists {
+		return
+	}
+	unstructuredObj, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		return
+	}
+	var current = &resourceInfo{}
+	resController.parseResource(unstructuredObj, current)
+	if !current.statusTime.Equal(resInfo.statusTime) {
+		// updated since. Requeued by its update event
+		return
+	}
+	if klog.V(3) {
+		klog.Infof("kappnav status of %s %s/%s expired, requeueing it", current.kind, current.namespace, current.name)
+	}
+	applications := make(map[string]*resourceInfo)
+	findAllApplicationsForResource(resController, unstructuredObj, applications)
+	resController.resourceChannel.send(&batchResources{
+		applications:    applications,
+		nonApplications: map[string]*resourceInfo{resController.resourceKey(current): current},
+	})
+}
@@ -0,0 +1,143 @@
+/*
+Copyright 2019 IBM Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// status function returning the status of all components as Normal
+func normalStatusFunc(destURL string, resInfo *resourceInfo) (string, string, string, error) {
+	return Normal, "from API server", "", nil
+}
+
+type statusFreshnessTestData struct {
+	setting         string
+	age             time.Duration // age of the kappnav status. 0 for no timestamp
+	expectedStatus  string
+	expectedFlyover string
+}
+
+func TestStatusFreshnessTTL(t *testing.T) {
+	defer func(saved map[string]time.Duration) { statusFreshnessTTLs = saved }(statusFreshnessTTLs)
+	var resController = &ClusterWatcher{
+		plugin:           &ControllerPlugin{statusFunc: normalStatusFunc},
+		statusPrecedence: []string{"Red Alert", problem, warning, "Unknown", Normal},
+		unknownStatus:    "Unknown",
+	}
+	now := time.Now().UTC().Truncate(time.Second)
+	stale := now.Add(-time.Minute * 10).Format(time.RFC3339)
+
+	var tests = []statusFreshnessTestData{
+		// no TTL
+		{"", time.Minute * 10, Normal, "from API server"},
+		// fresh within the TTL
+		{"Deployment=5m", time.Minute, Normal, "from API server"},
+		// stale beyond the TTL
+		{"Deployment=5m", time.Minute * 10, "Unknown", "Status not updated since " + stale},
+		{"StatefulSet=1m, Deployment=5m", time.Minute * 10, "Unknown", "Status not updated since " + stale},
+		// TTL of another kind
+		{"StatefulSet=1m", time.Minute * 10, Normal, "from API server"},
+		// no timestamp
+		{"Deployment=5m", 0, Normal, "from API server"},
+	}
+	for _, test := range tests {
+		ttls, err := parseStatusFreshnessTTL(test.setting)
+		if err != nil {
+			t.Fatal(err)
+		}
+		statusFreshnessTTLs = ttls
+		unstructuredObj, err := readJSON(deploymentDetailsV1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if test.age > 0 {
+			annotations := unstructuredObj.GetAnnotations()
+			if annotations == nil {
+				annotations = make(map[string]string)
+			}
+			annotations[kappnavStatusTimestamp] = now.Add(-test.age).Format(time.RFC3339)
+			unstructuredObj.SetAnnotations(annotations)
+		}
+		var resInfo = &resourceInfo{}
+		parseResourceBasic(unstructuredObj, resInfo)
+
+		status, flyover, _, err := resController.readComponentStatus(resInfo)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if status != test.expectedStatus || flyover != test.expectedFlyover {
+			t.Errorf("TTL %q age %s: expecting status %s flyover %q, got %s %q", test.setting, test.age, test.expectedStatus, test.expectedFlyover, status, flyover)
+		}
+	}
+}
+
+func TestStatusExpiryTimers(t *testing.T) {
+	timers := newStatusExpiryTimers()
+	expired := make(chan string, 2)
+	expiry := time.Now().Add(time.Millisecond * 50)
+	timers.schedule("default/ui", expiry, func() { expired <- "first" })
+	// already scheduled for the same expiry
+	timers.schedule("default/ui", expiry, func() { expired <- "duplicate" })
+
+	select {
+	case which := <-expired:
+		if which != "first" {
+			t.Errorf("expecting the first timer to fire, got %s", which)
+		}
+	case <-time.After(time.Second * 5):
+		t.Fatal("timed out waiting for the status to expire")
+	}
+	select {
+	case which := <-expired:
+		t.Errorf("expecting one timer for the same expiry, %s also fired", which)
+	case <-time.After(time.Millisecond * 200):
+	}
+
+	// a newer status replaces the expiry of the older one
+	expired = make(chan string, 2)
+	timers.schedule("default/ui", time.Now().Add(time.Millisecond*50), func() { expired <- "older" })
+	timers.schedule("default/ui", time.Now().Add(time.Millisecond*100), func() { expired <- "newer" })
+	select {
+	case which := <-expired:
+		if which != "newer" {
+			t.Errorf("expecting only the newer expiry, got %s", which)
+		}
+	case <-time.After(time.Second * 5):
+		t.Fatal("timed out waiting for the newer status to expire")
+	}
+
+	// nothing scheduled without timers
+	var none *statusExpiryTimers
+	none.schedule("default/ui", expiry, func() { t.Error("expecting nothing scheduled") })
+}
+
+func TestParseStatusFreshnessTTL(t *testing.T) {
+	ttls, err := parseStatusFreshnessTTL("Deployment=5m, StatefulSet=1h")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ttls) != 2 || ttls["Deployment"] != time.Minute*5 || ttls["StatefulSet"] != time.Hour {
+		t.Errorf("unexpected TTLs %v", ttls)
+	}
+	for _, invalid := range []string{"Deployment", "Deployment=soon", "Deployment=-1m", "=5m"} {
+		if _, err := parseStatusFreshnessTTL(invalid); err == nil {
+			t.Errorf("expecting %q to be invalid", invalid)
+		}
+	}
+}
@@ -17,9 +17,14 @@ limitations under the License.
 package main
 
 import (
+	"encoding/json"
 	"fmt"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
 
+	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/client-go/dynamic"
@@ -31,6 +36,8 @@ func sendResourceStatus(resController *ClusterWatcher, resInfo *resourceInfo, st
 	if klog.V(4) {
 		klog.Infof("sendResourceStatus %s set to %s\n", resInfo.name, status)
 	}
+	resController.statusWrites.acquire(resInfo.namespace)
+	defer resController.statusWrites.release(resInfo.namespace)
 	gvr, ok := resController.getWatchGVR(resInfo.gvr)
 	if ok {
 		var intfNoNS = resController.plugin.dynamicClient.Resource(gvr)
@@ -48,20 +55,78 @@ func sendResourceStatus(resController *ClusterWatcher, resInfo *resourceInfo, st
 		if err != nil {
 			return err
 		}
+		if resInfo.uid != "" && string(unstructuredObj.GetUID()) != resInfo.uid {
+			// resource was deleted and re-created with the same name. The status is for the old resource
+			if klog.V(2) {
+				klog.Infof("sendResourceStatus skipping status %s of deleted resource %s %s %s with uid %s", status, resInfo.kind, resInfo.namespace, resInfo.name, resInfo.uid)
+			}
+			return errors.NewNotFound(gvr.GroupResource(), resInfo.name)
+		}
 
+		var componentGroups = resInfo.componentGroups
+		var availability = resInfo.availability
+		var condition *statusCondition
+		if emitStatusConditions {
+			condition = resController.newStatusCondition(status, resInfo.statusBreakdown, flyoverText)
+		}
+		var assembly *assemblyStatus
+		if resInfo.unstructuredObj != nil {
+			assembly = resController.newAssemblyStatus(resInfo, resInfo.statusBreakdown, resInfo.unstructuredObj.GetGeneration())
+		}
+		if assembly != nil && assembly.staleFor(unstructuredObj) {
+			// computed from an older generation of the application. Its newer generation is processed next
+			if klog.V(2) {
+				klog.Infof("sendResourceStatus skipping assembly phase %s of %s %s generation %d", assembly.phase, resInfo.namespace, resInfo.name, assembly.observedGeneration)
+			}
+			assembly = nil
+		}
 		var resInfo = &resourceInfo{}
 		resController.parseResource(unstructuredObj, resInfo)
-		if strings.Compare(resInfo.kappnavStatVal, status) != 0 {
+		var conditionChanged = condition != nil && !condition.sameAs(getStatusCondition(unstructuredObj, statusConditionType))
+		var phaseChanged = assembly != nil && !assembly.sameAs(getAssemblyStatus(unstructuredObj))
+		if strings.Compare(resInfo.kappnavStatVal, status) != 0 ||
+			strings.Compare(resInfo.componentGroups, componentGroups) != 0 ||
+			strings.Compare(resInfo.availability, availability) != 0 || conditionChanged || phaseChanged {
 			// change status
 			if klog.V(2) {
 				klog.Infof("Setting kappnav status on Kubernetes server: resource: %s %s %s,  status: %s, flyover: %s\n", resInfo.kind, resInfo.namespace, resInfo.name, status, flyoverText)
 			}
+			if resController.skipWrite("kappnav status of %s %s/%s: %s, flyover: %s", resInfo.kind, resInfo.namespace, resInfo.name, status, flyoverText) {
+				return nil
+			}
 			setkAppNavStatus(unstructuredObj, status, flyoverText, flyOverNLS)
-			_, err = intf.Update(unstructuredObj, metav1.UpdateOptions{})
+			setkAppNavComponentGroups(unstructuredObj, componentGroups)
+			setkAppNavAvailability(unstructuredObj, availability)
+			if conditionChanged {
+				setStatusCondition(unstructuredObj, condition)
+			}
+			if phaseChanged {
+				setAssemblyStatus(unstructuredObj, assembly)
+			}
+			var updated *unstructured.Unstructured
+			resController.statusWriteLimiter.wait()
+			updated, err = intf.Update(unstructuredObj, metav1.UpdateOptions{})
 			if err != nil {
 				if klog.V(2) {
 					klog.Errorf("    error setting kappnav status %s\n", err)
 				}
+				return err
+			}
+			var writeCondition = conditionChanged && !condition.sameAs(getStatusCondition(updated, statusConditionType))
+			var writePhase = phaseChanged && !assembly.sameAs(getAssemblyStatus(updated)) && !assembly.staleFor(updated)
+			if writeCondition || writePhase {
+				// status is a subresource. Write the condition and assembly phase through it
+				if writeCondition {
+					setStatusCondition(updated, condition)
+				}
+				if writePhase {
+					setAssemblyStatus(updated, assembly)
+				}
+				resController.statusWriteLimiter.wait()
+				_, err = intf.UpdateStatus(updated, metav1.UpdateOptions{})
+				if err != nil && klog.V(2) {
+					klog.Errorf("    error setting kappnav status condition %s\n", err)
+				}
 			}
 			return err
 		}
@@ -74,14 +139,21 @@ type statusChecker struct {
 	count         map[string]int // counter number of each different status
 	precedence    []string       // precedence
 	unknownStatus string         // value of unknown status
+	healthyStatus string         // value of healthy status, the lowest precedence status other than unknown
+	healthyWeight float64        // total weight of healthy statuses
+	totalWeight   float64        // total weight of all valid statuses
 }
 
+// availability of applications without components
+const availabilityNotApplicable = "N/A"
+
 // Return a new Status checker
 func newStatusChecker(precedence []string, unkownStatus string) *statusChecker {
 	var checker = statusChecker{}
 
 	checker.unknownStatus = unkownStatus
 	checker.precedence = precedence
+	checker.healthyStatus = healthyStatusOf(precedence, unkownStatus)
 	checker.count = make(map[string]int)
 	for _, value := range precedence {
 		checker.count[value] = 0
@@ -96,6 +168,11 @@ func newStatusChecker(precedence []string, unkownStatus string) *statusChecker {
 //       highest precedence status.  Caller need not continue checking in
 //       that case
 func (checker *statusChecker) addStatus(status string) (valid bool, alreadyHighest bool) {
+	return checker.addWeightedStatus(status, 1)
+}
+
+// Add another status, with the weight of the component in the availability
+func (checker *statusChecker) addWeightedStatus(status string, weight float64) (valid bool, alreadyHighest bool) {
 	// status is unknown
 	if status == "" {
 		status = checker.unknownStatus
@@ -105,12 +182,36 @@ func (checker *statusChecker) addStatus(status string) (valid bool, alreadyHighe
 		return false, false
 	}
 	checker.count[status] = value + 1
+	checker.totalWeight += weight
+	if status == checker.healthyStatus {
+		checker.healthyWeight += weight
+	}
 	if status == checker.precedence[0] {
 		return true, true
 	}
 	return true, false
 }
 
+// Return the number of components for each status
+func (checker *statusChecker) breakdown() map[string]int {
+	ret := make(map[string]int)
+	for status, count := range checker.count {
+		if count > 0 {
+			ret[status] = count
+		}
+	}
+	return ret
+}
+
+// Return the weighted percentage of statuses that are healthy, e.g. Normal, as 66.7,
+// or N/A if there are none
+func (checker *statusChecker) availability() string {
+	if checker.totalWeight <= 0 {
+		return availabilityNotApplicable
+	}
+	return strconv.FormatFloat(checker.healthyWeight*100/checker.totalWeight, 'f', 1, 64)
+}
+
 // Return the final status
 func (checker *statusChecker) finalStatus() string {
 	var statusPrecedence = checker.precedence
@@ -139,19 +240,39 @@ func processBatchOfApplicationsAndResources(ts *batchStore, resources *batchReso
 	hasStatus := make(map[string]*resourceInfo)
 	toChange := make(map[string]*resourceInfo)
 
-	// calculate application status for all affected applications
-	for _, res := range resources.applications {
+	// parse each component once for the batch
+	ts.resController.parsedResources.begin()
+	defer ts.resController.parsedResources.end()
+
+	// calculate application status for all affected applications, children before their parents
+	for _, res := range applicationsInDependencyOrder(ts.resController, resources.applications) {
 		visited := make(map[string]*resourceInfo)
-		_, stat, err := processOneApplication(ts.resController, res, visited, hasStatus, resources.nonApplications, toChange)
+		_, stat, breakdown, availability, err := processOneApplication(ts.resController, res, visited, hasStatus, resources.nonApplications, resources.applications, toChange)
 		if err != nil {
 			return err
 		}
-		key := res.key()
-		if res.kappnavStatVal != stat {
+		key := ts.resController.resourceKey(res)
+		var causes []string
+		if computed, ok := hasStatus[key]; ok {
+			causes = computed.statusCauses
+		}
+		ts.resController.statusCache.set(res, stat, breakdown)
+		// a pending retry of an older status is stale, whether or not this one needs to be written
+		ts.resController.statusRetries.remove(key)
+		groups := componentGroupsOfApplication(ts.resController, res)
+		if res.kappnavStatVal != stat || res.componentGroups != groups || res.availability != availability ||
+			ts.resController.statusConditionChanged(res, stat, breakdown, res.flyOver) ||
+			ts.resController.assemblyStatusChanged(res, breakdown) {
 			// status changed
 			newRes := &resourceInfo{}
 			*newRes = *res
 			newRes.kappnavStatVal = stat
+			newRes.componentGroups = groups
+			newRes.statusBreakdown = breakdown
+			newRes.availability = availability
+			newRes.statusCauses = causes
+			newRes.previousStatVal = res.kappnavStatVal
+			// the transition is recorded once written
 			toChange[key] = newRes
 			hasStatus[key] = newRes
 		} else {
@@ -169,12 +290,52 @@ func processBatchOfApplicationsAndResources(ts *batchStore, resources *batchReso
 	}
 
 	// update kappnav status for all resources whose status have changed
-	for _, res := range toChange {
-		err := sendResourceStatus(ts.resController, res, res.kappnavStatVal, res.flyOver, res.flyOverNLS)
-		if err != nil {
-			return err
+	var wg sync.WaitGroup
+	var errMutex sync.Mutex
+	var writeErr error
+	for key, res := range toChange {
+		if statusWritesPerNamespace <= 0 {
+			if err := writeStatus(ts.resController, key, res); err != nil && writeErr == nil {
+				writeErr = err
+			}
+			continue
+		}
+		// write in parallel, limited per namespace
+		wg.Add(1)
+		go func(key string, res *resourceInfo) {
+			defer wg.Done()
+			if err := writeStatus(ts.resController, key, res); err != nil {
+				errMutex.Lock()
+				if writeErr == nil {
+					writeErr = err
+				}
+				errMutex.Unlock()
+			}
+		}(key, res)
+	}
+	wg.Wait()
+	// put back the batch to be computed again. A status that is still current
+	// by then is delivered from the retry queue
+	return writeErr
+}
+
+// Write the computed status of a resource, or queue it for retry if it fails.
+// Return the error of the write, or nil if the resource no longer exists
+func writeStatus(resController *ClusterWatcher, key string, res *resourceInfo) error {
+	err := sendResourceStatus(resController, res, res.kappnavStatVal, res.flyOver, res.flyOverNLS)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			// resource deleted, nothing to update
+			return nil
 		}
+		// keep the status to be retried later
+		if klog.V(2) {
+			klog.Infof("queuing status %s of %s for retry due to error %s", res.kappnavStatVal, key, err)
+		}
+		resController.statusRetries.enqueue(res, res.kappnavStatVal, res.flyOver, res.flyOverNLS)
+		return err
 	}
+	resController.recordWrittenStatus(res)
 	return nil
 }
 
@@ -184,26 +345,29 @@ Process status for one application
  visited: application already visited when computing status for one top level application
  hasStatus: accumulated resources with known status
  toFetch: call API server to fetch current status. If not in this set, fetch status from cache.
+ toCompute: applications whose status need to be computed. If not in this set, use status from cache.
  toChange: accumulated resources with status change. call API server to update status
 
  Return:
    statusOK: true if OK, false to skip this application to avoid infinite recursion
    status: the status of the application
+   breakdown: number of components for each status
+   availability: weighted percentage of components that are Normal, or N/A without components
    processErr : any error captured
 */
-func processOneApplication(resController *ClusterWatcher, res *resourceInfo, visited map[string]*resourceInfo, hasStatus map[string]*resourceInfo, toFetch map[string]*resourceInfo, toChange map[string]*resourceInfo) (statusOK bool, status string, processErr error) {
+func processOneApplication(resController *ClusterWatcher, res *resourceInfo, visited map[string]*resourceInfo, hasStatus map[string]*resourceInfo, toFetch map[string]*resourceInfo, toCompute map[string]*resourceInfo, toChange map[string]*resourceInfo) (statusOK bool, status string, breakdown map[string]int, availability string, processErr error) {
 	if klog.V(4) {
 		klog.Infof("processOneApplication for %s\n", res.name)
 	}
 
-	key := res.key()
+	key := resController.resourceKey(res)
 	_, ok := visited[key]
 	if ok {
 		if klog.V(4) {
 			klog.Infof("    application %s already visited\n", res.name)
 		}
 		// already visited
-		return false, "", nil
+		return false, "", nil, "", nil
 	}
 	visited[key] = res
 
@@ -213,70 +377,145 @@ func processOneApplication(resController *ClusterWatcher, res *resourceInfo, vis
 		if klog.V(4) {
 			klog.Infof("    application %s already has status %s\n", computed.name, computed.kappnavStatVal)
 		}
-		return true, computed.kappnavStatVal, nil
+		return true, computed.kappnavStatVal, computed.statusBreakdown, computed.availability, nil
 	}
 
 	obj := res.unstructuredObj
 	appInfo := &appResourceInfo{}
 	resController.parseAppResource(obj, appInfo)
+	applicationStatusComputations.Inc()
+	complete := true // false if any component application is skipped, or computed without one
 
-	checker := newStatusChecker(resController.getStatusPrecedence(), resController.unknownStatus)
+	precedence, unknownStatus := resController.getStatusConfig()
+	checker := newStatusChecker(precedence, unknownStatus)
+	components := make([]componentStatus, 0)
+	found := make(map[string]bool)
 	var componentKinds = appInfo.componentKinds
+	var snapshot *componentSnapshot
+	if statusSnapshot {
+		// compute from the components as they are now
+		snapshot = newComponentSnapshot(resController, componentKinds)
+	}
 	// loop over all components kinds
 	for _, component := range componentKinds {
 		// loop over all resources of each component kind
 		gvr, ok := resController.getGVRForGroupKind(component.group, component.kind)
-		var resources = resController.listResources(gvr)
+		var resources = snapshot.listResources(resController, gvr)
 		for _, res := range resources {
 
 			var unstructuredObj = res.(*unstructured.Unstructured)
 
 			var resInfo = &resourceInfo{}
-			resController.parseResource(unstructuredObj, resInfo)
+			resController.parseResourceCached(unstructuredObj, resInfo)
 			if resourceComponentOfApplication(resController, appInfo, resInfo) {
 				// not self and labels match selector
 				if klog.V(4) {
 					klog.Infof("    found component: %s\n", resInfo.name)
 				}
+				found[resController.resourceKey(resInfo)] = true
+
+				if resInfo.kind == APPLICATION && appInfo.excludeSubApplications {
+					// application computes its status from non-application components only
+					if klog.V(4) {
+						klog.Infof("    excluding child application: %s\n", resInfo.name)
+					}
+					continue
+				}
 
 				var stat string
 				var err error
-				if resInfo.kind == APPLICATION {
+				if resInfo.kind == APPLICATION && resInfo.kappnavStatVal != "" && toCompute[resController.resourceKey(resInfo)] == nil {
+					// child application not changed. Fold in its computed status
+					// rather than recomputing from its components
+					if klog.V(4) {
+						klog.Infof("    using computed status %s of application: %s\n", resInfo.kappnavStatVal, resInfo.name)
+					}
+					if _, ok := hasStatus[resController.resourceKey(resInfo)]; !ok {
+						hasStatus[resController.resourceKey(resInfo)] = resInfo
+					}
+					stat = resInfo.kappnavStatVal
+				} else if resInfo.kind == APPLICATION {
 					// recursively calculate application status
 					var tmpAppInfo = &appResourceInfo{}
 					err = resController.parseAppResource(unstructuredObj, tmpAppInfo)
-					if err != nil {
-						return false, "", err
+					if isFatalParseError(err) {
+						// recorded on the Valid condition of the child. Retrying would not help
+						klog.Errorf("    skipping malformed application %s: %s\n", resInfo.name, err)
+						continue
 					}
-					ok, stat, err = processOneApplication(resController, &tmpAppInfo.resourceInfo, visited, hasStatus, toFetch, toChange)
+					ok, stat, _, _, err = processOneApplication(resController, &tmpAppInfo.resourceInfo, visited, hasStatus, toFetch, toCompute, toChange)
 					if err != nil {
-						return false, "", err
+						return false, "", nil, "", err
 					}
 					if !ok {
 						// skip this one to avoid infinite recursion
+						complete = false
 						if klog.V(4) {
 							klog.Infof("    skipping application: %s\n", resInfo.name)
 						}
 						continue
 					}
+					if _, ok := hasStatus[resController.resourceKey(resInfo)]; !ok {
+						// computed without a component application in a cycle. Not final either
+						complete = false
+					}
 				} else {
 					// calculate resource status
 					stat, err = processOneResource(resController, resInfo, hasStatus, toFetch, toChange)
 					if err != nil {
-						return false, stat, err
+						return false, stat, nil, "", err
 					}
 
 				}
-				checker.addStatus(stat)
+				if resInfo.inMaintenance() {
+					// paused on purpose. Do not let it affect the status of the application
+					if klog.V(4) {
+						klog.Infof("    excluding component in maintenance: %s\n", resInfo.name)
+					}
+					continue
+				}
+				checker.addWeightedStatus(stat, appInfo.componentWeight(resInfo))
+				components = append(components, componentStatus{resInfo, stat, appInfo.componentWeight(resInfo)})
 			}
 		}
 	}
-	status = checker.finalStatus()
+
+	// components deleted within the grace period still count with their last status
+	for _, deleted := range snapshot.listDeleted(resController) {
+		if (deleted.kind == APPLICATION && appInfo.excludeSubApplications) || deleted.inMaintenance() {
+			continue
+		}
+		if !found[resController.resourceKey(deleted)] && resourceComponentOfApplication(resController, appInfo, deleted) {
+			if klog.V(4) {
+				klog.Infof("    counting deleted component: %s status: %s\n", deleted.name, deleted.kappnavStatVal)
+			}
+			checker.addWeightedStatus(deleted.kappnavStatVal, appInfo.componentWeight(deleted))
+			components = append(components, componentStatus{deleted, deleted.kappnavStatVal, appInfo.componentWeight(deleted)})
+		}
+	}
+	calculatorName, calculator := statusCalculatorFor(appInfo)
+	status = calculator.ApplicationStatus(appInfo, components, precedence, unknownStatus)
+	if klog.V(4) {
+		klog.Infof("    status calculator for application %s %s is %s\n", appInfo.namespace, appInfo.name, calculatorName)
+	}
+	breakdown = checker.breakdown()
+	availability = checker.availability()
 
 	if klog.V(4) {
 		klog.Infof("    processOneApplication final status for application %s %s %s is %s\n", appInfo.kind, appInfo.namespace, appInfo.name, status)
 	}
-	return true, status, nil
+	if complete {
+		// compute each application at most once per batch, even if it is also a component of other applications
+		computed = &resourceInfo{}
+		*computed = *res
+		computed.kappnavStatVal = status
+		computed.statusBreakdown = breakdown
+		computed.statusCauses = statusCauses(components, status)
+		computed.components = components
+		computed.availability = availability
+		hasStatus[key] = computed
+	}
+	return true, status, breakdown, availability, nil
 }
 
 /* Process status update for one non-application resource
@@ -286,7 +525,7 @@ func processOneApplication(resController *ClusterWatcher, res *resourceInfo, vis
    toChange: resources whose status have changed, need to update API server
 */
 func processOneResource(resController *ClusterWatcher, resInfo *resourceInfo, hasStatus map[string]*resourceInfo, toFetch map[string]*resourceInfo, toChange map[string]*resourceInfo) (string, error) {
-	key := resInfo.key()
+	key := resController.resourceKey(resInfo)
 	if res, ok := hasStatus[key]; ok {
 		// status already computed
 		if klog.V(4) {
@@ -300,7 +539,7 @@ func processOneResource(resController *ClusterWatcher, resInfo *resourceInfo, ha
 		if klog.V(4) {
 			klog.Infof("processOneResource fetching status for %s %s %s\n", resInfo.gvr, resInfo.namespace, resInfo.name)
 		}
-		stat, flyover, flyoverNLS, err := resController.plugin.statusFunc(apiURL, resInfo)
+		stat, flyover, flyoverNLS, err := resController.readComponentStatus(resInfo)
 		if err != nil {
 			if klog.V(4) {
 				klog.Infof("processOneResource error fetching status for %s %s %s\n", resInfo.gvr, resInfo.namespace, resInfo.name)
@@ -308,7 +547,10 @@ func processOneResource(resController *ClusterWatcher, resInfo *resourceInfo, ha
 			}
 			return stat, err
 		}
-		if stat != resInfo.kappnavStatVal || flyover != resInfo.flyOver || flyoverNLS != resInfo.flyOverNLS {
+		// a pending retry of an older status is stale, whether or not this one needs to be written
+		resController.statusRetries.remove(key)
+		if stat != resInfo.kappnavStatVal || flyover != resInfo.flyOver || flyoverNLS != resInfo.flyOverNLS ||
+			resController.statusConditionChanged(resInfo, stat, nil, flyover) {
 			newRes := &resourceInfo{}
 			*newRes = *resInfo
 			newRes.kappnavStatVal = stat
@@ -325,3 +567,54 @@ func processOneResource(resController *ClusterWatcher, resInfo *resourceInfo, ha
 	hasStatus[key] = resInfo
 	return resInfo.kappnavStatVal, nil
 }
+
+// Bucket the components of an application by display group, based on the
+// configured mapping from component kind to group.
+// Return the groups as JSON, mapping each group to sorted "kind/namespace/name" of its components.
+// Return empty string if there are no groups.
+func componentGroupsOfApplication(resController *ClusterWatcher, res *resourceInfo) string {
+	kindGroups := resController.getComponentKindGroups()
+	if len(kindGroups) == 0 {
+		// default is no grouping
+		return ""
+	}
+
+	appInfo := &appResourceInfo{}
+	resController.parseAppResource(res.unstructuredObj, appInfo)
+
+	groups := make(map[string][]string)
+	seen := make(map[string]bool)
+	for _, component := range appInfo.componentKinds {
+		group, ok := kindGroups[component.kind]
+		if !ok {
+			// kind not in any group
+			continue
+		}
+		gvr, ok := resController.getGVRForGroupKind(component.group, component.kind)
+		if !ok {
+			continue
+		}
+		for _, obj := range resController.listResources(gvr) {
+			var resInfo = &resourceInfo{}
+			resController.parseResourceCached(obj.(*unstructured.Unstructured), resInfo)
+			if !seen[resController.resourceKey(resInfo)] && resourceComponentOfApplication(resController, appInfo, resInfo) {
+				seen[resController.resourceKey(resInfo)] = true
+				groups[group] = append(groups[group], resInfo.kind+"/"+resInfo.namespace+"/"+resInfo.name)
+			}
+		}
+	}
+	if len(groups) == 0 {
+		return ""
+	}
+	for _, members := range groups {
+		sort.Strings(members)
+	}
+	bytes, err := json.Marshal(groups)
+	if err != nil {
+		if klog.V(2) {
+			klog.Infof("Unable to marshal component groups of %s %s: %s", res.namespace, res.name, err)
+		}
+		return ""
+	}
+	return string(bytes)
+}
@@ -0,0 +1,666 @@
+/*
+Copyright 2019 IBM Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+const (
+	componentGroupsConfigMap = "test_data/kappnav-config-groups.json"
+)
+
+// wait for the component groups annotation of a resource to have the expected value
+func waitForComponentGroups(resController *ClusterWatcher, resInfo resourceID, expected string) error {
+	var current string
+	for i := 0; i < 20; i++ {
+		unstructuredObj, err := getResource(resController, resInfo)
+		if err != nil {
+			return err
+		}
+		current = unstructuredObj.GetAnnotations()[kappnavStatusComponentGroups]
+		if current == expected {
+			return nil
+		}
+		time.Sleep(time.Millisecond * 500)
+	}
+	return fmt.Errorf("timed out waiting for component groups of %s %s %s, expected: %s current: %s", resInfo.kind, resInfo.namespace, resInfo.name, expected, current)
+}
+
+// Test components of an application are bucketed per the configured kind to group map
+func TestComponentGroups(t *testing.T) {
+	testName := "TestComponentGroups"
+	beforeTest()
+	// kinds to check for status
+	var kindsToCheckStatus = map[string]bool{
+		APPLICATION:   true,
+		"Service":     true,
+		"Deployment":  true,
+		"StatefulSet": true,
+	}
+
+	var files = []string{
+		/* 0 */ CrdApplication,
+		/* 1 */ appProductpage,
+		/* 2 */ networkpolicyProductpage,
+		/* 3 */ deploymentProcuctpageV1,
+		/* 4 */ serviceProductpage,
+		/* 5 */ componentGroupsConfigMap,
+	}
+	iteration0IDs, err := readResourceIDs(files)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	/* Iteration 0: all normal */
+	testActions := newTestActions(testName, kindsToCheckStatus)
+	var emptyIDs = []resourceID{}
+	testActions.addIteration(iteration0IDs, emptyIDs)
+
+	clusterWatcher, err := createClusterWatcher(iteration0IDs, testActions, StatusFailureRate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer clusterWatcher.shutDown()
+
+	// check all statuses are normal
+	err = testActions.transitionAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// NetworkPolicy is a component, but not in any group
+	expected := `{"networking":["Service/default/productpage"],"workloads":["Deployment/default/productpage-v1"]}`
+	err = waitForComponentGroups(clusterWatcher, iteration0IDs[1], expected)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Test there is no grouping by default
+func TestComponentGroupsDefault(t *testing.T) {
+	var resController = &ClusterWatcher{}
+	appObj, err := readJSON(appProductpage)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var resInfo = &resourceInfo{}
+	resController.parseResource(appObj, resInfo)
+	groups := componentGroupsOfApplication(resController, resInfo)
+	if groups != "" {
+		t.Errorf("expecting no component groups by default, got %s", groups)
+	}
+}
+
+// Test status of a parent application folds in the computed status of a child application
+func TestApplicationOfApplications(t *testing.T) {
+	testName := "TestApplicationOfApplications"
+	beforeTest()
+	// kinds to check for status
+	var kindsToCheckStatus = map[string]bool{
+		APPLICATION:  true,
+		"Service":    true,
+		"Deployment": true,
+	}
+
+	var files = []string{
+		/* 0 */ CrdApplication,
+		/* 1 */ appBookinfo,
+		/* 2 */ appProductpage,
+		/* 3 */ deploymentProcuctpageV1,
+		/* 4 */ serviceProductpage,
+		/* 5 */ KappnavConfigFile,
+	}
+	iteration0IDs, err := readResourceIDs(files)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	/* Iteration 0: all normal */
+	testActions := newTestActions(testName, kindsToCheckStatus)
+	var emptyIDs = []resourceID{}
+	testActions.addIteration(iteration0IDs, emptyIDs)
+
+	clusterWatcher, err := createClusterWatcher(iteration0IDs, testActions, StatusFailureRate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer clusterWatcher.shutDown()
+
+	err = testActions.transitionAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// change the computed status of the child application in the cache only
+	rw := clusterWatcher.getResourceWatcher(coreApplicationGVR)
+	if rw == nil {
+		t.Fatal("applications not watched")
+	}
+	child := iteration0IDs[2]
+	obj, exists, err := rw.store.GetByKey(child.namespace + "/" + child.name)
+	if err != nil || !exists {
+		t.Fatalf("unable to get application %s from cache: %v", child.name, err)
+	}
+	childObj := obj.(*unstructured.Unstructured).DeepCopy()
+	annotations := childObj.GetAnnotations()
+	annotations[kappnavStatusValue] = warning
+	childObj.SetAnnotations(annotations)
+	if err = rw.store.Update(childObj); err != nil {
+		t.Fatal(err)
+	}
+
+	parentObj, err := getResource(clusterWatcher, iteration0IDs[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	var parent = &resourceInfo{}
+	clusterWatcher.parseResource(parentObj, parent)
+
+	// child not changed: its computed status is used, counted as one component
+	toCompute := map[string]*resourceInfo{parent.key(): parent}
+	_, stat, breakdown, _, err := processOneApplication(clusterWatcher, parent, make(map[string]*resourceInfo),
+		make(map[string]*resourceInfo), make(map[string]*resourceInfo), toCompute, make(map[string]*resourceInfo))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stat != warning || len(breakdown) != 1 || breakdown[warning] != 1 {
+		t.Errorf("expecting status %s from child application with breakdown of 1 %s, got %s %v", warning, warning, stat, breakdown)
+	}
+
+	// child changed: its status is recomputed from its components
+	var childInfo = &resourceInfo{}
+	clusterWatcher.parseResource(childObj, childInfo)
+	toCompute[childInfo.key()] = childInfo
+	_, stat, breakdown, _, err = processOneApplication(clusterWatcher, parent, make(map[string]*resourceInfo),
+		make(map[string]*resourceInfo), make(map[string]*resourceInfo), toCompute, make(map[string]*resourceInfo))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stat != Normal || len(breakdown) != 1 || breakdown[Normal] != 1 {
+		t.Errorf("expecting recomputed status %s with breakdown of 1 %s, got %s %v", Normal, Normal, stat, breakdown)
+	}
+}
+
+// Test child applications are left out of the status of an application that excludes them
+func TestExcludeSubApplications(t *testing.T) {
+	testName := "TestExcludeSubApplications"
+	beforeTest()
+	// kinds to check for status
+	var kindsToCheckStatus = map[string]bool{
+		APPLICATION:  true,
+		"Service":    true,
+		"Deployment": true,
+	}
+
+	var files = []string{
+		/* 0 */ CrdApplication,
+		/* 1 */ appBookinfo,
+		/* 2 */ appProductpage,
+		/* 3 */ deploymentProcuctpageV1,
+		/* 4 */ serviceProductpage,
+		/* 5 */ KappnavConfigFile,
+	}
+	iteration0IDs, err := readResourceIDs(files)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	/* Iteration 0: all normal */
+	testActions := newTestActions(testName, kindsToCheckStatus)
+	var emptyIDs = []resourceID{}
+	testActions.addIteration(iteration0IDs, emptyIDs)
+
+	clusterWatcher, err := createClusterWatcher(iteration0IDs, testActions, StatusFailureRate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer clusterWatcher.shutDown()
+
+	err = testActions.transitionAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parentObj, err := getResource(clusterWatcher, iteration0IDs[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	var parent = &resourceInfo{}
+	clusterWatcher.parseResource(parentObj, parent)
+
+	// default: child application is rolled up
+	toCompute := map[string]*resourceInfo{parent.key(): parent}
+	_, stat, breakdown, _, err := processOneApplication(clusterWatcher, parent, make(map[string]*resourceInfo),
+		make(map[string]*resourceInfo), make(map[string]*resourceInfo), toCompute, make(map[string]*resourceInfo))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stat != Normal || len(breakdown) != 1 || breakdown[Normal] != 1 {
+		t.Errorf("expecting status %s from child application with breakdown of 1 %s, got %s %v", Normal, Normal, stat, breakdown)
+	}
+
+	// bookinfo has no components other than the child application
+	annotations := parentObj.GetAnnotations()
+	if annotations == nil {
+		annotations = make(map[string]string)
+	}
+	annotations[kappnavExcludeSubApplications] = "true"
+	parentObj.SetAnnotations(annotations)
+	parent = &resourceInfo{}
+	clusterWatcher.parseResource(parentObj, parent)
+	_, stat, breakdown, _, err = processOneApplication(clusterWatcher, parent, make(map[string]*resourceInfo),
+		make(map[string]*resourceInfo), make(map[string]*resourceInfo), toCompute, make(map[string]*resourceInfo))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stat != clusterWatcher.unknownStatus || len(breakdown) != 0 {
+		t.Errorf("expecting status %s with no components, got %s %v", clusterWatcher.unknownStatus, stat, breakdown)
+	}
+}
+
+func TestMaintenanceComponent(t *testing.T) {
+	testName := "TestMaintenanceComponent"
+	beforeTest()
+	// kinds to check for status
+	var kindsToCheckStatus = map[string]bool{
+		APPLICATION:  true,
+		"Service":    true,
+		"Deployment": true,
+	}
+
+	var files = []string{
+		/* 0 */ CrdApplication,
+		/* 1 */ appProductpage,
+		/* 2 */ deploymentProcuctpageV1,
+		/* 3 */ serviceProductpage,
+		/* 4 */ KappnavConfigFile,
+	}
+	iteration0IDs, err := readResourceIDs(files)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	/* Iteration 0: all normal */
+	testActions := newTestActions(testName, kindsToCheckStatus)
+	var emptyIDs = []resourceID{}
+	testActions.addIteration(iteration0IDs, emptyIDs)
+
+	clusterWatcher, err := createClusterWatcher(iteration0IDs, testActions, StatusFailureRate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer clusterWatcher.shutDown()
+
+	err = testActions.transitionAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	appObj, err := getResource(clusterWatcher, iteration0IDs[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	var app = &resourceInfo{}
+	clusterWatcher.parseResource(appObj, app)
+
+	// all components count
+	_, stat, breakdown, _, err := processOneApplication(clusterWatcher, app, make(map[string]*resourceInfo),
+		make(map[string]*resourceInfo), make(map[string]*resourceInfo), make(map[string]*resourceInfo), make(map[string]*resourceInfo))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stat != Normal || breakdown[Normal] != 2 {
+		t.Errorf("expecting status %s with 2 %s components, got %s %v", Normal, Normal, stat, breakdown)
+	}
+
+	// pause the Deployment in the cache
+	deploymentObj, err := getResource(clusterWatcher, iteration0IDs[2])
+	if err != nil {
+		t.Fatal(err)
+	}
+	paused := deploymentObj.DeepCopy()
+	annotations := paused.GetAnnotations()
+	if annotations == nil {
+		annotations = make(map[string]string)
+	}
+	annotations[kappnavMaintenance] = "true"
+	paused.SetAnnotations(annotations)
+	rw := clusterWatcher.getResourceWatcher(coreDeploymentGVR)
+	if err := rw.store.Update(paused); err != nil {
+		t.Fatal(err)
+	}
+	defer rw.store.Update(deploymentObj)
+
+	var pausedInfo = &resourceInfo{}
+	clusterWatcher.parseResource(paused, pausedInfo)
+	toFetch := map[string]*resourceInfo{pausedInfo.key(): pausedInfo}
+	toChange := make(map[string]*resourceInfo)
+	_, stat, breakdown, _, err = processOneApplication(clusterWatcher, app, make(map[string]*resourceInfo),
+		make(map[string]*resourceInfo), toFetch, make(map[string]*resourceInfo), toChange)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stat != Normal || len(breakdown) != 1 || breakdown[Normal] != 1 {
+		t.Errorf("expecting status %s from the component not in maintenance only, got %s %v", Normal, stat, breakdown)
+	}
+	if changed, ok := toChange[pausedInfo.key()]; !ok || changed.kappnavStatVal != clusterWatcher.unknownStatus {
+		t.Errorf("expecting component in maintenance to get status %s, got %v", clusterWatcher.unknownStatus, changed)
+	}
+
+	// any other value counts
+	annotations[kappnavMaintenance] = "false"
+	paused.SetAnnotations(annotations)
+	pausedInfo = &resourceInfo{}
+	clusterWatcher.parseResource(paused, pausedInfo)
+	if pausedInfo.inMaintenance() {
+		t.Errorf("expecting %s=false not to be in maintenance", kappnavMaintenance)
+	}
+}
+
+func TestStatusAnnotationKey(t *testing.T) {
+	savedStatusAnnotation := statusAnnotation
+	statusAnnotation = "example.com/health"
+	defer func() {
+		statusAnnotation = savedStatusAnnotation
+	}()
+
+	unstructuredObj, err := readJSON(deploymentProcuctpageV1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	setkAppNavStatus(unstructuredObj, Normal, "all replicas available", "")
+	annotations := unstructuredObj.GetAnnotations()
+	if annotations["example.com/health"] != Normal {
+		t.Errorf("expecting status %s written to the configured key, got annotations %v", Normal, annotations)
+	}
+	if _, ok := annotations[kappnavStatusValue]; ok {
+		t.Errorf("expecting no status written to %s, got annotations %v", kappnavStatusValue, annotations)
+	}
+
+	// unchanged status read back from the configured key is not written again
+	var resController = &ClusterWatcher{
+		plugin: &ControllerPlugin{statusFunc: func(destURL string, resInfo *resourceInfo) (string, string, string, error) {
+			return Normal, "all replicas available", "", nil
+		}},
+		statusPrecedence: []string{"Red Alert", problem, warning, "Unknown", Normal},
+		unknownStatus:    "Unknown",
+	}
+	var resInfo = &resourceInfo{}
+	resController.parseResource(unstructuredObj, resInfo)
+	if resInfo.kappnavStatVal != Normal {
+		t.Fatalf("expecting status %s read from the configured key, got %q", Normal, resInfo.kappnavStatVal)
+	}
+	toFetch := map[string]*resourceInfo{resInfo.key(): resInfo}
+	toChange := make(map[string]*resourceInfo)
+	_, err = processOneResource(resController, resInfo, make(map[string]*resourceInfo), toFetch, toChange)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(toChange) != 0 {
+		t.Errorf("expecting unchanged status not to be written, got %v", toChange)
+	}
+}
+
+// predicate that changes a cached resource the first time it is evaluated
+type mutateCachePredicate struct {
+	mutate  func()
+	mutated bool
+}
+
+func (predicate *mutateCachePredicate) includes(appResInfo *appResourceInfo, resInfo *resourceInfo) bool {
+	if !predicate.mutated {
+		predicate.mutated = true
+		predicate.mutate()
+	}
+	return true
+}
+
+// Test the status of an application computed from a snapshot does not change when the cache changes during the computation
+func TestStatusSnapshot(t *testing.T) {
+	testName := "TestStatusSnapshot"
+	beforeTest()
+	// kinds to check for status
+	var kindsToCheckStatus = map[string]bool{
+		APPLICATION:  true,
+		"Service":    true,
+		"Deployment": true,
+	}
+
+	var files = []string{
+		/* 0 */ CrdApplication,
+		/* 1 */ appProductpage,
+		/* 2 */ deploymentProcuctpageV1,
+		/* 3 */ serviceProductpage,
+		/* 4 */ KappnavConfigFile,
+	}
+	iteration0IDs, err := readResourceIDs(files)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	/* Iteration 0: all normal */
+	testActions := newTestActions(testName, kindsToCheckStatus)
+	var emptyIDs = []resourceID{}
+	testActions.addIteration(iteration0IDs, emptyIDs)
+
+	clusterWatcher, err := createClusterWatcher(iteration0IDs, testActions, StatusFailureRate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer clusterWatcher.shutDown()
+
+	err = testActions.transitionAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	deploymentID := iteration0IDs[2]
+	gvr, ok := clusterWatcher.getWatchGVR(deploymentID.gvr)
+	if !ok {
+		t.Fatalf("Unable to find GVR for kind %s", deploymentID.kind)
+	}
+	rw := clusterWatcher.getResourceWatcher(gvr)
+	if rw == nil {
+		t.Fatal("deployments not watched")
+	}
+	deploymentKey := deploymentID.namespace + "/" + deploymentID.name
+
+	// the Service is evaluated before the Deployment. Change the status of the Deployment in the cache at that time
+	predicate := &mutateCachePredicate{mutate: func() {
+		obj, exists, err := rw.store.GetByKey(deploymentKey)
+		if err != nil || !exists {
+			t.Errorf("unable to get deployment %s from cache: %v", deploymentKey, err)
+			return
+		}
+		deploymentObj := obj.(*unstructured.Unstructured).DeepCopy()
+		annotations := deploymentObj.GetAnnotations()
+		annotations[kappnavStatusValue] = warning
+		deploymentObj.SetAnnotations(annotations)
+		if err = rw.store.Update(deploymentObj); err != nil {
+			t.Error(err)
+		}
+	}}
+	registerComponentPredicate(testName, predicate)
+	defer unregisterComponentPredicate(testName)
+	statusSnapshot = true
+	defer func() {
+		statusSnapshot = false
+	}()
+
+	appObj, err := getResource(clusterWatcher, iteration0IDs[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	var app = &resourceInfo{}
+	clusterWatcher.parseResource(appObj, app)
+	toCompute := map[string]*resourceInfo{app.key(): app}
+	_, stat, breakdown, _, err := processOneApplication(clusterWatcher, app, make(map[string]*resourceInfo),
+		make(map[string]*resourceInfo), make(map[string]*resourceInfo), toCompute, make(map[string]*resourceInfo))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !predicate.mutated {
+		t.Fatal("expecting cache to be changed during the computation")
+	}
+	if stat != Normal || len(breakdown) != 1 || breakdown[Normal] != 2 {
+		t.Errorf("expecting status %s with breakdown of 2 %s from the snapshot, got %s %v", Normal, Normal, stat, breakdown)
+	}
+}
+
+// Test status computed for a deleted resource is not written to a new resource with the same name
+func TestSendResourceStatusNameReuse(t *testing.T) {
+	testName := "TestSendResourceStatusNameReuse"
+	beforeTest()
+	// kinds to check for status
+	var kindsToCheckStatus = map[string]bool{
+		APPLICATION:  true,
+		"Deployment": true,
+	}
+
+	var files = []string{
+		/* 0 */ CrdApplication,
+		/* 1 */ appProductpage,
+		/* 2 */ deploymentProcuctpageV1,
+		/* 3 */ KappnavConfigFile,
+	}
+	iteration0IDs, err := readResourceIDs(files)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	/* Iteration 0: all normal */
+	testActions := newTestActions(testName, kindsToCheckStatus)
+	var emptyIDs = []resourceID{}
+	testActions.addIteration(iteration0IDs, emptyIDs)
+
+	clusterWatcher, err := createClusterWatcher(iteration0IDs, testActions, StatusFailureRate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer clusterWatcher.shutDown()
+
+	err = testActions.transitionAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	oldObj, err := readJSON(deploymentProcuctpageV1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var oldResInfo = &resourceInfo{}
+	clusterWatcher.parseResource(oldObj, oldResInfo)
+
+	// delete and re-create with the same name
+	intf := clusterWatcher.plugin.dynamicClient.Resource(oldResInfo.gvr).Namespace(oldResInfo.namespace)
+	if err = intf.Delete(oldResInfo.name, nil); err != nil {
+		t.Fatal(err)
+	}
+	newObj := oldObj.DeepCopy()
+	newObj.SetUID(types.UID("7c3a4e9a-347d-11e9-9d73-0800275638b6"))
+	newObj.SetResourceVersion("")
+	if _, err = intf.Create(newObj, metav1.CreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	err = sendResourceStatus(clusterWatcher, oldResInfo, warning, "", "")
+	if !errors.IsNotFound(err) {
+		t.Errorf("expecting not found sending status of deleted resource, got %v", err)
+	}
+	current, err := intf.Get(oldResInfo.name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if current.GetAnnotations()[kappnavStatusValue] == warning {
+		t.Errorf("expecting status of deleted resource not written to new resource %s", oldResInfo.name)
+	}
+}
+
+type componentStatusWeight struct {
+	status string
+	weight float64
+}
+
+var availabilityTestData = []struct {
+	components []componentStatusWeight
+	expected   string
+}{
+	{components: nil, expected: availabilityNotApplicable},
+	{components: []componentStatusWeight{{Normal, 1}, {Normal, 1}}, expected: "100.0"},
+	{components: []componentStatusWeight{{Normal, 1}, {warning, 1}, {problem, 1}}, expected: "33.3"},
+	{components: []componentStatusWeight{{Normal, 1}, {Normal, 1}, {warning, 1}}, expected: "66.7"},
+	// unknown status counts as not available
+	{components: []componentStatusWeight{{Normal, 1}, {"", 1}}, expected: "50.0"},
+	// weighted
+	{components: []componentStatusWeight{{Normal, 3}, {problem, 1}}, expected: "75.0"},
+	{components: []componentStatusWeight{{Normal, 1}, {problem, 0}}, expected: "100.0"},
+	{components: []componentStatusWeight{{problem, 0}}, expected: availabilityNotApplicable},
+	// invalid status not counted
+	{components: []componentStatusWeight{{Normal, 1}, {"Bogus", 1}}, expected: "100.0"},
+}
+
+func TestStatusCheckerAvailability(t *testing.T) {
+	for _, data := range availabilityTestData {
+		checker := newStatusChecker([]string{problem, warning, "Unknown", Normal}, "Unknown")
+		for _, component := range data.components {
+			checker.addWeightedStatus(component.status, component.weight)
+		}
+		if availability := checker.availability(); availability != data.expected {
+			t.Errorf("components %v: expecting availability %s, got %s", data.components, data.expected, availability)
+		}
+	}
+
+	// the healthy status is the lowest precedence status, whatever its value
+	checker := newStatusChecker([]string{"Red", "Yellow", "Green", "Unknown"}, "Unknown")
+	for _, status := range []string{"Green", "Green", "Yellow", Normal} {
+		checker.addStatus(status)
+	}
+	if availability := checker.availability(); availability != "66.7" {
+		t.Errorf("expecting availability 66.7 with a custom healthy status, got %s", availability)
+	}
+}
+
+func TestStatusWeight(t *testing.T) {
+	var testData = []struct {
+		annotation string
+		expected   float64
+	}{
+		{"", 1},
+		{"2.5", 2.5},
+		{"0", 0},
+		{"-1", 1},
+		{"heavy", 1},
+		{"NaN", 1},
+	}
+	for _, data := range testData {
+		resInfo := &resourceInfo{metadata: map[string]interface{}{}}
+		if data.annotation != "" {
+			resInfo.metadata[ANNOTATIONS] = map[string]interface{}{kappnavStatusWeight: data.annotation}
+		}
+		if weight := resInfo.statusWeight(); weight != data.expected {
+			t.Errorf("annotation %q: expecting weight %v, got %v", data.annotation, data.expected, weight)
+		}
+	}
+}
@@ -0,0 +1,59 @@
+/*
+Copyright 2019 IBM Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"strings"
+
+	"k8s.io/klog"
+)
+
+/*
+ The kappnav status of a component may be a JSON document rather than a bare
+ value, e.g. {"health": {"value": "Warning", "since": "..."}}. The status is
+ then read at the componentStatusJSONPath within it, e.g. .health.value.
+*/
+
+// Return the status within the value of the kappnav status of a component: the value at the path
+// if the value is a JSON document, or the whole value if there is no path or the value is not JSON.
+// Return "" if the path is not found in the document
+func statusAtJSONPath(value string, path string) string {
+	if path == "" {
+		return value
+	}
+	trimmed := strings.TrimSpace(value)
+	if !strings.HasPrefix(trimmed, "{") && !strings.HasPrefix(trimmed, "[") {
+		// bare value
+		return value
+	}
+	var document interface{}
+	if err := json.Unmarshal([]byte(trimmed), &document); err != nil {
+		if klog.V(2) {
+			klog.Infof("kappnav status %s is not valid JSON, using it as the status: %s", logString(value), err)
+		}
+		return value
+	}
+	status, err := jsonPathValue("componentStatusJSONPath", path, document)
+	if err != nil {
+		if klog.V(2) {
+			klog.Infof("unable to read kappnav status at %s of %s: %s", path, logString(value), err)
+		}
+		return ""
+	}
+	return status
+}
@@ -0,0 +1,80 @@
+/*
+Copyright 2019 IBM Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type statusJSONPathTestData struct {
+	value    string
+	path     string
+	expected string
+}
+
+var statusJSONPathTestDataArray = []statusJSONPathTestData{
+	// whole value without path
+	{"Warning", "", "Warning"},
+	{`{"health": {"value": "Warning"}}`, "", `{"health": {"value": "Warning"}}`},
+	// bare value with path
+	{"Normal", ".health.value", "Normal"},
+	// nested JSON
+	{`{"health": {"value": "Warning", "since": "2019-10-01T00:00:00Z"}}`, ".health.value", "Warning"},
+	{`{"health": {"value": "Problem"}}`, "{.health.value}", "Problem"},
+	// path not found
+	{`{"health": {}}`, ".health.value", ""},
+	// not valid JSON
+	{"{Warning", ".health.value", "{Warning"},
+}
+
+func TestStatusAtJSONPath(t *testing.T) {
+	for _, data := range statusJSONPathTestDataArray {
+		if status := statusAtJSONPath(data.value, data.path); status != data.expected {
+			t.Errorf("%s at %q: expecting status %q, got %q", data.value, data.path, data.expected, status)
+		}
+	}
+}
+
+// Test the status is extracted from the value returned by the kAppNav API server
+func TestCalculateComponentStatusJSONPath(t *testing.T) {
+	defer func(saved string) { componentStatusJSONPath = saved }(componentStatusJSONPath)
+	componentStatusJSONPath = ".health.value"
+
+	var value string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{"value": value, "flyover": "flyover text"})
+	}))
+	defer server.Close()
+
+	resInfo := &resourceInfo{apiVersion: "apps/v1", kind: "Deployment", namespace: "default", name: "details-v1"}
+	for _, data := range []struct{ value, expected string }{
+		{"Normal", "Normal"},
+		{`{"health": {"value": "Warning"}}`, "Warning"},
+	} {
+		value = data.value
+		status, flyover, _, err := calculateComponentStatus(server.URL, resInfo)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if status != data.expected || flyover != "flyover text" {
+			t.Errorf("%s: expecting status %s flyover %q, got %s %q", data.value, data.expected, "flyover text", status, flyover)
+		}
+	}
+}
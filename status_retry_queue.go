@@ -0,0 +1,227 @@
+/*
+Copyright 2019 IBM Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"container/list"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/klog"
+)
+
+/*
+ The statusRetryQueue keeps status updates that failed to be delivered,
+ and periodically tries to deliver them again. Only the latest update
+ for each resource is kept. When the queue is full, the oldest update
+ is dropped to make room.
+*/
+
+const (
+	// maximum number of status updates waiting to be retried
+	defaultStatusRetryQueueSize = 1024
+
+	// interval between attempts to flush the retry queue
+	defaultStatusRetryInterval = time.Second * 30
+)
+
+var (
+	// error logger to limit excessive logging
+	statusRetryErrorLogger = newSamplingLogger()
+)
+
+// function to deliver a status update
+type statusDeliveryFunc func(resInfo *resourceInfo, status string, flyover string, flyoverNLS string) error
+
+// A status update waiting to be delivered
+type statusDelivery struct {
+	resInfo    *resourceInfo
+	status     string
+	flyover    string
+	flyoverNLS string
+	attempts   int // number of failed attempts so far
+}
+
+type statusRetryQueue struct {
+	maxSize  int                // maximum number of pending updates
+	interval time.Duration      // interval between flushes
+	deliver  statusDeliveryFunc // function to deliver an update
+	keyer    resourceKeyer      // key of a resource. nil for the default
+
+	pending  *list.List               // pending updates, oldest first
+	elements map[string]*list.Element // resource key to its pending update
+	dropped  int                      // number of updates dropped due to overflow
+
+	stopCh  chan struct{}
+	stopped bool
+	mutex   sync.Mutex
+}
+
+// Create a new retry queue
+// maxSize: maximum number of pending updates before the oldest is dropped
+// interval: time between attempts to deliver pending updates
+// deliver: function called to deliver an update
+func newStatusRetryQueue(maxSize int, interval time.Duration, deliver statusDeliveryFunc) *statusRetryQueue {
+	return &statusRetryQueue{
+		maxSize:  maxSize,
+		interval: interval,
+		deliver:  deliver,
+		pending:  list.New(),
+		elements: make(map[string]*list.Element),
+		stopCh:   make(chan struct{}),
+	}
+}
+
+// Add a status update that failed to be delivered.
+// A pending update for the same resource is replaced.
+func (queue *statusRetryQueue) enqueue(resInfo *resourceInfo, status string, flyover string, flyoverNLS string) {
+	queue.mutex.Lock()
+	defer queue.mutex.Unlock()
+	queue.add(&statusDelivery{
+		resInfo:    resInfo,
+		status:     status,
+		flyover:    flyover,
+		flyoverNLS: flyoverNLS,
+		attempts:   1,
+	})
+}
+
+// Add a delivery to the end of the queue. Must be called with mutex held
+func (queue *statusRetryQueue) add(delivery *statusDelivery) {
+	key := queue.keyer.keyOf(delivery.resInfo)
+	if elem, ok := queue.elements[key]; ok {
+		// newer update replaces the pending one
+		if klog.V(4) {
+			klog.Infof("statusRetryQueue replacing pending status for %s", key)
+		}
+		queue.pending.Remove(elem)
+		delete(queue.elements, key)
+	}
+	for queue.maxSize > 0 && queue.pending.Len() >= queue.maxSize {
+		// drop the oldest
+		oldest := queue.pending.Front()
+		oldDelivery := oldest.Value.(*statusDelivery)
+		queue.pending.Remove(oldest)
+		delete(queue.elements, queue.keyer.keyOf(oldDelivery.resInfo))
+		queue.dropped++
+		statusRetryQueueDropped.Inc()
+		if klog.V(2) {
+			klog.Infof("statusRetryQueue full, dropping status %s for %s", oldDelivery.status, queue.keyer.keyOf(oldDelivery.resInfo))
+		}
+	}
+	queue.elements[key] = queue.pending.PushBack(delivery)
+	statusRetryQueueDepth.Set(float64(queue.pending.Len()))
+}
+
+// Remove pending update for a resource, e.g. after a newer status was delivered
+func (queue *statusRetryQueue) remove(key string) {
+	if queue == nil {
+		return
+	}
+	queue.mutex.Lock()
+	defer queue.mutex.Unlock()
+	if elem, ok := queue.elements[key]; ok {
+		queue.pending.Remove(elem)
+		delete(queue.elements, key)
+		statusRetryQueueDepth.Set(float64(queue.pending.Len()))
+	}
+}
+
+// Return number of pending updates
+func (queue *statusRetryQueue) len() int {
+	queue.mutex.Lock()
+	defer queue.mutex.Unlock()
+	return queue.pending.Len()
+}
+
+// Return number of updates dropped due to overflow
+func (queue *statusRetryQueue) droppedCount() int {
+	queue.mutex.Lock()
+	defer queue.mutex.Unlock()
+	return queue.dropped
+}
+
+// Try to deliver all pending updates.
+// Updates that fail again are put back, unless a newer update for the
+// same resource was queued in the mean time.
+// Return number of updates delivered
+func (queue *statusRetryQueue) flush() int {
+	queue.mutex.Lock()
+	deliveries := make([]*statusDelivery, 0, queue.pending.Len())
+	for elem := queue.pending.Front(); elem != nil; elem = elem.Next() {
+		deliveries = append(deliveries, elem.Value.(*statusDelivery))
+	}
+	queue.pending.Init()
+	queue.elements = make(map[string]*list.Element)
+	statusRetryQueueDepth.Set(0)
+	queue.mutex.Unlock()
+
+	if klog.V(4) && len(deliveries) > 0 {
+		klog.Infof("statusRetryQueue flushing %d status updates", len(deliveries))
+	}
+
+	delivered := 0
+	for _, delivery := range deliveries {
+		err := queue.deliver(delivery.resInfo, delivery.status, delivery.flyover, delivery.flyoverNLS)
+		if err == nil {
+			delivered++
+			continue
+		}
+		if errors.IsNotFound(err) {
+			// resource no longer exists
+			if klog.V(4) {
+				klog.Infof("statusRetryQueue discarding status for %s: %s", queue.keyer.keyOf(delivery.resInfo), err)
+			}
+			continue
+		}
+		statusRetryErrorLogger.logError(err)
+		queue.mutex.Lock()
+		if _, ok := queue.elements[queue.keyer.keyOf(delivery.resInfo)]; !ok {
+			delivery.attempts++
+			queue.add(delivery)
+		}
+		queue.mutex.Unlock()
+	}
+	return delivered
+}
+
+// Start periodic flush on a separate thread
+func (queue *statusRetryQueue) start() {
+	go func() {
+		ticker := time.NewTicker(queue.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				queue.flush()
+			case <-queue.stopCh:
+				return
+			}
+		}
+	}()
+}
+
+// Stop periodic flush
+func (queue *statusRetryQueue) stop() {
+	queue.mutex.Lock()
+	defer queue.mutex.Unlock()
+	if !queue.stopped {
+		queue.stopped = true
+		close(queue.stopCh)
+	}
+}
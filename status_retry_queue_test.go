@@ -0,0 +1,175 @@
+/*
+Copyright 2019 IBM Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// delivery function that fails until told otherwise, recording what was delivered
+type fakeStatusDelivery struct {
+	fail      bool
+	delivered map[string]string // resource key to delivered status
+}
+
+func newFakeStatusDelivery() *fakeStatusDelivery {
+	return &fakeStatusDelivery{fail: true, delivered: make(map[string]string)}
+}
+
+func (fake *fakeStatusDelivery) deliver(resInfo *resourceInfo, status string, flyover string, flyoverNLS string) error {
+	if fake.fail {
+		return fmt.Errorf("unable to deliver status %s for %s", status, resInfo.key())
+	}
+	fake.delivered[resInfo.key()] = status
+	return nil
+}
+
+func newRetryTestResource(name string) *resourceInfo {
+	return &resourceInfo{
+		gvr:       coreDeploymentGVR,
+		kind:      DEPLOYMENT,
+		namespace: "default",
+		name:      name,
+	}
+}
+
+func TestStatusRetryQueueEnqueue(t *testing.T) {
+	fake := newFakeStatusDelivery()
+	queue := newStatusRetryQueue(10, time.Minute, fake.deliver)
+
+	queue.enqueue(newRetryTestResource("dep1"), Normal, "", "")
+	queue.enqueue(newRetryTestResource("dep2"), warning, "", "")
+	if queue.len() != 2 {
+		t.Fatalf("expecting 2 pending status updates, got %d", queue.len())
+	}
+	if testutil.ToFloat64(statusRetryQueueDepth) != 2 {
+		t.Errorf("expecting queue depth metric 2, got %v", testutil.ToFloat64(statusRetryQueueDepth))
+	}
+
+	// newer status replaces pending status of the same resource
+	queue.enqueue(newRetryTestResource("dep1"), problem, "", "")
+	if queue.len() != 2 {
+		t.Fatalf("expecting 2 pending status updates after replacing, got %d", queue.len())
+	}
+
+	// failed flush keeps everything
+	if delivered := queue.flush(); delivered != 0 {
+		t.Errorf("expecting nothing delivered, got %d", delivered)
+	}
+	if queue.len() != 2 {
+		t.Errorf("expecting 2 pending status updates after failed flush, got %d", queue.len())
+	}
+
+	// remove after newer status delivered elsewhere
+	queue.remove(newRetryTestResource("dep2").key())
+	if queue.len() != 1 {
+		t.Errorf("expecting 1 pending status update after remove, got %d", queue.len())
+	}
+}
+
+func TestStatusRetryQueueRetrySuccess(t *testing.T) {
+	fake := newFakeStatusDelivery()
+	queue := newStatusRetryQueue(10, time.Minute, fake.deliver)
+
+	dep1 := newRetryTestResource("dep1")
+	dep2 := newRetryTestResource("dep2")
+	queue.enqueue(dep1, Normal, "", "")
+	queue.enqueue(dep2, warning, "", "")
+	queue.enqueue(dep1, problem, "", "")
+
+	// endpoint is back
+	fake.fail = false
+	if delivered := queue.flush(); delivered != 2 {
+		t.Errorf("expecting 2 status updates delivered, got %d", delivered)
+	}
+	if queue.len() != 0 {
+		t.Errorf("expecting empty queue after successful flush, got %d", queue.len())
+	}
+	if fake.delivered[dep1.key()] != problem {
+		t.Errorf("expecting latest status %s delivered for %s, got %s", problem, dep1.key(), fake.delivered[dep1.key()])
+	}
+	if fake.delivered[dep2.key()] != warning {
+		t.Errorf("expecting status %s delivered for %s, got %s", warning, dep2.key(), fake.delivered[dep2.key()])
+	}
+	if testutil.ToFloat64(statusRetryQueueDepth) != 0 {
+		t.Errorf("expecting queue depth metric 0, got %v", testutil.ToFloat64(statusRetryQueueDepth))
+	}
+}
+
+// Test a pending status is dropped once a newer status is computed, even if the newer one needs no write
+func TestStatusRetryDroppedForNewerStatus(t *testing.T) {
+	fake := newFakeStatusDelivery()
+	var resController = &ClusterWatcher{
+		plugin: &ControllerPlugin{statusFunc: func(destURL string, resInfo *resourceInfo) (string, string, string, error) {
+			return Normal, "", "", nil
+		}},
+		statusPrecedence: []string{"Red Alert", problem, warning, "Unknown", Normal},
+		unknownStatus:    "Unknown",
+		statusRetries:    newStatusRetryQueue(10, time.Minute, fake.deliver),
+	}
+	resInfo := newRetryTestResource("dep1")
+	resInfo.kappnavStatVal = Normal
+	resController.statusRetries.enqueue(resInfo, warning, "", "")
+
+	toFetch := map[string]*resourceInfo{resInfo.key(): resInfo}
+	toChange := make(map[string]*resourceInfo)
+	if _, err := processOneResource(resController, resInfo, make(map[string]*resourceInfo), toFetch, toChange); err != nil {
+		t.Fatal(err)
+	}
+	if len(toChange) != 0 {
+		t.Errorf("expecting status equal to the annotation not to be written, got %v", toChange)
+	}
+	if resController.statusRetries.len() != 0 {
+		t.Errorf("expecting stale pending status dropped, got %d pending", resController.statusRetries.len())
+	}
+}
+
+func TestStatusRetryQueueDropOnOverflow(t *testing.T) {
+	fake := newFakeStatusDelivery()
+	queue := newStatusRetryQueue(3, time.Minute, fake.deliver)
+	droppedBefore := testutil.ToFloat64(statusRetryQueueDropped)
+
+	for i := 0; i < 5; i++ {
+		queue.enqueue(newRetryTestResource(fmt.Sprintf("dep%d", i)), Normal, "", "")
+	}
+	if queue.len() != 3 {
+		t.Fatalf("expecting 3 pending status updates, got %d", queue.len())
+	}
+	if queue.droppedCount() != 2 {
+		t.Errorf("expecting 2 dropped status updates, got %d", queue.droppedCount())
+	}
+	if testutil.ToFloat64(statusRetryQueueDropped)-droppedBefore != 2 {
+		t.Errorf("expecting dropped metric to increase by 2, got %v", testutil.ToFloat64(statusRetryQueueDropped)-droppedBefore)
+	}
+
+	// oldest were dropped
+	fake.fail = false
+	queue.flush()
+	for i := 0; i < 5; i++ {
+		key := newRetryTestResource(fmt.Sprintf("dep%d", i)).key()
+		_, ok := fake.delivered[key]
+		if i < 2 && ok {
+			t.Errorf("expecting status of %s to be dropped", key)
+		} else if i >= 2 && !ok {
+			t.Errorf("expecting status of %s to be delivered", key)
+		}
+	}
+}
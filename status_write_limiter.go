@@ -0,0 +1,72 @@
+/*
+Copyright 2019 IBM Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"time"
+
+	"golang.org/x/time/rate"
+	"k8s.io/klog"
+)
+
+/*
+ Client-side limit on the rate of status writes to the API server, so that
+ a mass rollout changing thousands of components does not flood it. Each
+ application is still computed at most once per batch, and its status only
+ written if it changed, so the limit is only consumed by actual writes.
+ Conditions written to applications share the same limit.
+*/
+
+const (
+	// DefaultStatusWriteBurst - status writes allowed at once before the rate limit applies
+	DefaultStatusWriteBurst = 10
+)
+
+// Token bucket limiting the rate of status writes
+type statusWriteLimiter struct {
+	limiter *rate.Limiter
+}
+
+// Create a limiter of qps writes per second, with bursts of up to burst writes.
+// Return nil for no limit if qps is not positive
+func newStatusWriteLimiter(qps float64, burst int) *statusWriteLimiter {
+	if qps <= 0 {
+		return nil
+	}
+	if burst < 1 {
+		burst = 1
+	}
+	if klog.V(2) {
+		klog.Infof("limiting status writes to %v per second, with bursts of %d", qps, burst)
+	}
+	return &statusWriteLimiter{limiter: rate.NewLimiter(rate.Limit(qps), burst)}
+}
+
+// Wait until a status write may be made
+func (writes *statusWriteLimiter) wait() {
+	if writes == nil {
+		return
+	}
+	delay := writes.limiter.Reserve().Delay()
+	if delay > 0 {
+		statusWritesThrottled.Inc()
+		if klog.V(4) {
+			klog.Infof("status write delayed %s by the rate limit", delay)
+		}
+		time.Sleep(delay)
+	}
+}
@@ -0,0 +1,105 @@
+/*
+Copyright 2019 IBM Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic/fake"
+)
+
+// Test the rate limit is consulted for each status write, and only for writes
+func TestStatusWriteLimiter(t *testing.T) {
+	resController, client, resInfo := newDeleteTestWatcher(t)
+	// one write at once, then one every 500ms
+	resController.statusWriteLimiter = newStatusWriteLimiter(2, 1)
+
+	var statusTestData = []struct {
+		status            string
+		expectedWrites    int
+		expectedThrottled float64
+	}{
+		{warning, 1, 0}, // within the burst
+		{warning, 0, 0}, // unchanged, not written
+		{Normal, 1, 1},  // waits for the next token
+		{Normal, 0, 0},
+	}
+	for i, data := range statusTestData {
+		client.ClearActions()
+		before := testutil.ToFloat64(statusWritesThrottled)
+		if err := sendResourceStatus(resController, resInfo, data.status, "", ""); err != nil {
+			t.Fatal(err)
+		}
+		if writes := clientWrites(client); len(writes) != data.expectedWrites {
+			t.Errorf("write %d of %s: expecting %d writes, got %v", i, data.status, data.expectedWrites, writes)
+		}
+		if throttled := testutil.ToFloat64(statusWritesThrottled) - before; throttled != data.expectedThrottled {
+			t.Errorf("write %d of %s: expecting %v writes delayed, got %v", i, data.status, data.expectedThrottled, throttled)
+		}
+	}
+
+	var none *statusWriteLimiter
+	none.wait()
+	if limiter := newStatusWriteLimiter(0, DefaultStatusWriteBurst); limiter != nil {
+		t.Error("expecting no limit with a rate of 0")
+	}
+}
+
+// Test condition writes to applications are also rate limited
+func TestStatusWriteLimiterConditions(t *testing.T) {
+	app, err := readJSON(appProductpage)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := fake.NewSimpleDynamicClient(runtime.NewScheme())
+	if _, err = client.Resource(coreApplicationGVR).Namespace(app.GetNamespace()).Create(app, metav1.CreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	resController := &ClusterWatcher{
+		plugin:      &ControllerPlugin{dynamicClient: client},
+		resourceMap: map[schema.GroupVersionResource]*ResourceWatcher{coreApplicationGVR: {GroupVersionResource: coreApplicationGVR}},
+		// one write at once, then one every 500ms
+		statusWriteLimiter: newStatusWriteLimiter(2, 1),
+	}
+	initControllerMaps(resController)
+	var appInfo = &appResourceInfo{}
+	if err = resController.parseAppResource(app, appInfo); err != nil {
+		t.Fatal(err)
+	}
+
+	var conditionTestData = []struct {
+		cond              *statusCondition
+		expectedThrottled float64
+	}{
+		{&statusCondition{conditionType: validCondition, status: conditionFalse, reason: "ParseError"}, 0}, // within the burst
+		{&statusCondition{conditionType: validCondition, status: conditionFalse, reason: "ParseError"}, 0}, // unchanged, not written
+		{&statusCondition{conditionType: validCondition, status: conditionTrue, reason: validReason}, 1},   // waits for the next token
+	}
+	for i, data := range conditionTestData {
+		before := testutil.ToFloat64(statusWritesThrottled)
+		if err = writeApplicationCondition(resController, appInfo, data.cond, false); err != nil {
+			t.Fatal(err)
+		}
+		if throttled := testutil.ToFloat64(statusWritesThrottled) - before; throttled != data.expectedThrottled {
+			t.Errorf("condition write %d: expecting %v writes delayed, got %v", i, data.expectedThrottled, throttled)
+		}
+	}
+}
@@ -0,0 +1,160 @@
+/*
+Copyright 2019 IBM Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/klog"
+)
+
+/*
+ Structured logging of the hottest log sites, the batch handlers. Messages
+ carry key/value fields, e.g. the key, gvr, namespace and name of the resource.
+ In text format they go through klog as "message key=value ...". In json
+ format each message is written as a JSON object on its own line, for log
+ aggregation.
+
+ The json messages go to their own sink, stdout or the -logFile, apart from
+ klog's text on stderr, so that every line of the sink parses. Only these
+ structured messages are json: the other messages of the controller and of
+ client-go are still klog text on stderr. klog v0.2.0 has no SetLogger to
+ route them through a json encoder.
+*/
+
+const (
+	logFormatText = "text"
+	logFormatJSON = "json"
+)
+
+// Logger of structured messages
+type structuredLogger struct {
+	format string
+	out    io.Writer // destination of json messages, apart from klog's output
+	now    func() time.Time
+	mutex  sync.Mutex
+}
+
+var structuredLog = newStructuredLogger(logFormatText, os.Stdout)
+
+// Return true if the log format is supported
+func validLogFormat(format string) bool {
+	return format == logFormatText || format == logFormatJSON
+}
+
+// Return the destination of json messages: the file at path, appended to,
+// or stdout if path is empty
+func openLogOutput(path string) (io.Writer, error) {
+	if path == "" {
+		return os.Stdout, nil
+	}
+	return os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+}
+
+func newStructuredLogger(format string, out io.Writer) *structuredLogger {
+	return &structuredLogger{format: format, out: out, now: time.Now}
+}
+
+// Log an informational message with key/value pairs
+func logInfoS(msg string, keysAndValues ...interface{}) {
+	structuredLog.log("info", nil, msg, keysAndValues)
+}
+
+// Log an error with key/value pairs
+func logErrorS(err error, msg string, keysAndValues ...interface{}) {
+	structuredLog.log("error", err, msg, keysAndValues)
+}
+
+// Return the fields identifying the resource of an event
+func eventLogFields(eventData *eventHandlerData) []interface{} {
+	namespace, name, _ := cache.SplitMetaNamespaceKey(eventData.key)
+	return []interface{}{"key", eventData.key, "gvr", eventData.gvr.String(), "namespace", namespace, "name", name}
+}
+
+func (logger *structuredLogger) log(level string, err error, msg string, keysAndValues []interface{}) {
+	if logger.format != logFormatJSON {
+		text := msg + formatKeysAndValues(err, keysAndValues)
+		if err != nil {
+			klog.ErrorDepth(2, text)
+		} else {
+			klog.InfoDepth(2, text)
+		}
+		return
+	}
+
+	entry := map[string]interface{}{
+		"ts":    logger.now().UTC().Format(time.RFC3339Nano),
+		"level": level,
+		"msg":   msg,
+	}
+	for i := 0; i < len(keysAndValues); i += 2 {
+		key := fmt.Sprint(keysAndValues[i])
+		if i+1 < len(keysAndValues) {
+			entry[key] = logValue(keysAndValues[i+1])
+		} else {
+			entry[key] = nil
+		}
+	}
+	if err != nil {
+		entry["error"] = err.Error()
+	}
+	line, marshalErr := json.Marshal(entry)
+	if marshalErr != nil {
+		klog.Errorf("unable to log %s as json: %s", msg, marshalErr)
+		return
+	}
+	logger.mutex.Lock()
+	defer logger.mutex.Unlock()
+	logger.out.Write(append(line, '\n'))
+}
+
+// Return a value that marshals to json. Values other than numbers, booleans
+// and strings are formatted as text
+func logValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case nil, string, bool, int, int32, int64, float64:
+		return value
+	case error:
+		return v.Error()
+	default:
+		return fmt.Sprint(value)
+	}
+}
+
+// Return the key/value pairs formatted as " key=value ..."
+func formatKeysAndValues(err error, keysAndValues []interface{}) string {
+	var builder strings.Builder
+	for i := 0; i < len(keysAndValues); i += 2 {
+		builder.WriteString(" ")
+		builder.WriteString(fmt.Sprint(keysAndValues[i]))
+		builder.WriteString("=")
+		if i+1 < len(keysAndValues) {
+			fmt.Fprintf(&builder, "%v", keysAndValues[i+1])
+		}
+	}
+	if err != nil {
+		fmt.Fprintf(&builder, " error=%s", err)
+	}
+	return builder.String()
+}
@@ -0,0 +1,120 @@
+/*
+Copyright 2019 IBM Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestStructuredLogJSON(t *testing.T) {
+	var out bytes.Buffer
+	saved := structuredLog
+	defer func() { structuredLog = saved }()
+	structuredLog = newStructuredLogger(logFormatJSON, &out)
+
+	gvr := schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "deployments"}
+	eventData := &eventHandlerData{funcType: AddFunc, gvr: gvr, key: "default/ui"}
+	logInfoS("processing added resource", eventLogFields(eventData)...)
+	logErrorS(fmt.Errorf("not found"), "fetching resource from store failed", append(eventLogFields(eventData), "applications", 2)...)
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expecting 2 lines of json, got %d: %s", len(lines), out.String())
+	}
+	var tests = []struct {
+		line     string
+		expected map[string]interface{}
+	}{
+		{lines[0], map[string]interface{}{"level": "info", "msg": "processing added resource", "key": "default/ui", "gvr": gvr.String(), "namespace": "default", "name": "ui"}},
+		{lines[1], map[string]interface{}{"level": "error", "msg": "fetching resource from store failed", "key": "default/ui", "namespace": "default", "name": "ui", "applications": float64(2), "error": "not found"}},
+	}
+	for _, test := range tests {
+		var entry map[string]interface{}
+		if err := json.Unmarshal([]byte(test.line), &entry); err != nil {
+			t.Fatalf("unable to parse log line %s: %s", test.line, err)
+		}
+		if _, ok := entry["ts"]; !ok {
+			t.Errorf("expecting timestamp in log line %s", test.line)
+		}
+		for key, value := range test.expected {
+			if entry[key] != value {
+				t.Errorf("expecting %s to be %v in log line %s, got %v", key, value, test.line, entry[key])
+			}
+		}
+	}
+}
+
+func TestStructuredLogFile(t *testing.T) {
+	if out, err := openLogOutput(""); err != nil || out != os.Stdout {
+		t.Errorf("expecting stdout without a logFile, got %v, %v", out, err)
+	}
+
+	dir, err := ioutil.TempDir("", "logfile")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "controller.json")
+	out, err := openLogOutput(path)
+	if err != nil {
+		t.Fatalf("unable to open %s: %s", path, err)
+	}
+	saved := structuredLog
+	defer func() { structuredLog = saved }()
+	structuredLog = newStructuredLogger(logFormatJSON, out)
+	logInfoS("processing added resource", "key", "default/ui")
+	out.(*os.File).Close()
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var entry map[string]interface{}
+	if err := json.Unmarshal(data, &entry); err != nil {
+		t.Fatalf("expecting only json in %s, got %s: %s", path, data, err)
+	}
+	if entry["key"] != "default/ui" {
+		t.Errorf("expecting key default/ui, got %v", entry["key"])
+	}
+}
+
+func TestFormatKeysAndValues(t *testing.T) {
+	var tests = []struct {
+		err           error
+		keysAndValues []interface{}
+		expected      string
+	}{
+		{nil, nil, ""},
+		{nil, []interface{}{"key", "default/ui", "applications", 2}, " key=default/ui applications=2"},
+		{nil, []interface{}{"key"}, " key="},
+		{fmt.Errorf("not found"), []interface{}{"name", "ui"}, " name=ui error=not found"},
+	}
+	for _, test := range tests {
+		if actual := formatKeysAndValues(test.err, test.keysAndValues); actual != test.expected {
+			t.Errorf("expecting %q for %v, got %q", test.expected, test.keysAndValues, actual)
+		}
+	}
+}
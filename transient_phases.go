@@ -0,0 +1,85 @@
+/*
+Copyright 2019 IBM Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+/*
+ Resources in a transient phase, e.g. Pods or PersistentVolumeClaims Pending,
+ may be given a status configured per kind with the transientPhaseStatus
+ flag, e.g. Pod:Pending=Warning,PersistentVolumeClaim:Pending=Unknown,
+ instead of the status read by the built-in health readers.
+*/
+
+// Status of resources in a transient phase, by kind:phase, parsed from transientPhaseStatus at startup.
+// Empty for none
+var transientPhaseStatuses map[string]string
+
+// Parse the setting of the transientPhaseStatus flag into a map from kind:phase to status
+func parseTransientPhaseStatus(value string) (map[string]string, error) {
+	ret := make(map[string]string)
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		parts := strings.Split(entry, "=")
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("%s is not of the form kind:phase=status", entry)
+		}
+		kindPhase := strings.Split(strings.TrimSpace(parts[0]), ":")
+		if len(kindPhase) != 2 || kindPhase[0] == "" || kindPhase[1] == "" {
+			return nil, fmt.Errorf("%s is not of the form kind:phase=status", entry)
+		}
+		status := strings.Title(strings.ToLower(strings.TrimSpace(parts[1])))
+		switch status {
+		case statusNormal, statusWarning, statusProblem, noReaderStatusUnknown:
+		default:
+			return nil, fmt.Errorf("status %s of %s must be one of %s, %s, %s, %s", parts[1], parts[0],
+				statusNormal, statusWarning, statusProblem, noReaderStatusUnknown)
+		}
+		ret[kindPhase[0]+":"+kindPhase[1]] = status
+	}
+	return ret, nil
+}
+
+// Return the configured status and flyover text of a resource in a transient phase,
+// or an empty status if its kind and phase have no configured status
+func (resController *ClusterWatcher) transientPhaseHealth(resInfo *resourceInfo) (status string, flyover string) {
+	if len(transientPhaseStatuses) == 0 || resInfo.unstructuredObj == nil {
+		return "", ""
+	}
+	statusObj, ok := resInfo.unstructuredObj.Object[STATUS].(map[string]interface{})
+	if !ok {
+		return "", ""
+	}
+	phase, _ := statusObj["phase"].(string)
+	if phase == "" {
+		return "", ""
+	}
+	status, ok = transientPhaseStatuses[resInfo.kind+":"+phase]
+	if !ok {
+		return "", ""
+	}
+	if status == noReaderStatusUnknown {
+		status = resController.getUnknownStatus()
+	}
+	return status, fmt.Sprintf("%s %s", resInfo.kind, phase)
+}
@@ -0,0 +1,84 @@
+/*
+Copyright 2019 IBM Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"testing"
+)
+
+const pvcPending = "test_data/pvc-pending.json"
+
+type transientPhaseTestData struct {
+	setting         string
+	fileName        string
+	expectedStatus  string
+	expectedFlyover string
+}
+
+var transientPhaseTestDataArray = []transientPhaseTestData{
+	// default: Pending Pod is a Problem, Pending PVC has no built-in status
+	{"", podPending, statusProblem, "Pod Pending, no containers ready"},
+	{"", pvcPending, "", ""},
+	{"Pod:Pending=Warning", podPending, statusWarning, "Pod Pending"},
+	{"Pod:Pending=Warning", pvcPending, "", ""},
+	{"Pod:Pending=unknown, PersistentVolumeClaim:Pending=Warning", podPending, "Unknown", "Pod Pending"},
+	{"Pod:Pending=unknown, PersistentVolumeClaim:Pending=Warning", pvcPending, statusWarning, "PersistentVolumeClaim Pending"},
+	// other phases are not affected
+	{"Pod:Running=Warning", podPending, statusProblem, "Pod Pending, no containers ready"},
+}
+
+func TestTransientPhaseStatus(t *testing.T) {
+	defer func(saved map[string]string) { transientPhaseStatuses = saved }(transientPhaseStatuses)
+	resController := &ClusterWatcher{unknownStatus: "Unknown"}
+	for _, data := range transientPhaseTestDataArray {
+		statuses, err := parseTransientPhaseStatus(data.setting)
+		if err != nil {
+			t.Fatal(err)
+		}
+		transientPhaseStatuses = statuses
+		unstructuredObj, err := readJSON(data.fileName)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var resInfo = &resourceInfo{}
+		parseResourceBasic(unstructuredObj, resInfo)
+
+		status, flyover := resController.transientPhaseHealth(resInfo)
+		if status == "" && resInfo.podStatus != nil {
+			// falls through to the built-in Pod reader
+			status, flyover = podHealth(resInfo.podStatus)
+		}
+		if status != data.expectedStatus || flyover != data.expectedFlyover {
+			t.Errorf("%s with %q: expecting status %s flyover %q, got %s %q", data.fileName, data.setting, data.expectedStatus, data.expectedFlyover, status, flyover)
+		}
+	}
+}
+
+func TestParseTransientPhaseStatus(t *testing.T) {
+	statuses, err := parseTransientPhaseStatus("Pod:Pending=warning,PersistentVolumeClaim:Pending=Unknown,")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(statuses) != 2 || statuses["Pod:Pending"] != statusWarning || statuses["PersistentVolumeClaim:Pending"] != "Unknown" {
+		t.Errorf("unexpected statuses %v", statuses)
+	}
+	for _, invalid := range []string{"Pod=Warning", "Pod:Pending", "Pod:Pending=Broken", ":Pending=Warning"} {
+		if _, err := parseTransientPhaseStatus(invalid); err == nil {
+			t.Errorf("expecting %q to be invalid", invalid)
+		}
+	}
+}
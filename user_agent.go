@@ -0,0 +1,43 @@
+/*
+Copyright 2019 IBM Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"k8s.io/client-go/rest"
+)
+
+/*
+ Every request to the API server carries a User-Agent naming the controller
+ and its version, so that the requests can be attributed in the audit log.
+ The version is set at build time with -ldflags "-X main.controllerVersion=<version>".
+*/
+
+// name of the controller in the User-Agent
+const userAgentName = "kappnav-status-controller"
+
+// version of the controller, set at build time
+var controllerVersion = "dev"
+
+// Return the User-Agent of the controller
+func userAgent() string {
+	return userAgentName + "/" + controllerVersion
+}
+
+// Set the User-Agent of the controller on the configuration of the clients
+func setUserAgent(cfg *rest.Config) {
+	cfg.UserAgent = userAgent()
+}
@@ -0,0 +1,43 @@
+/*
+Copyright 2019 IBM Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"testing"
+
+	"k8s.io/client-go/rest"
+)
+
+func TestSetUserAgent(t *testing.T) {
+	var tests = []struct {
+		version  string
+		expected string
+	}{
+		{"dev", "kappnav-status-controller/dev"},
+		{"1.2.3", "kappnav-status-controller/1.2.3"},
+	}
+	saved := controllerVersion
+	defer func() { controllerVersion = saved }()
+	for _, test := range tests {
+		controllerVersion = test.version
+		cfg := &rest.Config{Host: "https://localhost:6443"}
+		setUserAgent(cfg)
+		if cfg.UserAgent != test.expected {
+			t.Errorf("expecting User-Agent %s for version %s, got %s", test.expected, test.version, cfg.UserAgent)
+		}
+	}
+}
@@ -617,3 +617,25 @@ func TestExpressionsMatch(t *testing.T) {
 		}
 	}
 }
+
+// Benchmark labelsMatch with V(5) logging off. Should not allocate
+// as log arguments are not formatted
+func BenchmarkLabelsMatch(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		for _, data := range matchLabelTestDataArray {
+			labelsMatch(data.matchLabels, data.labels)
+		}
+	}
+}
+
+// Benchmark expressionsMatch with V(5) logging off. Should not allocate
+// as log arguments are not formatted
+func BenchmarkExpressionsMatch(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		for _, data := range expressionTestDataArray {
+			expressionsMatch(data.expressions, data.labels)
+		}
+	}
+}
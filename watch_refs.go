@@ -0,0 +1,142 @@
+/*
+Copyright 2019 IBM Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"sync"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/klog"
+)
+
+/*
+ GVRs are watched for as long as an application includes their kind. Each
+ application holds one reference to each GVR of its component kinds, and
+ once the last application referencing a GVR is deleted, or changed to no
+ longer include its kind, the GVR is no longer watched. GVRs pinned by the
+ controller itself, e.g. applications and CRDs, are watched forever.
+*/
+
+// References of the applications to the GVRs they watch, keyed as the events of the application informer
+type watchRefs struct {
+	gvrs   map[string]map[schema.GroupVersionResource]bool // key of application to the GVRs it references
+	counts map[schema.GroupVersionResource]int             // number of applications referencing each GVR
+	pinned map[schema.GroupVersionResource]bool            // GVRs watched regardless of references
+	mutex  sync.Mutex
+}
+
+func newWatchRefs() *watchRefs {
+	return &watchRefs{
+		gvrs:   make(map[string]map[schema.GroupVersionResource]bool),
+		counts: make(map[schema.GroupVersionResource]int),
+		pinned: make(map[schema.GroupVersionResource]bool),
+	}
+}
+
+// Pin a GVR so that it is watched forever
+func (refs *watchRefs) pin(gvr schema.GroupVersionResource) {
+	if refs == nil {
+		return
+	}
+	refs.mutex.Lock()
+	defer refs.mutex.Unlock()
+	refs.pinned[gvr] = true
+}
+
+// Record the GVRs referenced by an application, replacing those of its previous version.
+// Return the GVRs no longer referenced by any application
+func (refs *watchRefs) set(key string, gvrs []schema.GroupVersionResource) []schema.GroupVersionResource {
+	if refs == nil {
+		return nil
+	}
+	refs.mutex.Lock()
+	defer refs.mutex.Unlock()
+	previous := refs.gvrs[key]
+	current := make(map[schema.GroupVersionResource]bool, len(gvrs))
+	for _, gvr := range gvrs {
+		if !current[gvr] && !previous[gvr] {
+			refs.counts[gvr]++
+		}
+		current[gvr] = true
+	}
+	var released []schema.GroupVersionResource
+	for gvr := range previous {
+		if !current[gvr] {
+			released = refs.releaseLocked(gvr, released)
+		}
+	}
+	if len(current) == 0 {
+		delete(refs.gvrs, key)
+	} else {
+		refs.gvrs[key] = current
+	}
+	return released
+}
+
+// Remove the references of a deleted application.
+// Return the GVRs no longer referenced by any application
+func (refs *watchRefs) remove(key string) []schema.GroupVersionResource {
+	return refs.set(key, nil)
+}
+
+// Release one reference to a GVR, appending it to released if it is no
+// longer referenced nor pinned. Must be called with the mutex held
+func (refs *watchRefs) releaseLocked(gvr schema.GroupVersionResource, released []schema.GroupVersionResource) []schema.GroupVersionResource {
+	refs.counts[gvr]--
+	if refs.counts[gvr] > 0 {
+		return released
+	}
+	delete(refs.counts, gvr)
+	if refs.pinned[gvr] {
+		return released
+	}
+	return append(released, gvr)
+}
+
+// Return the number of applications referencing a GVR
+func (refs *watchRefs) count(gvr schema.GroupVersionResource) int {
+	refs.mutex.Lock()
+	defer refs.mutex.Unlock()
+	return refs.counts[gvr]
+}
+
+// PinWatch adds a GVR to the watch list, to be watched forever
+func (resController *ClusterWatcher) PinWatch(gvr schema.GroupVersionResource) error {
+	resController.watchRefs.pin(gvr)
+	return resController.AddToWatch(gvr)
+}
+
+// RemoveFromWatch stops watching a GVR no longer referenced by any application.
+// Noop if the GVR was referenced again in the meantime, or is pinned.
+// The references are locked until the watch is stopped, so an application
+// referencing the GVR again starts a new watch once it is
+func (resController *ClusterWatcher) RemoveFromWatch(gvr schema.GroupVersionResource) {
+	refs := resController.watchRefs
+	refs.mutex.Lock()
+	defer refs.mutex.Unlock()
+	if refs.counts[gvr] > 0 || refs.pinned[gvr] {
+		return
+	}
+	if klog.V(2) {
+		klog.Infof("RemoveFromWatch %s no longer referenced by any application\n", gvr)
+	}
+	resController.mutex.Lock()
+	delete(resController.gvrsToWatch, gvr)
+	resController.mutex.Unlock()
+	resController.stopWatch(gvr)
+	watchesStopped.Inc()
+}
@@ -0,0 +1,133 @@
+/*
+Copyright 2019 IBM Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestWatchRefs(t *testing.T) {
+	gadgetGVR := schema.GroupVersionResource{Group: "example.com", Version: "v1", Resource: "gadgets"}
+	refs := newWatchRefs()
+	refs.pin(coreDeploymentGVR)
+
+	if released := refs.set("default/app1", []schema.GroupVersionResource{widgetGVR, gadgetGVR, coreDeploymentGVR}); len(released) != 0 {
+		t.Errorf("expecting no GVR released when app1 is added, got %v", released)
+	}
+	// counted once however many times the application is seen
+	refs.set("default/app1", []schema.GroupVersionResource{widgetGVR, gadgetGVR, coreDeploymentGVR})
+	refs.set("default/app2", []schema.GroupVersionResource{widgetGVR, widgetGVR})
+	if count := refs.count(widgetGVR); count != 2 {
+		t.Errorf("expecting 2 applications referencing %s, got %d", widgetGVR, count)
+	}
+
+	// kind dropped from app1
+	released := refs.set("default/app1", []schema.GroupVersionResource{widgetGVR, coreDeploymentGVR})
+	if !reflect.DeepEqual(released, []schema.GroupVersionResource{gadgetGVR}) {
+		t.Errorf("expecting %s released once app1 no longer includes it, got %v", gadgetGVR, released)
+	}
+
+	// pinned GVR not released
+	if released := refs.remove("default/app1"); len(released) != 0 {
+		t.Errorf("expecting no GVR released when app1 is deleted, got %v", released)
+	}
+	released = refs.remove("default/app2")
+	if !reflect.DeepEqual(released, []schema.GroupVersionResource{widgetGVR}) {
+		t.Errorf("expecting %s released once app2 is deleted, got %v", widgetGVR, released)
+	}
+	if released := refs.remove("default/app2"); len(released) != 0 {
+		t.Errorf("expecting no GVR released when app2 is deleted again, got %v", released)
+	}
+
+	var none *watchRefs
+	if released := none.set("default/app1", []schema.GroupVersionResource{widgetGVR}); released != nil {
+		t.Errorf("expecting no GVR released without references, got %v", released)
+	}
+}
+
+// Test the watch of a component kind stops once its last application is deleted, and starts again when recreated
+func TestRemoveFromWatch(t *testing.T) {
+	testName := "TestRemoveFromWatch"
+	beforeTest()
+	var files = []string{
+		/* 0 */ CrdApplication,
+		/* 1 */ crdWidget,
+		/* 2 */ widgetApp,
+		/* 3 */ exampleWidget,
+		/* 4 */ KappnavConfigFile,
+	}
+	iteration0IDs, err := readResourceIDs(files)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testActions := newTestActions(testName, map[string]bool{})
+	testActions.addIteration(iteration0IDs, []resourceID{})
+
+	clusterWatcher, err := createClusterWatcher(iteration0IDs, testActions, StatusFailureRate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer clusterWatcher.shutDown()
+	if err = testActions.transitionAll(); err != nil {
+		t.Fatal(err)
+	}
+
+	isWatched := func() bool { return clusterWatcher.isWatching(widgetGVR) }
+	if err = waitFor("Widgets to be watched", isWatched); err != nil {
+		t.Fatal(err)
+	}
+	rw := clusterWatcher.getResourceWatcher(widgetGVR)
+	queue := rw.queue
+
+	// last application deleted
+	app, err := readJSON(widgetApp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	intf := clusterWatcher.plugin.dynamicClient.Resource(coreApplicationGVR).Namespace(app.GetNamespace())
+	if err = intf.Delete(app.GetName(), nil); err != nil {
+		t.Fatal(err)
+	}
+	if err = waitFor("Widgets to no longer be watched", func() bool { return !isWatched() }); err != nil {
+		t.Fatal(err)
+	}
+	if !queue.ShuttingDown() {
+		t.Error("expecting the queue of Widgets shut down, stopping its worker")
+	}
+	if !clusterWatcher.isWatching(coreApplicationGVR) {
+		t.Error("expecting applications still watched")
+	}
+
+	// application recreated
+	app.SetResourceVersion("")
+	if _, err = intf.Create(app, metav1.CreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if err = waitFor("Widgets to be watched again", isWatched); err != nil {
+		t.Fatal(err)
+	}
+	if clusterWatcher.getResourceWatcher(widgetGVR).queue == queue {
+		t.Error("expecting a new queue for Widgets")
+	}
+	if count := clusterWatcher.watchRefs.count(widgetGVR); count != 1 {
+		t.Errorf("expecting 1 application referencing %s, got %d", widgetGVR, count)
+	}
+}